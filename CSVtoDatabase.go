@@ -2,32 +2,39 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"runtime"
 	"strconv"
 	"sync"
+	"time"
 
+	"github.com/getsentry/sentry-go"
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/postgres"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 // Define the struct to map to the user_data table
 type UserData struct {
-	ID         int    `gorm:"primaryKey;autoIncrement"`
-	FirstName  string `gorm:"size:100"`
-	LastName   string `gorm:"size:100"`
-	Email      string `gorm:"size:150"`
-	Age        int
-	Gender     string `gorm:"size:10"`
-	Department string `gorm:"size:100"`
-	Company    string `gorm:"size:100"`
-	Salary     float64
-	DateJoined string `gorm:"type:date"`
-	IsActive   bool
+	ID         int     `gorm:"primaryKey;autoIncrement" json:"id"`
+	FirstName  string  `gorm:"size:100" json:"first_name"`
+	LastName   string  `gorm:"size:100" json:"last_name"`
+	Email      string  `gorm:"size:150" json:"email"`
+	Age        int     `json:"age"`
+	Gender     string  `gorm:"size:10" json:"gender"`
+	Department string  `gorm:"size:100" json:"department"`
+	Company    string  `gorm:"size:100" json:"company"`
+	Salary     float64 `json:"salary"`
+	DateJoined Date    `gorm:"type:date" json:"date_joined"`
+	IsActive   bool    `json:"is_active"`
+
+	Provenance recordProvenance  `gorm:"embedded" json:"-"` // Import and file line the row was read from
+	Extra      map[string]string `gorm:"-" json:"-"`        // Values of the columns added by schema evolution
 }
 
 // TableName specifies the name of the table in the database
@@ -42,11 +49,44 @@ type DBHandler interface {
 	Limit(limit int) DBHandler
 	Order(value string) DBHandler
 	CreateInBatches(value interface{}, batchSize int) error // Change return type to error
+	CopyFrom(users []UserData) error
+	Upsert(users []UserData, batchSize int) error
+	EnsureUniqueEmail() error
+	Transaction(fn func(tx DBHandler) error) error
 }
 
 // GormDBHandler is a concrete implementation of DBHandler using GORM
 type GormDBHandler struct {
-	db *gorm.DB
+	db   *gorm.DB
+	conn *sql.Conn // Connection of the transaction db runs in, shared with COPY; nil outside transactions
+}
+
+// Transaction runs fn in a transaction, committed when fn returns nil and rolled back otherwise.
+// Transactions started within fn are savepoints, so a failed part can be rolled back alone and the
+// rest still committed. The transaction is pinned to a connection, so COPY takes part in it too.
+func (handler *GormDBHandler) Transaction(fn func(tx DBHandler) error) error {
+	if handler.conn != nil {
+		return handler.db.Transaction(func(tx *gorm.DB) error {
+			return fn(&GormDBHandler{db: tx, conn: handler.conn})
+		})
+	}
+
+	sqlDB, err := handler.db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := handler.db.Statement.Context
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	pinned := handler.db.Session(&gorm.Session{Context: ctx})
+	pinned.Statement.ConnPool = conn
+	return pinned.Transaction(func(tx *gorm.DB) error {
+		return fn(&GormDBHandler{db: tx, conn: conn})
+	})
 }
 
 // Implement the Find method for GormDBHandler
@@ -80,178 +120,493 @@ func (handler *GormDBHandler) CreateInBatches(value interface{}, batchSize int)
 		return fmt.Errorf("expected []UserData but got %T", value)
 	}
 
-	// Perform batch creation
-	return handler.db.CreateInBatches(users, batchSize).Error
-}
-
-// Initialize PostgreSQL connection using GORM
-func setupDatabase() *gorm.DB {
-	dsn := "host=localhost user=postgres password=Virat@2#Virat@2# dbname=mini-Project port=8899 sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Size each multi-row INSERT to the driver's bind parameter limit
+	columns, err := insertColumnCount(&UserData{}, handler.db.NamingStrategy)
 	if err != nil {
-		panic("Failed to connect to the database: " + err.Error())
+		return fmt.Errorf("failed to parse UserData schema: %w", err)
 	}
+	batchSize = safeBatchSize(batchSize, columns, handler.db.Dialector.Name())
 
-	// Automatically create the "user_data" table if it doesn't exist
-	if err := db.AutoMigrate(&UserData{}); err != nil {
-		panic("Failed to migrate database: " + err.Error())
+	// Perform batch creation, setting the columns added by schema evolution in the same transaction
+	extra := extraColumns(users)
+	if len(extra) == 0 {
+		return handler.db.CreateInBatches(users, batchSize).Error
 	}
-
-	fmt.Println("Database connected and table user_data created successfully.")
-	return db
+	return handler.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(users, batchSize).Error; err != nil {
+			return err
+		}
+		return writeExtraColumns(tx, users, extra)
+	})
 }
 
-// Log memory usage
+// CSV ingestion settings
+const (
+	csvChannelBuffer   = 2        // Number of chunks the reader may read ahead of the workers
+	maxMultipartMemory = 30 << 30 // 30 GB for large file uploads
+	workersPerCPU      = 4        // Chunk workers per CPU unless the worker count is configured
+)
+
+// Log memory usage at debug level; reading the stats briefly stops the world, so it is skipped otherwise
 func logMemoryUsage() {
+	if !log.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
-	fmt.Printf("Memory Usage: Allocated: %d KB, Total Alloc: %d KB, System: %d KB\n",
-		m.Alloc/1024, m.TotalAlloc/1024, m.Sys/1024)
+	log.WithFields(logrus.Fields{
+		"alloc_kb":       m.Alloc / 1024,
+		"total_alloc_kb": m.TotalAlloc / 1024,
+		"sys_kb":         m.Sys / 1024,
+	}).Debug("Memory usage")
+}
+
+// csvChunk is a batch of CSV records with the file line each record starts on
+type csvChunk struct {
+	records [][]string
+	lines   []int
 }
 
-// Read CSV in chunks and send data to a channel
-func readCSVChunk(file multipart.File, chunkSize int, ch chan<- [][]string) {
+// Read CSV in chunks and send data to a channel, pausing while the channel is full.
+// Columns are found by the names in the header row and records are sent in the order of csvColumns.
+// Header columns of evolved are read after those, and the columns that aren't read are reported in stats.
+func readCSVChunk(file io.Reader, mapping columnMapping, evolved []string, chunkSize int, ch chan<- csvChunk, stats *readerStats) {
 	reader := csv.NewReader(bufio.NewReader(file))
 
-	_, _ = reader.Read() // Skip the header row
+	header, err := reader.Read()
+	if err == io.EOF {
+		close(ch) // An empty file has no rows to import
+		return
+	}
+	var layout columnLayout
+	if err == nil {
+		layout, err = resolveColumns(header, mapping)
+	}
+	if err != nil {
+		stats.logger().WithError(err).Error("Error reading CSV header")
+		stats.fail(err)
+		close(ch)
+		return
+	}
+	layout = layout.extend(header, evolved)
+	stats.skipColumns(unmappedColumns(header, layout))
 
 	for {
-		records := make([][]string, 0, chunkSize)
+		chunk := csvChunk{records: make([][]string, 0, chunkSize), lines: make([]int, 0, chunkSize)}
 		for i := 0; i < chunkSize; i++ {
 			record, err := reader.Read()
 			if err != nil {
 				if err == io.EOF {
-					if len(records) > 0 {
-						ch <- records // Send the last chunk
+					if len(chunk.records) > 0 {
+						sendChunk(ch, chunk, stats) // Send the last chunk
 					}
 					close(ch)
 					return
 				}
-				fmt.Printf("Error reading CSV file: %v\n", err)
+				stats.logger().WithError(err).Error("Error reading CSV file")
+				stats.fail(err)
 				close(ch)
 				return
 			}
-			records = append(records, record)
+			line, _ := reader.FieldPos(0)
+			chunk.records = append(chunk.records, layout.project(record))
+			chunk.lines = append(chunk.lines, line)
 		}
-		ch <- records
+		sendChunk(ch, chunk, stats)
 	}
 }
 
-// Process a chunk of CSV records and store them in the database
-func processChunk(records [][]string, dbHandler DBHandler, batchSize int, semaphore chan struct{}, wg *sync.WaitGroup) {
-	defer wg.Done()
-
-	// Acquire semaphore
-	semaphore <- struct{}{}
+// chunkRow is a parsed row of a chunk, ready to be written
+type chunkRow struct {
+	user    UserData
+	record  []string
+	index   int // Position of the record in the chunk
+	line    int
+	coerced []string // Columns whose values had to be coerced
+	key     dedupKey // Key of the row when duplicates are dropped
+}
 
-	// Declare the array of users that will be inserted
-	var users []UserData
-	for _, record := range records {
-		// Parse record values safely
-		age, err := strconv.Atoi(record[4])
+// Process a chunk of CSV records and store them in the database
+// The caller must have acquired a worker slot from limiter; it is released when the chunk is done.
+// Overlong values are truncated or their rows rejected per overflow.
+// Rows repeating the key of a row already written from the file are dropped when dedup is not nil.
+// Dates are parsed with the first of dateFormats that matches.
+// Inserted rows are counted and rejected rows recorded with their line and reason in progress when it is not nil.
+func processChunk(chunk csvChunk, sink Sink, limiter *adaptiveLimiter, overflow *overflowHandler, dedup *deduplicator, dateFormats []string, progress *importProgress) {
+	start := time.Now()
+
+	// Declare the rows that will be inserted, and those waiting for an earlier row with their key
+	var rows []chunkRow
+	var held heldRows
+	chunkKeys := map[dedupKey]bool{}
+	for i, record := range chunk.records {
+		line := chunk.lines[i]
+
+		// Parse record values safely, noting the columns whose values had to be coerced
+		var coerced []string
+		age, ageCoerced, err := parseAge(record[4])
 		if err != nil {
-			fmt.Printf("Skipping record with invalid age: %v\n", record)
+			progress.reject(line, "age", fmt.Sprintf("invalid age %q", record[4]), record)
 			continue // Skip invalid records
 		}
+		if ageCoerced {
+			coerced = append(coerced, "age")
+		}
 
-		salary, err := strconv.ParseFloat(record[8], 64)
+		salary, salaryCoerced, err := parseSalary(record[8])
 		if err != nil {
-			fmt.Printf("Skipping record with invalid salary: %v\n", record)
+			progress.reject(line, "salary", fmt.Sprintf("invalid salary %q", record[8]), record)
 			continue // Skip invalid records
 		}
+		if salaryCoerced {
+			coerced = append(coerced, "salary")
+		}
 
-		isActive := record[10] == "true"
+		isActive, activeCoerced := parseIsActive(record[10])
+		if activeCoerced {
+			coerced = append(coerced, "is_active")
+		}
+
+		// Reject or truncate values longer than their varchar column
+		if column, ok := overflow.apply(record); !ok {
+			progress.reject(line, column, fmt.Sprintf("longer than %d characters", csvColumnSizes[column]), record)
+			continue
+		}
+
+		// Skip rows failing validation, naming every invalid field
+		errs := validateFields(record[3], age, salary, record[5])
+		dateJoined, err := parseDate(record[9], dateFormats)
+		if err != nil {
+			errs = append(errs, dateFieldError(dateFormats))
+		}
+		if len(errs) > 0 {
+			progress.reject(line, errs.Fields(), errs.Error(), record)
+			continue
+		}
 
 		// Construct UserData object
-		users = append(users, UserData{
-			FirstName:  record[1],
-			LastName:   record[2],
-			Email:      record[3],
-			Age:        age,
-			Gender:     record[5],
-			Department: record[6],
-			Company:    record[7],
-			Salary:     salary,
-			DateJoined: record[9],
-			IsActive:   isActive,
-		})
-	}
+		row := chunkRow{
+			user: UserData{
+				FirstName:  record[1],
+				LastName:   record[2],
+				Email:      record[3],
+				Age:        age,
+				Gender:     record[5],
+				Department: record[6],
+				Company:    record[7],
+				Salary:     salary,
+				DateJoined: dateJoined,
+				IsActive:   isActive,
+				Provenance: progress.provenance(line),
+				Extra:      progress.extraValues(record),
+			},
+			record:  record,
+			index:   i,
+			line:    line,
+			coerced: coerced,
+		}
 
-	// Batch insert
-	if len(users) > 0 {
-		if err := dbHandler.CreateInBatches(users, batchSize); err != nil {
-			fmt.Printf("Database insertion error: %v\n", err)
+		// Drop rows repeating the key of a row already written, and hold those repeating one of this chunk
+		// until it is written
+		if dedup != nil {
+			row.key = dedup.keyOf(record)
+			if dedup.isWritten(row.key) {
+				progress.reject(line, dedup.key, "duplicate of an earlier row", record)
+				continue
+			}
+			if chunkKeys[row.key] {
+				held.hold(row)
+				continue
+			}
+			chunkKeys[row.key] = true
 		}
+		rows = append(rows, row)
 	}
 
-	// Free up memory and trigger garbage collection
-	runtime.GC()
+	// Insert the chunk in one transaction, retrying halves of a failed batch to reject only the offending rows.
+	// Held duplicates of rejected rows are inserted next, in place of those rows.
+	for len(rows) > 0 {
+		users := make([]UserData, len(rows))
+		userLines := make([]int, len(rows))
+		for j, row := range rows {
+			progress.coerce(row.coerced...)
+			users[j], userLines[j] = row.user, row.line
+		}
+
+		inserted, rejected := insertChunk(sink, users)
+		var dbErr error
+		for _, rejectedRow := range rejected {
+			row := rows[rejectedRow.index]
+			progress.reject(row.line, "", rejectedRow.err.Error(), row.record)
+			if dbErr == nil && !isRowError(rejectedRow.err) {
+				dbErr = rejectedRow.err
+			}
+		}
 
-	// Release semaphore
-	<-semaphore
+		// Bad rows are expected in uploads; only report failures of the database itself
+		if dbErr != nil {
+			progress.logger().WithError(dbErr).WithFields(logrus.Fields{"rows": len(rejected), "first_line": chunk.lines[0], "last_line": chunk.lines[len(chunk.lines)-1]}).Error("Batch insert failed")
+			sentry.CaptureException(fmt.Errorf("batch insert of %d records failed: %w", len(rejected), dbErr))
+		}
+		progress.addProcessed(inserted)
+		progress.recordIDs(users, userLines, rejected)
+		progress.recordCommitted(userLines, rejected)
+		rows = dedup.settle(rows, rejected, &held)
+	}
+	dedup.dropHeld(&held, progress)
+
+	// Release the worker slot and report how long the chunk took
+	limiter.Release(time.Since(start))
 }
 
-// POST handler for CSV file upload
-func uploadCSV(c *gin.Context, dbHandler DBHandler) {
+// POST handler for CSV file upload, queueing an asynchronous import job
+func uploadCSV(c *gin.Context, dbHandler DBHandler, imports *importManager) {
+	// Reject uploads that cannot fit before reading the body
+	if !preflightUpload(c, tableSizer) {
+		return
+	}
+
+	options, ok := parseImportOptions(c)
+	if !ok {
+		return
+	}
+
+	// The sheet form field selects the sheet of XLSX uploads
+	options.sheet = c.PostForm("sheet")
+	// The column_mapping form field names header columns whose names differ, e.g. {"first_name":"Given Name"}
+	columns, err := parseColumnMapping(c.PostForm("column_mapping"))
+	if err != nil {
+		respondError(c, 400, "Invalid column mapping", err.Error())
+		return
+	}
+	options.columns = columns
+
+	// The description, source_system, business_date and tags form fields tell what the file is
+	metadata, err := parseImportMetadata(c.PostFormArray)
+	if err != nil {
+		respondError(c, 400, "Invalid import metadata", err.Error())
+		return
+	}
+
+	sinkName, sink, ok := uploadSink(c, dbHandler, imports)
+	if !ok {
+		return
+	}
+
 	// Get file from form-data
 	fileHeader, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(400, gin.H{"error": "Failed to get file", "details": err.Error()})
+		respondError(c, 400, "Failed to get file", err.Error())
 		return
 	}
 
 	file, err := fileHeader.Open()
 	if err != nil {
-		c.JSON(400, gin.H{"error": "Failed to open file", "details": err.Error()})
+		respondError(c, 400, "Failed to open file", err.Error())
 		return
 	}
 	defer file.Close()
 
-	// Initialize CSV processing
-	chunkSize := 5000               // Adjust chunk size
-	batchSize := 10000              // Set batch size to stay within parameter limit
-	ch := make(chan [][]string, 10) // Increase buffered channel size for better performance
-	var wg sync.WaitGroup
+	// Copy the upload to the blob store, since the form is discarded once the request ends
+	key, err := imports.storeUpload(c.Request.Context(), file, fileHeader.Size)
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to store upload")
+		respondError(c, 500, "Failed to store upload", err.Error())
+		return
+	}
 
-	// Semaphore to limit the number of concurrent Goroutines
-	semaphore := make(chan struct{}, runtime.NumCPU()*4)
+	// Queue the import and return immediately; progress is reported by GET /api/imports/:id
+	job := &ImportJob{FileName: fileHeader.Filename, FileSize: fileHeader.Size, Source: "upload", Sink: sinkName}
+	metadata.apply(job)
+	submitImport(c, imports, importTask{
+		job:     job,
+		source:  &blobSource{blobs: imports.blobs, key: key, remove: true},
+		sink:    sink,
+		options: options,
+	})
+}
 
-	// Start reading the CSV file in chunks
-	go readCSVChunk(file, chunkSize, ch)
+// uploadSink creates the sink of an upload, responding with 400 when it is invalid.
+// sink=<name> writes the rows to a registered sink instead of PostgreSQL,
+// mode=upsert updates the records with the same email instead of adding duplicates.
+func uploadSink(c *gin.Context, dbHandler DBHandler, imports *importManager) (string, Sink, bool) {
+	sinkName := c.DefaultQuery("sink", "postgres")
+	sink, err := newSink(sinkName, connectorDeps{dbHandler: dbHandler, blobs: imports.blobs}, map[string]string{"mode": c.Query("mode")})
+	if err != nil {
+		respondError(c, 400, "Invalid sink", err.Error())
+		return "", nil, false
+	}
+	return sinkName, sink, true
+}
 
-	// Process each chunk in a separate Goroutine
-	for records := range ch {
-		wg.Add(1)
+// parseImportOptions reads the per-import query parameters, responding with 400 when one is invalid
+func parseImportOptions(c *gin.Context) (importOptions, bool) {
+	// preserve_order=true inserts chunks sequentially so auto-increment IDs follow file order
+	preserveOrder, err := strconv.ParseBool(c.DefaultQuery("preserve_order", "false"))
+	if err != nil {
+		respondError(c, 400, "Invalid preserve_order value", err.Error())
+		return importOptions{}, false
+	}
 
-		go processChunk(records, dbHandler, batchSize, semaphore, &wg)
+	// dedup_key=email (or a comma-separated column list) drops repeated rows within the file
+	dedup, err := newDeduplicator(c.Query("dedup_key"))
+	if err != nil {
+		respondError(c, 400, "Invalid dedup_key value", err.Error())
+		return importOptions{}, false
+	}
 
-		// Optional: Log memory usage
-		logMemoryUsage() // This can be enabled for debugging
+	// overflow=truncate keeps rows with overlong values by cutting them; the default rejects the row
+	overflow, err := newOverflowHandler(c.Query("overflow"))
+	if err != nil {
+		respondError(c, 400, "Invalid overflow value", err.Error())
+		return importOptions{}, false
 	}
 
-	// Wait for all Goroutines to finish
-	wg.Wait()
+	// wait=true keeps the request open until the import is finished; disconnecting cancels the import
+	wait, err := strconv.ParseBool(c.DefaultQuery("wait", "false"))
+	if err != nil {
+		respondError(c, 400, "Invalid wait value", err.Error())
+		return importOptions{}, false
+	}
+
+	// return_ids=true reports the IDs the rows were written with, as ranges of file lines
+	returnIDs, err := strconv.ParseBool(c.DefaultQuery("return_ids", "false"))
+	if err != nil {
+		respondError(c, 400, "Invalid return_ids value", err.Error())
+		return importOptions{}, false
+	}
+
+	// validation=strict rejects the whole file when a row fails validation; lenient skips the invalid rows
+	validation, err := parseValidationMode(c.Query("validation"))
+	if err != nil {
+		respondError(c, 400, "Invalid validation value", err.Error())
+		return importOptions{}, false
+	}
+
+	// date_formats=dd/mm/yyyy,yyyy-mm-dd names the formats of the file's dates, tried in order
+	dateFormats, err := parseDateFormats(c.Query("date_formats"))
+	if err != nil {
+		respondError(c, 400, "Invalid date_formats value", err.Error())
+		return importOptions{}, false
+	}
 
-	// Respond with success message
-	c.JSON(200, gin.H{"message": "CSV file processed successfully and data stored in database."})
+	return importOptions{preserveOrder: preserveOrder, overflow: overflow, dedup: dedup, wait: wait, returnIDs: returnIDs, validation: validation, dateFormats: dateFormats}, true
 }
 
-func CSVtoDB() {
-	// Set up the database using the updated setupDatabase function
-	db := setupDatabase()
+// submitImport queues the task and responds with 202 and the created job,
+// or with 200 and the finished job when the client waits for the import
+func submitImport(c *gin.Context, imports *importManager, task importTask) {
+	task.tags = queryTagsFrom(c.Request.Context())
+	task.job.RequestID = c.GetString(requestIDContextKey)
+	if task.options.wait {
+		task.ctx = c.Request.Context()
+		task.done = make(chan struct{})
+	}
+	job, err := imports.submit(task)
+	if errors.Is(err, errImportQueueFull) {
+		respondError(c, 503, err.Error())
+		return
+	}
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to queue import")
+		respondError(c, 500, "Failed to queue import", err.Error())
+		return
+	}
 
-	// Create a GormDBHandler instance that implements the DBHandler interface
-	var dbHandler DBHandler = &GormDBHandler{db: db}
+	requestLogger(c).WithField("job_id", job.ID).Info("Import queued")
+	c.Header("Location", fmt.Sprintf("/api/imports/%d", job.ID))
+	if task.done != nil {
+		<-task.done
+		respond(c, 200, *task.job, gin.H{"report": jobReport(task.job)})
+		return
+	}
+	respond(c, 202, job, nil)
+}
 
-	// Create a new Gin router
-	r := gin.Default()
-	r.MaxMultipartMemory = 30 << 30 // 30 GB for large file uploads
+// runImport reads the CSV or XLSX file, optionally gzip-compressed, and writes it to sink chunk by chunk,
+// returning the ingestion metrics. Cancelling ctx stops reading and skips the chunks not yet inserted.
+func runImport(ctx context.Context, file io.Reader, sink Sink, options importOptions, progress *importProgress) (map[string]interface{}, error) {
+	// Decompress .gz files while reading them
+	buffered, decompressor, compression, err := decompress(bufio.NewReader(&contextReader{ctx: ctx, r: file}))
+	if err != nil {
+		return nil, err
+	}
+	defer decompressor.Close()
 
-	// Define the POST endpoint to upload the CSV file
-	r.POST("/upload-csv", func(c *gin.Context) {
-		uploadCSV(c, dbHandler)
-	})
+	// Initialize CSV processing
+	ch := make(chan csvChunk, csvChannelBuffer)
+	stats := &readerStats{entry: progress.logger()}
+	var wg sync.WaitGroup
+
+	// Limit the number of workers inserting at once, scaling with queue depth and DB latency.
+	// A single writer is used when the file order must be preserved, and when duplicates are dropped,
+	// so the first row of a key to be written is the first in the file.
+	minWorkers, maxWorkers := ingestMinWorkers(), ingestMaxWorkers()
+	if options.preserveOrder || options.dedup != nil {
+		minWorkers, maxWorkers = 1, 1
+	}
+	limiter := newAdaptiveLimiter(minWorkers, maxWorkers, ingestTargetChunkLatency, func() int { return len(ch) })
 
-	// Start the Gin server
-	r.Run(":8080")
+	// Start reading the file in chunks, telling workbooks from CSV by their content unless the format is given
+	format := startReader(buffered, options.format, options.sheet, options.columns, progress.evolvedColumns(), ch, stats)
+
+	// A fixed pool of workers takes chunks from the channel, each inserting once the limiter frees a slot.
+	// While every worker is busy the channel fills up and the reader pauses, so memory stays bounded
+	// by the channel buffer plus one chunk per worker however large the file is.
+	for i := 0; i < maxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range ch {
+				if ctx.Err() != nil {
+					continue // Drain the chunks read before the import was cancelled
+				}
+				limiter.Acquire()
+				processChunk(chunk, sink, limiter, options.overflow, options.dedup, options.dateFormats, progress)
+
+				// Log memory usage when debug logging is enabled
+				logMemoryUsage()
+			}
+		}()
+	}
+
+	// Wait for the workers to drain the channel
+	wg.Wait()
+
+	// Collect the reader backpressure, duplicate, overflow, coercion and sink metrics
+	metrics := stats.metrics()
+	metrics["file_format"] = format
+	metrics["compression"] = compression
+	metrics["validation"] = options.validation
+	metrics["duplicates_dropped"] = options.dedup.Dropped()
+	metrics["unmapped_columns"] = stats.unmappedColumns()
+	metrics["proposed_columns"] = proposeSchemaChanges(progress, stats.unmappedColumns())
+	for key, value := range options.overflow.report() {
+		metrics[key] = value
+	}
+	coercions := progress.coercions()
+	for name, count := range options.overflow.truncations() {
+		coercions[name] += count
+	}
+	metrics["coercions"] = coercions
+	if reporter, ok := sink.(sinkReporter); ok {
+		for key, value := range reporter.report() {
+			metrics[key] = value
+		}
+	}
+
+	// Summarize the rejected rows; the full list is served by GET /api/imports/:id/errors
+	rowErrors, dropped := progress.rejectedRows()
+	metrics["row_errors"] = len(rowErrors) + dropped
+	metrics["row_error_samples"] = rowErrors[:min(len(rowErrors), importRowErrorSamples)]
+	metrics["committed_ranges"], metrics["committed_ranges_dropped"] = progress.committedRanges()
+	if ids, dropped, ok := progress.generatedIDs(); ok {
+		metrics["generated_ids"] = ids
+		metrics["generated_ids_dropped"] = dropped
+	}
+	progress.logger().WithFields(metrics).Info("CSV ingestion completed")
+	if ctx.Err() != nil {
+		return metrics, fmt.Errorf("import cancelled: %w", context.Cause(ctx))
+	}
+	return metrics, stats.Err()
 }
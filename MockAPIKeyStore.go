@@ -0,0 +1,123 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: api_keys.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAPIKeyStore is a mock of APIKeyStore interface.
+type MockAPIKeyStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyStoreMockRecorder
+}
+
+// MockAPIKeyStoreMockRecorder is the mock recorder for MockAPIKeyStore.
+type MockAPIKeyStoreMockRecorder struct {
+	mock *MockAPIKeyStore
+}
+
+// NewMockAPIKeyStore creates a new mock instance.
+func NewMockAPIKeyStore(ctrl *gomock.Controller) *MockAPIKeyStore {
+	mock := &MockAPIKeyStore{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyStore) EXPECT() *MockAPIKeyStoreMockRecorder {
+	return m.recorder
+}
+
+// ChargeUpload mocks base method.
+func (m *MockAPIKeyStore) ChargeUpload(id uint, bytes int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChargeUpload", id, bytes)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChargeUpload indicates an expected call of ChargeUpload.
+func (mr *MockAPIKeyStoreMockRecorder) ChargeUpload(id, bytes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChargeUpload", reflect.TypeOf((*MockAPIKeyStore)(nil).ChargeUpload), id, bytes)
+}
+
+// Create mocks base method.
+func (m *MockAPIKeyStore) Create(key *APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAPIKeyStoreMockRecorder) Create(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAPIKeyStore)(nil).Create), key)
+}
+
+// Find mocks base method.
+func (m *MockAPIKeyStore) Find(hash string) (*APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", hash)
+	ret0, _ := ret[0].(*APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockAPIKeyStoreMockRecorder) Find(hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockAPIKeyStore)(nil).Find), hash)
+}
+
+// List mocks base method.
+func (m *MockAPIKeyStore) List() ([]APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List")
+	ret0, _ := ret[0].([]APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAPIKeyStoreMockRecorder) List() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAPIKeyStore)(nil).List))
+}
+
+// Revoke mocks base method.
+func (m *MockAPIKeyStore) Revoke(id uint) (*APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", id)
+	ret0, _ := ret[0].(*APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockAPIKeyStoreMockRecorder) Revoke(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockAPIKeyStore)(nil).Revoke), id)
+}
+
+// Touch mocks base method.
+func (m *MockAPIKeyStore) Touch(id uint, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Touch", id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Touch indicates an expected call of Touch.
+func (mr *MockAPIKeyStoreMockRecorder) Touch(id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Touch", reflect.TypeOf((*MockAPIKeyStore)(nil).Touch), id, at)
+}
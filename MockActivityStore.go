@@ -0,0 +1,64 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: db_admin.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockActivityStore is a mock of ActivityStore interface.
+type MockActivityStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockActivityStoreMockRecorder
+}
+
+// MockActivityStoreMockRecorder is the mock recorder for MockActivityStore.
+type MockActivityStoreMockRecorder struct {
+	mock *MockActivityStore
+}
+
+// NewMockActivityStore creates a new mock instance.
+func NewMockActivityStore(ctrl *gomock.Controller) *MockActivityStore {
+	mock := &MockActivityStore{ctrl: ctrl}
+	mock.recorder = &MockActivityStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockActivityStore) EXPECT() *MockActivityStoreMockRecorder {
+	return m.recorder
+}
+
+// Cancel mocks base method.
+func (m *MockActivityStore) Cancel(pid int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cancel", pid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Cancel indicates an expected call of Cancel.
+func (mr *MockActivityStoreMockRecorder) Cancel(pid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cancel", reflect.TypeOf((*MockActivityStore)(nil).Cancel), pid)
+}
+
+// List mocks base method.
+func (m *MockActivityStore) List() ([]dbActivity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List")
+	ret0, _ := ret[0].([]dbActivity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockActivityStoreMockRecorder) List() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockActivityStore)(nil).List))
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: C:\Users\iman.das\Desktop\GoLang\Mini-Project\CSVtoDatabase.go
+// Source: CSVtoDatabase.go
 
 // Package main is a generated GoMock package.
 package main
@@ -34,6 +34,20 @@ func (m *MockDBHandler) EXPECT() *MockDBHandlerMockRecorder {
 	return m.recorder
 }
 
+// CopyFrom mocks base method.
+func (m *MockDBHandler) CopyFrom(users []UserData) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CopyFrom", users)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CopyFrom indicates an expected call of CopyFrom.
+func (mr *MockDBHandlerMockRecorder) CopyFrom(users interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopyFrom", reflect.TypeOf((*MockDBHandler)(nil).CopyFrom), users)
+}
+
 // CreateInBatches mocks base method.
 func (m *MockDBHandler) CreateInBatches(value interface{}, batchSize int) error {
 	m.ctrl.T.Helper()
@@ -48,6 +62,20 @@ func (mr *MockDBHandlerMockRecorder) CreateInBatches(value, batchSize interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInBatches", reflect.TypeOf((*MockDBHandler)(nil).CreateInBatches), value, batchSize)
 }
 
+// EnsureUniqueEmail mocks base method.
+func (m *MockDBHandler) EnsureUniqueEmail() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnsureUniqueEmail")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureUniqueEmail indicates an expected call of EnsureUniqueEmail.
+func (mr *MockDBHandlerMockRecorder) EnsureUniqueEmail() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureUniqueEmail", reflect.TypeOf((*MockDBHandler)(nil).EnsureUniqueEmail))
+}
+
 // Find mocks base method.
 func (m *MockDBHandler) Find(dest interface{}, conds ...interface{}) *gorm.DB {
 	m.ctrl.T.Helper()
@@ -108,3 +136,31 @@ func (mr *MockDBHandlerMockRecorder) Order(value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Order", reflect.TypeOf((*MockDBHandler)(nil).Order), value)
 }
+
+// Transaction mocks base method.
+func (m *MockDBHandler) Transaction(fn func(DBHandler) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transaction", fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transaction indicates an expected call of Transaction.
+func (mr *MockDBHandlerMockRecorder) Transaction(fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transaction", reflect.TypeOf((*MockDBHandler)(nil).Transaction), fn)
+}
+
+// Upsert mocks base method.
+func (m *MockDBHandler) Upsert(users []UserData, batchSize int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", users, batchSize)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockDBHandlerMockRecorder) Upsert(users, batchSize interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockDBHandler)(nil).Upsert), users, batchSize)
+}
@@ -0,0 +1,270 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: user_data_API.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	gorm "gorm.io/gorm"
+)
+
+// MockDatabase is a mock of Database interface.
+type MockDatabase struct {
+	ctrl     *gomock.Controller
+	recorder *MockDatabaseMockRecorder
+}
+
+// MockDatabaseMockRecorder is the mock recorder for MockDatabase.
+type MockDatabaseMockRecorder struct {
+	mock *MockDatabase
+}
+
+// NewMockDatabase creates a new mock instance.
+func NewMockDatabase(ctrl *gomock.Controller) *MockDatabase {
+	mock := &MockDatabase{ctrl: ctrl}
+	mock.recorder = &MockDatabaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDatabase) EXPECT() *MockDatabaseMockRecorder {
+	return m.recorder
+}
+
+// Count mocks base method.
+func (m *MockDatabase) Count(count *int64) *gorm.DB {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", count)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockDatabaseMockRecorder) Count(count interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockDatabase)(nil).Count), count)
+}
+
+// Create mocks base method.
+func (m *MockDatabase) Create(value interface{}) *gorm.DB {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", value)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockDatabaseMockRecorder) Create(value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockDatabase)(nil).Create), value)
+}
+
+// Delete mocks base method.
+func (m *MockDatabase) Delete(value interface{}, conds ...interface{}) *gorm.DB {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{value}
+	for _, a := range conds {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Delete", varargs...)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockDatabaseMockRecorder) Delete(value interface{}, conds ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{value}, conds...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockDatabase)(nil).Delete), varargs...)
+}
+
+// Find mocks base method.
+func (m *MockDatabase) Find(dest interface{}, conds ...interface{}) *gorm.DB {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{dest}
+	for _, a := range conds {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Find", varargs...)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockDatabaseMockRecorder) Find(dest interface{}, conds ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{dest}, conds...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockDatabase)(nil).Find), varargs...)
+}
+
+// FindInBatches mocks base method.
+func (m *MockDatabase) FindInBatches(dest interface{}, batchSize int, fc func(*gorm.DB, int) error) *gorm.DB {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindInBatches", dest, batchSize, fc)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// FindInBatches indicates an expected call of FindInBatches.
+func (mr *MockDatabaseMockRecorder) FindInBatches(dest, batchSize, fc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindInBatches", reflect.TypeOf((*MockDatabase)(nil).FindInBatches), dest, batchSize, fc)
+}
+
+// First mocks base method.
+func (m *MockDatabase) First(dest interface{}, conds ...interface{}) *gorm.DB {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{dest}
+	for _, a := range conds {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "First", varargs...)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// First indicates an expected call of First.
+func (mr *MockDatabaseMockRecorder) First(dest interface{}, conds ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{dest}, conds...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "First", reflect.TypeOf((*MockDatabase)(nil).First), varargs...)
+}
+
+// Group mocks base method.
+func (m *MockDatabase) Group(name string) Database {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Group", name)
+	ret0, _ := ret[0].(Database)
+	return ret0
+}
+
+// Group indicates an expected call of Group.
+func (mr *MockDatabaseMockRecorder) Group(name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Group", reflect.TypeOf((*MockDatabase)(nil).Group), name)
+}
+
+// Limit mocks base method.
+func (m *MockDatabase) Limit(limit int) Database {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Limit", limit)
+	ret0, _ := ret[0].(Database)
+	return ret0
+}
+
+// Limit indicates an expected call of Limit.
+func (mr *MockDatabaseMockRecorder) Limit(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Limit", reflect.TypeOf((*MockDatabase)(nil).Limit), limit)
+}
+
+// Model mocks base method.
+func (m *MockDatabase) Model(value interface{}) Database {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Model", value)
+	ret0, _ := ret[0].(Database)
+	return ret0
+}
+
+// Model indicates an expected call of Model.
+func (mr *MockDatabaseMockRecorder) Model(value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Model", reflect.TypeOf((*MockDatabase)(nil).Model), value)
+}
+
+// Offset mocks base method.
+func (m *MockDatabase) Offset(offset int) Database {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Offset", offset)
+	ret0, _ := ret[0].(Database)
+	return ret0
+}
+
+// Offset indicates an expected call of Offset.
+func (mr *MockDatabaseMockRecorder) Offset(offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Offset", reflect.TypeOf((*MockDatabase)(nil).Offset), offset)
+}
+
+// Order mocks base method.
+func (m *MockDatabase) Order(value string) Database {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Order", value)
+	ret0, _ := ret[0].(Database)
+	return ret0
+}
+
+// Order indicates an expected call of Order.
+func (mr *MockDatabaseMockRecorder) Order(value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Order", reflect.TypeOf((*MockDatabase)(nil).Order), value)
+}
+
+// Save mocks base method.
+func (m *MockDatabase) Save(value interface{}) *gorm.DB {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", value)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockDatabaseMockRecorder) Save(value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockDatabase)(nil).Save), value)
+}
+
+// Scan mocks base method.
+func (m *MockDatabase) Scan(dest interface{}) *gorm.DB {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", dest)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockDatabaseMockRecorder) Scan(dest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockDatabase)(nil).Scan), dest)
+}
+
+// Select mocks base method.
+func (m *MockDatabase) Select(query interface{}, args ...interface{}) Database {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Select", varargs...)
+	ret0, _ := ret[0].(Database)
+	return ret0
+}
+
+// Select indicates an expected call of Select.
+func (mr *MockDatabaseMockRecorder) Select(query interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Select", reflect.TypeOf((*MockDatabase)(nil).Select), varargs...)
+}
+
+// Where mocks base method.
+func (m *MockDatabase) Where(query interface{}, args ...interface{}) Database {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Where", varargs...)
+	ret0, _ := ret[0].(Database)
+	return ret0
+}
+
+// Where indicates an expected call of Where.
+func (mr *MockDatabaseMockRecorder) Where(query interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Where", reflect.TypeOf((*MockDatabase)(nil).Where), varargs...)
+}
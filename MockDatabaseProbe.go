@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: health.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	sql "database/sql"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDatabaseProbe is a mock of DatabaseProbe interface.
+type MockDatabaseProbe struct {
+	ctrl     *gomock.Controller
+	recorder *MockDatabaseProbeMockRecorder
+}
+
+// MockDatabaseProbeMockRecorder is the mock recorder for MockDatabaseProbe.
+type MockDatabaseProbeMockRecorder struct {
+	mock *MockDatabaseProbe
+}
+
+// NewMockDatabaseProbe creates a new mock instance.
+func NewMockDatabaseProbe(ctrl *gomock.Controller) *MockDatabaseProbe {
+	mock := &MockDatabaseProbe{ctrl: ctrl}
+	mock.recorder = &MockDatabaseProbeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDatabaseProbe) EXPECT() *MockDatabaseProbeMockRecorder {
+	return m.recorder
+}
+
+// Ping mocks base method.
+func (m *MockDatabaseProbe) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockDatabaseProbeMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockDatabaseProbe)(nil).Ping))
+}
+
+// PoolStats mocks base method.
+func (m *MockDatabaseProbe) PoolStats() (sql.DBStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PoolStats")
+	ret0, _ := ret[0].(sql.DBStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PoolStats indicates an expected call of PoolStats.
+func (mr *MockDatabaseProbeMockRecorder) PoolStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PoolStats", reflect.TypeOf((*MockDatabaseProbe)(nil).PoolStats))
+}
+
+// SchemaDrift mocks base method.
+func (m *MockDatabaseProbe) SchemaDrift() ([]schemaDrift, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SchemaDrift")
+	ret0, _ := ret[0].([]schemaDrift)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SchemaDrift indicates an expected call of SchemaDrift.
+func (mr *MockDatabaseProbeMockRecorder) SchemaDrift() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SchemaDrift", reflect.TypeOf((*MockDatabaseProbe)(nil).SchemaDrift))
+}
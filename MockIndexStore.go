@@ -0,0 +1,64 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: index_advisor.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIndexStore is a mock of IndexStore interface.
+type MockIndexStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockIndexStoreMockRecorder
+}
+
+// MockIndexStoreMockRecorder is the mock recorder for MockIndexStore.
+type MockIndexStoreMockRecorder struct {
+	mock *MockIndexStore
+}
+
+// NewMockIndexStore creates a new mock instance.
+func NewMockIndexStore(ctrl *gomock.Controller) *MockIndexStore {
+	mock := &MockIndexStore{ctrl: ctrl}
+	mock.recorder = &MockIndexStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIndexStore) EXPECT() *MockIndexStoreMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockIndexStore) Create(column string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", column)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockIndexStoreMockRecorder) Create(column interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockIndexStore)(nil).Create), column)
+}
+
+// Stats mocks base method.
+func (m *MockIndexStore) Stats() (*tableIndexStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(*tableIndexStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockIndexStoreMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockIndexStore)(nil).Stats))
+}
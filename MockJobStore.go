@@ -0,0 +1,153 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: imports.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockJobStore is a mock of JobStore interface.
+type MockJobStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockJobStoreMockRecorder
+}
+
+// MockJobStoreMockRecorder is the mock recorder for MockJobStore.
+type MockJobStoreMockRecorder struct {
+	mock *MockJobStore
+}
+
+// NewMockJobStore creates a new mock instance.
+func NewMockJobStore(ctrl *gomock.Controller) *MockJobStore {
+	mock := &MockJobStore{ctrl: ctrl}
+	mock.recorder = &MockJobStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockJobStore) EXPECT() *MockJobStoreMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockJobStore) Create(job *ImportJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockJobStoreMockRecorder) Create(job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockJobStore)(nil).Create), job)
+}
+
+// FailStale mocks base method.
+func (m *MockJobStore) FailStale(before time.Time, reason string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailStale", before, reason)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FailStale indicates an expected call of FailStale.
+func (mr *MockJobStoreMockRecorder) FailStale(before, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailStale", reflect.TypeOf((*MockJobStore)(nil).FailStale), before, reason)
+}
+
+// Get mocks base method.
+func (m *MockJobStore) Get(id uint) (*ImportJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", id)
+	ret0, _ := ret[0].(*ImportJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockJobStoreMockRecorder) Get(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockJobStore)(nil).Get), id)
+}
+
+// List mocks base method.
+func (m *MockJobStore) List(filter importHistoryFilter, offset, limit int) ([]ImportJob, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", filter, offset, limit)
+	ret0, _ := ret[0].([]ImportJob)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// List indicates an expected call of List.
+func (mr *MockJobStoreMockRecorder) List(filter, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockJobStore)(nil).List), filter, offset, limit)
+}
+
+// RecentDone mocks base method.
+func (m *MockJobStore) RecentDone(limit int) ([]ImportJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecentDone", limit)
+	ret0, _ := ret[0].([]ImportJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecentDone indicates an expected call of RecentDone.
+func (mr *MockJobStoreMockRecorder) RecentDone(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecentDone", reflect.TypeOf((*MockJobStore)(nil).RecentDone), limit)
+}
+
+// RowErrors mocks base method.
+func (m *MockJobStore) RowErrors(jobID uint) ([]ImportRowError, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RowErrors", jobID)
+	ret0, _ := ret[0].([]ImportRowError)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RowErrors indicates an expected call of RowErrors.
+func (mr *MockJobStoreMockRecorder) RowErrors(jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RowErrors", reflect.TypeOf((*MockJobStore)(nil).RowErrors), jobID)
+}
+
+// Save mocks base method.
+func (m *MockJobStore) Save(job *ImportJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockJobStoreMockRecorder) Save(job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockJobStore)(nil).Save), job)
+}
+
+// SaveRowErrors mocks base method.
+func (m *MockJobStore) SaveRowErrors(rowErrors []ImportRowError) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveRowErrors", rowErrors)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveRowErrors indicates an expected call of SaveRowErrors.
+func (mr *MockJobStoreMockRecorder) SaveRowErrors(rowErrors interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveRowErrors", reflect.TypeOf((*MockJobStore)(nil).SaveRowErrors), rowErrors)
+}
@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: search.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRecordSearcher is a mock of RecordSearcher interface.
+type MockRecordSearcher struct {
+	ctrl     *gomock.Controller
+	recorder *MockRecordSearcherMockRecorder
+}
+
+// MockRecordSearcherMockRecorder is the mock recorder for MockRecordSearcher.
+type MockRecordSearcherMockRecorder struct {
+	mock *MockRecordSearcher
+}
+
+// NewMockRecordSearcher creates a new mock instance.
+func NewMockRecordSearcher(ctrl *gomock.Controller) *MockRecordSearcher {
+	mock := &MockRecordSearcher{ctrl: ctrl}
+	mock.recorder = &MockRecordSearcherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRecordSearcher) EXPECT() *MockRecordSearcherMockRecorder {
+	return m.recorder
+}
+
+// Search mocks base method.
+func (m *MockRecordSearcher) Search(query string, offset, limit int) ([]searchResult, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", query, offset, limit)
+	ret0, _ := ret[0].([]searchResult)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockRecordSearcherMockRecorder) Search(query, offset, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockRecordSearcher)(nil).Search), query, offset, limit)
+}
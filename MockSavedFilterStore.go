@@ -0,0 +1,92 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: saved_filters.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSavedFilterStore is a mock of SavedFilterStore interface.
+type MockSavedFilterStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockSavedFilterStoreMockRecorder
+}
+
+// MockSavedFilterStoreMockRecorder is the mock recorder for MockSavedFilterStore.
+type MockSavedFilterStoreMockRecorder struct {
+	mock *MockSavedFilterStore
+}
+
+// NewMockSavedFilterStore creates a new mock instance.
+func NewMockSavedFilterStore(ctrl *gomock.Controller) *MockSavedFilterStore {
+	mock := &MockSavedFilterStore{ctrl: ctrl}
+	mock.recorder = &MockSavedFilterStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSavedFilterStore) EXPECT() *MockSavedFilterStoreMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockSavedFilterStore) Delete(owner, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", owner, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockSavedFilterStoreMockRecorder) Delete(owner, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSavedFilterStore)(nil).Delete), owner, name)
+}
+
+// Find mocks base method.
+func (m *MockSavedFilterStore) Find(owner, name string) (*SavedFilter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", owner, name)
+	ret0, _ := ret[0].(*SavedFilter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockSavedFilterStoreMockRecorder) Find(owner, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockSavedFilterStore)(nil).Find), owner, name)
+}
+
+// List mocks base method.
+func (m *MockSavedFilterStore) List(owner string) ([]SavedFilter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", owner)
+	ret0, _ := ret[0].([]SavedFilter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSavedFilterStoreMockRecorder) List(owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSavedFilterStore)(nil).List), owner)
+}
+
+// Save mocks base method.
+func (m *MockSavedFilterStore) Save(filter *SavedFilter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", filter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockSavedFilterStoreMockRecorder) Save(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSavedFilterStore)(nil).Save), filter)
+}
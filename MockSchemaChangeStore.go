@@ -0,0 +1,93 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: schema_evolution.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSchemaChangeStore is a mock of SchemaChangeStore interface.
+type MockSchemaChangeStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockSchemaChangeStoreMockRecorder
+}
+
+// MockSchemaChangeStoreMockRecorder is the mock recorder for MockSchemaChangeStore.
+type MockSchemaChangeStoreMockRecorder struct {
+	mock *MockSchemaChangeStore
+}
+
+// NewMockSchemaChangeStore creates a new mock instance.
+func NewMockSchemaChangeStore(ctrl *gomock.Controller) *MockSchemaChangeStore {
+	mock := &MockSchemaChangeStore{ctrl: ctrl}
+	mock.recorder = &MockSchemaChangeStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSchemaChangeStore) EXPECT() *MockSchemaChangeStoreMockRecorder {
+	return m.recorder
+}
+
+// AppliedColumns mocks base method.
+func (m *MockSchemaChangeStore) AppliedColumns() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppliedColumns")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppliedColumns indicates an expected call of AppliedColumns.
+func (mr *MockSchemaChangeStoreMockRecorder) AppliedColumns() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppliedColumns", reflect.TypeOf((*MockSchemaChangeStore)(nil).AppliedColumns))
+}
+
+// Decide mocks base method.
+func (m *MockSchemaChangeStore) Decide(id uint, approve bool, by string) (*SchemaChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decide", id, approve, by)
+	ret0, _ := ret[0].(*SchemaChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Decide indicates an expected call of Decide.
+func (mr *MockSchemaChangeStoreMockRecorder) Decide(id, approve, by interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decide", reflect.TypeOf((*MockSchemaChangeStore)(nil).Decide), id, approve, by)
+}
+
+// List mocks base method.
+func (m *MockSchemaChangeStore) List(state string) ([]SchemaChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", state)
+	ret0, _ := ret[0].([]SchemaChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSchemaChangeStoreMockRecorder) List(state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSchemaChangeStore)(nil).List), state)
+}
+
+// Propose mocks base method.
+func (m *MockSchemaChangeStore) Propose(changes []SchemaChange) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Propose", changes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Propose indicates an expected call of Propose.
+func (mr *MockSchemaChangeStoreMockRecorder) Propose(changes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Propose", reflect.TypeOf((*MockSchemaChangeStore)(nil).Propose), changes)
+}
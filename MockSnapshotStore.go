@@ -0,0 +1,108 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: snapshots.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSnapshotStore is a mock of SnapshotStore interface.
+type MockSnapshotStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockSnapshotStoreMockRecorder
+}
+
+// MockSnapshotStoreMockRecorder is the mock recorder for MockSnapshotStore.
+type MockSnapshotStoreMockRecorder struct {
+	mock *MockSnapshotStore
+}
+
+// NewMockSnapshotStore creates a new mock instance.
+func NewMockSnapshotStore(ctrl *gomock.Controller) *MockSnapshotStore {
+	mock := &MockSnapshotStore{ctrl: ctrl}
+	mock.recorder = &MockSnapshotStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSnapshotStore) EXPECT() *MockSnapshotStoreMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockSnapshotStore) Create(name, by string) (*Snapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", name, by)
+	ret0, _ := ret[0].(*Snapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSnapshotStoreMockRecorder) Create(name, by interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSnapshotStore)(nil).Create), name, by)
+}
+
+// Delete mocks base method.
+func (m *MockSnapshotStore) Delete(id uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockSnapshotStoreMockRecorder) Delete(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSnapshotStore)(nil).Delete), id)
+}
+
+// List mocks base method.
+func (m *MockSnapshotStore) List() ([]Snapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List")
+	ret0, _ := ret[0].([]Snapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSnapshotStoreMockRecorder) List() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSnapshotStore)(nil).List))
+}
+
+// Records mocks base method.
+func (m *MockSnapshotStore) Records(id uint, afterID, limit int) ([]UserDatas, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Records", id, afterID, limit)
+	ret0, _ := ret[0].([]UserDatas)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Records indicates an expected call of Records.
+func (mr *MockSnapshotStoreMockRecorder) Records(id, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Records", reflect.TypeOf((*MockSnapshotStore)(nil).Records), id, afterID, limit)
+}
+
+// Restore mocks base method.
+func (m *MockSnapshotStore) Restore(id uint) (*Snapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", id)
+	ret0, _ := ret[0].(*Snapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockSnapshotStoreMockRecorder) Restore(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockSnapshotStore)(nil).Restore), id)
+}
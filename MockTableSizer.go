@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: preflight.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTableSizer is a mock of TableSizer interface.
+type MockTableSizer struct {
+	ctrl     *gomock.Controller
+	recorder *MockTableSizerMockRecorder
+}
+
+// MockTableSizerMockRecorder is the mock recorder for MockTableSizer.
+type MockTableSizerMockRecorder struct {
+	mock *MockTableSizer
+}
+
+// NewMockTableSizer creates a new mock instance.
+func NewMockTableSizer(ctrl *gomock.Controller) *MockTableSizer {
+	mock := &MockTableSizer{ctrl: ctrl}
+	mock.recorder = &MockTableSizerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTableSizer) EXPECT() *MockTableSizerMockRecorder {
+	return m.recorder
+}
+
+// TableSize mocks base method.
+func (m *MockTableSizer) TableSize() (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TableSize")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TableSize indicates an expected call of TableSize.
+func (mr *MockTableSizerMockRecorder) TableSize() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TableSize", reflect.TypeOf((*MockTableSizer)(nil).TableSize))
+}
@@ -0,0 +1,92 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: upload_streams.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockUploadStreamStore is a mock of UploadStreamStore interface.
+type MockUploadStreamStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockUploadStreamStoreMockRecorder
+}
+
+// MockUploadStreamStoreMockRecorder is the mock recorder for MockUploadStreamStore.
+type MockUploadStreamStoreMockRecorder struct {
+	mock *MockUploadStreamStore
+}
+
+// NewMockUploadStreamStore creates a new mock instance.
+func NewMockUploadStreamStore(ctrl *gomock.Controller) *MockUploadStreamStore {
+	mock := &MockUploadStreamStore{ctrl: ctrl}
+	mock.recorder = &MockUploadStreamStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUploadStreamStore) EXPECT() *MockUploadStreamStoreMockRecorder {
+	return m.recorder
+}
+
+// Commit mocks base method.
+func (m *MockUploadStreamStore) Commit(id uint, from, to int64, users []UserData, rejected int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit", id, from, to, users, rejected)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockUploadStreamStoreMockRecorder) Commit(id, from, to, users, rejected interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockUploadStreamStore)(nil).Commit), id, from, to, users, rejected)
+}
+
+// Complete mocks base method.
+func (m *MockUploadStreamStore) Complete(id uint) (*UploadStream, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", id)
+	ret0, _ := ret[0].(*UploadStream)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockUploadStreamStoreMockRecorder) Complete(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockUploadStreamStore)(nil).Complete), id)
+}
+
+// Create mocks base method.
+func (m *MockUploadStreamStore) Create(stream *UploadStream) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", stream)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockUploadStreamStoreMockRecorder) Create(stream interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUploadStreamStore)(nil).Create), stream)
+}
+
+// Get mocks base method.
+func (m *MockUploadStreamStore) Get(id uint) (*UploadStream, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", id)
+	ret0, _ := ret[0].(*UploadStream)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockUploadStreamStoreMockRecorder) Get(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockUploadStreamStore)(nil).Get), id)
+}
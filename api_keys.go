@@ -0,0 +1,354 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Scopes of API keys; a key may only make the requests of its scopes
+const (
+	scopeRead   = "read"   // GET and HEAD requests
+	scopeWrite  = "write"  // Record changes and other writes that aren't uploads
+	scopeUpload = "upload" // Uploads and import submissions, counted against the key's upload quota
+	scopeAdmin  = "admin"  // The admin endpoints and logs, and everything the other scopes allow
+)
+
+// validScopes are the scopes keys can be created with
+var validScopes = []string{scopeRead, scopeWrite, scopeUpload, scopeAdmin}
+
+const (
+	apiKeyHeader        = "X-API-Key"
+	apiKeyPrefix        = "mpk_"      // Marks the service's keys, e.g. for secret scanners
+	apiKeyPrefixLength  = 12          // Characters of a key kept in clear to recognize it in listings
+	apiKeyContextKey    = "api_key"   // Gin context key of the authenticated key
+	apiKeyTouchInterval = time.Minute // Last-used timestamps are only written this often per key
+)
+
+// uploadPaths are the routes whose requests need the upload scope and count against the upload quota,
+// besides the writes to upload streams
+var uploadPaths = map[string]bool{"/upload-csv": true, "/upload-json": true, "/api/imports": true, "/api/imports/url": true, uploadStreamsPath: true}
+
+// apiKeys stores the API keys; nil in tests, where keys are ignored
+var apiKeys APIKeyStore
+
+// apiKeyScopes are the scopes of a key, stored as a comma-separated list
+type apiKeyScopes []string
+
+// GormDataType is the column type of scopes
+func (apiKeyScopes) GormDataType() string {
+	return "string"
+}
+
+// Value stores the scopes comma-separated
+func (s apiKeyScopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+// Scan reads comma-separated scopes
+func (s *apiKeyScopes) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into scopes", src)
+	}
+	*s = nil
+	if value != "" {
+		*s = strings.Split(value, ",")
+	}
+	return nil
+}
+
+// allows reports whether the scopes cover a request needing scope
+func (s apiKeyScopes) allows(scope string) bool {
+	for _, granted := range s {
+		if granted == scope || granted == scopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a key for scripts and integrations, stored in the api_keys table. Only the SHA-256 hash of the
+// key is kept; keys are random, so a slow hash like the bcrypt of passwords isn't needed.
+type APIKey struct {
+	ID               uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name             string       `gorm:"size:100" json:"name"`
+	Prefix           string       `gorm:"size:20" json:"prefix"` // First characters of the key
+	KeyHash          string       `gorm:"size:64;uniqueIndex" json:"-"`
+	Scopes           apiKeyScopes `gorm:"size:100" json:"scopes"`
+	UploadQuotaBytes int64        `json:"upload_quota_bytes"` // Bytes the key may upload per day, 0 for no limit
+	UploadedBytes    int64        `json:"uploaded_bytes"`     // Bytes uploaded on upload_day
+	UploadDay        Date         `json:"upload_day"`
+	CreatedBy        string       `gorm:"size:255" json:"created_by"`
+	CreatedAt        time.Time    `json:"created_at"`
+	LastUsedAt       *time.Time   `json:"last_used_at"`
+	RevokedAt        *time.Time   `json:"revoked_at"`
+}
+
+// TableName specifies the name of the table in the database
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// APIKeyStore interface defines how API keys are created, looked up, revoked and metered
+type APIKeyStore interface {
+	Create(key *APIKey) error
+	List() ([]APIKey, error)
+	Find(hash string) (*APIKey, error)
+	Revoke(id uint) (*APIKey, error)
+	Touch(id uint, at time.Time) error
+	ChargeUpload(id uint, bytes int64) (bool, error)
+}
+
+// GormAPIKeyStore is a concrete implementation of APIKeyStore using GORM
+type GormAPIKeyStore struct {
+	db *gorm.DB
+}
+
+// Create records a new key
+func (store *GormAPIKeyStore) Create(key *APIKey) error {
+	return store.db.Create(key).Error
+}
+
+// List loads every key, oldest first
+func (store *GormAPIKeyStore) List() ([]APIKey, error) {
+	var keys []APIKey
+	err := store.db.Order("id ASC").Find(&keys).Error
+	return keys, err
+}
+
+// Find loads the key with the given hash, returning gorm.ErrRecordNotFound for unknown keys
+func (store *GormAPIKeyStore) Find(hash string) (*APIKey, error) {
+	var key APIKey
+	if err := store.db.Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Revoke marks a key revoked, keeping the time of an earlier revocation.
+// It returns gorm.ErrRecordNotFound for unknown keys.
+func (store *GormAPIKeyStore) Revoke(id uint) (*APIKey, error) {
+	var key APIKey
+	if err := store.db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := store.db.Model(&key).Update("revoked_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &key, nil
+}
+
+// Touch records when the key was last used
+func (store *GormAPIKeyStore) Touch(id uint, at time.Time) error {
+	return store.db.Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+// uploadedToday is the bytes a key uploaded on the current day; the counter restarts every day
+const uploadedToday = "(CASE WHEN upload_day = CURRENT_DATE THEN uploaded_bytes ELSE 0 END)"
+
+// ChargeUpload adds bytes to the key's uploads of the day, unless that exceeds its quota.
+// The check and the update are one statement, so concurrent uploads can't both slip under the quota.
+func (store *GormAPIKeyStore) ChargeUpload(id uint, bytes int64) (bool, error) {
+	result := store.db.Exec("UPDATE api_keys SET uploaded_bytes = "+uploadedToday+" + ?, upload_day = CURRENT_DATE "+
+		"WHERE id = ? AND (upload_quota_bytes = 0 OR "+uploadedToday+" + ? <= upload_quota_bytes)", bytes, id, bytes)
+	return result.RowsAffected == 1, result.Error
+}
+
+// hashAPIKey returns the hex SHA-256 hash a key is stored and looked up by
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey returns a new random key
+func generateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// requiredScope returns the scope a key needs for a request, following the roles of requiredRole
+func requiredScope(method, path string) string {
+	switch requiredRole(method, path) {
+	case "":
+		return ""
+	case roleAdmin:
+		return scopeAdmin
+	case roleUploader:
+		if uploadPaths[path] || strings.HasPrefix(path, uploadStreamsPath+"/") {
+			return scopeUpload
+		}
+		return scopeWrite
+	default:
+		return scopeRead
+	}
+}
+
+// apiKeyAuthenticator authenticates requests by the API key in X-API-Key
+type apiKeyAuthenticator struct {
+	store APIKeyStore
+}
+
+// Authenticate checks the key of a request for the scope its route needs, records its use and charges
+// uploads to its quota. It rejects unknown and revoked keys with 401, missing scopes with 403, uploads
+// of unknown size on keys with a quota with 411 and uploads over the quota with 429.
+func (a *apiKeyAuthenticator) Authenticate(c *gin.Context, role string) (*Principal, error) {
+	secret := c.GetHeader(apiKeyHeader)
+	if secret == "" {
+		return nil, nil
+	}
+	key, err := a.store.Find(hashAPIKey(secret))
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && key.RevokedAt != nil) {
+		return nil, &AuthError{Code: 401, Message: "Invalid API key", Details: "the key is unknown or was revoked"}
+	}
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to load API key")
+		return nil, &AuthError{Code: 500, Message: "Failed to load API key"}
+	}
+	scope := requiredScope(c.Request.Method, c.Request.URL.Path)
+	if !key.Scopes.allows(scope) {
+		return nil, &AuthError{Code: 403, Message: "Forbidden", Details: fmt.Sprintf("requires the %s scope", scope)}
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := a.store.Touch(key.ID, now); err != nil {
+			requestLogger(c).WithError(err).WithField("api_key", key.ID).Warn("Failed to record API key use")
+		}
+	}
+
+	if scope == scopeUpload {
+		if key.UploadQuotaBytes > 0 && c.Request.ContentLength < 0 {
+			return nil, &AuthError{Code: 411, Message: "Length required", Details: "uploads with a quota-limited API key need a Content-Length"}
+		}
+		allowed, err := a.store.ChargeUpload(key.ID, max(c.Request.ContentLength, 0))
+		if err != nil {
+			requestLogger(c).WithError(err).Error("Failed to charge API key upload")
+			return nil, &AuthError{Code: 500, Message: "Failed to charge API key upload"}
+		}
+		if !allowed {
+			requestLogger(c).WithFields(logrus.Fields{"api_key": key.ID, "bytes": c.Request.ContentLength, "quota": key.UploadQuotaBytes}).Warn("Rejected upload over the API key quota")
+			return nil, &AuthError{Code: 429, Message: "Upload quota exceeded", Details: fmt.Sprintf("the key may upload %d bytes per day", key.UploadQuotaBytes)}
+		}
+	}
+
+	c.Set(apiKeyContextKey, key)
+	return &Principal{Subject: "key:" + strconv.FormatUint(uint64(key.ID), 10)}, nil
+}
+
+// createAPIKey handles POST /api/admin/api-keys, answering with the new key. The key itself is only
+// returned here; afterwards only its prefix is shown.
+func createAPIKey(c *gin.Context, store APIKeyStore) {
+	if store == nil {
+		respondError(c, 404, "API keys are not available")
+		return
+	}
+	var req struct {
+		Name             string   `json:"name" binding:"required,max=100"`
+		Scopes           []string `json:"scopes" binding:"required,min=1,dive,oneof=read write upload admin"`
+		UploadQuotaBytes int64    `json:"upload_quota_bytes" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid API key", err.Error())
+		return
+	}
+
+	secret, err := generateAPIKey()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate API key")
+		respondError(c, 500, "Failed to create API key")
+		return
+	}
+	var scopes apiKeyScopes
+	for _, scope := range validScopes {
+		if containsFold(req.Scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	key := &APIKey{
+		Name:             req.Name,
+		Prefix:           secret[:apiKeyPrefixLength],
+		KeyHash:          hashAPIKey(secret),
+		Scopes:           scopes,
+		UploadQuotaBytes: req.UploadQuotaBytes,
+		CreatedBy:        clientKey(c),
+	}
+	if err := store.Create(key); err != nil {
+		log.WithError(err).Error("Failed to create API key")
+		respondError(c, 500, "Failed to create API key")
+		return
+	}
+	log.WithFields(logrus.Fields{"api_key": key.ID, "name": key.Name, "scopes": strings.Join(key.Scopes, ","), "created_by": key.CreatedBy}).Warn("API key created")
+	respond(c, 201, struct {
+		*APIKey
+		Key string `json:"key"`
+	}{key, secret}, nil)
+}
+
+// listAPIKeys handles GET /api/admin/api-keys, including the revoked keys
+func listAPIKeys(c *gin.Context, store APIKeyStore) {
+	if store == nil {
+		respondError(c, 404, "API keys are not available")
+		return
+	}
+	keys, err := store.List()
+	if err != nil {
+		log.WithError(err).Error("Failed to list API keys")
+		respondError(c, 500, "Failed to list API keys")
+		return
+	}
+	if keys == nil {
+		keys = []APIKey{}
+	}
+	respond(c, 200, keys, gin.H{"count": len(keys)})
+}
+
+// revokeAPIKey handles POST /api/admin/api-keys/:id/revoke; requests with the key are refused right away
+func revokeAPIKey(c *gin.Context, store APIKeyStore) {
+	if store == nil {
+		respondError(c, 404, "API keys are not available")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, 400, "Invalid API key ID")
+		return
+	}
+
+	key, err := store.Revoke(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "API key not found")
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("api_key", id).Error("Failed to revoke API key")
+		respondError(c, 500, "Failed to revoke API key")
+		return
+	}
+	log.WithFields(logrus.Fields{"api_key": key.ID, "name": key.Name, "revoked_by": clientKey(c)}).Warn("API key revoked")
+	respond(c, 200, key, nil)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestRequiredScope tests the scope each kind of request needs
+func TestRequiredScope(t *testing.T) {
+	assert.Equal(t, "", requiredScope("POST", "/api/auth/token"))
+	assert.Equal(t, scopeRead, requiredScope("GET", "/api/records"))
+	assert.Equal(t, scopeWrite, requiredScope("PATCH", "/api/records/7"))
+	assert.Equal(t, scopeUpload, requiredScope("POST", "/upload-csv"))
+	assert.Equal(t, scopeUpload, requiredScope("POST", "/api/imports"))
+	assert.Equal(t, scopeUpload, requiredScope("POST", "/api/upload-streams/5/batches"))
+	assert.Equal(t, scopeRead, requiredScope("GET", "/api/upload-streams/5"))
+	assert.Equal(t, scopeAdmin, requiredScope("GET", "/api/admin/config"))
+
+	assert.True(t, apiKeyScopes{scopeAdmin}.allows(scopeUpload))
+	assert.False(t, apiKeyScopes{scopeRead, scopeWrite}.allows(scopeUpload))
+}
+
+// TestAPIKeyMiddleware tests authenticating requests by API key, with scopes, revocation and upload quotas
+func TestAPIKeyMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previousAuth, previousKeys := auth, apiKeys
+	defer func() { auth, apiKeys = previousAuth, previousKeys }()
+	auth = newJWTAuthenticator(AuthConfig{JWTSecret: strings.Repeat("s", 32), TokenTTL: Duration(time.Minute)})
+	store := NewMockAPIKeyStore(ctrl)
+	apiKeys = store
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(authMiddleware())
+	client := func(c *gin.Context) { c.String(200, clientKey(c)) }
+	r.GET("/api/records", client)
+	r.POST("/upload-csv", client)
+	r.GET("/api/admin/config", client)
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(apiKeyHeader, key)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	recent := time.Now()
+	uploader := &APIKey{ID: 3, Scopes: apiKeyScopes{scopeRead, scopeUpload}, UploadQuotaBytes: 10, LastUsedAt: &recent}
+	store.EXPECT().Find(hashAPIKey("mpk_uploader")).Return(uploader, nil).AnyTimes()
+	store.EXPECT().Find(hashAPIKey("mpk_unknown")).Return(nil, gorm.ErrRecordNotFound)
+	store.EXPECT().Find(hashAPIKey("mpk_revoked")).Return(&APIKey{ID: 4, Scopes: apiKeyScopes{scopeAdmin}, RevokedAt: &recent}, nil)
+	store.EXPECT().Find(hashAPIKey("mpk_new")).Return(&APIKey{ID: 5, Scopes: apiKeyScopes{scopeRead}}, nil)
+	store.EXPECT().Touch(uint(5), gomock.Any()).Return(nil)
+
+	// Keys are identified as clients; recently used keys aren't touched again
+	w := send("GET", "/api/records", "mpk_uploader", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "key:3", w.Body.String())
+	assert.Equal(t, 200, send("GET", "/api/records", "mpk_new", "").Code)
+
+	assert.Equal(t, 401, send("GET", "/api/records", "mpk_unknown", "").Code)
+	assert.Equal(t, 401, send("GET", "/api/records", "mpk_revoked", "").Code)
+	w = send("GET", "/api/admin/config", "mpk_uploader", "")
+	assert.Equal(t, 403, w.Code)
+	assert.Contains(t, w.Body.String(), "requires the admin scope")
+
+	// Uploads are charged until the daily quota is used up
+	gomock.InOrder(
+		store.EXPECT().ChargeUpload(uint(3), int64(8)).Return(true, nil),
+		store.EXPECT().ChargeUpload(uint(3), int64(8)).Return(false, nil),
+	)
+	assert.Equal(t, 200, send("POST", "/upload-csv", "mpk_uploader", "a,b\n1,2\n").Code)
+	assert.Equal(t, 429, send("POST", "/upload-csv", "mpk_uploader", "a,b\n1,2\n").Code)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/upload-csv", strings.NewReader("a,b\n"))
+	req.ContentLength = -1
+	req.Header.Set(apiKeyHeader, "mpk_uploader")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 411, w.Code)
+
+	// Without a key, tokens are still required
+	assert.Equal(t, 401, send("GET", "/api/records", "", "").Code)
+}
+
+// TestAPIKeyAdmin tests creating, listing and revoking keys
+func TestAPIKeyAdmin(t *testing.T) {
+	disableAuth(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := apiKeys
+	defer func() { apiKeys = previous }()
+	store := NewMockAPIKeyStore(ctrl)
+	apiKeys = store
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	var created *APIKey
+	store.EXPECT().Create(gomock.Any()).DoAndReturn(func(key *APIKey) error {
+		key.ID = 1
+		created = key
+		return nil
+	})
+	w := serveRecord(r, "POST", "/api/admin/api-keys", `{"name":"nightly sync","scopes":["upload","read","upload"],"upload_quota_bytes":1048576}`)
+	assert.Equal(t, 201, w.Code)
+	var resp struct {
+		Data struct {
+			ID     uint     `json:"id"`
+			Key    string   `json:"key"`
+			Prefix string   `json:"prefix"`
+			Scopes []string `json:"scopes"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, strings.HasPrefix(resp.Data.Key, apiKeyPrefix))
+	assert.Equal(t, resp.Data.Key[:apiKeyPrefixLength], resp.Data.Prefix)
+	assert.Equal(t, []string{scopeRead, scopeUpload}, resp.Data.Scopes)
+	assert.Equal(t, hashAPIKey(resp.Data.Key), created.KeyHash)
+	assert.NotContains(t, w.Body.String(), created.KeyHash)
+
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/admin/api-keys", `{"name":"x","scopes":["delete"]}`).Code)
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/admin/api-keys", `{"name":"x","scopes":[]}`).Code)
+
+	store.EXPECT().List().Return(nil, nil)
+	w = serveRecord(r, "GET", "/api/admin/api-keys", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":[]`)
+
+	revoked := time.Now()
+	store.EXPECT().Revoke(uint(1)).Return(&APIKey{ID: 1, Name: "nightly sync", RevokedAt: &revoked}, nil)
+	store.EXPECT().Revoke(uint(2)).Return(nil, gorm.ErrRecordNotFound)
+	assert.Equal(t, 200, serveRecord(r, "POST", "/api/admin/api-keys/1/revoke", "").Code)
+	assert.Equal(t, 404, serveRecord(r, "POST", "/api/admin/api-keys/2/revoke", "").Code)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Roles, each allowed everything the previous one is
+const (
+	roleReader   = "reader"   // Reads records, stats and import status
+	roleUploader = "uploader" // Also uploads files and changes records
+	roleAdmin    = "admin"    // Also uses the admin endpoints and manages users
+)
+
+// roleRanks orders the roles by what they are allowed
+var roleRanks = map[string]int{roleReader: 1, roleUploader: 2, roleAdmin: 3}
+
+// Token types; refresh tokens are only accepted by the refresh endpoint
+const (
+	tokenAccess  = "access"
+	tokenRefresh = "refresh"
+)
+
+// authContextKey is the gin context key of the authenticated user's claims
+const authContextKey = "auth"
+
+// AuthUser is an account that can obtain tokens, stored in the users table
+type AuthUser struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username     string    `gorm:"size:100;uniqueIndex" json:"username"`
+	PasswordHash string    `gorm:"size:100" json:"-"`
+	Role         string    `gorm:"size:20" json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the name of the table in the database
+func (AuthUser) TableName() string {
+	return "users"
+}
+
+// authClaims are the claims of the service's tokens; the subject is the username
+type authClaims struct {
+	Role string `json:"role"`
+	Type string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// jwtAuthenticator issues and verifies the HMAC-signed tokens of the service
+type jwtAuthenticator struct {
+	secret     []byte
+	tokenTTL   time.Duration
+	refreshTTL time.Duration
+}
+
+// Global token authenticator, set at startup when a JWT secret is configured; nil disables tokens
+var auth *jwtAuthenticator
+
+// newJWTAuthenticator creates a token authenticator from the configuration, or returns nil if no secret is set
+func newJWTAuthenticator(config AuthConfig) *jwtAuthenticator {
+	if config.JWTSecret == "" {
+		return nil
+	}
+	return &jwtAuthenticator{secret: []byte(config.JWTSecret), tokenTTL: time.Duration(config.TokenTTL), refreshTTL: time.Duration(config.RefreshTTL)}
+}
+
+// sign creates a token of the given type for the user
+func (a *jwtAuthenticator) sign(user AuthUser, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := authClaims{
+		Role: user.Role,
+		Type: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.secret)
+}
+
+// issue creates an access and a refresh token for the user
+func (a *jwtAuthenticator) issue(user AuthUser) (gin.H, error) {
+	access, err := a.sign(user, tokenAccess, a.tokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := a.sign(user, tokenRefresh, a.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    int(a.tokenTTL.Seconds()),
+		"role":          user.Role,
+	}, nil
+}
+
+// verify checks the signature, expiry and type of a token and returns its claims
+func (a *jwtAuthenticator) verify(token, tokenType string) (*authClaims, error) {
+	claims := &authClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != tokenType {
+		return nil, fmt.Errorf("expected an %s token", tokenType)
+	}
+	return claims, nil
+}
+
+// requiredRole returns the role a request needs: the admin endpoints and logs need admin, other writes
+// need uploader, and reads and saved filters need reader. Token endpoints, the API docs and OPTIONS are open.
+func requiredRole(method, path string) string {
+	switch {
+	case method == http.MethodOptions || strings.HasPrefix(path, "/api/auth/") || strings.HasPrefix(path, "/swagger/") || probePaths[path]:
+		return ""
+	case strings.HasPrefix(path, "/api/admin/") || path == "/api/logs":
+		return roleAdmin
+	case path == savedFiltersPath || strings.HasPrefix(path, savedFiltersPath+"/"):
+		return roleReader // Saved filters only concern their owner, so readers may save theirs
+	case isWriteMethod(method):
+		return roleUploader
+	default:
+		return roleReader
+	}
+}
+
+// Authenticate checks the Bearer access token of a request for a route needing role
+func (a *jwtAuthenticator) Authenticate(c *gin.Context, role string) (*Principal, error) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return nil, nil
+	}
+	claims, err := a.verify(token, tokenAccess)
+	if err != nil {
+		return nil, &AuthError{Code: 401, Message: "Invalid token", Details: err.Error(), Challenge: `Bearer error="invalid_token"`}
+	}
+	if err := requireRole(claims.Role, role); err != nil {
+		return nil, err
+	}
+	c.Set(authContextKey, claims)
+	return &Principal{Subject: "user:" + claims.Subject, Role: claims.Role}, nil
+}
+
+// challenge requires a token on every protected route while tokens are enabled
+func (a *jwtAuthenticator) challenge() *AuthError {
+	return &AuthError{Code: 401, Message: "Authentication required", Details: "send an access token from POST /api/auth/token as a Bearer token", Challenge: "Bearer"}
+}
+
+// findUser loads the account with the given username
+func findUser(db Database, username string) (*AuthUser, error) {
+	var user AuthUser
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// issueToken handles POST /api/auth/token, exchanging a username and password for tokens
+func issueToken(c *gin.Context, db Database) {
+	if auth == nil {
+		respondError(c, 404, "Authentication is disabled")
+		return
+	}
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid token request", err.Error())
+		return
+	}
+
+	user, err := findUser(db, req.Username)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.WithError(err).Error("Failed to load user")
+		respondError(c, 500, "Failed to load user")
+		return
+	}
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		log.WithField("username", req.Username).Warn("Failed login")
+		respondError(c, 401, "Invalid username or password")
+		return
+	}
+
+	tokens, err := auth.issue(*user)
+	if err != nil {
+		log.WithError(err).Error("Failed to sign token")
+		respondError(c, 500, "Failed to sign token")
+		return
+	}
+	respond(c, 200, tokens, nil)
+}
+
+// refreshToken handles POST /api/auth/refresh, exchanging a refresh token for new tokens.
+// The user is loaded again, so deleted users and role changes take effect on refresh.
+func refreshToken(c *gin.Context, db Database) {
+	if auth == nil {
+		respondError(c, 404, "Authentication is disabled")
+		return
+	}
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid refresh request", err.Error())
+		return
+	}
+
+	claims, err := auth.verify(req.RefreshToken, tokenRefresh)
+	if err != nil {
+		respondError(c, 401, "Invalid refresh token", err.Error())
+		return
+	}
+	user, err := findUser(db, claims.Subject)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 401, "Invalid refresh token", "the user no longer exists")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to load user")
+		respondError(c, 500, "Failed to load user")
+		return
+	}
+
+	tokens, err := auth.issue(*user)
+	if err != nil {
+		log.WithError(err).Error("Failed to sign token")
+		respondError(c, 500, "Failed to sign token")
+		return
+	}
+	respond(c, 200, tokens, nil)
+}
+
+// newAuthUser creates an account with a bcrypt hash of the password
+func newAuthUser(username, password, role string) (*AuthUser, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthUser{Username: username, PasswordHash: string(hash), Role: role}, nil
+}
+
+// createUser handles POST /api/admin/users, adding an account with a role
+func createUser(c *gin.Context, db Database) {
+	var req struct {
+		Username string `json:"username" binding:"required,max=100"`
+		Password string `json:"password" binding:"required,min=8,max=72"`
+		Role     string `json:"role" binding:"required,oneof=reader uploader admin"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid user", err.Error())
+		return
+	}
+
+	user, err := newAuthUser(req.Username, req.Password, req.Role)
+	if err != nil {
+		log.WithError(err).Error("Failed to hash password")
+		respondError(c, 500, "Failed to create user")
+		return
+	}
+	if err := db.Create(user).Error; err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	log.WithFields(map[string]interface{}{"username": user.Username, "role": user.Role}).Info("User created")
+	respond(c, 201, user, nil)
+}
+
+// ensureAdminUser creates the configured admin account at startup if it doesn't exist yet,
+// so the first tokens can be issued on a fresh database
+func ensureAdminUser(db Database, username, password string) error {
+	if username == "" {
+		return nil
+	}
+	_, err := findUser(db, username)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	user, err := newAuthUser(username, password, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if err := db.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create admin user: %w", err)
+	}
+	log.WithField("username", username).Info("Admin user created")
+	return nil
+}
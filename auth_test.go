@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// TestRequiredRole tests the role each kind of request needs
+func TestRequiredRole(t *testing.T) {
+	assert.Equal(t, "", requiredRole("POST", "/api/auth/token"))
+	assert.Equal(t, "", requiredRole("OPTIONS", "/api/records"))
+	assert.Equal(t, roleReader, requiredRole("GET", "/api/records"))
+	assert.Equal(t, roleReader, requiredRole("HEAD", "/api/records"))
+	assert.Equal(t, roleUploader, requiredRole("POST", "/upload-csv"))
+	assert.Equal(t, roleUploader, requiredRole("DELETE", "/api/records/7"))
+	assert.Equal(t, roleReader, requiredRole("PUT", "/api/saved-filters/my_team"))
+	assert.Equal(t, roleAdmin, requiredRole("GET", "/api/admin/config"))
+	assert.Equal(t, roleAdmin, requiredRole("POST", "/api/admin/users"))
+	assert.Equal(t, roleAdmin, requiredRole("GET", "/api/logs"))
+}
+
+// sendAuth sends a request with an optional bearer token and JSON body
+func sendAuth(r *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// tokensFrom returns the access and refresh token of a token response
+func tokensFrom(t *testing.T, w *httptest.ResponseRecorder) (string, string) {
+	var resp struct {
+		Data struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data.AccessToken, resp.Data.RefreshToken
+}
+
+// TestAuthFlow tests issuing, using and refreshing tokens with role checks
+func TestAuthFlow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	auth = newJWTAuthenticator(AuthConfig{JWTSecret: strings.Repeat("s", 32), TokenTTL: Duration(time.Minute), RefreshTTL: Duration(time.Hour)})
+	defer func() { auth = nil }()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	stored := AuthUser{ID: 1, Username: "rita", PasswordHash: string(hash), Role: roleReader}
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Where("username = ?", gomock.Any()).Return(mockDB).AnyTimes()
+	mockDB.EXPECT().First(gomock.Any()).DoAndReturn(func(dest interface{}, conds ...interface{}) *gorm.DB {
+		*dest.(*AuthUser) = stored
+		return &gorm.DB{}
+	}).AnyTimes()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(authMiddleware())
+	r.POST("/api/auth/token", func(c *gin.Context) { issueToken(c, mockDB) })
+	r.POST("/api/auth/refresh", func(c *gin.Context) { refreshToken(c, mockDB) })
+	r.GET("/api/records", func(c *gin.Context) { respond(c, 200, []UserDatas{}, nil) })
+	r.POST("/api/records", func(c *gin.Context) { respond(c, 201, nil, nil) })
+
+	w := sendAuth(r, "GET", "/api/records", "", "")
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	w = sendAuth(r, "POST", "/api/auth/token", "", `{"username":"rita","password":"wrong"}`)
+	assert.Equal(t, 401, w.Code)
+
+	w = sendAuth(r, "POST", "/api/auth/token", "", `{"username":"rita","password":"correct horse"}`)
+	assert.Equal(t, 200, w.Code)
+	access, refresh := tokensFrom(t, w)
+
+	// Readers may read but not write
+	assert.Equal(t, 200, sendAuth(r, "GET", "/api/records", access, "").Code)
+	assert.Equal(t, 403, sendAuth(r, "POST", "/api/records", access, "{}").Code)
+
+	// Refresh tokens are only accepted for refreshing, and pick up role changes
+	assert.Equal(t, 401, sendAuth(r, "GET", "/api/records", refresh, "").Code)
+	stored.Role = roleUploader
+	w = sendAuth(r, "POST", "/api/auth/refresh", "", `{"refresh_token":"`+refresh+`"}`)
+	assert.Equal(t, 200, w.Code)
+	access, _ = tokensFrom(t, w)
+	assert.Equal(t, 201, sendAuth(r, "POST", "/api/records", access, "{}").Code)
+	assert.Equal(t, 401, sendAuth(r, "POST", "/api/auth/refresh", "", `{"refresh_token":"`+access+`"}`).Code)
+
+	// Expired and forged tokens are rejected
+	expired, _ := auth.sign(stored, tokenAccess, -time.Minute)
+	assert.Equal(t, 401, sendAuth(r, "GET", "/api/records", expired, "").Code)
+	forged, _ := (&jwtAuthenticator{secret: []byte(strings.Repeat("x", 32))}).sign(stored, tokenAccess, time.Minute)
+	assert.Equal(t, 401, sendAuth(r, "GET", "/api/records", forged, "").Code)
+}
+
+// TestCreateUser tests adding accounts with a hashed password
+func TestCreateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Create(gomock.Any()).DoAndReturn(func(value interface{}) *gorm.DB {
+		user := value.(*AuthUser)
+		assert.Equal(t, roleUploader, user.Role)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("long enough")))
+		user.ID = 3
+		return &gorm.DB{}
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/admin/users", func(c *gin.Context) { createUser(c, mockDB) })
+
+	w := sendAuth(r, "POST", "/api/admin/users", "", `{"username":"ulla","password":"long enough","role":"uploader"}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":3`)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	w = sendAuth(r, "POST", "/api/admin/users", "", `{"username":"ulla","password":"long enough","role":"owner"}`)
+	assert.Equal(t, 400, w.Code)
+	w = sendAuth(r, "POST", "/api/admin/users", "", `{"username":"ulla","password":"short","role":"reader"}`)
+	assert.Equal(t, 400, w.Code)
+}
+
+// TestEnsureAdminUser tests that the configured admin account is only created when missing
+func TestEnsureAdminUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Where("username = ?", "root").Return(mockDB).Times(2)
+	mockDB.EXPECT().First(gomock.Any()).Return(&gorm.DB{})
+	assert.NoError(t, ensureAdminUser(mockDB, "root", "long enough"))
+
+	mockDB.EXPECT().First(gomock.Any()).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+	mockDB.EXPECT().Create(gomock.Any()).DoAndReturn(func(value interface{}) *gorm.DB {
+		assert.Equal(t, roleAdmin, value.(*AuthUser).Role)
+		return &gorm.DB{}
+	})
+	assert.NoError(t, ensureAdminUser(mockDB, "root", "long enough"))
+
+	assert.NoError(t, ensureAdminUser(mockDB, "", ""))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalContextKey is the gin context key of the authenticated principal
+const principalContextKey = "principal"
+
+// Principal is the authenticated client of a request
+type Principal struct {
+	Subject string // Identifies the client in rate limits and audit logs, e.g. "user:jane" or "key:7"
+	Role    string // Role granted by the credentials, empty when they grant scopes instead
+}
+
+// AuthError rejects a request with its status code, e.g. 401 for invalid credentials or 403 for an
+// insufficient role. Challenge is sent in the WWW-Authenticate header.
+type AuthError struct {
+	Code      int
+	Message   string
+	Details   string
+	Challenge string
+}
+
+// Error describes the rejection
+func (e *AuthError) Error() string {
+	if e.Details == "" {
+		return e.Message
+	}
+	return e.Message + ": " + e.Details
+}
+
+// Authenticator checks one kind of credentials, such as tokens, API keys, client certificates or the
+// identity headers of a gateway. Authenticate returns nil and no error when the request carries none of
+// its credentials, so the next authenticator is tried. Otherwise it returns the principal when the
+// credentials are valid and allow a route needing role, or an error rejecting the request, preferably
+// an *AuthError; other errors are answered with 401.
+type Authenticator interface {
+	Authenticate(c *gin.Context, role string) (*Principal, error)
+}
+
+// challenger is implemented by authenticators that require protected requests to be authenticated.
+// Requests without credentials are rejected with the challenge of the first one; while none is in use,
+// they are let through.
+type challenger interface {
+	challenge() *AuthError
+}
+
+// Authenticators added to the built-in API keys and tokens with registerAuthenticator
+var (
+	authenticatorsMu     sync.RWMutex
+	customAuthenticators []Authenticator
+)
+
+// registerAuthenticator adds an authenticator, tried after the built-in ones in registration order.
+// Deployments register theirs from an init function, e.g. one trusting the user and role headers set by
+// a gateway, which must then strip those headers from client requests.
+func registerAuthenticator(authenticator Authenticator) {
+	authenticatorsMu.Lock()
+	defer authenticatorsMu.Unlock()
+	customAuthenticators = append(customAuthenticators, authenticator)
+}
+
+// authenticators returns the authenticators in use, in the order they are tried: client certificates
+// first, so in required mode no other credentials are accepted
+func authenticators() []Authenticator {
+	var chain []Authenticator
+	if clientCerts != nil {
+		chain = append(chain, clientCerts)
+	}
+	if apiKeys != nil {
+		chain = append(chain, &apiKeyAuthenticator{store: apiKeys})
+	}
+	if auth != nil {
+		chain = append(chain, auth)
+	}
+	authenticatorsMu.RLock()
+	defer authenticatorsMu.RUnlock()
+	return append(chain, customAuthenticators...)
+}
+
+// requireRole returns a 403 AuthError when the granted role is below the required one
+func requireRole(granted, required string) error {
+	if roleRanks[granted] < roleRanks[required] {
+		return &AuthError{Code: 403, Message: "Forbidden", Details: fmt.Sprintf("requires the %s role", required)}
+	}
+	return nil
+}
+
+// authMiddleware authenticates every protected route with the first authenticator finding its
+// credentials in the request, and records the principal for rate limits and audit logs. Requests
+// without credentials are rejected while an authenticator requires them, e.g. once tokens are enabled.
+// Otherwise they may only read, unless authentication is disabled explicitly.
+func authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := requiredRole(c.Request.Method, c.Request.URL.Path)
+		if role == "" {
+			c.Next()
+			return
+		}
+
+		chain := authenticators()
+		for _, authenticator := range chain {
+			principal, err := authenticator.Authenticate(c, role)
+			if err != nil {
+				rejectUnauthenticated(c, err)
+				return
+			}
+			if principal != nil {
+				c.Set(principalContextKey, principal)
+				c.Next()
+				return
+			}
+		}
+		for _, authenticator := range chain {
+			if required, ok := authenticator.(challenger); ok {
+				rejectUnauthenticated(c, required.challenge())
+				return
+			}
+		}
+		if role != roleReader && !appConfig.Auth.Disabled {
+			rejectUnauthenticated(c, &AuthError{Code: 401, Message: "Authentication required",
+				Details: fmt.Sprintf("requires the %s role; configure AUTH_JWT_SECRET or another authenticator, or set AUTH_DISABLED=true", role)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// rejectUnauthenticated answers a request an authenticator rejected
+func rejectUnauthenticated(c *gin.Context, err error) {
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		authErr = &AuthError{Code: 401, Message: "Authentication failed", Details: err.Error()}
+	}
+	if authErr.Challenge != "" {
+		c.Header("WWW-Authenticate", authErr.Challenge)
+	}
+	respondError(c, authErr.Code, authErr.Message, authErr.Details)
+	c.Abort()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatewayAuthenticator trusts the user and role headers set by a gateway, as a deployment would plug in
+type gatewayAuthenticator struct{}
+
+func (a *gatewayAuthenticator) Authenticate(c *gin.Context, role string) (*Principal, error) {
+	user := c.GetHeader("X-Gateway-User")
+	if user == "" {
+		return nil, nil
+	}
+	granted := c.GetHeader("X-Gateway-Role")
+	if err := requireRole(granted, role); err != nil {
+		return nil, err
+	}
+	return &Principal{Subject: "gateway:" + user, Role: granted}, nil
+}
+
+// requiredGatewayAuthenticator also rejects requests that didn't pass the gateway
+type requiredGatewayAuthenticator struct {
+	gatewayAuthenticator
+}
+
+func (a *requiredGatewayAuthenticator) challenge() *AuthError {
+	return &AuthError{Code: 401, Message: "Authentication required", Details: "sign in through the gateway"}
+}
+
+// disableAuth lets requests without credentials write and administer, for tests of the handlers behind setupAPI
+func disableAuth(t *testing.T) {
+	previous := appConfig.Auth.Disabled
+	t.Cleanup(func() { appConfig.Auth.Disabled = previous })
+	appConfig.Auth.Disabled = true
+}
+
+// TestCustomAuthenticator tests that a registered authenticator authenticates requests, and only closes
+// the API when it requires credentials
+func TestCustomAuthenticator(t *testing.T) {
+	previous := customAuthenticators
+	defer func() { customAuthenticators = previous }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(authMiddleware())
+	r.GET("/api/records", func(c *gin.Context) { respond(c, 200, clientKey(c), nil) })
+	r.POST("/api/records", func(c *gin.Context) { respond(c, 201, clientKey(c), nil) })
+	send := func(method, user, role string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/records", nil)
+		if user != "" {
+			req.Header.Set("X-Gateway-User", user)
+			req.Header.Set("X-Gateway-Role", role)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	registerAuthenticator(&gatewayAuthenticator{})
+	w := send("GET", "jane", roleReader)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":"gateway:jane"`)
+	w = send("POST", "jane", roleReader)
+	assert.Equal(t, 403, w.Code)
+	assert.Contains(t, w.Body.String(), "requires the uploader role")
+	assert.Equal(t, 201, send("POST", "jane", roleUploader).Code)
+
+	// Without credentials the API may only be read until an authenticator requires them, unless
+	// authentication is disabled
+	assert.Equal(t, 200, send("GET", "", "").Code)
+	w = send("POST", "", "")
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Body.String(), "AUTH_DISABLED=true")
+	disableAuth(t)
+	assert.Equal(t, 201, send("POST", "", "").Code)
+	appConfig.Auth.Disabled = false
+	customAuthenticators = previous
+	registerAuthenticator(&requiredGatewayAuthenticator{})
+	w = send("GET", "", "")
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Body.String(), "sign in through the gateway")
+	assert.Equal(t, 200, send("GET", "jane", roleAdmin).Code)
+}
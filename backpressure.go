@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// readerStallThreshold is how long the CSV reader may wait on a full channel before it is logged as stalled
+const readerStallThreshold = time.Second
+
+// readerStats records how long the CSV reader waited on downstream backpressure
+type readerStats struct {
+	mu        sync.Mutex
+	chunks    int
+	waits     int
+	stalls    int
+	totalWait time.Duration
+	maxWait   time.Duration
+	err       error         // Error that stopped the reader early
+	unmapped  []string      // Header columns whose values aren't imported
+	entry     *logrus.Entry // Logs the reader's messages with the fields of its import
+}
+
+// logger returns the entry the reader logs with
+func (s *readerStats) logger() *logrus.Entry {
+	if s.entry == nil {
+		return logrus.NewEntry(log)
+	}
+	return s.entry
+}
+
+// record adds one chunk hand-off that waited for wait
+func (s *readerStats) record(wait time.Duration, stalled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chunks++
+	if wait > 0 {
+		s.waits++
+		s.totalWait += wait
+	}
+	if stalled {
+		s.stalls++
+	}
+	if wait > s.maxWait {
+		s.maxWait = wait
+	}
+}
+
+// fail records the error that stopped the reader
+func (s *readerStats) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// skipColumns records the header columns the reader doesn't import
+func (s *readerStats) skipColumns(columns []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unmapped = columns
+}
+
+// unmappedColumns returns the header columns the reader didn't import
+func (s *readerStats) unmappedColumns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unmapped
+}
+
+// Err returns the error that stopped the reader, or nil if it read the whole file
+func (s *readerStats) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// metrics returns the recorded wait-time metrics for logs and responses
+func (s *readerStats) metrics() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]interface{}{
+		"chunks_read":        s.chunks,
+		"reader_waits":       s.waits,
+		"reader_stalls":      s.stalls,
+		"reader_wait_ms":     s.totalWait.Milliseconds(),
+		"reader_max_wait_ms": s.maxWait.Milliseconds(),
+	}
+}
+
+// sendChunk hands a chunk to the workers, blocking (and so pausing reading) while the channel is full
+func sendChunk(ch chan<- csvChunk, chunk csvChunk, stats *readerStats) {
+	select {
+	case ch <- chunk:
+		stats.record(0, false)
+		return
+	default:
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(readerStallThreshold)
+	defer timer.Stop()
+
+	stalled := false
+	select {
+	case ch <- chunk:
+	case <-timer.C:
+		stalled = true
+		stats.logger().WithField("threshold", readerStallThreshold.String()).Warn("CSV reader paused: workers are not keeping up")
+		ch <- chunk
+	}
+	stats.record(time.Since(start), stalled)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSendChunkRecordsWait tests that a full channel blocks the reader and the wait is recorded
+func TestSendChunkRecordsWait(t *testing.T) {
+	ch := make(chan csvChunk, 1)
+	stats := &readerStats{}
+
+	// Room in the channel: no wait
+	sendChunk(ch, csvChunk{records: [][]string{{"a"}}, lines: []int{2}}, stats)
+
+	// Full channel: the reader waits until a worker takes a chunk
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		<-ch
+	}()
+	sendChunk(ch, csvChunk{records: [][]string{{"b"}}, lines: []int{3}}, stats)
+
+	metrics := stats.metrics()
+	assert.Equal(t, 2, metrics["chunks_read"])
+	assert.Equal(t, 1, metrics["reader_waits"])
+	assert.Equal(t, 0, metrics["reader_stalls"])
+	assert.GreaterOrEqual(t, metrics["reader_wait_ms"], int64(40))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// rejectedRow is a row that could not be inserted, with its position in the batch and the error of its insert
+type rejectedRow struct {
+	index int
+	user  UserData
+	err   error
+}
+
+// isRowError reports whether err was caused by the data of a row rather than the database,
+// i.e. a PostgreSQL cardinality violation (class 21, e.g. an upsert batch repeating an email),
+// data exception (class 22, e.g. an overlong value) or integrity constraint violation
+// (class 23, e.g. a duplicate key)
+func isRowError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, "21") || strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+}
+
+// insertBisecting inserts users, splitting a batch that fails on bad row data in halves
+// and retrying each, so only the offending rows are rejected instead of the whole batch.
+// Batches failing for any other reason are rejected as a whole without retrying.
+// It returns the number of inserted rows and the rejected rows.
+func insertBisecting(sink Sink, users []UserData) (int, []rejectedRow) {
+	return insertBisectingFrom(sink, users, 0)
+}
+
+// insertBisectingFrom inserts users, the part of the whole batch starting at offset
+func insertBisectingFrom(sink Sink, users []UserData, offset int) (int, []rejectedRow) {
+	err := sink.Write(users)
+	if err == nil {
+		return len(users), nil
+	}
+
+	if len(users) == 1 || !isRowError(err) {
+		rejected := make([]rejectedRow, len(users))
+		for i, user := range users {
+			rejected[i] = rejectedRow{index: offset + i, user: user, err: err}
+		}
+		return 0, rejected
+	}
+
+	mid := len(users) / 2
+	leftInserted, leftRejected := insertBisectingFrom(sink, users[:mid], offset)
+	rightInserted, rightRejected := insertBisectingFrom(sink, users[mid:], offset+mid)
+	return leftInserted + rightInserted, append(leftRejected, rightRejected...)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInsertBisecting tests that only the rows violating constraints are rejected
+func TestInsertBisecting(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Fail any batch containing a "Bad" row, like a duplicate key would
+	var inserted []string
+	mockDBHandler := NewMockDBHandler(ctrl)
+	mockDBHandler.EXPECT().CreateInBatches(gomock.Any(), gomock.Any()).DoAndReturn(func(value interface{}, batchSize int) error {
+		users := value.([]UserData)
+		for _, user := range users {
+			if user.FirstName == "Bad" {
+				return &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}
+			}
+		}
+		for _, user := range users {
+			inserted = append(inserted, user.FirstName)
+		}
+		return nil
+	}).AnyTimes()
+
+	users := []UserData{{FirstName: "A"}, {FirstName: "B"}, {FirstName: "Bad"}, {FirstName: "C"}, {FirstName: "D"}}
+	count, rejected := insertBisecting(&postgresSink{dbHandler: mockDBHandler, batchSize: 100}, users)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, []string{"A", "B", "C", "D"}, inserted)
+	assert.Len(t, rejected, 1)
+	assert.Equal(t, "Bad", rejected[0].user.FirstName)
+	assert.Equal(t, 2, rejected[0].index)
+	assert.True(t, isRowError(rejected[0].err))
+}
+
+// TestInsertBisectingDatabaseError tests that failures unrelated to the rows are not retried
+func TestInsertBisectingDatabaseError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDBHandler := NewMockDBHandler(ctrl)
+	mockDBHandler.EXPECT().CreateInBatches(gomock.Any(), gomock.Any()).Return(errors.New("connection refused")).Times(1)
+
+	count, rejected := insertBisecting(&postgresSink{dbHandler: mockDBHandler, batchSize: 100}, []UserData{{FirstName: "A"}, {FirstName: "B"}})
+	assert.Equal(t, 0, count)
+	assert.Len(t, rejected, 2)
+	assert.False(t, isRowError(rejected[0].err))
+	assert.True(t, isRowError(&pgconn.PgError{Code: "22001"}))
+	assert.True(t, isRowError(&pgconn.PgError{Code: "21000"}))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// Maximum bind parameters per statement by GORM dialector name
+var maxBindParams = map[string]int{
+	"postgres":  65535,
+	"mysql":     65535,
+	"sqlite":    32766,
+	"sqlserver": 2100,
+}
+
+// defaultMaxBindParams is used for dialects not listed in maxBindParams
+const defaultMaxBindParams = 999
+
+// insertColumnCount returns the number of columns GORM binds per inserted row of model.
+// Auto-increment primary keys are excluded since they are left for the database to fill.
+func insertColumnCount(model interface{}, namer schema.Namer) (int, error) {
+	s, err := schema.Parse(model, &sync.Map{}, namer)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, field := range s.Fields {
+		if field.DBName == "" || !field.Creatable {
+			continue
+		}
+		if field.PrimaryKey && field.AutoIncrement {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// safeBatchSize clamps the requested rows per INSERT so rows*columns stays within the parameter limit
+func safeBatchSize(requested, columns int, dialect string) int {
+	limit, ok := maxBindParams[dialect]
+	if !ok {
+		limit = defaultMaxBindParams
+	}
+	if columns < 1 {
+		return requested
+	}
+
+	maxRows := limit / columns
+	if maxRows < 1 {
+		maxRows = 1
+	}
+	if requested < 1 || requested > maxRows {
+		return maxRows
+	}
+	return requested
+}
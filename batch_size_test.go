@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/schema"
+)
+
+// TestInsertColumnCount tests that the auto-increment ID is not counted as a bound column
+// while the embedded provenance columns are
+func TestInsertColumnCount(t *testing.T) {
+	columns, err := insertColumnCount(&UserData{}, schema.NamingStrategy{})
+	assert.NoError(t, err)
+	assert.Equal(t, 13, columns)
+}
+
+// TestSafeBatchSize tests clamping batch sizes to the dialect parameter limit
+func TestSafeBatchSize(t *testing.T) {
+	// 10000 rows x 11 columns would exceed Postgres's 65535 parameters
+	assert.Equal(t, 5957, safeBatchSize(10000, 11, "postgres"))
+	assert.Equal(t, 500, safeBatchSize(500, 11, "postgres"))
+	assert.Equal(t, 190, safeBatchSize(10000, 11, "sqlserver"))
+	assert.Equal(t, 90, safeBatchSize(10000, 11, "unknown"))
+	assert.Equal(t, 5957, safeBatchSize(0, 11, "postgres"))
+}
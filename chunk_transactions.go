@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// importMaxCommittedRanges is the number of committed line ranges kept per import for the report
+const importMaxCommittedRanges = 100000
+
+// lineRange is a range of consecutive file lines
+type lineRange struct {
+	FirstLine int `json:"first_line"`
+	LastLine  int `json:"last_line"`
+}
+
+// insertChunk inserts the users of a chunk in one transaction when the sink supports it. Batches failing on
+// bad rows are bisected within the transaction, each attempt in a savepoint, so the valid rows are committed
+// together. When the database fails instead, e.g. because the connection was lost, the whole chunk is rolled
+// back and every row rejected, so no chunk is left partly written. It returns the number of inserted rows and
+// the rejected rows, like insertBisecting.
+func insertChunk(sink Sink, users []UserData) (int, []rejectedRow) {
+	transactional, ok := sink.(transactionalSink)
+	if !ok {
+		return insertBisecting(sink, users)
+	}
+
+	var inserted int
+	var rejected []rejectedRow
+	err := transactional.Transaction(func(tx Sink) error {
+		inserted, rejected = insertBisecting(tx, users)
+		for _, row := range rejected {
+			if !isRowError(row.err) {
+				return row.err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return inserted, rejected
+	}
+
+	rolledBack := make([]rejectedRow, len(users))
+	for i, user := range users {
+		rolledBack[i] = rejectedRow{index: i, user: user, err: fmt.Errorf("chunk rolled back: %w", err)}
+	}
+	return 0, rolledBack
+}
+
+// recordCommitted records the file lines of the written users that weren't rejected, as ranges of consecutive lines
+func (p *importProgress) recordCommitted(lines []int, rejected []rejectedRow) {
+	if p == nil {
+		return
+	}
+	skip := make(map[int]bool, len(rejected))
+	for _, row := range rejected {
+		skip[row.index] = true
+	}
+
+	var ranges []lineRange
+	for i, line := range lines {
+		if skip[i] {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].LastLine+1 == line {
+			ranges[n-1].LastLine = line
+			continue
+		}
+		ranges = append(ranges, lineRange{FirstLine: line, LastLine: line})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range ranges {
+		if len(p.committed) >= importMaxCommittedRanges {
+			p.committedDropped += r.LastLine - r.FirstLine + 1
+			continue
+		}
+		p.committed = append(p.committed, r)
+	}
+}
+
+// committedRanges returns the committed line ranges sorted by line, joining those of consecutive chunks,
+// and how many committed rows weren't kept
+func (p *importProgress) committedRanges() ([]lineRange, int) {
+	if p == nil {
+		return []lineRange{}, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sorted := append([]lineRange{}, p.committed...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FirstLine < sorted[j].FirstLine })
+	ranges := []lineRange{}
+	for _, r := range sorted {
+		if n := len(ranges); n > 0 && ranges[n-1].LastLine+1 == r.FirstLine {
+			ranges[n-1].LastLine = r.LastLine
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, p.committedDropped
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectTransactions lets the mock run transactions and savepoints by calling their function with itself
+func expectTransactions(mockDBHandler *MockDBHandler) *gomock.Call {
+	return mockDBHandler.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(tx DBHandler) error) error {
+		return fn(mockDBHandler)
+	}).AnyTimes()
+}
+
+// TestInsertChunk tests that a chunk is written in one transaction, bisecting bad rows in savepoints,
+// and rolled back as a whole when the database fails
+func TestInsertChunk(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var transactions int
+	mockDBHandler := NewMockDBHandler(ctrl)
+	mockDBHandler.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(tx DBHandler) error) error {
+		transactions++
+		return fn(mockDBHandler)
+	}).AnyTimes()
+	failure := error(&pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"})
+	mockDBHandler.EXPECT().CreateInBatches(gomock.Any(), gomock.Any()).DoAndReturn(func(value interface{}, batchSize int) error {
+		for _, user := range value.([]UserData) {
+			if user.FirstName == "Bad" {
+				return failure
+			}
+		}
+		return nil
+	}).AnyTimes()
+	sink := &postgresSink{dbHandler: mockDBHandler, batchSize: 100}
+
+	users := []UserData{{FirstName: "A"}, {FirstName: "Bad"}, {FirstName: "C"}}
+	inserted, rejected := insertChunk(sink, users)
+	assert.Equal(t, 2, inserted)
+	assert.Len(t, rejected, 1)
+	assert.Equal(t, 1, rejected[0].index)
+	assert.Equal(t, 6, transactions, "the chunk's transaction and a savepoint per write: ABC, A, BC, B, C")
+
+	// A lost connection rolls the whole chunk back, including the rows written before it
+	failure = errors.New("conn closed")
+	inserted, rejected = insertChunk(sink, users)
+	assert.Equal(t, 0, inserted)
+	assert.Len(t, rejected, 3)
+	assert.EqualError(t, rejected[0].err, "chunk rolled back: conn closed")
+
+	// Sinks without transactions are written batch by batch
+	memory := &memorySink{}
+	inserted, rejected = insertChunk(memory, users)
+	assert.Equal(t, 3, inserted)
+	assert.Empty(t, rejected)
+}
+
+// TestCommittedRanges tests that the committed lines are reported as ranges, joined across chunks
+func TestCommittedRanges(t *testing.T) {
+	progress := &importProgress{}
+	progress.recordCommitted([]int{7, 8, 9}, nil)
+	progress.recordCommitted([]int{2, 3, 4, 5, 6}, []rejectedRow{{index: 2}})
+	progress.recordCommitted([]int{10, 11}, []rejectedRow{{index: 0}, {index: 1}})
+
+	ranges, dropped := progress.committedRanges()
+	assert.Equal(t, []lineRange{{FirstLine: 2, LastLine: 3}, {FirstLine: 5, LastLine: 9}}, ranges)
+	assert.Equal(t, 0, dropped)
+}
+
+// TestTeeSinkTransaction tests that only the batches of committed transactions are copied to the warehouse
+func TestTeeSinkTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDBHandler := NewMockDBHandler(ctrl)
+	expectTransactions(mockDBHandler)
+	mockDBHandler.EXPECT().CreateInBatches(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	warehouseSink := &memorySink{}
+	tee := newTeeSink(&postgresSink{dbHandler: mockDBHandler, batchSize: 100}, warehouseSink)
+
+	err := tee.Transaction(func(tx Sink) error {
+		assert.NoError(t, tx.Write([]UserData{{FirstName: "A"}}))
+		assert.Empty(t, warehouseSink.users, "nothing is copied before the commit")
+		return errors.New("rolled back")
+	})
+	assert.Error(t, err)
+	assert.Empty(t, warehouseSink.users)
+
+	assert.NoError(t, tee.Transaction(func(tx Sink) error {
+		return tx.Write([]UserData{{FirstName: "B"}})
+	}))
+	assert.Len(t, warehouseSink.users, 1)
+	assert.Equal(t, "B", warehouseSink.users[0].FirstName)
+}
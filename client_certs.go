@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Client certificate modes
+const (
+	clientCertsOptional = "optional" // Certificates are accepted along with the other credentials
+	clientCertsRequired = "required" // Protected routes only accept certificates
+)
+
+// clientCerts authenticates machine callers by their TLS client certificates, nil while they are disabled
+var clientCerts *certAuthenticator
+
+// certAuthenticator maps the subjects of verified client certificates to roles
+type certAuthenticator struct {
+	pool     *x509.CertPool
+	roles    map[string]string // Role by full subject DN, e.g. "CN=billing-sync,O=Acme", or by common name
+	required bool
+}
+
+// newCertAuthenticator loads the CA bundle client certificates must chain to, or returns nil
+// while client certificates are disabled
+func newCertAuthenticator(config AuthConfig) (*certAuthenticator, error) {
+	if config.ClientCerts == "" {
+		return nil, nil
+	}
+	bundle, err := os.ReadFile(config.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("client CA bundle %s holds no PEM certificates", config.ClientCAFile)
+	}
+	return &certAuthenticator{pool: pool, roles: config.ClientCertRoles, required: config.ClientCerts == clientCertsRequired}, nil
+}
+
+// parseCertRoles parses a subject-to-role mapping such as "billing-sync=uploader;CN=ops,O=Acme=admin".
+// Entries are separated by semicolons and the role follows the last '=', since subjects contain '=' and ','.
+func parseCertRoles(value string) (map[string]string, error) {
+	roles := map[string]string{}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("client certificate role %q must be written as subject=role", entry)
+		}
+		roles[strings.TrimSpace(entry[:i])] = strings.TrimSpace(entry[i+1:])
+	}
+	return roles, nil
+}
+
+// tlsConfig returns the server TLS settings, asking clients for a certificate signed by the CA bundle.
+// Certificates are verified when sent rather than required in the handshake, so probes and the token
+// endpoints stay reachable; the authenticator decides which routes need one.
+func (a *certAuthenticator) tlsConfig() *tls.Config {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if a != nil {
+		config.ClientCAs = a.pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config
+}
+
+// Authenticate maps the subject of the request's verified client certificate to a role. Subjects without
+// a role are rejected with 403. In required mode, requests without a certificate are rejected with 401
+// rather than tried with the other credentials.
+func (a *certAuthenticator) Authenticate(c *gin.Context, role string) (*Principal, error) {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		if a.required {
+			return nil, errClientCertRequired()
+		}
+		return nil, nil
+	}
+	subject := c.Request.TLS.VerifiedChains[0][0].Subject
+	granted, ok := a.roles[subject.String()]
+	if !ok {
+		granted, ok = a.roles[subject.CommonName]
+	}
+	if !ok {
+		return nil, &AuthError{Code: 403, Message: "Forbidden", Details: fmt.Sprintf("client certificate %q is not mapped to a role", subject.String())}
+	}
+	if err := requireRole(granted, role); err != nil {
+		return nil, err
+	}
+	return &Principal{Subject: "cert:" + subject.CommonName, Role: granted}, nil
+}
+
+// errClientCertRequired rejects a request without a client certificate in required mode. It isn't a
+// challenge, which would also close the API to the other credentials in optional mode.
+func errClientCertRequired() *AuthError {
+	return &AuthError{Code: 401, Message: "Client certificate required", Details: "connect with a TLS client certificate signed by the configured CA"}
+}
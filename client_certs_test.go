@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCertificate is a key pair signed by parent, or self-signed when parent is nil
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCertificate(t *testing.T, subject pkix.Name, parent *testCertificate, usage x509.ExtKeyUsage) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		DNSNames:     []string{"localhost"},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCertificate{cert: cert, key: key}
+}
+
+func (c *testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// writeCABundle writes the PEM bundle of the given CAs to a temporary file
+func writeCABundle(t *testing.T, cas ...*testCertificate) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	var bundle []byte
+	for _, ca := range cas {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+	}
+	require.NoError(t, os.WriteFile(path, bundle, 0o600))
+	return path
+}
+
+// TestParseCertRoles tests that subjects may contain '=' and ',' and the role follows the last '='
+func TestParseCertRoles(t *testing.T) {
+	roles, err := parseCertRoles(" billing-sync=uploader; CN=ops,O=Acme=admin;")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"billing-sync": roleUploader, "CN=ops,O=Acme": roleAdmin}, roles)
+
+	_, err = parseCertRoles("billing-sync")
+	assert.Error(t, err)
+}
+
+// TestClientCertConfig tests the validation of the client certificate settings
+func TestClientCertConfig(t *testing.T) {
+	config := defaultConfig()
+	config.Auth.ClientCerts = clientCertsRequired
+	err := config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires server tls_cert_file")
+	assert.Contains(t, err.Error(), "client_ca_file is required")
+	assert.Contains(t, err.Error(), "must map at least one certificate subject")
+
+	config.Server.TLSCertFile, config.Server.TLSKeyFile = "server.pem", "server-key.pem"
+	config.Auth.ClientCAFile = "ca.pem"
+	config.Auth.ClientCertRoles = map[string]string{"billing-sync": "owner"}
+	err = config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported role "owner"`)
+
+	config.Auth.ClientCertRoles["billing-sync"] = roleUploader
+	assert.NoError(t, config.Validate())
+
+	config.Auth.ClientCerts = "always"
+	assert.ErrorContains(t, config.Validate(), "unsupported auth client_certs")
+
+	t.Setenv("AUTH_CLIENT_CERT_ROLES", "billing-sync")
+	assert.ErrorContains(t, applyEnvOverrides(&config), "AUTH_CLIENT_CERT_ROLES")
+}
+
+// TestCertAuthenticator tests that verified certificates get the role of their subject over HTTPS, and
+// that required mode refuses other credentials
+func TestCertAuthenticator(t *testing.T) {
+	ca := newTestCertificate(t, pkix.Name{CommonName: "Test CA"}, nil, 0)
+	otherCA := newTestCertificate(t, pkix.Name{CommonName: "Other CA"}, nil, 0)
+	server := newTestCertificate(t, pkix.Name{CommonName: "localhost"}, ca, x509.ExtKeyUsageServerAuth)
+	billing := newTestCertificate(t, pkix.Name{CommonName: "billing-sync", Organization: []string{"Acme"}}, ca, x509.ExtKeyUsageClientAuth)
+	ops := newTestCertificate(t, pkix.Name{CommonName: "ops", Organization: []string{"Acme"}}, ca, x509.ExtKeyUsageClientAuth)
+	stranger := newTestCertificate(t, pkix.Name{CommonName: "stranger"}, ca, x509.ExtKeyUsageClientAuth)
+	forged := newTestCertificate(t, pkix.Name{CommonName: "billing-sync"}, otherCA, x509.ExtKeyUsageClientAuth)
+
+	previous := clientCerts
+	defer func() { clientCerts = previous }()
+	var err error
+	clientCerts, err = newCertAuthenticator(AuthConfig{
+		ClientCerts:     clientCertsOptional,
+		ClientCAFile:    writeCABundle(t, ca),
+		ClientCertRoles: map[string]string{"CN=billing-sync,O=Acme": roleUploader, "ops": roleReader},
+	})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(authMiddleware())
+	r.GET("/api/records", func(c *gin.Context) { respond(c, 200, clientKey(c), nil) })
+	r.POST("/api/records", func(c *gin.Context) { respond(c, 201, clientKey(c), nil) })
+	r.GET("/healthz", healthz)
+
+	srv := httptest.NewUnstartedServer(r)
+	srv.TLS = clientCerts.tlsConfig()
+	srv.TLS.Certificates = []tls.Certificate{server.tlsCertificate()}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	send := func(method, path string, client *testCertificate) (int, error) {
+		config := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+		if client != nil {
+			// Send the certificate even when it isn't signed by a CA the server asks for
+			certificate := client.tlsCertificate()
+			config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return &certificate, nil }
+		}
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	code, err := send("POST", "/api/records", billing)
+	require.NoError(t, err)
+	assert.Equal(t, 201, code, "matched by the full subject DN")
+
+	code, _ = send("GET", "/api/records", ops)
+	assert.Equal(t, 200, code, "matched by the common name")
+	code, _ = send("POST", "/api/records", ops)
+	assert.Equal(t, 403, code, "the reader role can't write")
+
+	code, _ = send("GET", "/api/records", stranger)
+	assert.Equal(t, 403, code, "subjects without a role are refused")
+
+	code, _ = send("GET", "/api/records", nil)
+	assert.Equal(t, 200, code, "optional mode leaves requests without credentials to the other authenticators")
+
+	_, err = send("GET", "/api/records", forged)
+	assert.Error(t, err, "certificates of other CAs fail the handshake")
+
+	clientCerts.required = true
+	code, _ = send("GET", "/api/records", nil)
+	assert.Equal(t, 401, code)
+	code, _ = send("GET", "/healthz", nil)
+	assert.Equal(t, 200, code, "probes stay reachable without a certificate")
+	code, _ = send("GET", "/api/records", ops)
+	assert.Equal(t, 200, code)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/records", nil)
+	c.Request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{ops.cert, ca.cert}}}
+	principal, err := clientCerts.Authenticate(c, roleReader)
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "cert:ops", Role: roleReader}, principal)
+}
+
+// TestNewCertAuthenticator tests that client certificates stay off unless configured and that the CA
+// bundle must hold certificates
+func TestNewCertAuthenticator(t *testing.T) {
+	authenticator, err := newCertAuthenticator(AuthConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, authenticator)
+	assert.Nil(t, authenticator.tlsConfig().ClientCAs)
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate"), 0o600))
+	_, err = newCertAuthenticator(AuthConfig{ClientCerts: clientCertsOptional, ClientCAFile: empty})
+	assert.ErrorContains(t, err, "holds no PEM certificates")
+
+	_, err = newCertAuthenticator(AuthConfig{ClientCerts: clientCertsOptional, ClientCAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read client CA bundle")
+}
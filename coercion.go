@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseAge parses an age. Surrounding spaces and whole numbers written as decimals, e.g. " 30" or "30.0",
+// are accepted and reported as coerced so the import summary shows the values that were changed.
+func parseAge(value string) (age int, coerced bool, err error) {
+	if age, err = strconv.Atoi(value); err == nil {
+		return age, false, nil
+	}
+	trimmed := strings.TrimSpace(value)
+	if age, err := strconv.Atoi(trimmed); err == nil {
+		return age, true, nil
+	}
+	f, ferr := strconv.ParseFloat(trimmed, 64)
+	if ferr != nil || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, false, err
+	}
+	return int(f), true, nil
+}
+
+// parseSalary parses a salary, accepting surrounding spaces as a coercion
+func parseSalary(value string) (salary float64, coerced bool, err error) {
+	if salary, err = strconv.ParseFloat(value, 64); err == nil {
+		return salary, false, nil
+	}
+	if salary, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+		return salary, true, nil
+	}
+	return 0, false, err
+}
+
+// parseIsActive parses the is_active flag. Only "true" and "false" (or an empty value) are taken as is;
+// other spellings such as "TRUE", "1" or "yes" are coerced, and unrecognized values become false.
+func parseIsActive(value string) (isActive bool, coerced bool) {
+	switch value {
+	case "true":
+		return true, false
+	case "false", "":
+		return false, false
+	}
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "t", "1", "yes", "y":
+		return true, true
+	default:
+		return false, true
+	}
+}
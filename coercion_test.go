@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseCoercedValues tests which values are taken as is, coerced or rejected
+func TestParseCoercedValues(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		age     int
+		coerced bool
+		valid   bool
+	}{
+		{"30", 30, false, true},
+		{" 30 ", 30, true, true},
+		{"30.0", 30, true, true},
+		{"30.5", 0, false, false},
+		{"old", 0, false, false},
+	} {
+		age, coerced, err := parseAge(tc.value)
+		assert.Equal(t, tc.valid, err == nil, tc.value)
+		assert.Equal(t, tc.age, age, tc.value)
+		assert.Equal(t, tc.coerced, coerced, tc.value)
+	}
+
+	salary, coerced, err := parseSalary("50000.5")
+	assert.NoError(t, err)
+	assert.Equal(t, 50000.5, salary)
+	assert.False(t, coerced)
+	salary, coerced, err = parseSalary(" 50000 ")
+	assert.NoError(t, err)
+	assert.Equal(t, 50000.0, salary)
+	assert.True(t, coerced)
+	_, _, err = parseSalary("50,000")
+	assert.Error(t, err)
+
+	for value, expected := range map[string][2]bool{
+		"true": {true, false}, "false": {false, false}, "": {false, false},
+		"TRUE": {true, true}, "1": {true, true}, "yes": {true, true}, "no": {false, true}, "maybe": {false, true},
+	} {
+		isActive, coerced := parseIsActive(value)
+		assert.Equal(t, expected, [2]bool{isActive, coerced}, value)
+	}
+}
+
+// TestRunImportCoercions tests that coerced and truncated values are counted per column in the import metrics
+func TestRunImportCoercions(t *testing.T) {
+	csvData := "ID,FirstName,LastName,Email,Age,Gender,Department,Company,Salary,DateJoined,IsActive\n" +
+		"1,Jane,Doe,jane@example.com,30.0,Female,IT,Acme,50000,2022-01-01,TRUE\n" +
+		"2,Jim,Doe,jim@example.com,41,Male," + strings.Repeat("x", 101) + ",Acme, 40000,2021-06-01,yes\n" +
+		"3,Joe,Doe,joe@example.com,29.5,Male,IT,Acme,40000,2021-06-01,true\n"
+
+	sink := &memorySink{}
+	overflow, _ := newOverflowHandler(overflowTruncate)
+	progress := &importProgress{}
+	metrics, err := runImport(context.Background(), strings.NewReader(csvData), sink, importOptions{overflow: overflow, preserveOrder: true}, progress)
+	assert.NoError(t, err)
+	assert.Len(t, sink.users, 2)
+	assert.Equal(t, 30, sink.users[0].Age)
+	assert.True(t, sink.users[1].IsActive)
+	assert.Equal(t, map[string]int{"age": 1, "salary": 1, "is_active": 2, "department": 1}, metrics["coercions"])
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// csvRequiredColumns must be present in every upload; the other columns default to empty values
+var csvRequiredColumns = []string{"first_name", "last_name", "email", "age", "salary"}
+
+// columnMapping maps column names to the header names of an upload that differ from them,
+// e.g. {"first_name": "Given Name", "salary": "Annual Pay"}
+type columnMapping map[string]string
+
+// parseColumnMapping parses the JSON column mapping of an upload; an empty string means no mapping
+func parseColumnMapping(raw string) (columnMapping, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var mapping columnMapping
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		return nil, fmt.Errorf("column mapping must be a JSON object of column names to header names: %w", err)
+	}
+	for name := range mapping {
+		if _, ok := csvColumns[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q in column mapping", name)
+		}
+	}
+	return mapping, nil
+}
+
+// normalizeHeader reduces a header name to lowercase letters and digits,
+// so "First Name", "first_name" and "FirstName" all match the first_name column
+func normalizeHeader(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// columnLayout holds, for each position of csvColumns, the index of that column in the upload or -1 if absent
+type columnLayout []int
+
+// resolveColumns finds the columns in the header row of an upload by name, so they may come in any order.
+// It fails on missing required columns, on header names mapped to but not found, and on ambiguous headers.
+func resolveColumns(header []string, mapping columnMapping) (columnLayout, error) {
+	index := map[string]int{}
+	for i, name := range header {
+		key := normalizeHeader(name)
+		if key == "" {
+			continue
+		}
+		if _, ok := index[key]; ok {
+			return nil, fmt.Errorf("header has more than one %q column", strings.TrimSpace(name))
+		}
+		index[key] = i
+	}
+
+	layout := make(columnLayout, len(csvColumns))
+	var missing []string
+	for name, position := range csvColumns {
+		source := name
+		if mapped, ok := mapping[name]; ok {
+			source = mapped
+		}
+		i, ok := index[normalizeHeader(source)]
+		if !ok {
+			if _, mapped := mapping[name]; mapped {
+				return nil, fmt.Errorf("column %q mapped to %s is not in the header", source, name)
+			}
+			i = -1
+		}
+		layout[position] = i
+	}
+	for _, name := range csvRequiredColumns {
+		if layout[csvColumns[name]] < 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required columns %s; the header has %s",
+			strings.Join(missing, ", "), strings.Join(header, ", "))
+	}
+	return layout, nil
+}
+
+// extend appends the positions of the added columns of schema evolution, which follow the csvColumns
+// in projected rows; added columns the header lacks are left empty
+func (l columnLayout) extend(header []string, evolved []string) columnLayout {
+	if len(evolved) == 0 {
+		return l
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[normalizeHeader(name)] = i
+	}
+	extended := append(columnLayout{}, l...)
+	for _, column := range evolved {
+		i, ok := index[normalizeHeader(column)]
+		if !ok {
+			i = -1
+		}
+		extended = append(extended, i)
+	}
+	return extended
+}
+
+// unmappedColumns returns the named header columns the layout doesn't read, whose values are dropped
+func unmappedColumns(header []string, layout columnLayout) []string {
+	used := map[int]bool{}
+	for _, i := range layout {
+		used[i] = true
+	}
+	var unmapped []string
+	for i, name := range header {
+		if !used[i] && normalizeHeader(name) != "" {
+			unmapped = append(unmapped, strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		}
+	}
+	return unmapped
+}
+
+// project reorders an upload row into the positions of csvColumns, leaving absent columns empty
+func (l columnLayout) project(record []string) []string {
+	row := make([]string, len(l))
+	for position, i := range l {
+		if i >= 0 && i < len(record) {
+			row[position] = record[i]
+		}
+	}
+	return row
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseColumnMapping tests parsing the JSON column mapping of an upload
+func TestParseColumnMapping(t *testing.T) {
+	mapping, err := parseColumnMapping("")
+	assert.NoError(t, err)
+	assert.Nil(t, mapping)
+
+	mapping, err = parseColumnMapping(`{"first_name":"Given Name","salary":"Annual Pay"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, columnMapping{"first_name": "Given Name", "salary": "Annual Pay"}, mapping)
+
+	_, err = parseColumnMapping(`{"nickname":"Alias"}`)
+	assert.ErrorContains(t, err, `unknown column "nickname"`)
+
+	_, err = parseColumnMapping(`["first_name"]`)
+	assert.ErrorContains(t, err, "must be a JSON object")
+}
+
+// TestResolveColumns tests finding columns by header name in any order
+func TestResolveColumns(t *testing.T) {
+	// Reordered headers in other spellings after a byte order mark, without the optional id and gender columns
+	header := []string{"\ufeffSalary", "Email", "Last Name", "FirstName", "age", "Department", "COMPANY", "date_joined", "Is Active"}
+	layout, err := resolveColumns(header, nil)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{"", "Jane", "Doe", "jane@example.com", "30", "", "IT", "Acme", "50000", "2022-01-01", "true"},
+		layout.project([]string{"50000", "jane@example.com", "Doe", "Jane", "30", "IT", "Acme", "2022-01-01", "true"}))
+
+	// Mapped headers replace the column names
+	layout, err = resolveColumns([]string{"Given Name", "Surname", "Mail", "Age", "Pay"},
+		columnMapping{"first_name": "Given Name", "last_name": "Surname", "email": "mail", "salary": "Pay"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "Jane", "Doe", "jane@example.com", "30", "", "", "", "50000", "", ""},
+		layout.project([]string{"Jane", "Doe", "jane@example.com", "30", "50000"}))
+
+	// Short rows leave the missing columns empty
+	assert.Equal(t, []string{"", "Jane", "", "", "", "", "", "", "", "", ""}, layout.project([]string{"Jane"}))
+
+	_, err = resolveColumns([]string{"First Name", "Last Name", "Email"}, nil)
+	assert.EqualError(t, err, "missing required columns age, salary; the header has First Name, Last Name, Email")
+
+	_, err = resolveColumns(header, columnMapping{"first_name": "Given Name"})
+	assert.ErrorContains(t, err, `column "Given Name" mapped to first_name is not in the header`)
+
+	_, err = resolveColumns(append(header, "first name"), nil)
+	assert.ErrorContains(t, err, `more than one "first name" column`)
+}
+
+// TestReadCSVChunkHeader tests that reordered CSV columns are read by name and missing ones stop the import
+func TestReadCSVChunkHeader(t *testing.T) {
+	ch := make(chan csvChunk, 1)
+	stats := &readerStats{}
+	readCSVChunk(strings.NewReader("Email,Salary,Age,Last Name,First Name\njane@example.com,50000,30,Doe,Jane\n"), nil, nil, 10, ch, stats)
+	assert.NoError(t, stats.Err())
+	chunk := <-ch
+	assert.Equal(t, [][]string{{"", "Jane", "Doe", "jane@example.com", "30", "", "", "", "50000", "", ""}}, chunk.records)
+	assert.Equal(t, []int{2}, chunk.lines)
+
+	ch = make(chan csvChunk, 1)
+	stats = &readerStats{}
+	readCSVChunk(strings.NewReader("Email,Salary\njane@example.com,50000\n"), nil, nil, 10, ch, stats)
+	assert.ErrorContains(t, stats.Err(), "missing required columns age, first_name, last_name")
+	_, open := <-ch
+	assert.False(t, open)
+
+	// Empty files have no rows
+	ch = make(chan csvChunk, 1)
+	stats = &readerStats{}
+	readCSVChunk(strings.NewReader(""), nil, nil, 10, ch, stats)
+	assert.NoError(t, stats.Err())
+	_, open = <-ch
+	assert.False(t, open)
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// comparePath is the dataset comparison endpoint, which only reads the records although it is a POST
+const comparePath = "/api/compare"
+
+// Dataset comparison limits
+const (
+	compareDefaultKey     = "email"
+	compareDefaultSamples = 10
+	compareMaxSamples     = 100
+	compareMaxRows        = 500000 // Rows of the compared file or snapshot, which are held in memory by key
+	comparePageSize       = 5000   // Records read per query while scanning a table
+)
+
+// compareFields are the columns compared between the records, in the order of csvColumns. The ID isn't
+// compared since imports assign new ones.
+var compareFields = []string{"first_name", "last_name", "email", "age", "gender", "department", "company", "salary", "date_joined", "is_active"}
+
+// errCompareTooLarge is returned when the compared file or snapshot has more than compareMaxRows rows
+var errCompareTooLarge = fmt.Errorf("more than %d rows to compare", compareMaxRows)
+
+// comparePager reads the records with IDs above afterID, ordered by ID
+type comparePager func(afterID, limit int) ([]UserDatas, error)
+
+// compareValues returns the record's values in the order of csvColumns, normalized so equal records compare equal
+func compareValues(record UserDatas) []string {
+	return []string{
+		strconv.Itoa(record.ID),
+		strings.TrimSpace(record.FirstName),
+		strings.TrimSpace(record.LastName),
+		strings.TrimSpace(record.Email),
+		strconv.Itoa(record.Age),
+		strings.TrimSpace(record.Gender),
+		strings.TrimSpace(record.Department),
+		strings.TrimSpace(record.Company),
+		strconv.FormatFloat(record.Salary, 'f', -1, 64),
+		record.DateJoined.String(),
+		strconv.FormatBool(record.IsActive),
+	}
+}
+
+// parseCompareRow converts a valid row read from a file, in the order of csvColumns, the way imports do
+func parseCompareRow(record []string, dateFormats []string) UserDatas {
+	age, _, _ := parseAge(record[4])
+	dateJoined, _ := parseDate(record[9], dateFormats)
+	salary, _, _ := parseSalary(record[8])
+	isActive, _ := parseIsActive(record[10])
+	return UserDatas{
+		FirstName: record[1], LastName: record[2], Email: record[3], Age: age, Gender: record[5],
+		Department: record[6], Company: record[7], Salary: salary, DateJoined: dateJoined, IsActive: isActive,
+	}
+}
+
+// compareKey joins the key columns of values, compared case-insensitively with surrounding spaces trimmed
+func compareKey(values []string, columns []int) string {
+	parts := make([]string, len(columns))
+	for i, index := range columns {
+		parts[i] = strings.ToLower(strings.TrimSpace(values[index]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// compareRow is a compared record and whether a current record had its key
+type compareRow struct {
+	values  []string
+	matched bool
+}
+
+// compareSet holds the compared records by key, in the order they were read
+type compareSet struct {
+	columns    []int
+	rows       map[string]*compareRow
+	keys       []string
+	total      int
+	invalid    int
+	duplicates int
+}
+
+// newCompareSet creates an empty set keyed on the columns of key
+func newCompareSet(columns []int) *compareSet {
+	return &compareSet{columns: columns, rows: map[string]*compareRow{}}
+}
+
+// add keeps the record, counting it as a duplicate when an earlier one had its key
+func (s *compareSet) add(record UserDatas) error {
+	s.total++
+	values := compareValues(record)
+	key := compareKey(values, s.columns)
+	if _, ok := s.rows[key]; ok {
+		s.duplicates++
+		return nil
+	}
+	if len(s.rows) >= compareMaxRows {
+		return errCompareTooLarge
+	}
+	s.rows[key] = &compareRow{values: values}
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// loadPages adds the records of every page
+func (s *compareSet) loadPages(pager comparePager) error {
+	for afterID := 0; ; {
+		records, err := pager(afterID, comparePageSize)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := s.add(record); err != nil {
+				return err
+			}
+		}
+		if len(records) < comparePageSize {
+			return nil
+		}
+		afterID = records[len(records)-1].ID
+	}
+}
+
+// loadFile adds the rows of a CSV or XLSX file, optionally gzip-compressed. Rows failing the validation
+// of imports are counted as invalid; dates are parsed with dateFormats.
+func (s *compareSet) loadFile(file io.Reader, sheet string, mapping columnMapping, dateFormats []string) error {
+	buffered, decompressor, _, err := decompress(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer decompressor.Close()
+
+	ch := make(chan csvChunk, csvChannelBuffer)
+	stats := &readerStats{}
+	startReader(buffered, "", sheet, mapping, nil, ch, stats)
+
+	// Keep draining the channel after an error so the reader doesn't block
+	var addErr error
+	for chunk := range ch {
+		for _, row := range chunk.records {
+			if addErr != nil {
+				continue
+			}
+			if len(validateRow(row, dateFormats)) > 0 {
+				s.total++
+				s.invalid++
+				continue
+			}
+			addErr = s.add(parseCompareRow(row, dateFormats))
+		}
+	}
+	if addErr != nil {
+		return addErr
+	}
+	return stats.Err()
+}
+
+// fieldChange is a compared value differing from the current one
+type fieldChange struct {
+	Current  string `json:"current"`
+	Compared string `json:"compared"`
+}
+
+// changedRecord is a sample of a record whose key is in both datasets with different values
+type changedRecord struct {
+	Key    string                 `json:"key"`
+	ID     int                    `json:"id"`
+	Fields map[string]fieldChange `json:"fields"`
+}
+
+// datasetSamples are the first records of each kind of difference
+type datasetSamples struct {
+	Added   []map[string]string `json:"added"`
+	Removed []map[string]string `json:"removed"`
+	Changed []changedRecord     `json:"changed"`
+}
+
+// datasetDiff is the result of comparing the records with a file or snapshot. Added records are only in the
+// compared dataset, removed ones only in the table.
+type datasetDiff struct {
+	Key           string         `json:"key"`
+	Source        string         `json:"source"`
+	CurrentRows   int            `json:"current_rows"`
+	ComparedRows  int            `json:"compared_rows"`
+	Added         int            `json:"added"`
+	Removed       int            `json:"removed"`
+	Changed       int            `json:"changed"`
+	Unchanged     int            `json:"unchanged"`
+	InvalidRows   int            `json:"invalid_rows"`   // Compared rows imports would reject
+	DuplicateKeys int            `json:"duplicate_keys"` // Rows of either dataset repeating the key of an earlier one
+	Samples       datasetSamples `json:"samples"`
+}
+
+// sampleRecord returns the compared fields of values, with the ID when the record has one
+func sampleRecord(values []string) map[string]string {
+	sample := make(map[string]string, len(compareFields)+1)
+	if values[0] != "0" {
+		sample["id"] = values[0]
+	}
+	for _, field := range compareFields {
+		sample[field] = values[csvColumns[field]]
+	}
+	return sample
+}
+
+// diffDataset scans the current records page by page, matching each with the compared record of its key
+func diffDataset(current comparePager, compared *compareSet, samples int) (*datasetDiff, error) {
+	diff := &datasetDiff{
+		ComparedRows: compared.total, InvalidRows: compared.invalid, DuplicateKeys: compared.duplicates,
+		Samples: datasetSamples{Added: []map[string]string{}, Removed: []map[string]string{}, Changed: []changedRecord{}},
+	}
+
+	for afterID := 0; ; {
+		records, err := current(afterID, comparePageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			diff.CurrentRows++
+			values := compareValues(record)
+			key := compareKey(values, compared.columns)
+			row, ok := compared.rows[key]
+			if ok && row.matched {
+				diff.DuplicateKeys++
+				continue
+			}
+			if !ok {
+				diff.Removed++
+				if len(diff.Samples.Removed) < samples {
+					diff.Samples.Removed = append(diff.Samples.Removed, sampleRecord(values))
+				}
+				continue
+			}
+			row.matched = true
+
+			fields := map[string]fieldChange{}
+			for _, field := range compareFields {
+				if i := csvColumns[field]; values[i] != row.values[i] {
+					fields[field] = fieldChange{Current: values[i], Compared: row.values[i]}
+				}
+			}
+			if len(fields) == 0 {
+				diff.Unchanged++
+				continue
+			}
+			diff.Changed++
+			if len(diff.Samples.Changed) < samples {
+				diff.Samples.Changed = append(diff.Samples.Changed, changedRecord{Key: key, ID: record.ID, Fields: fields})
+			}
+		}
+		if len(records) < comparePageSize {
+			break
+		}
+		afterID = records[len(records)-1].ID
+	}
+
+	for _, key := range compared.keys {
+		if row := compared.rows[key]; !row.matched {
+			diff.Added++
+			if len(diff.Samples.Added) < samples {
+				diff.Samples.Added = append(diff.Samples.Added, sampleRecord(row.values))
+			}
+		}
+	}
+	return diff, nil
+}
+
+// compareDataset handles POST /api/compare, diffing the records by key against the uploaded file of the
+// multipart form or the snapshot of the snapshot query parameter
+func compareDataset(c *gin.Context, db Database, store SnapshotStore) {
+	// The key query parameter names the columns identifying a record, like the dedup option of uploads
+	keySpec := c.DefaultQuery("key", compareDefaultKey)
+	key, err := newDeduplicator(keySpec)
+	if err != nil || key == nil {
+		respondError(c, 400, "Invalid key", fmt.Sprintf("expected a comma-separated list of columns, got %q", keySpec))
+		return
+	}
+	samples, err := strconv.Atoi(c.DefaultQuery("samples", strconv.Itoa(compareDefaultSamples)))
+	if err != nil || samples < 0 || samples > compareMaxSamples {
+		respondError(c, 400, "Invalid samples", fmt.Sprintf("samples must be between 0 and %d", compareMaxSamples))
+		return
+	}
+
+	compared := newCompareSet(key.columns)
+	var source string
+	if snapshot := c.Query("snapshot"); snapshot != "" {
+		id, err := strconv.ParseUint(snapshot, 10, 64)
+		if err != nil || id == 0 {
+			respondError(c, 400, "Invalid snapshot ID", "snapshot must be a positive integer")
+			return
+		}
+		source = "snapshot " + snapshot
+		err = compared.loadPages(func(afterID, limit int) ([]UserDatas, error) {
+			return store.Records(uint(id), afterID, limit)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, 404, "Snapshot not found")
+			return
+		}
+		if errors.Is(err, errCompareTooLarge) {
+			respondError(c, 413, "Dataset too large to compare", err.Error())
+			return
+		}
+		if err != nil {
+			log.WithError(err).WithField("snapshot_id", id).Error("Failed to read snapshot")
+			respondError(c, 500, "Failed to read snapshot", err.Error())
+			return
+		}
+	} else {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, appConfig.Ingestion.MaxUploadBytes)
+		file, fileHeader, err := c.Request.FormFile("file")
+		if err != nil {
+			respondError(c, 400, "Missing file or snapshot", "upload a file or name a snapshot to compare with")
+			return
+		}
+		defer file.Close()
+		mapping, err := parseColumnMapping(c.PostForm("column_mapping"))
+		if err != nil {
+			respondError(c, 400, "Invalid column mapping", err.Error())
+			return
+		}
+		dateFormats, err := parseDateFormats(c.PostForm("date_formats"))
+		if err != nil {
+			respondError(c, 400, "Invalid date_formats value", err.Error())
+			return
+		}
+
+		source = "file " + fileHeader.Filename
+		if err := compared.loadFile(file, c.PostForm("sheet"), mapping, dateFormats); err != nil {
+			if errors.Is(err, errCompareTooLarge) {
+				respondError(c, 413, "Dataset too large to compare", err.Error())
+				return
+			}
+			respondError(c, 400, "Failed to read file", err.Error())
+			return
+		}
+	}
+
+	result, err := diffDataset(func(afterID, limit int) ([]UserDatas, error) {
+		var records []UserDatas
+		err := db.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&records).Error
+		return records, err
+	}, compared, samples)
+	if err != nil {
+		log.WithError(err).Error("Failed to read records to compare")
+		respondError(c, 500, "Failed to read records")
+		return
+	}
+	result.Key, result.Source = key.key, source
+
+	log.WithFields(logrus.Fields{
+		"source": result.Source, "key": result.Key, "added": result.Added, "removed": result.Removed, "changed": result.Changed,
+	}).Info("Compared dataset")
+	respond(c, 200, result, nil)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestDiffDataset tests counting and sampling the added, removed and changed records by key
+func TestDiffDataset(t *testing.T) {
+	current := []UserDatas{
+		{ID: 1, FirstName: "Jane", Email: "jane@example.com", Age: 30, Salary: 50000, DateJoined: newDate(2020, 1, 1)},
+		{ID: 2, FirstName: "John", Email: "john@example.com", Age: 41, Salary: 40000},
+		{ID: 3, FirstName: "Old", Email: "old@example.com"},
+	}
+	pager := func(afterID, limit int) ([]UserDatas, error) {
+		var page []UserDatas
+		for _, record := range current {
+			if record.ID > afterID && len(page) < limit {
+				page = append(page, record)
+			}
+		}
+		return page, nil
+	}
+
+	compared := newCompareSet([]int{csvColumns["email"]})
+	assert.NoError(t, compared.add(UserDatas{FirstName: "Jane", Email: " jane@example.com ", Age: 30, Salary: 50000, DateJoined: newDate(2020, 1, 1)}))
+	assert.NoError(t, compared.add(UserDatas{FirstName: "John", Email: "john@example.com", Age: 42, Salary: 45000}))
+	assert.NoError(t, compared.add(UserDatas{FirstName: "Johnny", Email: "john@example.com"}))
+	assert.NoError(t, compared.add(UserDatas{FirstName: "New", Email: "new@example.com"}))
+
+	diff, err := diffDataset(pager, compared, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, diff.CurrentRows)
+	assert.Equal(t, 4, diff.ComparedRows)
+	assert.Equal(t, 1, diff.Added)
+	assert.Equal(t, 1, diff.Removed)
+	assert.Equal(t, 1, diff.Changed)
+	assert.Equal(t, 1, diff.Unchanged)
+	assert.Equal(t, 1, diff.DuplicateKeys)
+	assert.Equal(t, "new@example.com", diff.Samples.Added[0]["email"])
+	assert.Equal(t, "3", diff.Samples.Removed[0]["id"])
+	assert.Equal(t, changedRecord{Key: "john@example.com", ID: 2, Fields: map[string]fieldChange{
+		"age":    {Current: "41", Compared: "42"},
+		"salary": {Current: "40000", Compared: "45000"},
+	}}, diff.Samples.Changed[0])
+
+	// No samples are returned with samples=0
+	compared = newCompareSet([]int{csvColumns["email"]})
+	diff, err = diffDataset(pager, compared, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, diff.Removed)
+	assert.Empty(t, diff.Samples.Removed)
+}
+
+// TestCompareDataset tests comparing the records with an uploaded file and with a snapshot
+func TestCompareDataset(t *testing.T) {
+	disableAuth(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Where("id > ?", 0).Return(mockDB).Times(2)
+	mockDB.EXPECT().Order("id ASC").Return(mockDB).Times(2)
+	mockDB.EXPECT().Limit(comparePageSize).Return(mockDB).Times(2)
+	mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(func(dest interface{}, conds ...interface{}) *gorm.DB {
+		*dest.(*[]UserDatas) = []UserDatas{{ID: 1, FirstName: "Jane", Email: "jane@example.com", Age: 30, Salary: 50000, IsActive: true}}
+		return &gorm.DB{}
+	}).Times(2)
+	previous := snapshots
+	defer func() { snapshots = previous }()
+	store := NewMockSnapshotStore(ctrl)
+	snapshots = store
+	store.EXPECT().Records(uint(3), 0, comparePageSize).
+		Return([]UserDatas{{ID: 1, FirstName: "Jane", Email: "jane@example.com", Age: 30, Salary: 50000, IsActive: true}}, nil)
+	store.EXPECT().Records(uint(4), 0, comparePageSize).Return(nil, gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, _ := writer.CreateFormFile("file", "feed.csv")
+	part.Write([]byte("First Name,Last Name,Email,Age,Salary,Is Active\n" +
+		"Jane,Doe,jane@example.com,31,50000,true\n" +
+		"John,Doe,john@example.com,40,40000,true\n" +
+		"Bad,Row,bad@example.com,old,1,true\n"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/compare?key=Email", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"source":"file feed.csv"`)
+	assert.Contains(t, w.Body.String(), `"added":1,"removed":0,"changed":1,"unchanged":0,"invalid_rows":1`)
+	assert.Contains(t, w.Body.String(), `"last_name":{"current":"","compared":"Doe"}`)
+
+	w = serveRecord(r, "POST", "/api/compare?snapshot=3", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"unchanged":1`)
+
+	assert.Equal(t, 404, serveRecord(r, "POST", "/api/compare?snapshot=4", "").Code)
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/compare", "").Code)
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/compare?snapshot=3&key=badge", "").Code)
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/compare?snapshot=3&samples=1000", "").Code)
+}
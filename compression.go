@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compressionGzip names gzip-compressed uploads in import reports
+const compressionGzip = "gzip"
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns a reader of the decompressed data when r holds a gzip stream, such as a .csv.gz upload,
+// and r itself otherwise. It reports the detected compression, empty for uncompressed data.
+// The data is decompressed while it is read, so the uncompressed file is never held in full.
+func decompress(r *bufio.Reader) (*bufio.Reader, io.Closer, string, error) {
+	head, _ := r.Peek(len(gzipMagic))
+	if !bytes.Equal(head, gzipMagic) {
+		return r, io.NopCloser(nil), "", nil
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to decompress gzip file: %w", err)
+	}
+	return bufio.NewReader(gz), gz, compressionGzip, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecompress tests that gzip streams are decompressed and other data passed through
+func TestDecompress(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("ID,First Name\n1,Jane\n"))
+	gz.Close()
+
+	r, closer, compression, err := decompress(bufio.NewReader(&compressed))
+	assert.NoError(t, err)
+	defer closer.Close()
+	data, _ := io.ReadAll(r)
+	assert.Equal(t, "ID,First Name\n1,Jane\n", string(data))
+	assert.Equal(t, compressionGzip, compression)
+
+	r, _, compression, err = decompress(bufio.NewReader(strings.NewReader("ID\n")))
+	assert.NoError(t, err)
+	data, _ = io.ReadAll(r)
+	assert.Equal(t, "ID\n", string(data))
+	assert.Empty(t, compression)
+
+	_, _, _, err = decompress(bufio.NewReader(bytes.NewReader([]byte{0x1f, 0x8b, 0})))
+	assert.ErrorContains(t, err, "failed to decompress")
+}
+
+// TestRunImportGzip tests importing a gzip-compressed CSV file
+func TestRunImportGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("ID,First Name,Last Name,Email,Age,Gender,Department,Company,Salary,Date Joined,Is Active\n" +
+		"1,Jane,Doe,jane@example.com,30,Female,IT,Acme,50000,2022-01-01,true\n"))
+	gz.Close()
+
+	sink := &memorySink{}
+	overflow, _ := newOverflowHandler("")
+	metrics, err := runImport(context.Background(), &compressed, sink, importOptions{overflow: overflow}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, compressionGzip, metrics["compression"])
+	assert.Equal(t, fileFormatCSV, metrics["file_format"])
+	assert.Len(t, sink.users, 1)
+	assert.Equal(t, "jane@example.com", sink.users[0].Email)
+}
@@ -0,0 +1,656 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Config holds the application settings loaded at startup
+type Config struct {
+	Database  DatabaseConfig  `yaml:"database" json:"database"`
+	Server    ServerConfig    `yaml:"server" json:"server"`
+	Ingestion IngestionConfig `yaml:"ingestion" json:"ingestion"`
+	Storage   StorageConfig   `yaml:"storage" json:"storage"`
+	Sources   SourcesConfig   `yaml:"sources" json:"sources"`
+	Warehouse WarehouseConfig `yaml:"warehouse" json:"warehouse"`
+	Auth      AuthConfig      `yaml:"auth" json:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+}
+
+// DatabaseConfig holds the PostgreSQL connection and pool settings
+type DatabaseConfig struct {
+	Host            string   `yaml:"host" json:"host"`
+	Port            int      `yaml:"port" json:"port"`
+	User            string   `yaml:"user" json:"user"`
+	Password        string   `yaml:"password" json:"password"`
+	Name            string   `yaml:"name" json:"name"`
+	SSLMode         string   `yaml:"sslmode" json:"sslmode"`
+	MaxOpenConns    int      `yaml:"max_open_conns" json:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" json:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" json:"conn_max_lifetime"`
+	ApplicationName string   `yaml:"application_name" json:"application_name"` // Shown in pg_stat_activity
+	QueryTags       bool     `yaml:"query_tags" json:"query_tags"`             // Prefix queries with a comment naming the request or import
+	Migrate         string   `yaml:"migrate" json:"migrate"`                   // auto or dry-run, which only reports schema drift
+	AutoIndex       bool     `yaml:"auto_index" json:"auto_index"`             // Create the indexes the advisor suggests for /api/records queries
+
+	SlowQueryThreshold Duration `yaml:"slow_query_threshold" json:"slow_query_threshold"` // Statements running longer are logged as slow queries, 0 to disable
+}
+
+// ServerConfig holds the HTTP server settings
+type ServerConfig struct {
+	Port            int      `yaml:"port" json:"port"`
+	ReadOnly        bool     `yaml:"read_only" json:"read_only"`               // Reject every write with 503, e.g. on replicas
+	ReadOnlyReason  string   `yaml:"read_only_reason" json:"read_only_reason"` // Reported to clients whose writes are rejected
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"` // How long requests and imports may finish on shutdown
+
+	SlowRequestThreshold Duration `yaml:"slow_request_threshold" json:"slow_request_threshold"` // Latency budget past which requests are logged with diagnostics, 0 to disable
+
+	Warmup        bool     `yaml:"warmup" json:"warmup"`                 // Open connections and run the hot queries before /readyz reports ready
+	WarmupTimeout Duration `yaml:"warmup_timeout" json:"warmup_timeout"` // Longest the warm-up may keep the server unready
+
+	TLSCertFile string `yaml:"tls_cert_file" json:"tls_cert_file"` // PEM certificate chain served over HTTPS, empty for plain HTTP
+	TLSKeyFile  string `yaml:"tls_key_file" json:"tls_key_file"`
+
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"` // IPs or CIDRs of the proxies whose X-Forwarded-For is believed; none while empty
+
+	JSONCasing string `yaml:"json_casing" json:"json_casing"` // Casing of JSON response field names, snake or camel
+}
+
+// IngestionConfig holds the CSV ingestion settings
+type IngestionConfig struct {
+	ChunkSize    int    `yaml:"chunk_size" json:"chunk_size"`       // Number of CSV rows read per chunk
+	BatchSize    int    `yaml:"batch_size" json:"batch_size"`       // Requested rows per INSERT, clamped to the parameter limit
+	InsertMethod string `yaml:"insert_method" json:"insert_method"` // insert or copy
+	Validation   string `yaml:"validation" json:"validation"`       // lenient skips invalid rows, strict rejects the file
+
+	MinWorkers int `yaml:"min_workers" json:"min_workers"` // Fewest chunk workers inserting at once, 0 for 2 (never more than max_workers)
+	MaxWorkers int `yaml:"max_workers" json:"max_workers"` // Chunk workers per import and most inserting at once, 0 for 4 per CPU
+
+	SchemaEvolution bool `yaml:"schema_evolution" json:"schema_evolution"` // Propose nullable columns for unknown CSV columns
+
+	MaxUploadBytes   int64 `yaml:"max_upload_bytes" json:"max_upload_bytes"`       // Largest accepted upload or download
+	MinFreeDiskBytes int64 `yaml:"min_free_disk_bytes" json:"min_free_disk_bytes"` // Free temp space that must remain after buffering an upload
+	MaxTableBytes    int64 `yaml:"max_table_bytes" json:"max_table_bytes"`         // Max size of user_data after an import, 0 disables the check
+	QuotaWarnings    []int `yaml:"quota_warnings" json:"quota_warnings"`           // Percentages of max_table_bytes from which uploads are warned
+}
+
+// StorageConfig selects where artifacts such as uploaded files are kept
+type StorageConfig struct {
+	Backend   string `yaml:"backend" json:"backend"`       // local, s3 or gcs
+	LocalPath string `yaml:"local_path" json:"local_path"` // Root directory of the local backend
+	Bucket    string `yaml:"bucket" json:"bucket"`
+	Endpoint  string `yaml:"endpoint" json:"endpoint"` // Defaults to the AWS or GCS endpoint
+	Region    string `yaml:"region" json:"region"`
+	AccessKey string `yaml:"access_key" json:"access_key"`
+	SecretKey string `yaml:"secret_key" json:"secret_key"`
+	Insecure  bool   `yaml:"insecure" json:"insecure"` // Use plain HTTP, e.g. for a local MinIO
+}
+
+// SourcesConfig holds the credentials of the object stores imports read files from directly, and the
+// hosts the url source may download from
+type SourcesConfig struct {
+	S3  ObjectSourceConfig `yaml:"s3" json:"s3"`
+	GCS ObjectSourceConfig `yaml:"gcs" json:"gcs"`
+	URL URLSourceConfig    `yaml:"url" json:"url"`
+}
+
+// URLSourceConfig restricts where the url import source may download from, so imports can't be used to
+// reach internal services
+type URLSourceConfig struct {
+	Hosts        []string `yaml:"hosts" json:"hosts"`                 // e.g. files.example.com or *.example.com; any host while empty
+	AllowPrivate bool     `yaml:"allow_private" json:"allow_private"` // Also connect to loopback, private and link-local addresses
+}
+
+// ObjectSourceConfig holds the credentials of the s3 or gcs import source and the buckets it may read
+type ObjectSourceConfig struct {
+	Buckets   []string `yaml:"buckets" json:"buckets"`   // Buckets imports may read from; the source is disabled while empty
+	Endpoint  string   `yaml:"endpoint" json:"endpoint"` // Defaults to the AWS or GCS endpoint
+	Region    string   `yaml:"region" json:"region"`
+	AccessKey string   `yaml:"access_key" json:"access_key"`
+	SecretKey string   `yaml:"secret_key" json:"secret_key"`
+	Insecure  bool     `yaml:"insecure" json:"insecure"` // Use plain HTTP, e.g. for a local MinIO
+}
+
+// objectSource returns the settings of the s3 or gcs source
+func (c SourcesConfig) objectSource(backend string) ObjectSourceConfig {
+	if backend == storageGCS {
+		return c.GCS
+	}
+	return c.S3
+}
+
+// WarehouseConfig selects the analytical database imported rows are copied to
+type WarehouseConfig struct {
+	Backend  string `yaml:"backend" json:"backend"` // clickhouse, or empty to disable the copy
+	URL      string `yaml:"url" json:"url"`         // HTTP interface, e.g. http://localhost:8123
+	Table    string `yaml:"table" json:"table"`     // Optionally prefixed with the database, e.g. analytics.user_data
+	User     string `yaml:"user" json:"user"`
+	Password string `yaml:"password" json:"password"`
+}
+
+// AuthConfig holds the token authentication settings; the API is open while no JWT secret is set
+type AuthConfig struct {
+	JWTSecret     string   `yaml:"jwt_secret" json:"jwt_secret"` // HMAC key signing the tokens, at least 32 characters
+	TokenTTL      Duration `yaml:"token_ttl" json:"token_ttl"`   // Lifetime of access tokens
+	RefreshTTL    Duration `yaml:"refresh_ttl" json:"refresh_ttl"`
+	AdminUser     string   `yaml:"admin_user" json:"admin_user"` // Admin account created at startup if missing
+	AdminPassword string   `yaml:"admin_password" json:"admin_password"`
+
+	ClientCerts     string            `yaml:"client_certs" json:"client_certs"`           // optional or required to authenticate TLS client certificates, empty to disable
+	ClientCAFile    string            `yaml:"client_ca_file" json:"client_ca_file"`       // PEM bundle of the CAs client certificates must chain to
+	ClientCertRoles map[string]string `yaml:"client_cert_roles" json:"client_cert_roles"` // Role by certificate subject DN or common name
+
+	Disabled bool `yaml:"disabled" json:"disabled"` // Let requests without credentials write and administer, e.g. in local development
+}
+
+// RateLimitConfig holds the per-client limits, keyed by the authenticated user or the client IP; 0 disables a limit
+type RateLimitConfig struct {
+	ReadsPerMinute  int `yaml:"reads_per_minute" json:"reads_per_minute"`   // GET and HEAD requests a client may send per minute
+	WritesPerMinute int `yaml:"writes_per_minute" json:"writes_per_minute"` // Other requests a client may send per minute
+	ClientUploads   int `yaml:"client_uploads" json:"client_uploads"`       // Uploads and import submissions a client may run at once
+}
+
+// Duration is a time.Duration read from strings such as "30m" in config files
+type Duration time.Duration
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// appConfig is the configuration of the running instance
+var appConfig = defaultConfig()
+
+// defaultConfig returns the settings used when nothing is configured
+func defaultConfig() Config {
+	return Config{
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            8899,
+			User:            "postgres",
+			Name:            "mini-Project",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			ApplicationName: "mini-Project",
+			QueryTags:       true,
+			Migrate:         migrateAuto,
+
+			SlowQueryThreshold: Duration(200 * time.Millisecond),
+		},
+		Server: ServerConfig{Port: 8080, JSONCasing: casingSnake, ShutdownTimeout: Duration(30 * time.Second), SlowRequestThreshold: Duration(time.Second), WarmupTimeout: Duration(30 * time.Second)},
+		Ingestion: IngestionConfig{
+			ChunkSize:    5000,
+			BatchSize:    10000,
+			InsertMethod: insertMethodInsert,
+			Validation:   validationLenient,
+
+			MaxUploadBytes:   30 << 30,
+			MinFreeDiskBytes: 1 << 30,
+			QuotaWarnings:    []int{80, 90},
+		},
+		Storage: StorageConfig{
+			Backend:   storageLocal,
+			LocalPath: filepath.Join(os.TempDir(), "mini-Project"),
+		},
+		Warehouse: WarehouseConfig{Table: "user_data"},
+		Auth: AuthConfig{
+			TokenTTL:   Duration(15 * time.Minute),
+			RefreshTTL: Duration(24 * time.Hour),
+		},
+	}
+}
+
+// DSN builds the PostgreSQL connection string. Values are quoted, so empty values and values with
+// spaces, quotes or backslashes, e.g. in the password, don't spill into the next key.
+func (d DatabaseConfig) DSN() string {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		dsnValue(d.Host), dsnValue(d.User), dsnValue(d.Password), dsnValue(d.Name), d.Port, dsnValue(d.SSLMode))
+	if d.ApplicationName != "" {
+		dsn += " application_name=" + dsnValue(d.ApplicationName)
+	}
+	return dsn
+}
+
+// dsnValue quotes a value of a keyword/value DSN, escaping backslashes and single quotes
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// Addr returns the listen address of the HTTP server
+func (s ServerConfig) Addr() string {
+	return fmt.Sprintf(":%d", s.Port)
+}
+
+// configurePool applies the connection pool settings to the database handle
+func configurePool(db *gorm.DB, config DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to access connection pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetime))
+	return nil
+}
+
+// loadConfig builds the configuration from defaults, the optional file named by
+// CONFIG_FILE (YAML or JSON) and environment variable overrides, then validates it
+func loadConfig() (Config, error) {
+	config := defaultConfig()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadConfigFile(path, &config); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := applyEnvOverrides(&config); err != nil {
+		return Config{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// loadConfigFile reads a YAML or JSON config file over the given configuration
+func loadConfigFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, config)
+	case ".json":
+		err = json.Unmarshal(data, config)
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnvOverrides overrides settings from environment variables
+func applyEnvOverrides(config *Config) error {
+	stringVars := map[string]*string{
+		"DB_HOST":     &config.Database.Host,
+		"DB_USER":     &config.Database.User,
+		"DB_PASSWORD": &config.Database.Password,
+		"DB_NAME":     &config.Database.Name,
+		"DB_SSLMODE":  &config.Database.SSLMode,
+
+		"DB_APPLICATION_NAME": &config.Database.ApplicationName,
+		"DB_MIGRATE":          &config.Database.Migrate,
+
+		"SERVER_READ_ONLY_REASON": &config.Server.ReadOnlyReason,
+		"JSON_CASING":             &config.Server.JSONCasing,
+
+		"CSV_INSERT_METHOD": &config.Ingestion.InsertMethod,
+		"CSV_VALIDATION":    &config.Ingestion.Validation,
+
+		"STORAGE_BACKEND":    &config.Storage.Backend,
+		"STORAGE_LOCAL_PATH": &config.Storage.LocalPath,
+		"STORAGE_BUCKET":     &config.Storage.Bucket,
+		"STORAGE_ENDPOINT":   &config.Storage.Endpoint,
+		"STORAGE_REGION":     &config.Storage.Region,
+		"STORAGE_ACCESS_KEY": &config.Storage.AccessKey,
+		"STORAGE_SECRET_KEY": &config.Storage.SecretKey,
+
+		"SOURCE_S3_ENDPOINT":    &config.Sources.S3.Endpoint,
+		"SOURCE_S3_REGION":      &config.Sources.S3.Region,
+		"SOURCE_S3_ACCESS_KEY":  &config.Sources.S3.AccessKey,
+		"SOURCE_S3_SECRET_KEY":  &config.Sources.S3.SecretKey,
+		"SOURCE_GCS_ENDPOINT":   &config.Sources.GCS.Endpoint,
+		"SOURCE_GCS_ACCESS_KEY": &config.Sources.GCS.AccessKey,
+		"SOURCE_GCS_SECRET_KEY": &config.Sources.GCS.SecretKey,
+
+		"WAREHOUSE_BACKEND":  &config.Warehouse.Backend,
+		"WAREHOUSE_URL":      &config.Warehouse.URL,
+		"WAREHOUSE_TABLE":    &config.Warehouse.Table,
+		"WAREHOUSE_USER":     &config.Warehouse.User,
+		"WAREHOUSE_PASSWORD": &config.Warehouse.Password,
+
+		"AUTH_JWT_SECRET":     &config.Auth.JWTSecret,
+		"AUTH_ADMIN_USER":     &config.Auth.AdminUser,
+		"AUTH_ADMIN_PASSWORD": &config.Auth.AdminPassword,
+
+		"SERVER_TLS_CERT_FILE": &config.Server.TLSCertFile,
+		"SERVER_TLS_KEY_FILE":  &config.Server.TLSKeyFile,
+		"AUTH_CLIENT_CERTS":    &config.Auth.ClientCerts,
+		"AUTH_CLIENT_CA_FILE":  &config.Auth.ClientCAFile,
+	}
+	for name, target := range stringVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+
+	intVars := map[string]*int{
+		"DB_PORT":           &config.Database.Port,
+		"DB_MAX_OPEN_CONNS": &config.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &config.Database.MaxIdleConns,
+		"SERVER_PORT":       &config.Server.Port,
+		"CSV_CHUNK_SIZE":    &config.Ingestion.ChunkSize,
+		"CSV_BATCH_SIZE":    &config.Ingestion.BatchSize,
+		"CSV_MIN_WORKERS":   &config.Ingestion.MinWorkers,
+		"CSV_MAX_WORKERS":   &config.Ingestion.MaxWorkers,
+
+		"RATE_LIMIT_READS_PER_MINUTE":  &config.RateLimit.ReadsPerMinute,
+		"RATE_LIMIT_WRITES_PER_MINUTE": &config.RateLimit.WritesPerMinute,
+		"RATE_LIMIT_CLIENT_UPLOADS":    &config.RateLimit.ClientUploads,
+	}
+	for name, target := range intVars {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*target = parsed
+	}
+
+	int64Vars := map[string]*int64{
+		"CSV_MAX_UPLOAD_BYTES":    &config.Ingestion.MaxUploadBytes,
+		"CSV_MIN_FREE_DISK_BYTES": &config.Ingestion.MinFreeDiskBytes,
+		"CSV_MAX_TABLE_BYTES":     &config.Ingestion.MaxTableBytes,
+	}
+	for name, target := range int64Vars {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*target = parsed
+	}
+
+	boolVars := map[string]*bool{
+		"DB_QUERY_TAGS":    &config.Database.QueryTags,
+		"DB_AUTO_INDEX":    &config.Database.AutoIndex,
+		"SERVER_READ_ONLY": &config.Server.ReadOnly,
+		"SERVER_WARMUP":    &config.Server.Warmup,
+		"STORAGE_INSECURE": &config.Storage.Insecure,
+
+		"SOURCE_S3_INSECURE":       &config.Sources.S3.Insecure,
+		"SOURCE_GCS_INSECURE":      &config.Sources.GCS.Insecure,
+		"SOURCE_URL_ALLOW_PRIVATE": &config.Sources.URL.AllowPrivate,
+		"AUTH_DISABLED":            &config.Auth.Disabled,
+
+		"CSV_SCHEMA_EVOLUTION": &config.Ingestion.SchemaEvolution,
+	}
+	for name, target := range boolVars {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*target = parsed
+	}
+
+	durationVars := map[string]*Duration{
+		"DB_CONN_MAX_LIFETIME":          &config.Database.ConnMaxLifetime,
+		"DB_SLOW_QUERY_THRESHOLD":       &config.Database.SlowQueryThreshold,
+		"SERVER_SHUTDOWN_TIMEOUT":       &config.Server.ShutdownTimeout,
+		"SERVER_SLOW_REQUEST_THRESHOLD": &config.Server.SlowRequestThreshold,
+		"SERVER_WARMUP_TIMEOUT":         &config.Server.WarmupTimeout,
+		"AUTH_TOKEN_TTL":                &config.Auth.TokenTTL,
+		"AUTH_REFRESH_TTL":              &config.Auth.RefreshTTL,
+	}
+	for name, target := range durationVars {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := target.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	listVars := map[string]*[]string{
+		"SOURCE_S3_BUCKETS":  &config.Sources.S3.Buckets,
+		"SOURCE_GCS_BUCKETS": &config.Sources.GCS.Buckets,
+		"SOURCE_URL_HOSTS":   &config.Sources.URL.Hosts,
+
+		"SERVER_TRUSTED_PROXIES": &config.Server.TrustedProxies,
+	}
+	for name, target := range listVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = splitList(value)
+		}
+	}
+
+	if value, ok := os.LookupEnv("CSV_QUOTA_WARNINGS"); ok {
+		config.Ingestion.QuotaWarnings = nil
+		for _, entry := range splitList(value) {
+			percent, err := strconv.Atoi(strings.TrimSuffix(entry, "%"))
+			if err != nil {
+				return fmt.Errorf("invalid CSV_QUOTA_WARNINGS: %w", err)
+			}
+			config.Ingestion.QuotaWarnings = append(config.Ingestion.QuotaWarnings, percent)
+		}
+	}
+
+	if value, ok := os.LookupEnv("AUTH_CLIENT_CERT_ROLES"); ok {
+		roles, err := parseCertRoles(value)
+		if err != nil {
+			return fmt.Errorf("invalid AUTH_CLIENT_CERT_ROLES: %w", err)
+		}
+		config.Auth.ClientCertRoles = roles
+	}
+	return nil
+}
+
+// splitList splits a comma-separated environment variable into its trimmed entries, leaving out empty ones
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Validate checks that the configuration is usable
+func (c Config) Validate() error {
+	var errs []error
+	if c.Database.Host == "" {
+		errs = append(errs, errors.New("database host is required"))
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		errs = append(errs, fmt.Errorf("database port %d is out of range", c.Database.Port))
+	}
+	if c.Database.User == "" {
+		errs = append(errs, errors.New("database user is required"))
+	}
+	if c.Database.Name == "" {
+		errs = append(errs, errors.New("database name is required"))
+	}
+	switch c.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("unsupported database sslmode %q", c.Database.SSLMode))
+	}
+	if c.Database.MaxOpenConns < 1 {
+		errs = append(errs, errors.New("database max_open_conns must be at least 1"))
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database max_idle_conns must be between 0 and max_open_conns"))
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("database conn_max_lifetime must not be negative"))
+	}
+	if c.Database.SlowQueryThreshold < 0 {
+		errs = append(errs, errors.New("database slow_query_threshold must not be negative"))
+	}
+	if strings.ContainsAny(c.Database.ApplicationName, " \t'\\") || len(c.Database.ApplicationName) > 63 {
+		errs = append(errs, errors.New("database application_name must be at most 63 characters without spaces, quotes or backslashes"))
+	}
+	switch c.Database.Migrate {
+	case migrateAuto, migrateDryRun:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported database migrate mode %q, expected auto or dry-run", c.Database.Migrate))
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server port %d is out of range", c.Server.Port))
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		errs = append(errs, errors.New("server shutdown_timeout must not be negative"))
+	}
+	if c.Server.SlowRequestThreshold < 0 {
+		errs = append(errs, errors.New("server slow_request_threshold must not be negative"))
+	}
+	if c.Server.WarmupTimeout <= 0 {
+		errs = append(errs, errors.New("server warmup_timeout must be positive"))
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("server tls_cert_file and tls_key_file must be set together"))
+	}
+	switch c.Server.JSONCasing {
+	case casingSnake, casingCamel:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported server json_casing %q, expected snake or camel", c.Server.JSONCasing))
+	}
+	if c.Ingestion.MaxUploadBytes < 1 {
+		errs = append(errs, errors.New("ingestion max_upload_bytes must be positive"))
+	}
+	if c.Ingestion.MinFreeDiskBytes < 0 || c.Ingestion.MaxTableBytes < 0 {
+		errs = append(errs, errors.New("ingestion min_free_disk_bytes and max_table_bytes must not be negative"))
+	}
+	for _, percent := range c.Ingestion.QuotaWarnings {
+		if percent < 1 || percent > 100 {
+			errs = append(errs, fmt.Errorf("ingestion quota_warnings %d must be a percentage between 1 and 100", percent))
+		}
+	}
+	if c.Ingestion.ChunkSize < 1 {
+		errs = append(errs, errors.New("ingestion chunk_size must be at least 1"))
+	}
+	if c.Ingestion.BatchSize < 1 {
+		errs = append(errs, errors.New("ingestion batch_size must be at least 1"))
+	}
+	if c.Ingestion.MinWorkers < 0 || c.Ingestion.MaxWorkers < 0 {
+		errs = append(errs, errors.New("ingestion min_workers and max_workers must not be negative"))
+	}
+	if c.Ingestion.MinWorkers > 0 && c.Ingestion.MaxWorkers > 0 && c.Ingestion.MinWorkers > c.Ingestion.MaxWorkers {
+		errs = append(errs, fmt.Errorf("ingestion min_workers %d must not exceed max_workers %d", c.Ingestion.MinWorkers, c.Ingestion.MaxWorkers))
+	}
+	if c.RateLimit.ReadsPerMinute < 0 || c.RateLimit.WritesPerMinute < 0 || c.RateLimit.ClientUploads < 0 {
+		errs = append(errs, errors.New("rate_limit settings must not be negative"))
+	}
+	switch c.Ingestion.InsertMethod {
+	case insertMethodInsert, insertMethodCopy:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported ingestion insert_method %q, expected insert or copy", c.Ingestion.InsertMethod))
+	}
+	switch c.Ingestion.Validation {
+	case validationLenient, validationStrict:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported ingestion validation %q, expected lenient or strict", c.Ingestion.Validation))
+	}
+	switch c.Storage.Backend {
+	case storageLocal:
+		if c.Storage.LocalPath == "" {
+			errs = append(errs, errors.New("storage local_path is required for the local backend"))
+		}
+	case storageS3, storageGCS:
+		if c.Storage.Bucket == "" {
+			errs = append(errs, fmt.Errorf("storage bucket is required for the %s backend", c.Storage.Backend))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported storage backend %q, expected local, s3 or gcs", c.Storage.Backend))
+	}
+	for _, backend := range []string{storageS3, storageGCS} {
+		for _, bucket := range c.Sources.objectSource(backend).Buckets {
+			if bucket == "" || strings.ContainsAny(bucket, "/ ") {
+				errs = append(errs, fmt.Errorf("invalid %s source bucket %q", backend, bucket))
+			}
+		}
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("invalid trusted proxy %q, expected an IP address or CIDR range", proxy))
+		}
+	}
+	for _, host := range c.Sources.URL.Hosts {
+		if name := strings.TrimPrefix(host, "*."); name == "" || strings.ContainsAny(name, "/:* ") {
+			errs = append(errs, fmt.Errorf("invalid url source host %q, expected a host name such as files.example.com or *.example.com", host))
+		}
+	}
+	switch c.Warehouse.Backend {
+	case "":
+	case warehouseClickHouse:
+		if u, err := url.Parse(c.Warehouse.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("warehouse url %q must be an http or https url", c.Warehouse.URL))
+		}
+		if !warehouseTableName.MatchString(c.Warehouse.Table) {
+			errs = append(errs, fmt.Errorf("invalid warehouse table %q", c.Warehouse.Table))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported warehouse backend %q, expected clickhouse", c.Warehouse.Backend))
+	}
+	if c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < 32 {
+		errs = append(errs, errors.New("auth jwt_secret must be at least 32 characters"))
+	}
+	if c.Auth.TokenTTL <= 0 || c.Auth.RefreshTTL <= 0 {
+		errs = append(errs, errors.New("auth token_ttl and refresh_ttl must be positive"))
+	}
+	if c.Auth.AdminUser != "" && len(c.Auth.AdminPassword) < 8 {
+		errs = append(errs, errors.New("auth admin_password must be at least 8 characters"))
+	}
+	switch c.Auth.ClientCerts {
+	case "":
+	case clientCertsOptional, clientCertsRequired:
+		if c.Server.TLSCertFile == "" {
+			errs = append(errs, errors.New("auth client_certs requires server tls_cert_file and tls_key_file"))
+		}
+		if c.Auth.ClientCAFile == "" {
+			errs = append(errs, errors.New("auth client_ca_file is required for client certificates"))
+		}
+		if len(c.Auth.ClientCertRoles) == 0 {
+			errs = append(errs, errors.New("auth client_cert_roles must map at least one certificate subject to a role"))
+		}
+		for subject, role := range c.Auth.ClientCertRoles {
+			if _, ok := roleRanks[role]; !ok {
+				errs = append(errs, fmt.Errorf("unsupported role %q for client certificate %q", role, subject))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported auth client_certs %q, expected optional or required", c.Auth.ClientCerts))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// redactedDSN builds the DSN with the password replaced by a placeholder, so no part of it is exposed
+func (d DatabaseConfig) redactedDSN() string {
+	if d.Password != "" {
+		d.Password = "****"
+	}
+	return d.DSN()
+}
+
+// effectiveConfig collects the settings the running instance is actually using
+func effectiveConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"database": map[string]interface{}{
+			"driver":               "postgres",
+			"dsn":                  appConfig.Database.redactedDSN(),
+			"max_open_conns":       appConfig.Database.MaxOpenConns,
+			"max_idle_conns":       appConfig.Database.MaxIdleConns,
+			"conn_max_lifetime":    time.Duration(appConfig.Database.ConnMaxLifetime).String(),
+			"application_name":     appConfig.Database.ApplicationName,
+			"query_tags":           appConfig.Database.QueryTags,
+			"migrate":              appConfig.Database.Migrate,
+			"auto_index":           appConfig.Database.AutoIndex,
+			"slow_query_threshold": time.Duration(appConfig.Database.SlowQueryThreshold).String(),
+		},
+		"server": map[string]interface{}{
+			"addr":                   appConfig.Server.Addr(),
+			"read_only":              appConfig.Server.ReadOnly,
+			"shutdown_timeout":       time.Duration(appConfig.Server.ShutdownTimeout).String(),
+			"slow_request_threshold": time.Duration(appConfig.Server.SlowRequestThreshold).String(),
+			"warmup":                 appConfig.Server.Warmup,
+			"warmup_timeout":         time.Duration(appConfig.Server.WarmupTimeout).String(),
+			"tls":                    appConfig.Server.TLSCertFile != "",
+			"trusted_proxies":        appConfig.Server.TrustedProxies,
+			"config_file":            os.Getenv("CONFIG_FILE"),
+			"json_casing":            appConfig.Server.JSONCasing,
+		},
+		"ingestion": map[string]interface{}{
+			"chunk_size":                 appConfig.Ingestion.ChunkSize,
+			"batch_size":                 appConfig.Ingestion.BatchSize,
+			"insert_method":              appConfig.Ingestion.InsertMethod,
+			"validation":                 appConfig.Ingestion.Validation,
+			"channel_buffer":             csvChannelBuffer,
+			"min_workers":                ingestMinWorkers(),
+			"max_workers":                ingestMaxWorkers(),
+			"target_chunk_latency":       ingestTargetChunkLatency.String(),
+			"max_multipart_memory_bytes": maxMultipartMemory,
+			"max_upload_bytes":           appConfig.Ingestion.MaxUploadBytes,
+			"min_free_disk_bytes":        appConfig.Ingestion.MinFreeDiskBytes,
+			"max_table_bytes":            appConfig.Ingestion.MaxTableBytes,
+			"quota_warnings":             appConfig.Ingestion.QuotaWarnings,
+			"schema_evolution":           appConfig.Ingestion.SchemaEvolution,
+		},
+		"route_limits": map[string]interface{}{
+			"uploads":    map[string]int{"concurrency": uploadConcurrency, "queue": uploadQueue},
+			"stats":      map[string]int{"concurrency": statsConcurrency, "queue": statsQueue},
+			"records":    map[string]int{"concurrency": listConcurrency, "queue": listQueue},
+			"queue_wait": routeQueueWait.String(),
+		},
+		"rate_limit": map[string]int{
+			"reads_per_minute":  appConfig.RateLimit.ReadsPerMinute,
+			"writes_per_minute": appConfig.RateLimit.WritesPerMinute,
+			"client_uploads":    appConfig.RateLimit.ClientUploads,
+		},
+		"storage": map[string]interface{}{
+			"backend":    appConfig.Storage.Backend,
+			"local_path": appConfig.Storage.LocalPath,
+			"bucket":     appConfig.Storage.Bucket,
+			"endpoint":   appConfig.Storage.Endpoint,
+			"region":     appConfig.Storage.Region,
+		},
+		"sources": map[string]interface{}{
+			"s3":  objectSourceSettings(appConfig.Sources.S3),
+			"gcs": objectSourceSettings(appConfig.Sources.GCS),
+			"url": map[string]interface{}{
+				"hosts":         appConfig.Sources.URL.Hosts,
+				"allow_private": appConfig.Sources.URL.AllowPrivate,
+			},
+		},
+		"warehouse": map[string]interface{}{
+			"backend": appConfig.Warehouse.Backend,
+			"url":     appConfig.Warehouse.URL,
+			"table":   appConfig.Warehouse.Table,
+		},
+		"auth": map[string]interface{}{
+			"enabled":     appConfig.Auth.JWTSecret != "",
+			"disabled":    appConfig.Auth.Disabled,
+			"token_ttl":   time.Duration(appConfig.Auth.TokenTTL).String(),
+			"refresh_ttl": time.Duration(appConfig.Auth.RefreshTTL).String(),
+			"admin_user":  appConfig.Auth.AdminUser,
+
+			"client_certs":      appConfig.Auth.ClientCerts,
+			"client_ca_file":    appConfig.Auth.ClientCAFile,
+			"client_cert_roles": appConfig.Auth.ClientCertRoles,
+		},
+		"logging": map[string]interface{}{
+			"file":        logFilePath,
+			"level":       log.GetLevel().String(),
+			"max_size_mb": logMaxSizeMB,
+			"max_backups": logMaxBackups,
+			"max_age":     logMaxAgeDays,
+		},
+		"error_reporting": map[string]interface{}{
+			"sentry_enabled": os.Getenv("SENTRY_DSN") != "",
+			"environment":    os.Getenv("SENTRY_ENVIRONMENT"),
+			"release":        os.Getenv("SENTRY_RELEASE"),
+		},
+		"slo": map[string]interface{}{
+			"window":               sloWindow.String(),
+			"availability":         sloAvailabilityGoal,
+			"latency":              sloLatencyGoal,
+			"latency_threshold_ms": sloLatencyThreshold.Milliseconds(),
+		},
+	}
+}
+
+// objectSourceSettings returns the settings of an object source without its credentials
+func objectSourceSettings(config ObjectSourceConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"buckets":  config.Buckets,
+		"endpoint": config.Endpoint,
+		"region":   config.Region,
+		"insecure": config.Insecure,
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRedactedDSN tests that the whole password is removed from the DSN, even when it has spaces
+func TestRedactedDSN(t *testing.T) {
+	database := DatabaseConfig{Host: "localhost", User: "postgres", Password: "correct horse battery", Name: "test", Port: 5432, SSLMode: "disable"}
+	assert.Equal(t, "host='localhost' user='postgres' password='****' dbname='test' port=5432 sslmode='disable'", database.redactedDSN())
+	assert.Equal(t, "correct horse battery", database.Password)
+	database.Password = ""
+	assert.Contains(t, database.redactedDSN(), "password=''")
+}
+
+// TestConfigEndpoint tests that the config dump endpoint never leaks secrets
+func TestConfigEndpoint(t *testing.T) {
+	disableAuth(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	gin.SetMode(gin.TestMode)
+	previous := appConfig
+	defer func() { appConfig = previous }()
+	appConfig.Database.Password = "Virat@2# Virat@2#"
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/config", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "chunk_size")
+	assert.NotContains(t, w.Body.String(), "Virat")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadConfigDefaults tests that the defaults are valid and used without a file or env vars
+func TestLoadConfigDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	config, err := loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, defaultConfig(), config)
+	assert.Equal(t, ":8080", config.Server.Addr())
+	assert.Equal(t, "host='localhost' user='postgres' password='' dbname='mini-Project' port=8899 sslmode='disable' application_name='mini-Project'", config.Database.DSN())
+}
+
+// TestDatabaseDSN tests that empty values and values with spaces, quotes and backslashes are read back intact
+func TestDatabaseDSN(t *testing.T) {
+	for _, password := range []string{"", "two words", `it's a \ secret`, "dbname=other"} {
+		database := defaultConfig().Database
+		database.Password = password
+		parsed, err := pgconn.ParseConfig(database.DSN())
+		require.NoError(t, err, password)
+		assert.Equal(t, password, parsed.Password)
+		assert.Equal(t, "mini-Project", parsed.Database)
+		assert.Equal(t, "postgres", parsed.User)
+		assert.Equal(t, "mini-Project", parsed.RuntimeParams["application_name"])
+	}
+}
+
+// TestLoadConfigFile tests loading YAML and JSON files with env vars taking precedence
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "config.yaml")
+	os.WriteFile(yamlPath, []byte("database:\n  host: db\n  password: secret\n  conn_max_lifetime: 1h\ningestion:\n  chunk_size: 200\n"), 0o600)
+	t.Setenv("CONFIG_FILE", yamlPath)
+	t.Setenv("DB_HOST", "override")
+	t.Setenv("SERVER_PORT", "9090")
+
+	config, err := loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "override", config.Database.Host)
+	assert.Equal(t, "secret", config.Database.Password)
+	assert.Equal(t, Duration(time.Hour), config.Database.ConnMaxLifetime)
+	assert.Equal(t, 200, config.Ingestion.ChunkSize)
+	assert.Equal(t, 10000, config.Ingestion.BatchSize)
+	assert.Equal(t, ":9090", config.Server.Addr())
+
+	// Bucket lists are comma-separated in env vars
+	t.Setenv("SOURCE_S3_BUCKETS", "exports, backfills,")
+	config, err = loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"exports", "backfills"}, config.Sources.S3.Buckets)
+	assert.Empty(t, config.Sources.GCS.Buckets)
+
+	// Upload limits are given in bytes
+	t.Setenv("CSV_MAX_UPLOAD_BYTES", "1073741824")
+	t.Setenv("CSV_MAX_TABLE_BYTES", "53687091200")
+	config, err = loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1<<30), config.Ingestion.MaxUploadBytes)
+	assert.Equal(t, int64(1<<30), config.Ingestion.MinFreeDiskBytes)
+	assert.Equal(t, int64(50<<30), config.Ingestion.MaxTableBytes)
+	assert.Equal(t, []int{80, 90}, config.Ingestion.QuotaWarnings)
+
+	t.Setenv("CSV_QUOTA_WARNINGS", "75%, 95")
+	config, err = loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, []int{75, 95}, config.Ingestion.QuotaWarnings)
+
+	t.Setenv("SOURCE_URL_HOSTS", "files.example.com,*.partner.net")
+	t.Setenv("SOURCE_URL_ALLOW_PRIVATE", "true")
+	config, err = loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, URLSourceConfig{Hosts: []string{"files.example.com", "*.partner.net"}, AllowPrivate: true}, config.Sources.URL)
+
+	jsonPath := filepath.Join(dir, "config.json")
+	os.WriteFile(jsonPath, []byte(`{"database": {"port": 5432}, "ingestion": {"batch_size": 50}}`), 0o600)
+	t.Setenv("CONFIG_FILE", jsonPath)
+
+	config, err = loadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, 5432, config.Database.Port)
+	assert.Equal(t, 50, config.Ingestion.BatchSize)
+
+	tomlPath := filepath.Join(dir, "config.toml")
+	os.WriteFile(tomlPath, []byte("[database]\n"), 0o600)
+	t.Setenv("CONFIG_FILE", tomlPath)
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "unsupported config file extension")
+}
+
+// TestLoadConfigInvalid tests that malformed env vars and invalid settings are rejected
+func TestLoadConfigInvalid(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_PORT", "abc")
+	_, err := loadConfig()
+	assert.ErrorContains(t, err, "invalid DB_PORT")
+
+	t.Setenv("DB_PORT", "0")
+	t.Setenv("DB_SSLMODE", "sometimes")
+	t.Setenv("CSV_CHUNK_SIZE", "0")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "database port 0 is out of range")
+	assert.ErrorContains(t, err, "unsupported database sslmode")
+	assert.ErrorContains(t, err, "chunk_size must be at least 1")
+
+	t.Setenv("CSV_MAX_WORKERS", "-1")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "ingestion min_workers and max_workers must not be negative")
+
+	t.Setenv("CSV_MIN_WORKERS", "4")
+	t.Setenv("CSV_MAX_WORKERS", "2")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "ingestion min_workers 4 must not exceed max_workers 2")
+
+	t.Setenv("CSV_QUOTA_WARNINGS", "high")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "invalid CSV_QUOTA_WARNINGS")
+
+	t.Setenv("CSV_QUOTA_WARNINGS", "80,150")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "ingestion quota_warnings 150 must be a percentage between 1 and 100")
+
+	t.Setenv("CSV_INSERT_METHOD", "bulk")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "unsupported ingestion insert_method")
+
+	t.Setenv("JSON_CASING", "kebab")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "unsupported server json_casing \"kebab\"")
+
+	t.Setenv("DB_MIGRATE", "always")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "unsupported database migrate mode")
+
+	t.Setenv("WAREHOUSE_BACKEND", "clickhouse")
+	t.Setenv("WAREHOUSE_URL", "localhost:8123")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "warehouse url \"localhost:8123\" must be an http or https url")
+
+	t.Setenv("AUTH_JWT_SECRET", "too short")
+	t.Setenv("AUTH_TOKEN_TTL", "0s")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "jwt_secret must be at least 32 characters")
+	assert.ErrorContains(t, err, "token_ttl and refresh_ttl must be positive")
+
+	t.Setenv("STORAGE_BACKEND", "s3")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "storage bucket is required for the s3 backend")
+
+	t.Setenv("SOURCE_GCS_BUCKETS", "exports/2024")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, `invalid gcs source bucket "exports/2024"`)
+
+	t.Setenv("CSV_MAX_UPLOAD_BYTES", "0")
+	t.Setenv("CSV_MIN_FREE_DISK_BYTES", "-1")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "ingestion max_upload_bytes must be positive")
+	assert.ErrorContains(t, err, "min_free_disk_bytes and max_table_bytes must not be negative")
+	t.Setenv("CSV_MAX_UPLOAD_BYTES", "lots")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, "invalid CSV_MAX_UPLOAD_BYTES")
+	os.Unsetenv("CSV_MAX_UPLOAD_BYTES")
+	os.Unsetenv("CSV_MIN_FREE_DISK_BYTES")
+
+	t.Setenv("SERVER_TRUSTED_PROXIES", "10.0.0.0/8,proxy")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, `invalid trusted proxy "proxy"`)
+
+	t.Setenv("SOURCE_URL_HOSTS", "*")
+	_, err = loadConfig()
+	assert.ErrorContains(t, err, `invalid url source host "*"`)
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Source provides the CSV data of an import
+type Source interface {
+	Open(ctx context.Context) (io.ReadCloser, error)
+}
+
+// releaser is implemented by sources holding data that must be removed once the import is finished
+type releaser interface {
+	Release()
+}
+
+// Sink writes parsed rows. A failed Write must not have written any of the rows,
+// so failed batches can be bisected and retried.
+type Sink interface {
+	Write(users []UserData) error
+}
+
+// contextSink is implemented by sinks whose writes can be scoped to the context of the running import
+type contextSink interface {
+	WithContext(ctx context.Context) Sink
+}
+
+// transactionalSink is implemented by sinks that can write several batches in one transaction. The sink
+// passed to fn writes within it, and a failed Write there only rolls back its own rows.
+type transactionalSink interface {
+	Transaction(fn func(tx Sink) error) error
+}
+
+// sinkReporter is implemented by sinks that add their own counters to the import metrics
+type sinkReporter interface {
+	report() map[string]interface{}
+}
+
+// connectorDeps are the shared services connectors may use
+type connectorDeps struct {
+	dbHandler DBHandler
+	blobs     BlobStore
+}
+
+// SourceFactory creates a source from its request parameters
+type SourceFactory func(deps connectorDeps, params map[string]string) (Source, error)
+
+// SinkFactory creates a sink from its request parameters
+type SinkFactory func(deps connectorDeps, params map[string]string) (Sink, error)
+
+// Connector registries by name; new connectors are added with registerSource and registerSink
+var (
+	connectorsMu    sync.RWMutex
+	sourceFactories = map[string]SourceFactory{
+		"url":  newURLSource,
+		"blob": newBlobSource,
+		"s3":   objectSourceFactory(storageS3),
+		"gcs":  objectSourceFactory(storageGCS),
+	}
+	sinkFactories = map[string]SinkFactory{
+		"postgres": newPostgresSink,
+	}
+)
+
+// registerSource makes a source available to imports under name
+func registerSource(name string, factory SourceFactory) {
+	connectorsMu.Lock()
+	defer connectorsMu.Unlock()
+	sourceFactories[name] = factory
+}
+
+// registerSink makes a sink available to imports under name
+func registerSink(name string, factory SinkFactory) {
+	connectorsMu.Lock()
+	defer connectorsMu.Unlock()
+	sinkFactories[name] = factory
+}
+
+// newSource creates the source registered under name
+func newSource(name string, deps connectorDeps, params map[string]string) (Source, error) {
+	connectorsMu.RLock()
+	factory, ok := sourceFactories[name]
+	connectorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q, expected one of %v", name, registeredNames(sourceFactories))
+	}
+	return factory(deps, params)
+}
+
+// newSink creates the sink registered under name
+func newSink(name string, deps connectorDeps, params map[string]string) (Sink, error) {
+	connectorsMu.RLock()
+	factory, ok := sinkFactories[name]
+	connectorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q, expected one of %v", name, registeredNames(sinkFactories))
+	}
+	return factory(deps, params)
+}
+
+// registeredNames returns the sorted names of a registry
+func registeredNames[F any](factories map[string]F) []string {
+	connectorsMu.RLock()
+	defer connectorsMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// URL source limits
+const (
+	urlSourceTimeout    = time.Hour      // How long a download may take by default
+	urlSourceMaxTimeout = 24 * time.Hour // Longest timeout an import may ask for
+	urlSourceRedirects  = 10             // Redirects followed per download
+)
+
+// errChecksumMismatch is returned at the end of a download whose SHA-256 differs from the expected one
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// errPrivateAddress is returned for downloads from an address the url source may not connect to
+var errPrivateAddress = errors.New("address is not allowed")
+
+// nonPublicPrefixes are the ranges refused besides those netip classifies as loopback, private, link-local
+// or multicast: "this network", which reaches the local host, and the shared address space of carrier NAT
+var nonPublicPrefixes = []netip.Prefix{netip.MustParsePrefix("0.0.0.0/8"), netip.MustParsePrefix("100.64.0.0/10")}
+
+// isPublicAddress reports whether addr is a public unicast address, e.g. not 127.0.0.1, 10.0.0.1 or
+// the 169.254.169.254 of cloud metadata services
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// refuseNonPublicAddress is the Control hook of the url source's dialer. It runs after the host name is
+// resolved, for every connection including those of redirects, so DNS names pointing inward are refused too.
+func refuseNonPublicAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddress(addr) {
+		return fmt.Errorf("%w: %s is not a public address", errPrivateAddress, host)
+	}
+	return nil
+}
+
+// checkURLTarget checks that the url source may download from u: an http or https url of an allowed host,
+// and not a literal non-public address
+func checkURLTarget(u *url.URL, config URLSourceConfig) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url source requires an http or https url, got %q", u.String())
+	}
+	host := strings.ToLower(u.Hostname())
+	if len(config.Hosts) > 0 && !urlHostAllowed(host, config.Hosts) {
+		return fmt.Errorf("host %q is not allowed for the url source, expected one of %v", host, config.Hosts)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !config.AllowPrivate && !isPublicAddress(addr) {
+		return fmt.Errorf("%w: %s is not a public address", errPrivateAddress, host)
+	}
+	return nil
+}
+
+// urlHostAllowed reports whether host is one of hosts, where *.example.com stands for the subdomains of
+// example.com
+func urlHostAllowed(host string, hosts []string) bool {
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// urlSource downloads the CSV over HTTP(S), streaming it into the import as it arrives
+type urlSource struct {
+	url      string
+	maxBytes int64
+	timeout  time.Duration // Covers the whole download, not only the response headers
+	sha256   []byte        // Expected digest of the file, nil when it isn't checked
+	config   URLSourceConfig
+}
+
+// newURLSource creates a source for the "url" parameter. "max_bytes" lowers the size limit, "timeout"
+// sets how long the download may take, e.g. 2h, and "sha256" is the hex digest the file must have.
+// Downloads are limited to the upload limit by default, which max_bytes can't raise.
+// The host must be allowed by the url source configuration.
+func newURLSource(deps connectorDeps, params map[string]string) (Source, error) {
+	u, err := url.Parse(params["url"])
+	if err != nil {
+		return nil, fmt.Errorf("url source requires an http or https url, got %q", params["url"])
+	}
+	config := appConfig.Sources.URL
+	if err := checkURLTarget(u, config); err != nil {
+		return nil, err
+	}
+	limit := appConfig.Ingestion.MaxUploadBytes
+	source := &urlSource{url: u.String(), maxBytes: limit, timeout: urlSourceTimeout, config: config}
+	if value := params["max_bytes"]; value != "" {
+		source.maxBytes, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || source.maxBytes < 1 || source.maxBytes > limit {
+			return nil, fmt.Errorf("max_bytes must be between 1 and %d, got %q", limit, value)
+		}
+	}
+	if value := params["timeout"]; value != "" {
+		source.timeout, err = time.ParseDuration(value)
+		if err != nil || source.timeout <= 0 || source.timeout > urlSourceMaxTimeout {
+			return nil, fmt.Errorf("timeout must be a duration up to %s, got %q", urlSourceMaxTimeout, value)
+		}
+	}
+	if value := params["sha256"]; value != "" {
+		source.sha256, err = hex.DecodeString(value)
+		if err != nil || len(source.sha256) != sha256.Size {
+			return nil, fmt.Errorf("sha256 must be %d hex characters, got %q", 2*sha256.Size, value)
+		}
+	}
+	return source, nil
+}
+
+// Open starts the download, refusing files whose declared length exceeds the limit
+func (s *urlSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download of %s failed with status %s", s.url, resp.Status)
+	}
+	if resp.ContentLength > s.maxBytes {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download of %s is %d bytes, limit is %d bytes", s.url, resp.ContentLength, s.maxBytes)
+	}
+	body := &downloadReader{body: resp.Body, url: s.url, limit: s.maxBytes, expected: s.sha256}
+	if s.sha256 != nil {
+		body.hash = sha256.New()
+	}
+	return body, nil
+}
+
+// client returns the HTTP client of the download. Unless private addresses are allowed, it refuses to
+// connect to them, and redirects are checked like the url itself. Proxies aren't used, since the
+// addresses they connect to can't be checked.
+func (s *urlSource) client() *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !s.config.AllowPrivate {
+		dialer.Control = refuseNonPublicAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   s.timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= urlSourceRedirects {
+				return fmt.Errorf("download of %s stopped after %d redirects", s.url, urlSourceRedirects)
+			}
+			return checkURLTarget(req.URL, s.config)
+		},
+	}
+}
+
+// downloadReader enforces the size limit of a download while it is read and checks its digest at the
+// end, returning an error in place of io.EOF when it doesn't match
+type downloadReader struct {
+	body     io.ReadCloser
+	url      string
+	limit    int64
+	read     int64
+	hash     hash.Hash // Digest of the bytes read so far, nil when it isn't checked
+	expected []byte
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	if allowed := r.limit - r.read + 1; int64(len(p)) > allowed {
+		p = p[:allowed] // Reading one byte past the limit tells a file of exactly the limit from a larger one
+	}
+	n, err := r.body.Read(p)
+	r.read += int64(n)
+	if r.read > r.limit {
+		return 0, fmt.Errorf("download of %s exceeds the limit of %d bytes", r.url, r.limit)
+	}
+	if r.hash != nil {
+		r.hash.Write(p[:n])
+		if err == io.EOF {
+			if actual := r.hash.Sum(nil); !bytes.Equal(actual, r.expected) {
+				return n, fmt.Errorf("%w: download of %s has sha256 %x, expected %x", errChecksumMismatch, r.url, actual, r.expected)
+			}
+		}
+	}
+	return n, err
+}
+
+func (r *downloadReader) Close() error {
+	return r.body.Close()
+}
+
+// blobSource reads the CSV from the configured blob store, e.g. an S3 or GCS bucket
+type blobSource struct {
+	blobs  BlobStore
+	key    string
+	remove bool // Delete the blob once the import is finished, used for HTTP uploads
+}
+
+// newBlobSource creates a source for the "key" parameter
+func newBlobSource(deps connectorDeps, params map[string]string) (Source, error) {
+	if params["key"] == "" {
+		return nil, fmt.Errorf("blob source requires a key")
+	}
+	return &blobSource{blobs: deps.blobs, key: params["key"]}, nil
+}
+
+// Open opens the blob
+func (s *blobSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return s.blobs.Get(ctx, s.key)
+}
+
+// Release deletes uploaded blobs that are only kept for the import
+func (s *blobSource) Release() {
+	if !s.remove {
+		return
+	}
+	if err := s.blobs.Delete(context.Background(), s.key); err != nil {
+		log.WithError(err).WithField("key", s.key).Error("Failed to delete stored upload")
+	}
+}
+
+// postgresSink writes rows to the user_data table with the configured insert method
+type postgresSink struct {
+	dbHandler DBHandler
+	batchSize int
+	upsert    bool
+	inTx      bool // Writes are part of a transaction, each in its own savepoint
+}
+
+// newPostgresSink creates the default sink; the "mode" parameter selects insert (default) or upsert.
+// When a warehouse is configured, the batches are copied to it after they are committed.
+func newPostgresSink(deps connectorDeps, params map[string]string) (Sink, error) {
+	sink := &postgresSink{dbHandler: deps.dbHandler, batchSize: appConfig.Ingestion.BatchSize}
+	switch params["mode"] {
+	case "", writeModeInsert:
+	case writeModeUpsert:
+		if err := deps.dbHandler.EnsureUniqueEmail(); err != nil {
+			return nil, err
+		}
+		sink.upsert = true
+	default:
+		return nil, fmt.Errorf("unsupported mode %q, expected %q or %q", params["mode"], writeModeInsert, writeModeUpsert)
+	}
+	if warehouse != nil {
+		return newTeeSink(sink, warehouse), nil // Copy committed batches to the warehouse
+	}
+	return sink, nil
+}
+
+// WithContext returns a copy of the sink whose queries use ctx and carry its query tags
+func (s *postgresSink) WithContext(ctx context.Context) Sink {
+	scoped := *s
+	scoped.dbHandler = contextDBHandler(ctx, s.dbHandler)
+	return &scoped
+}
+
+// Transaction runs fn with a copy of the sink writing in a single transaction
+func (s *postgresSink) Transaction(fn func(tx Sink) error) error {
+	return s.dbHandler.Transaction(func(tx DBHandler) error {
+		scoped := *s
+		scoped.dbHandler = tx
+		scoped.inTx = true
+		return fn(&scoped)
+	})
+}
+
+// Write inserts the rows in a single transaction or COPY, or a savepoint within the sink's transaction;
+// upserts always use INSERT ... ON CONFLICT
+func (s *postgresSink) Write(users []UserData) error {
+	if s.inTx {
+		return s.dbHandler.Transaction(func(tx DBHandler) error {
+			return s.write(tx, users)
+		})
+	}
+	return s.write(s.dbHandler, users)
+}
+
+// write inserts or upserts the rows with dbHandler
+func (s *postgresSink) write(dbHandler DBHandler, users []UserData) error {
+	if s.upsert {
+		return dbHandler.Upsert(users, s.batchSize)
+	}
+	return insertUsers(dbHandler, users, s.batchSize)
+}
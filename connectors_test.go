@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink collects written rows, standing in for a connector registered by another package
+type memorySink struct {
+	users []UserData
+}
+
+func (s *memorySink) Write(users []UserData) error {
+	s.users = append(s.users, users...)
+	return nil
+}
+
+// TestConnectorRegistry tests creating registered connectors and rejecting unknown ones
+func TestConnectorRegistry(t *testing.T) {
+	sink := &memorySink{}
+	registerSink("memory", func(deps connectorDeps, params map[string]string) (Sink, error) { return sink, nil })
+	defer func() {
+		connectorsMu.Lock()
+		delete(sinkFactories, "memory")
+		connectorsMu.Unlock()
+	}()
+
+	created, err := newSink("memory", connectorDeps{}, nil)
+	assert.NoError(t, err)
+	assert.Same(t, sink, created)
+
+	_, err = newSink("kafka", connectorDeps{}, nil)
+	assert.ErrorContains(t, err, "expected one of [memory postgres]")
+
+	_, err = newSource("url", connectorDeps{}, map[string]string{"url": "ftp://example.com/a.csv"})
+	assert.Error(t, err)
+	_, err = newSource("blob", connectorDeps{}, nil)
+	assert.Error(t, err)
+}
+
+// allowPrivateURLs lets the url source download from the test servers, which listen on the loopback address
+func allowPrivateURLs(t *testing.T) {
+	previous := appConfig.Sources.URL
+	t.Cleanup(func() { appConfig.Sources.URL = previous })
+	appConfig.Sources.URL = URLSourceConfig{AllowPrivate: true}
+}
+
+// TestURLSource tests downloading the CSV of an import
+func TestURLSource(t *testing.T) {
+	allowPrivateURLs(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users.csv" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "ID\n1\n")
+	}))
+	defer server.Close()
+
+	source, err := newSource("url", connectorDeps{}, map[string]string{"url": server.URL + "/users.csv"})
+	assert.NoError(t, err)
+	body, err := source.Open(context.Background())
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "ID\n1\n", string(data))
+
+	source, _ = newSource("url", connectorDeps{}, map[string]string{"url": server.URL + "/missing.csv"})
+	_, err = source.Open(context.Background())
+	assert.ErrorContains(t, err, "404")
+}
+
+// TestURLSourceLimits tests the size limit, timeout and checksum of downloads
+func TestURLSourceLimits(t *testing.T) {
+	allowPrivateURLs(t)
+	content := "ID\n1\n2\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chunked.csv":
+			w.Write([]byte(content)) // Flushed before the handler returns, so no Content-Length is sent
+			w.(http.Flusher).Flush()
+		case "/slow.csv":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			io.WriteString(w, content)
+		}
+	}))
+	defer server.Close()
+
+	download := func(path string, params map[string]string) (string, error) {
+		if params == nil {
+			params = map[string]string{}
+		}
+		params["url"] = server.URL + path
+		source, err := newSource("url", connectorDeps{}, params)
+		require.NoError(t, err)
+		body, err := source.Open(context.Background())
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		return string(data), err
+	}
+
+	data, err := download("/users.csv", map[string]string{"max_bytes": strconv.Itoa(len(content))})
+	assert.NoError(t, err, "files of exactly the limit are read")
+	assert.Equal(t, content, data)
+
+	_, err = download("/users.csv", map[string]string{"max_bytes": "4"})
+	assert.ErrorContains(t, err, "is 7 bytes, limit is 4 bytes", "declared lengths are checked before reading")
+	_, err = download("/chunked.csv", map[string]string{"max_bytes": "4"})
+	assert.ErrorContains(t, err, "exceeds the limit of 4 bytes")
+
+	sum := sha256.Sum256([]byte(content))
+	_, err = download("/users.csv", map[string]string{"sha256": strings.ToUpper(hex.EncodeToString(sum[:]))})
+	assert.NoError(t, err)
+	other := sha256.Sum256([]byte("other"))
+	_, err = download("/users.csv", map[string]string{"sha256": hex.EncodeToString(other[:])})
+	assert.ErrorIs(t, err, errChecksumMismatch)
+
+	_, err = download("/slow.csv", map[string]string{"timeout": "50ms"})
+	assert.Error(t, err)
+
+	for params, message := range map[string]string{
+		`{"max_bytes": "0"}`:     "max_bytes must be between 1",
+		`{"timeout": "forever"}`: "timeout must be a duration",
+		`{"timeout": "48h"}`:     "timeout must be a duration up to 24h0m0s",
+		`{"sha256": "abc"}`:      "sha256 must be 64 hex characters",
+	} {
+		var parsed map[string]string
+		require.NoError(t, json.Unmarshal([]byte(params), &parsed))
+		parsed["url"] = server.URL
+		_, err := newSource("url", connectorDeps{}, parsed)
+		assert.ErrorContains(t, err, message, params)
+	}
+}
+
+// TestURLSourceAddresses tests that downloads can't reach loopback, private or link-local addresses, directly
+// or through DNS names and redirects, and are limited to the configured hosts
+func TestURLSourceAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect.csv" {
+			http.Redirect(w, r, "http://127.0.0.1:"+r.Host[strings.LastIndex(r.Host, ":")+1:]+"/users.csv", http.StatusFound)
+			return
+		}
+		io.WriteString(w, "ID\n1\n")
+	}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	previous := appConfig.Sources.URL
+	defer func() { appConfig.Sources.URL = previous }()
+	appConfig.Sources.URL = URLSourceConfig{}
+
+	for _, address := range []string{"127.0.0.1", "10.1.2.3", "192.168.0.10", "169.254.169.254", "[::1]", "[::ffff:127.0.0.1]", "0.0.0.0", "100.64.0.1"} {
+		_, err := newSource("url", connectorDeps{}, map[string]string{"url": "http://" + address + "/users.csv"})
+		assert.ErrorIs(t, err, errPrivateAddress, address)
+	}
+	_, err := newSource("url", connectorDeps{}, map[string]string{"url": "http://93.184.215.14/users.csv"})
+	assert.NoError(t, err)
+
+	// Names are checked once resolved, when connecting
+	source, err := newSource("url", connectorDeps{}, map[string]string{"url": "http://localhost:" + port + "/users.csv"})
+	require.NoError(t, err)
+	_, err = source.Open(context.Background())
+	assert.ErrorIs(t, err, errPrivateAddress)
+
+	// Redirects are checked against the allowed hosts like the url itself
+	appConfig.Sources.URL = URLSourceConfig{Hosts: []string{"localhost"}, AllowPrivate: true}
+	source, err = newSource("url", connectorDeps{}, map[string]string{"url": "http://localhost:" + port + "/users.csv"})
+	require.NoError(t, err)
+	body, err := source.Open(context.Background())
+	require.NoError(t, err)
+	body.Close()
+	source, _ = newSource("url", connectorDeps{}, map[string]string{"url": "http://localhost:" + port + "/redirect.csv"})
+	_, err = source.Open(context.Background())
+	assert.ErrorContains(t, err, `host "127.0.0.1" is not allowed`)
+
+	appConfig.Sources.URL = URLSourceConfig{Hosts: []string{"*.example.com"}}
+	_, err = newSource("url", connectorDeps{}, map[string]string{"url": "https://files.Example.com/users.csv"})
+	assert.NoError(t, err)
+	for _, host := range []string{"example.com", "example.com.evil.net", "badexample.com"} {
+		_, err = newSource("url", connectorDeps{}, map[string]string{"url": "https://" + host + "/users.csv"})
+		assert.ErrorContains(t, err, "is not allowed for the url source", host)
+	}
+}
+
+// TestCreateURLImport tests streaming a remote CSV into a sink, and failing the import when its checksum
+// doesn't match
+func TestCreateURLImport(t *testing.T) {
+	allowPrivateURLs(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sink := &memorySink{}
+	registerSink("memory", func(deps connectorDeps, params map[string]string) (Sink, error) { return sink, nil })
+	defer func() {
+		connectorsMu.Lock()
+		delete(sinkFactories, "memory")
+		connectorsMu.Unlock()
+	}()
+
+	csvData := "ID,FirstName,LastName,Email,Age,Gender,Department,Company,Salary,DateJoined,IsActive\n" +
+		"1,John,Doe,john@example.com,30,Male,IT,ExampleCorp,50000,2020-01-01,true\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, csvData)
+	}))
+	defer server.Close()
+
+	store, final := newRecordingJobStore(ctrl)
+	blobs, err := newLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	imports := newImportManager(store, blobs)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/imports/url", func(c *gin.Context) {
+		createURLImport(c, NewMockDBHandler(ctrl), imports)
+	})
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/imports/url", strings.NewReader(body))
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	sum := sha256.Sum256([]byte(csvData))
+	assert.Equal(t, 202, post(`{"url": "`+server.URL+`/users.csv", "sha256": "`+hex.EncodeToString(sum[:])+`", "max_bytes": 1000, "sink": "memory", "tags": ["backfill-2023"]}`))
+	imports.Wait()
+	assert.Equal(t, importDone, final().State)
+	assert.Equal(t, server.URL+"/users.csv", final().FileName)
+	assert.Equal(t, importTags{"backfill-2023"}, final().Tags)
+	assert.Len(t, sink.users, 1)
+	assert.Equal(t, 400, post(`{"url": "`+server.URL+`/users.csv", "sink": "memory", "tags": ["backfill 2023"]}`))
+
+	// A corrupt download fails the import
+	sink.users = nil
+	assert.Equal(t, 202, post(`{"url": "`+server.URL+`/users.csv", "sha256": "`+strings.Repeat("0", 64)+`", "sink": "memory"}`))
+	imports.Wait()
+	assert.Equal(t, importFailed, final().State)
+	assert.Contains(t, final().Error, "checksum mismatch")
+
+	// A strict import stores the download before validating it, so a corrupt one fails before any row is written
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/imports/url?validation=strict", strings.NewReader(`{"url": "`+server.URL+`/users.csv", "sha256": "`+strings.Repeat("0", 64)+`", "sink": "memory"}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 202, w.Code)
+	imports.Wait()
+	assert.Equal(t, importFailed, final().State)
+	assert.Contains(t, final().Error, "checksum mismatch")
+	assert.Empty(t, sink.users)
+
+	assert.Equal(t, 400, post(`{"url": "`+server.URL+`/users.csv", "timeout": "soon"}`))
+	assert.Equal(t, 400, post(`{"sha256": "`+hex.EncodeToString(sum[:])+`"}`))
+}
+
+// TestCreateImport tests queueing an import from a blob into a registered sink
+func TestCreateImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sink := &memorySink{}
+	registerSink("memory", func(deps connectorDeps, params map[string]string) (Sink, error) { return sink, nil })
+	defer func() {
+		connectorsMu.Lock()
+		delete(sinkFactories, "memory")
+		connectorsMu.Unlock()
+	}()
+
+	blobs, _ := newLocalBlobStore(t.TempDir())
+	csvData := "ID,FirstName,LastName,Email,Age,Gender,Department,Company,Salary,DateJoined,IsActive\n" +
+		"1,John,Doe,john@example.com,30,Male,IT,ExampleCorp,50000,2020-01-01,true\n"
+	assert.NoError(t, blobs.Put(context.Background(), "exports/users.csv", strings.NewReader(csvData), int64(len(csvData))))
+
+	store, final := newRecordingJobStore(ctrl)
+	imports := newImportManager(store, blobs)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/imports", func(c *gin.Context) {
+		createImport(c, NewMockDBHandler(ctrl), imports)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/imports", strings.NewReader(`{"source": "blob", "source_params": {"key": "exports/users.csv"}, "sink": "memory"}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 202, w.Code)
+
+	imports.Wait()
+	assert.Equal(t, importDone, final().State)
+	assert.Equal(t, "exports/users.csv", final().FileName)
+	assert.Len(t, sink.users, 1)
+	assert.Equal(t, "John", sink.users[0].FirstName)
+
+	// Blobs named by the request are not deleted after the import
+	_, err := blobs.Get(context.Background(), "exports/users.csv")
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/imports", strings.NewReader(`{"source": "sftp"}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+// TestPostgresSinkModes tests that upsert mode creates the email index and writes with upserts
+func TestPostgresSinkModes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDBHandler := NewMockDBHandler(ctrl)
+	deps := connectorDeps{dbHandler: mockDBHandler}
+	users := []UserData{{Email: "jane@example.com"}}
+
+	mockDBHandler.EXPECT().EnsureUniqueEmail().Return(nil)
+	mockDBHandler.EXPECT().Upsert(users, gomock.Any()).Return(nil)
+	sink, err := newSink("postgres", deps, map[string]string{"mode": "upsert"})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write(users))
+
+	mockDBHandler.EXPECT().CreateInBatches(users, gomock.Any()).Return(nil)
+	sink, err = newSink("postgres", deps, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write(users))
+
+	// The index can't be created while the table holds duplicate emails
+	mockDBHandler.EXPECT().EnsureUniqueEmail().Return(errors.New("could not create unique index"))
+	_, err = newSink("postgres", deps, map[string]string{"mode": "upsert"})
+	assert.ErrorContains(t, err, "could not create unique index")
+
+	_, err = newSink("postgres", deps, map[string]string{"mode": "merge"})
+	assert.ErrorContains(t, err, "unsupported mode")
+}
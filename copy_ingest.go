@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Supported ways of writing CSV rows to the database
+const (
+	insertMethodInsert = "insert" // Multi-row INSERT statements through GORM
+	insertMethodCopy   = "copy"   // PostgreSQL COPY protocol, much faster for large files
+)
+
+// copyColumns are the user_data columns written by COPY, in the order of copyRows values
+var copyColumns = []string{
+	"first_name", "last_name", "email", "age", "gender",
+	"department", "company", "salary", "date_joined", "is_active",
+	"import_id", "source_file", "source_row_number",
+}
+
+// insertUsers writes users with the configured insert method
+func insertUsers(dbHandler DBHandler, users []UserData, batchSize int) error {
+	if appConfig.Ingestion.InsertMethod == insertMethodCopy {
+		return dbHandler.CopyFrom(users)
+	}
+	return dbHandler.CreateInBatches(users, batchSize)
+}
+
+// copyRows converts users to COPY values, followed by those of the added extra columns;
+// COPY uses the binary format, so dates are passed as times and empty ones as NULL
+func copyRows(users []UserData, extra []string) [][]interface{} {
+	rows := make([][]interface{}, len(users))
+	for i, user := range users {
+		var dateJoined interface{}
+		if !user.DateJoined.IsZero() {
+			dateJoined = user.DateJoined.Time
+		}
+		rows[i] = []interface{}{
+			user.FirstName, user.LastName, user.Email, user.Age, user.Gender,
+			user.Department, user.Company, user.Salary, dateJoined, user.IsActive,
+			user.Provenance.ImportID, user.Provenance.SourceFile, user.Provenance.SourceRowNumber,
+		}
+		for _, column := range extra {
+			rows[i] = append(rows[i], extraValue(user, column))
+		}
+	}
+	return rows
+}
+
+// CopyFrom writes users with a single COPY on a pooled pgx connection, or on the connection of the handler's
+// transaction; like an INSERT it is all or nothing
+func (handler *GormDBHandler) CopyFrom(users []UserData) error {
+	extra := extraColumns(users)
+	rows := copyRows(users, extra)
+
+	ctx := handler.db.Statement.Context // COPY can't carry query tags, but is cancelled with the import
+	conn := handler.conn
+	if conn == nil {
+		sqlDB, err := handler.db.DB()
+		if err != nil {
+			return err
+		}
+		if conn, err = sqlDB.Conn(ctx); err != nil {
+			return err
+		}
+		defer conn.Close()
+	}
+
+	return conn.Raw(func(driverConn interface{}) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("COPY requires the pgx driver, got %T", driverConn)
+		}
+		_, err := stdlibConn.Conn().CopyFrom(ctx, pgx.Identifier{UserData{}.TableName()}, append(copyColumns[:len(copyColumns):len(copyColumns)], extra...), pgx.CopyFromRows(rows))
+		return err
+	})
+}
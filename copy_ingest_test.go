@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCopyRows tests converting users to COPY values
+func TestCopyRows(t *testing.T) {
+	rows := copyRows([]UserData{
+		{FirstName: "John", Age: 30, Salary: 50000, DateJoined: newDate(2020, 1, 31), IsActive: true},
+		{FirstName: "Jane"},
+	}, nil)
+	assert.Len(t, rows[0], len(copyColumns))
+	assert.Equal(t, time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC), rows[0][8])
+	assert.Nil(t, rows[1][8])
+}
+
+// TestInsertUsersCopy tests that the copy insert method writes through CopyFrom
+func TestInsertUsersCopy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := appConfig
+	defer func() { appConfig = previous }()
+	appConfig.Ingestion.InsertMethod = insertMethodCopy
+
+	users := []UserData{{FirstName: "John"}}
+	mockDBHandler := NewMockDBHandler(ctrl)
+	mockDBHandler.EXPECT().CopyFrom(users).Return(nil).Times(1)
+	assert.NoError(t, insertUsers(mockDBHandler, users, 100))
+}
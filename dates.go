@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// dateLayouts are the date layouts uploads may use, by the name given in the date_formats parameter.
+// Days and months may be written with one or two digits.
+var dateLayouts = map[string]string{
+	"yyyy-mm-dd": "2006-1-2",
+	"dd/mm/yyyy": "2/1/2006",
+	"mm/dd/yyyy": "1/2/2006",
+	"mm-dd-yyyy": "1-2-2006",
+	"dd.mm.yyyy": "2.1.2006",
+}
+
+// defaultDateFormats are tried in order when an upload doesn't name its formats; their separators
+// differ, so no date matches two of them
+var defaultDateFormats = []string{"yyyy-mm-dd", "dd/mm/yyyy", "mm-dd-yyyy"}
+
+// apiDateFormats are the formats of dates in JSON bodies
+var apiDateFormats = []string{"yyyy-mm-dd"}
+
+// dateType is the reflected type of Date, for telling its decoding errors apart
+var dateType = reflect.TypeOf(Date{})
+
+// Date is a calendar date stored in a date column. The zero Date is stored as NULL and encoded as null in JSON;
+// other dates are encoded as YYYY-MM-DD.
+type Date struct {
+	time.Time
+}
+
+// newDate returns the date of t, dropping its time of day
+func newDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// String formats the date as YYYY-MM-DD, or returns an empty string for the zero Date
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(time.DateOnly)
+}
+
+// GormDataType is the column type of dates
+func (Date) GormDataType() string {
+	return "date"
+}
+
+// Value stores the date, or NULL for the zero Date
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.String(), nil
+}
+
+// Scan reads a date column
+func (d *Date) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*d = Date{}
+	case time.Time:
+		*d = newDate(value.Date())
+	case string:
+		return d.scanString(value)
+	case []byte:
+		return d.scanString(string(value))
+	default:
+		return fmt.Errorf("cannot scan %T into a date", src)
+	}
+	return nil
+}
+
+// scanString parses a date read as text, as YYYY-MM-DD or a timestamp
+func (d *Date) scanString(value string) error {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		*d = newDate(t.Date())
+		return nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into a date", value)
+	}
+	*d = Date{t}
+	return nil
+}
+
+// MarshalJSON encodes the date as YYYY-MM-DD, or null for the zero Date
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes null, an empty string or a date in one of apiDateFormats
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Date{}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &json.UnmarshalTypeError{Value: "non-string", Type: dateType}
+	}
+	parsed, err := parseDate(value, apiDateFormats)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: "string " + value, Type: dateType}
+	}
+	*d = parsed
+	return nil
+}
+
+// parseDateFormats parses a comma-separated list of date format names, e.g. "dd/mm/yyyy,yyyy-mm-dd".
+// An empty list means defaultDateFormats.
+func parseDateFormats(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return defaultDateFormats, nil
+	}
+	var formats []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := dateLayouts[name]; !ok {
+			return nil, fmt.Errorf("unknown date format %q, expected yyyy-mm-dd, dd/mm/yyyy, mm/dd/yyyy, mm-dd-yyyy or dd.mm.yyyy", name)
+		}
+		formats = append(formats, name)
+	}
+	return formats, nil
+}
+
+// parseDate parses a date with the first of the named formats that matches it, or defaultDateFormats
+// when formats is empty. An empty value is the zero Date.
+func parseDate(value string, formats []string) (Date, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Date{}, nil
+	}
+	if len(formats) == 0 {
+		formats = defaultDateFormats
+	}
+	for _, name := range formats {
+		if t, err := time.Parse(dateLayouts[name], value); err == nil {
+			return Date{t}, nil
+		}
+	}
+	return Date{}, fmt.Errorf("invalid date %q", value)
+}
+
+// dateFieldError describes a date_joined value matching none of the formats
+func dateFieldError(formats []string) fieldError {
+	if len(formats) == 0 {
+		formats = defaultDateFormats
+	}
+	return fieldError{Field: "date_joined", Message: "must be a date formatted as " + strings.Join(formats, ", ")}
+}
+
+// dateColumnConversion converts text dates of the default formats to dates when changing the column's type;
+// other values become NULL
+const dateColumnConversion = `CASE
+	WHEN btrim(date_joined) ~ '^\d{4}-\d{1,2}-\d{1,2}$' THEN to_date(btrim(date_joined), 'YYYY-MM-DD')
+	WHEN btrim(date_joined) ~ '^\d{1,2}/\d{1,2}/\d{4}$' THEN to_date(btrim(date_joined), 'DD/MM/YYYY')
+	WHEN btrim(date_joined) ~ '^\d{1,2}-\d{1,2}-\d{4}$' THEN to_date(btrim(date_joined), 'MM-DD-YYYY')
+END`
+
+// migrateDateColumn changes a date_joined column created as text, e.g. by hand or by an older schema,
+// to the date type, converting the values written in the default formats. Date columns are left alone.
+func migrateDateColumn(db *gorm.DB) error {
+	var dataType string
+	err := db.Raw(`SELECT data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ? AND column_name = 'date_joined'`, UserData{}.TableName()).Scan(&dataType).Error
+	if err != nil || dataType == "" || dataType == "date" {
+		return err
+	}
+	if dataType != "text" && dataType != "character varying" {
+		return fmt.Errorf("can't convert date_joined of type %s to date", dataType)
+	}
+
+	log.WithField("type", dataType).Warn("Converting date_joined to the date type")
+	return db.Exec("ALTER TABLE " + UserData{}.TableName() + " ALTER COLUMN date_joined TYPE date USING " + dateColumnConversion).Error
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseDate tests parsing dates with the named formats in order
+func TestParseDate(t *testing.T) {
+	formats, err := parseDateFormats("")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultDateFormats, formats)
+
+	for value, expected := range map[string]Date{
+		"2020-01-31": newDate(2020, 1, 31),
+		"2020-1-5":   newDate(2020, 1, 5),
+		"31/01/2020": newDate(2020, 1, 31),
+		"01-31-2020": newDate(2020, 1, 31),
+		" ":          {},
+	} {
+		date, err := parseDate(value, formats)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, date, value)
+	}
+	for _, value := range []string{"2020-02-30", "31-01-2020", "01/31/2020", "yesterday"} {
+		_, err := parseDate(value, formats)
+		assert.Error(t, err, value)
+	}
+
+	formats, err = parseDateFormats("MM/DD/YYYY, dd.mm.yyyy")
+	assert.NoError(t, err)
+	date, err := parseDate("01/31/2020", formats)
+	assert.NoError(t, err)
+	assert.Equal(t, newDate(2020, 1, 31), date)
+	assert.Equal(t, "must be a date formatted as mm/dd/yyyy, dd.mm.yyyy", dateFieldError(formats).Message)
+
+	_, err = parseDateFormats("yyyy/mm/dd")
+	assert.Error(t, err)
+}
+
+// TestDateEncoding tests reading and writing dates in JSON and the database, the zero Date as null
+func TestDateEncoding(t *testing.T) {
+	data, err := json.Marshal([]Date{newDate(2022, 1, 1), {}})
+	assert.NoError(t, err)
+	assert.Equal(t, `["2022-01-01",null]`, string(data))
+
+	var dates []Date
+	assert.NoError(t, json.Unmarshal([]byte(`["2022-01-01",null,""]`), &dates))
+	assert.Equal(t, []Date{newDate(2022, 1, 1), {}, {}}, dates)
+	var date Date
+	assert.Error(t, json.Unmarshal([]byte(`"01/02/2022"`), &date))
+	assert.Error(t, json.Unmarshal([]byte(`20220101`), &date))
+
+	value, err := newDate(2022, 1, 1).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "2022-01-01", value)
+	value, err = Date{}.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	for _, src := range []interface{}{time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local), "2022-01-01T00:00:00Z", []byte("2022-01-01")} {
+		assert.NoError(t, date.Scan(src))
+		assert.Equal(t, newDate(2022, 1, 1), date)
+	}
+	assert.NoError(t, date.Scan(nil))
+	assert.True(t, date.IsZero())
+	assert.Error(t, date.Scan(42))
+}
+
+// TestImportDateFormats tests that imports parse dates with the upload's formats and reject the others
+func TestImportDateFormats(t *testing.T) {
+	csvData := "first_name,last_name,email,age,salary,date_joined\n" +
+		"Jane,Doe,jane@example.com,30,50000,01/31/2020\n" +
+		"John,Doe,john@example.com,40,40000,2020-01-31\n" +
+		"Jim,Doe,jim@example.com,50,30000,31/01/2020\n"
+
+	formats, _ := parseDateFormats("mm/dd/yyyy,yyyy-mm-dd")
+	overflow, _ := newOverflowHandler("")
+	sink := &memorySink{}
+	progress := &importProgress{}
+	_, err := runImport(context.Background(), strings.NewReader(csvData), sink, importOptions{overflow: overflow, preserveOrder: true, dateFormats: formats}, progress)
+	assert.NoError(t, err)
+	if assert.Len(t, sink.users, 2) {
+		assert.Equal(t, newDate(2020, 1, 31), sink.users[0].DateJoined)
+		assert.Equal(t, newDate(2020, 1, 31), sink.users[1].DateJoined)
+	}
+	rowErrors, _ := progress.rejectedRows()
+	if assert.Len(t, rowErrors, 1) {
+		assert.Equal(t, "date_joined", rowErrors[0].Column)
+		assert.Equal(t, "date_joined must be a date formatted as mm/dd/yyyy, yyyy-mm-dd", rowErrors[0].Reason)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// dbActivity is a backend of the application database as reported by pg_stat_activity
+type dbActivity struct {
+	PID             int        `json:"pid"`
+	Username        string     `json:"username"`
+	ApplicationName string     `json:"application_name"`
+	ClientAddr      string     `json:"client_addr"`
+	State           string     `json:"state"`
+	WaitEventType   string     `json:"wait_event_type"`
+	WaitEvent       string     `json:"wait_event"`
+	QueryStart      *time.Time `json:"query_start"`
+	DurationMs      int64      `json:"duration_ms"`
+	Query           string     `json:"query"`
+}
+
+// queryActivity lists and cancels the queries running against the application database
+var queryActivity ActivityStore
+
+// ActivityStore lists the backends of the application database and cancels their queries
+type ActivityStore interface {
+	List() ([]dbActivity, error)
+	Cancel(pid int) (bool, error)
+}
+
+// GormActivityStore is a concrete implementation of ActivityStore using GORM
+type GormActivityStore struct {
+	db *gorm.DB
+}
+
+// WithContext returns a store whose queries use ctx and carry its query tags
+func (s *GormActivityStore) WithContext(ctx context.Context) ActivityStore {
+	return &GormActivityStore{db: s.db.WithContext(ctx)}
+}
+
+// List lists the other backends connected to the application database, longest running query first
+func (s *GormActivityStore) List() ([]dbActivity, error) {
+	var activity []dbActivity
+	err := s.db.Raw(`SELECT pid, usename AS username, application_name,
+		COALESCE(host(client_addr), '') AS client_addr, COALESCE(state, '') AS state,
+		COALESCE(wait_event_type, '') AS wait_event_type, COALESCE(wait_event, '') AS wait_event,
+		query_start, COALESCE(EXTRACT(EPOCH FROM now() - query_start) * 1000, 0)::bigint AS duration_ms, query
+		FROM pg_stat_activity
+		WHERE datname = current_database() AND pid <> pg_backend_pid()
+		ORDER BY query_start NULLS LAST`).Scan(&activity).Error
+	return activity, err
+}
+
+// Cancel cancels the running query of a backend of the application database.
+// It reports false if no such backend exists.
+func (s *GormActivityStore) Cancel(pid int) (bool, error) {
+	var result struct{ Cancelled bool }
+	tx := s.db.Raw(`SELECT pg_cancel_backend(pid) AS cancelled FROM pg_stat_activity
+		WHERE pid = ? AND datname = current_database() AND pid <> pg_backend_pid()`, pid).Scan(&result)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0 && result.Cancelled, nil
+}
+
+// dbActivityHandler handles GET /api/admin/db/activity; min_duration (e.g. 30s) only lists queries running at least that long
+func dbActivityHandler(c *gin.Context, store ActivityStore) {
+	var minDuration time.Duration
+	if value := c.Query("min_duration"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			respondError(c, 400, "Invalid min_duration value", "expected a duration such as 30s or 5m")
+			return
+		}
+		minDuration = parsed
+	}
+
+	activity, err := store.List()
+	if err != nil {
+		log.WithError(err).Error("Failed to read database activity")
+		respondError(c, 500, "Failed to read database activity", err.Error())
+		return
+	}
+
+	filtered := make([]dbActivity, 0, len(activity))
+	for _, backend := range activity {
+		if time.Duration(backend.DurationMs)*time.Millisecond >= minDuration {
+			filtered = append(filtered, backend)
+		}
+	}
+	respond(c, 200, filtered, map[string]interface{}{"count": len(filtered)})
+}
+
+// cancelQueryHandler handles POST /api/admin/db/cancel/:pid, cancelling the backend's running query
+func cancelQueryHandler(c *gin.Context, store ActivityStore) {
+	pid, err := strconv.Atoi(c.Param("pid"))
+	if err != nil || pid <= 0 {
+		respondError(c, 400, "Invalid pid", "pid must be a positive integer")
+		return
+	}
+
+	cancelled, err := store.Cancel(pid)
+	if err != nil {
+		log.WithError(err).WithField("pid", pid).Error("Failed to cancel query")
+		respondError(c, 500, "Failed to cancel query", err.Error())
+		return
+	}
+	if !cancelled {
+		respondError(c, 404, "No query to cancel", "no backend of this database has pid "+c.Param("pid"))
+		return
+	}
+
+	log.WithFields(logrus.Fields{"pid": pid, "client_ip": c.ClientIP()}).Warn("Cancelled database query")
+	respond(c, 200, gin.H{"pid": pid, "cancelled": true}, nil)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDBActivity tests listing the running queries, optionally only the long-running ones
+func TestDBActivity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := time.Now().Add(-time.Minute)
+	store := NewMockActivityStore(ctrl)
+	store.EXPECT().List().Return([]dbActivity{
+		{PID: 101, State: "active", QueryStart: &started, DurationMs: 60000, Query: "SELECT * FROM user_data"},
+		{PID: 102, State: "idle", DurationMs: 20},
+	}, nil).Times(2)
+	store.EXPECT().List().Return(nil, errors.New("connection refused"))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/admin/db/activity", func(c *gin.Context) {
+		dbActivityHandler(c, store)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/db/activity", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":2`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/admin/db/activity?min_duration=30s", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.Contains(t, w.Body.String(), `"pid":101`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/admin/db/activity?min_duration=soon", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/admin/db/activity", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 500, w.Code)
+}
+
+// TestCancelQuery tests cancelling a running query by backend pid
+func TestCancelQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockActivityStore(ctrl)
+	store.EXPECT().Cancel(101).Return(true, nil)
+	store.EXPECT().Cancel(999).Return(false, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/admin/db/cancel/:pid", func(c *gin.Context) {
+		cancelQueryHandler(c, store)
+	})
+
+	for path, code := range map[string]int{
+		"/api/admin/db/cancel/101": 200,
+		"/api/admin/db/cancel/999": 404,
+		"/api/admin/db/cancel/abc": 400,
+		"/api/admin/db/cancel/-1":  400,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, path)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// csvColumns maps CSV column names to their position in an upload row
+var csvColumns = map[string]int{
+	"id":          0,
+	"first_name":  1,
+	"last_name":   2,
+	"email":       3,
+	"age":         4,
+	"gender":      5,
+	"department":  6,
+	"company":     7,
+	"salary":      8,
+	"date_joined": 9,
+	"is_active":   10,
+}
+
+// deduplicator drops rows whose key columns repeat those of a row already written from the same file.
+// A key only counts once its row is written, so a row rejected for another reason doesn't take a valid
+// duplicate down with it. Only a 128-bit hash of each key is kept, so memory stays small on large files.
+type deduplicator struct {
+	mu      sync.Mutex
+	key     string // Normalized key column names, e.g. "first_name,last_name"
+	columns []int
+	written map[dedupKey]struct{}
+	dropped int
+}
+
+// dedupKey is the hash of the key columns of a row
+type dedupKey [16]byte
+
+// newDeduplicator parses a comma-separated list of key columns, e.g. "email" or "first_name,last_name".
+// It returns nil when spec is empty, meaning deduplication is disabled.
+func newDeduplicator(spec string) (*deduplicator, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	d := &deduplicator{written: map[dedupKey]struct{}{}}
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		index, ok := csvColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown dedup column %q", name)
+		}
+		d.columns = append(d.columns, index)
+		names = append(names, name)
+	}
+	d.key = strings.Join(names, ",")
+	return d, nil
+}
+
+// keyOf hashes the key columns of a record. Keys are compared case-insensitively with surrounding
+// spaces trimmed.
+func (d *deduplicator) keyOf(record []string) dedupKey {
+	h := fnv.New128a()
+	for _, index := range d.columns {
+		value := ""
+		if index < len(record) {
+			value = strings.ToLower(strings.TrimSpace(record[index]))
+		}
+		h.Write([]byte(value))
+		h.Write([]byte{0}) // Separator so ("ab","c") and ("a","bc") differ
+	}
+	var key dedupKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// isWritten reports whether a row with the key was already written, counting the row as dropped if so
+func (d *deduplicator) isWritten(key dedupKey) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.written[key]; ok {
+		d.dropped++
+		return true
+	}
+	return false
+}
+
+// markWritten records that a row with the key was written, so later rows with it are dropped
+func (d *deduplicator) markWritten(key dedupKey) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.written[key] = struct{}{}
+}
+
+// heldRows are the rows of a chunk repeating the key of an earlier row in the chunk. They wait until that
+// row is written, taking its place if the sink rejects it, and are dropped otherwise.
+type heldRows struct {
+	rows  []chunkRow
+	byKey map[dedupKey][]int // Indexes of the rows not yet used, in file order
+}
+
+// hold keeps a row until the earlier row with its key is written
+func (h *heldRows) hold(row chunkRow) {
+	if h.byKey == nil {
+		h.byKey = map[dedupKey][]int{}
+	}
+	h.byKey[row.key] = append(h.byKey[row.key], len(h.rows))
+	h.rows = append(h.rows, row)
+}
+
+// settle records the keys of the rows of a batch the sink wrote and returns the next batch: for each row
+// it rejected, the next held row with the same key
+func (d *deduplicator) settle(batch []chunkRow, rejected []rejectedRow, held *heldRows) []chunkRow {
+	if d == nil {
+		return nil
+	}
+	failed := make(map[int]bool, len(rejected))
+	for _, row := range rejected {
+		failed[row.index] = true
+	}
+	var next []chunkRow
+	for i, row := range batch {
+		if !failed[i] {
+			d.markWritten(row.key)
+			continue
+		}
+		if waiting := held.byKey[row.key]; len(waiting) > 0 {
+			next = append(next, held.rows[waiting[0]])
+			held.byKey[row.key] = waiting[1:]
+		}
+	}
+	return next
+}
+
+// dropHeld rejects the held rows that weren't needed, since a row with their key was written
+func (d *deduplicator) dropHeld(held *heldRows, progress *importProgress) {
+	if d == nil {
+		return
+	}
+	waiting := map[int]bool{}
+	for _, indexes := range held.byKey {
+		for _, i := range indexes {
+			waiting[i] = true
+		}
+	}
+	for i, row := range held.rows {
+		if waiting[i] {
+			d.mu.Lock()
+			d.dropped++
+			d.mu.Unlock()
+			progress.reject(row.line, d.key, "duplicate of an earlier row", row.record)
+		}
+	}
+}
+
+// Dropped returns the number of duplicate rows dropped so far
+func (d *deduplicator) Dropped() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dropped
+}
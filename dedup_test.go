@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeduplicator tests recognizing repeated keys once a row with them is written
+func TestDeduplicator(t *testing.T) {
+	dedup, err := newDeduplicator("email")
+	assert.NoError(t, err)
+
+	row := func(first, email string) []string {
+		return []string{"1", first, "Doe", email, "30", "Male", "IT", "Corp", "50000", "2020-01-01", "true"}
+	}
+	john := dedup.keyOf(row("John", "john@example.com"))
+	assert.Equal(t, john, dedup.keyOf(row("Johnny", " JOHN@example.com ")))
+	assert.NotEqual(t, john, dedup.keyOf(row("Jane", "jane@example.com")))
+	assert.False(t, dedup.isWritten(john))
+	dedup.markWritten(john)
+	assert.True(t, dedup.isWritten(john))
+	assert.Equal(t, 1, dedup.Dropped())
+
+	// Composite keys compare every column
+	dedup, err = newDeduplicator("first_name, last_name")
+	assert.NoError(t, err)
+	assert.Equal(t, "first_name,last_name", dedup.key)
+	assert.Equal(t, dedup.keyOf(row("John", "a@example.com")), dedup.keyOf(row("John", "b@example.com")))
+	assert.NotEqual(t, dedup.keyOf(row("John", "a@example.com")), dedup.keyOf(row("Jane", "a@example.com")))
+}
+
+// rejectingSink fails every write containing a user with the given first name, standing in for rows the
+// database rejects
+type rejectingSink struct {
+	memorySink
+	firstName string
+}
+
+func (s *rejectingSink) Write(users []UserData) error {
+	for _, user := range users {
+		if user.FirstName == s.firstName {
+			return &pgconn.PgError{Code: "23514", Message: "value rejected"}
+		}
+	}
+	return s.memorySink.Write(users)
+}
+
+// TestDedupKeepsFirstWrittenRow tests that the first row of a key in the file is kept, and that a row the
+// sink rejects doesn't take its valid duplicates down with it
+func TestDedupKeepsFirstWrittenRow(t *testing.T) {
+	dedup, _ := newDeduplicator("email")
+	overflow, _ := newOverflowHandler("")
+	limiter := newAdaptiveLimiter(1, 1, time.Second, func() int { return 0 })
+	sink := &rejectingSink{firstName: "Rejected"}
+	progress := &importProgress{}
+	row := func(first, email string) []string {
+		return []string{"", first, "Doe", email, "30", "Male", "IT", "Corp", "50000", "2020-01-01", "true"}
+	}
+	process := func(lines []int, records ...[]string) {
+		limiter.Acquire()
+		processChunk(csvChunk{records: records, lines: lines}, sink, limiter, overflow, dedup, nil, progress)
+	}
+
+	process([]int{2, 3, 4, 5},
+		row("Rejected", "john@example.com"),
+		row("John", "john@example.com"),
+		row("Johnny", "john@example.com"),
+		row("Jane", "jane@example.com"),
+	)
+	process([]int{6, 7}, row("Jack", "JOHN@example.com"), row("Jill", "jill@example.com"))
+
+	var names []string
+	for _, user := range sink.users {
+		names = append(names, user.FirstName)
+	}
+	assert.Equal(t, []string{"Jane", "John", "Jill"}, names)
+	assert.Equal(t, 2, dedup.Dropped())
+
+	rejected, _ := progress.rejectedRows()
+	require.Len(t, rejected, 3)
+	assert.Equal(t, 2, rejected[0].Line)
+	assert.Contains(t, rejected[0].Reason, "value rejected")
+	for i, line := range []int{4, 6} {
+		assert.Equal(t, line, rejected[i+1].Line)
+		assert.Equal(t, "duplicate of an earlier row", rejected[i+1].Reason)
+		assert.Equal(t, "email", rejected[i+1].Column)
+	}
+}
+
+// TestNewDeduplicator tests key parsing
+func TestNewDeduplicator(t *testing.T) {
+	dedup, err := newDeduplicator("")
+	assert.NoError(t, err)
+	assert.Nil(t, dedup)
+	assert.Equal(t, 0, dedup.Dropped())
+
+	_, err = newDeduplicator("email,phone")
+	assert.Error(t, err)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package main
+
+import "golang.org/x/sys/windows"
+
+// freeDiskBytes returns the number of bytes available to the process on the volume holding path
+func freeDiskBytes(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var freeBytes, totalBytes, totalFreeBytes uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &freeBytes, &totalBytes, &totalFreeBytes); err != nil {
+		return 0, err
+	}
+	return freeBytes, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	requestIDHeader     = "X-Request-ID"
+	requestIDContextKey = "request_id"
+	requestStartKey     = "request_start"
+)
+
+// apiError is a single entry of the errors list in a response envelope
+type apiError struct {
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"` // JSON name of the invalid field, for validation errors
+	Details string `json:"details,omitempty"`
+}
+
+// envelope is the uniform shape of every API response
+type envelope struct {
+	Data   interface{}            `json:"data"`
+	Meta   map[string]interface{} `json:"meta"`
+	Errors []apiError             `json:"errors"`
+}
+
+// validRequestID matches the request IDs accepted from clients, such as UUIDs and the IDs of proxies and
+// tracing systems; others are replaced, so they can't forge log fields or bloat every line
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]{1,128}$`)
+
+// requestIDMiddleware propagates X-Request-ID (or generates one) and records the request start time
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		c.Set(requestIDContextKey, requestID)
+		c.Set(requestStartKey, time.Now())
+		c.Header(requestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// newRequestID returns a random 16-byte hex identifier
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// requestLogger returns the entry to log a request's messages with, tagged with its request ID so they
+// can be found in File.log along with the request and response lines and the error response sent
+func requestLogger(c *gin.Context) *logrus.Entry {
+	return log.WithField(requestIDContextKey, c.GetString(requestIDContextKey))
+}
+
+// responseMeta builds the meta block with request ID, timing and any extra fields
+func responseMeta(c *gin.Context, extra map[string]interface{}) map[string]interface{} {
+	meta := map[string]interface{}{"request_id": c.GetString(requestIDContextKey)}
+	if start, ok := c.Get(requestStartKey); ok {
+		meta["duration_ms"] = float64(time.Since(start.(time.Time)).Microseconds()) / 1000
+	}
+	for key, value := range extra {
+		meta[key] = value
+	}
+	return meta
+}
+
+// respond writes data wrapped in the response envelope
+func respond(c *gin.Context, code int, data interface{}, meta map[string]interface{}) {
+	renderJSON(c, code, envelope{Data: data, Meta: responseMeta(c, meta), Errors: []apiError{}})
+}
+
+// respondError writes an error wrapped in the response envelope
+func respondError(c *gin.Context, code int, message string, details ...string) {
+	apiErr := apiError{Message: message}
+	if len(details) > 0 {
+		apiErr.Details = details[0]
+	}
+	renderJSON(c, code, envelope{Meta: responseMeta(c, nil), Errors: []apiError{apiErr}})
+}
+
+// respondFieldErrors writes an error per invalid field wrapped in the response envelope
+func respondFieldErrors(c *gin.Context, code int, message string, errs fieldErrors) {
+	apiErrs := make([]apiError, len(errs))
+	for i, e := range errs {
+		apiErrs[i] = apiError{Message: message, Field: e.Field, Details: e.Message}
+	}
+	renderJSON(c, code, envelope{Meta: responseMeta(c, nil), Errors: apiErrs})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestResponseEnvelope tests the data/meta/errors envelope and request ID propagation
+func TestResponseEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestIDMiddleware())
+	r.GET("/ok", func(c *gin.Context) { respond(c, 200, []int{1, 2}, gin.H{"page": 1}) })
+	r.GET("/fail", func(c *gin.Context) { respondError(c, 400, "Invalid page number", "page must be >= 1") })
+
+	// A caller-supplied request ID is echoed back in the header and meta
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/ok", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	r.ServeHTTP(w, req)
+
+	var body envelope
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "abc-123", w.Header().Get(requestIDHeader))
+	assert.Equal(t, "abc-123", body.Meta["request_id"])
+	assert.Equal(t, 1.0, body.Meta["page"])
+	assert.Contains(t, body.Meta, "duration_ms")
+	assert.Equal(t, []interface{}{1.0, 2.0}, body.Data)
+	assert.Empty(t, body.Errors)
+
+	// Errors carry no data and a generated request ID
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/fail", nil)
+	r.ServeHTTP(w, req)
+
+	body = envelope{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 400, w.Code)
+	assert.Nil(t, body.Data)
+	assert.Len(t, body.Meta["request_id"], 32)
+	assert.Equal(t, []apiError{{Message: "Invalid page number", Details: "page must be >= 1"}}, body.Errors)
+}
+
+// TestRequestIDCorrelation tests that the request ID is logged with the request, the response and the
+// handler's messages, that malformed IDs are replaced, and that imports log the ID of their request
+func TestRequestIDCorrelation(t *testing.T) {
+	hook := logtest.NewLocal(log)
+	defer log.ReplaceHooks(make(logrus.LevelHooks))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestIDMiddleware(), requestResponseLogger())
+	r.POST("/upload-csv", func(c *gin.Context) {
+		requestLogger(c).Error("Failed to store upload")
+		respondError(c, 500, "Failed to store upload")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/upload-csv", nil)
+	req.Header.Set(requestIDHeader, "3f2c9a1e-upload")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "3f2c9a1e-upload", w.Header().Get(requestIDHeader))
+	assert.Contains(t, w.Body.String(), `"request_id":"3f2c9a1e-upload"`)
+	assert.Len(t, hook.AllEntries(), 3)
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, "3f2c9a1e-upload", entry.Data["request_id"], entry.Message)
+	}
+
+	for _, forged := range []string{"abc\n{\"level\":\"info\"}", "id with spaces", strings.Repeat("a", 129)} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/upload-csv", nil)
+		req.Header.Set(requestIDHeader, forged)
+		r.ServeHTTP(w, req)
+		assert.Len(t, w.Header().Get(requestIDHeader), 32, forged)
+	}
+
+	hook.Reset()
+	(&importProgress{jobID: 7, requestID: "3f2c9a1e-upload"}).logger().Info("CSV ingestion completed")
+	assert.Equal(t, logrus.Fields{"job_id": uint(7), "request_id": "3f2c9a1e-upload"}, hook.LastEntry().Data)
+}
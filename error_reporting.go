@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// setupSentry initializes Sentry error reporting from the environment.
+// Reporting is disabled when SENTRY_DSN is empty.
+func setupSentry() error {
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         os.Getenv("SENTRY_DSN"),
+		Environment: os.Getenv("SENTRY_ENVIRONMENT"),
+		Release:     os.Getenv("SENTRY_RELEASE"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize sentry: %w", err)
+	}
+	return nil
+}
+
+// flushSentry waits for buffered Sentry events to be delivered before exit
+func flushSentry() {
+	sentry.Flush(2 * time.Second)
+}
+
+// sentryMiddleware reports panics and 5xx responses to Sentry with the request context
+func sentryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub := sentry.CurrentHub().Clone()
+		hub.Scope().SetRequest(c.Request)
+
+		defer func() {
+			if err := recover(); err != nil {
+				hub.RecoverWithContext(c.Request.Context(), err)
+				log.WithField("panic", err).Error("Recovered from panic")
+				c.Abort()
+				respondError(c, 500, "Internal server error")
+			}
+		}()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 500 {
+			return
+		}
+
+		hub.WithScope(func(scope *sentry.Scope) {
+			scope.SetTag("route", c.FullPath())
+			scope.SetTag("status", fmt.Sprint(status))
+			scope.SetLevel(sentry.LevelError)
+			if err := c.Errors.Last(); err != nil {
+				hub.CaptureException(err)
+				return
+			}
+			hub.CaptureMessage(fmt.Sprintf("%d response for %s %s", status, c.Request.Method, c.FullPath()))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureTransport is a Sentry transport that keeps events in memory
+type captureTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *captureTransport) Flush(timeout time.Duration) bool       { return true }
+func (t *captureTransport) Configure(options sentry.ClientOptions) {}
+func (t *captureTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+// TestSentryMiddleware tests that panics and 5xx responses are reported
+func TestSentryMiddleware(t *testing.T) {
+	transport := &captureTransport{}
+	err := sentry.Init(sentry.ClientOptions{Dsn: "https://public@sentry.example.com/1", Transport: transport})
+	assert.NoError(t, err)
+	defer sentry.Init(sentry.ClientOptions{})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sentryMiddleware())
+	r.GET("/ok", func(c *gin.Context) { c.JSON(200, gin.H{}) })
+	r.GET("/fail", func(c *gin.Context) { c.JSON(500, gin.H{"error": "boom"}) })
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	for path, status := range map[string]int{"/ok": 200, "/fail": 500, "/panic": 500} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+
+	// Only the 5xx response and the panic should have been reported
+	assert.Len(t, transport.events, 2)
+}
@@ -0,0 +1,63 @@
+package main
+
+import "sort"
+
+// importMaxIDRanges is the number of generated ID ranges kept per import for the report
+const importMaxIDRanges = 100000
+
+// generatedIDRange maps consecutive file lines to the consecutive IDs their rows were written with
+type generatedIDRange struct {
+	FirstLine int `json:"first_line"`
+	LastLine  int `json:"last_line"`
+	FirstID   int `json:"first_id"`
+	LastID    int `json:"last_id"`
+}
+
+// recordIDs records the IDs the sink assigned to the written users, read from the given file lines.
+// Rejected users are skipped, since a retried batch may have left them with the ID of a rolled back insert,
+// as are users without an ID, e.g. written with COPY, which doesn't return them.
+func (p *importProgress) recordIDs(users []UserData, lines []int, rejected []rejectedRow) {
+	if p == nil || !p.trackIDs {
+		return
+	}
+	skip := make(map[int]bool, len(rejected))
+	for _, row := range rejected {
+		skip[row.index] = true
+	}
+
+	var ranges []generatedIDRange
+	for i, user := range users {
+		if skip[i] || user.ID == 0 {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].LastLine+1 == lines[i] && ranges[n-1].LastID+1 == user.ID {
+			ranges[n-1].LastLine, ranges[n-1].LastID = lines[i], user.ID
+			continue
+		}
+		ranges = append(ranges, generatedIDRange{FirstLine: lines[i], LastLine: lines[i], FirstID: user.ID, LastID: user.ID})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range ranges {
+		if len(p.idRanges) >= importMaxIDRanges {
+			p.idRangesDropped += r.LastLine - r.FirstLine + 1
+			continue
+		}
+		p.idRanges = append(p.idRanges, r)
+	}
+}
+
+// generatedIDs returns the recorded ID ranges sorted by line, how many rows weren't kept,
+// and whether IDs were requested at all
+func (p *importProgress) generatedIDs() ([]generatedIDRange, int, bool) {
+	if p == nil || !p.trackIDs {
+		return nil, 0, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ranges := append([]generatedIDRange{}, p.idRanges...)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].FirstLine < ranges[j].FirstLine })
+	return ranges, p.idRangesDropped, true
+}
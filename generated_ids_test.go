@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sequenceSink assigns increasing IDs to the written rows like a serial column
+type sequenceSink struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *sequenceSink) Write(users []UserData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range users {
+		s.next++
+		users[i].ID = s.next
+	}
+	return nil
+}
+
+// TestGeneratedIDs tests that return_ids reports the IDs of written rows as ranges of file lines
+func TestGeneratedIDs(t *testing.T) {
+	csvData := "ID,First Name,Last Name,Email,Age,Gender,Department,Company,Salary,Date Joined,Is Active\n" +
+		"1,John,Doe,john@example.com,30,Male,IT,Corp,50000,2020-01-01,true\n" +
+		"2,Jane,Doe,jane@example.com,old,Female,HR,Corp,60000,2021-01-01,true\n" +
+		"3,Jim,Doe,jim@example.com,40,Male,IT,Corp,40000,2022-01-01,true\n" +
+		"4,Joe,Doe,joe@example.com,50,Male,IT,Corp,45000,2022-01-01,true\n"
+	overflow, _ := newOverflowHandler("")
+	progress := &importProgress{trackIDs: true}
+
+	metrics, err := runImport(context.Background(), strings.NewReader(csvData), &sequenceSink{}, importOptions{overflow: overflow, preserveOrder: true}, progress)
+	assert.NoError(t, err)
+	assert.Equal(t, []generatedIDRange{
+		{FirstLine: 2, LastLine: 2, FirstID: 1, LastID: 1},
+		{FirstLine: 4, LastLine: 5, FirstID: 2, LastID: 3},
+	}, metrics["generated_ids"])
+	assert.Equal(t, 0, metrics["generated_ids_dropped"])
+
+	// Rejected rows and rows without an ID are left out, and IDs aren't reported unless requested
+	progress = &importProgress{trackIDs: true}
+	progress.recordIDs([]UserData{{ID: 7}, {ID: 8}, {ID: 9}, {}}, []int{2, 3, 4, 5}, []rejectedRow{{index: 1}})
+	ids, _, ok := progress.generatedIDs()
+	assert.True(t, ok)
+	assert.Equal(t, []generatedIDRange{{FirstLine: 2, LastLine: 2, FirstID: 7, LastID: 7}, {FirstLine: 4, LastLine: 4, FirstID: 9, LastID: 9}}, ids)
+
+	metrics, err = runImport(context.Background(), strings.NewReader(csvData), &sequenceSink{}, importOptions{overflow: overflow}, &importProgress{})
+	assert.NoError(t, err)
+	assert.NotContains(t, metrics, "generated_ids")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// gormLogger writes GORM's statements, slow queries and errors to the application log as structured
+// entries instead of GORM's default stdout logger. Statements are logged with their placeholders, never
+// the values, and carry the query tags of their request or import, e.g. request_id and job_id.
+type gormLogger struct {
+	entry         *logrus.Entry
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration // Statements running longer are logged as warnings, 0 to disable
+}
+
+// newGormLogger returns a GORM logger writing to log. Every statement is logged at debug level, so they
+// only show up when the application logs debug entries.
+func newGormLogger(slowThreshold time.Duration) *gormLogger {
+	return &gormLogger{entry: logrus.NewEntry(log).WithField("component", "gorm"), level: gormlogger.Info, slowThreshold: slowThreshold}
+}
+
+// LogMode returns a copy of the logger logging at level, as used by db.Debug() and Session
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+// Info logs GORM's informational messages
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.withTags(ctx).Info(fmt.Sprintf(msg, data...))
+	}
+}
+
+// Warn logs GORM's warnings, e.g. about models it can't parse
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.withTags(ctx).Warn(fmt.Sprintf(msg, data...))
+	}
+}
+
+// Error logs GORM's errors
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.withTags(ctx).Error(fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace logs a statement once it ran: failed ones as errors, those slower than the threshold as
+// warnings and the others at debug level. Missing records aren't errors; the handlers answer them with 404.
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := l.slowThreshold > 0 && elapsed > l.slowThreshold
+	switch {
+	case failed && l.level >= gormlogger.Error:
+		level := logrus.ErrorLevel
+		if errors.Is(err, context.Canceled) {
+			level = logrus.WarnLevel // The client went away or the server is shutting down
+		}
+		l.traceEntry(ctx, elapsed, fc).WithError(err).Log(level, "Query failed")
+	case slow && l.level >= gormlogger.Warn:
+		l.traceEntry(ctx, elapsed, fc).WithField("threshold_ms", l.slowThreshold.Milliseconds()).Warn("Slow query")
+	case l.level >= gormlogger.Info && log.IsLevelEnabled(logrus.DebugLevel):
+		l.traceEntry(ctx, elapsed, fc).Debug("Query")
+	}
+}
+
+// explainedPlaceholder matches the placeholders of a statement rendered without its values, which the
+// postgres dialect leaves as $1$
+var explainedPlaceholder = regexp.MustCompile(`\$(\d+)\$`)
+
+// ParamsFilter drops the values of a statement before GORM renders it for Trace, so they don't end up
+// in the log
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+// traceEntry returns an entry holding the statement, how long it took and the rows it affected
+func (l *gormLogger) traceEntry(ctx context.Context, elapsed time.Duration, fc func() (string, int64)) *logrus.Entry {
+	sql, rows := fc()
+	sql = explainedPlaceholder.ReplaceAllString(sql, "$$$1")
+	return l.withTags(ctx).WithFields(logrus.Fields{
+		"sql":         truncateSQL(sql),
+		"duration_ms": float64(elapsed.Microseconds()) / 1000,
+		"rows":        rows,
+	})
+}
+
+// withTags adds the query tags of ctx, naming the request or import the statement ran for
+func (l *gormLogger) withTags(ctx context.Context) *logrus.Entry {
+	tags := queryTagsFrom(ctx)
+	if len(tags) == 0 {
+		return l.entry
+	}
+	fields := make(logrus.Fields, len(tags))
+	for key, value := range tags {
+		fields[key] = value
+	}
+	return l.entry.WithFields(fields)
+}
+
+// truncateSQL shortens the SQL of a statement to slowRequestSQLLength characters for logging
+func truncateSQL(sql string) string {
+	if len(sql) > slowRequestSQLLength {
+		return sql[:slowRequestSQLLength] + "..."
+	}
+	return sql
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// TestGormLogger tests that statements are logged through logrus with their tags and without their values
+func TestGormLogger(t *testing.T) {
+	hook := logtest.NewLocal(log)
+	defer log.ReplaceHooks(make(logrus.LevelHooks))
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(logrus.DebugLevel)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: newGormLogger(time.Second),
+	})
+	require.NoError(t, err)
+
+	ctx := withQueryTags(context.Background(), queryTags{"request_id": "req-1"})
+	db.WithContext(ctx).Where("email = ?", "jane@example.com").Find(&[]UserDatas{})
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, logrus.DebugLevel, entry.Level)
+	assert.Equal(t, "Query", entry.Message)
+	assert.Equal(t, `SELECT * FROM "user_data" WHERE email = $1`, entry.Data["sql"])
+	assert.NotContains(t, entry.Data["sql"], "jane@example.com")
+	assert.Equal(t, "req-1", entry.Data["request_id"])
+	assert.Equal(t, "gorm", entry.Data["component"])
+
+	// Statements are left out at the default info level, unless they are silenced anyway
+	hook.Reset()
+	log.SetLevel(logrus.InfoLevel)
+	db.Find(&[]UserDatas{})
+	assert.Empty(t, hook.AllEntries())
+}
+
+// TestGormLoggerTrace tests which statements are logged as slow queries and errors
+func TestGormLoggerTrace(t *testing.T) {
+	hook := logtest.NewLocal(log)
+	defer log.ReplaceHooks(make(logrus.LevelHooks))
+
+	l := newGormLogger(100 * time.Millisecond)
+	statement := func() (string, int64) { return "SELECT 1", 1 }
+	ctx := withQueryTags(context.Background(), queryTags{"job_id": "12"})
+
+	l.Trace(ctx, time.Now().Add(-time.Second), statement, nil)
+	entry := hook.LastEntry()
+	assert.Equal(t, logrus.WarnLevel, entry.Level)
+	assert.Equal(t, "Slow query", entry.Message)
+	assert.Equal(t, int64(100), entry.Data["threshold_ms"])
+	assert.Equal(t, "12", entry.Data["job_id"])
+	assert.GreaterOrEqual(t, entry.Data["duration_ms"], 1000.0)
+
+	l.Trace(ctx, time.Now(), statement, errors.New("relation does not exist"))
+	entry = hook.LastEntry()
+	assert.Equal(t, logrus.ErrorLevel, entry.Level)
+	assert.Equal(t, "Query failed", entry.Message)
+
+	l.Trace(ctx, time.Now(), statement, context.Canceled)
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level, "canceled requests aren't errors")
+
+	hook.Reset()
+	l.Trace(ctx, time.Now(), statement, gorm.ErrRecordNotFound)
+	assert.Empty(t, hook.AllEntries(), "missing records are answered with 404")
+
+	newGormLogger(0).Trace(ctx, time.Now().Add(-time.Hour), statement, nil)
+	assert.Empty(t, hook.AllEntries(), "a zero threshold disables slow query logging")
+
+	l.LogMode(gormlogger.Silent).Trace(ctx, time.Now(), statement, errors.New("relation does not exist"))
+	assert.Empty(t, hook.AllEntries())
+
+	l.Warn(ctx, "model %s has no primary key", "UserData")
+	assert.Equal(t, "model UserData has no primary key", hook.LastEntry().Message)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// healthCheckTimeout bounds the database checks of /readyz, so a hung database fails the probe
+// instead of stalling it
+const healthCheckTimeout = 2 * time.Second
+
+// databaseProbe checks the database for the readiness probe and the schema drift report
+var databaseProbe DatabaseProbe
+
+// DatabaseProbe checks that the database answers and is migrated and reports its connection pool
+type DatabaseProbe interface {
+	Ping() error
+	PoolStats() (sql.DBStats, error)
+	SchemaDrift() ([]schemaDrift, error)
+}
+
+// GormDatabaseProbe is a concrete implementation of DatabaseProbe using GORM
+type GormDatabaseProbe struct {
+	db *gorm.DB
+}
+
+// WithContext returns a probe whose checks use ctx and carry its query tags
+func (p *GormDatabaseProbe) WithContext(ctx context.Context) DatabaseProbe {
+	return &GormDatabaseProbe{db: p.db.WithContext(ctx)}
+}
+
+// Ping checks that the database answers, using the probe's context
+func (p *GormDatabaseProbe) Ping() error {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(p.db.Statement.Context)
+}
+
+// PoolStats returns the statistics of the connection pool
+func (p *GormDatabaseProbe) PoolStats() (sql.DBStats, error) {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
+
+// poolStats is the connection pool state reported by /readyz
+type poolStats struct {
+	MaxOpen        int   `json:"max_open"`
+	Open           int   `json:"open"`
+	InUse          int   `json:"in_use"`
+	Idle           int   `json:"idle"`
+	WaitCount      int64 `json:"wait_count"`
+	WaitDurationMs int64 `json:"wait_duration_ms"`
+}
+
+// newPoolStats converts the statistics of database/sql
+func newPoolStats(stats sql.DBStats) poolStats {
+	return poolStats{
+		MaxOpen:        stats.MaxOpenConnections,
+		Open:           stats.OpenConnections,
+		InUse:          stats.InUse,
+		Idle:           stats.Idle,
+		WaitCount:      stats.WaitCount,
+		WaitDurationMs: stats.WaitDuration.Milliseconds(),
+	}
+}
+
+// readiness decides whether the server can take traffic: the database answers, its schema has
+// every table and column of the models and the startup warm-up, if any, is finished
+type readiness struct {
+	migrated atomic.Bool // Set once the schema was found complete; tables aren't expected to go missing afterwards
+}
+
+// schemaMigrated reports whether the migrations were applied, checking the schema until they were
+func (r *readiness) schemaMigrated(inspector SchemaInspector) (bool, error) {
+	if r.migrated.Load() {
+		return true, nil
+	}
+	drift, err := inspector.SchemaDrift()
+	if err != nil {
+		return false, err
+	}
+	for _, d := range drift {
+		if d.Kind == driftMissingTable || d.Kind == driftMissingColumn {
+			return false, nil
+		}
+	}
+	r.migrated.Store(true)
+	return true, nil
+}
+
+// probePaths are the health probe routes, open to unauthenticated and unlimited requests
+// so orchestrators and load balancers can always reach them
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// healthz handles GET /healthz, answering 200 while the process is up
+func healthz(c *gin.Context) {
+	respond(c, 200, gin.H{"status": "ok"}, nil)
+}
+
+// readyz handles GET /readyz, answering 200 when the database answers and is migrated and the warm-up
+// is finished, and 503 otherwise, with the result of each check and the connection pool statistics
+func (r *readiness) readyz(c *gin.Context, probe DatabaseProbe) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+	probe = contextStore(ctx, probe)
+
+	checks := gin.H{"database": "ok", "migrations": "ok"}
+	ready := true
+	if err := probe.Ping(); err != nil {
+		log.WithError(err).Warn("Readiness check failed: database unreachable")
+		checks["database"], checks["migrations"] = err.Error(), "unknown"
+		ready = false
+	} else if migrated, err := r.schemaMigrated(probe); err != nil {
+		log.WithError(err).Warn("Readiness check failed: schema check failed")
+		checks["migrations"] = err.Error()
+		ready = false
+	} else if !migrated {
+		checks["migrations"] = "pending"
+		ready = false
+	}
+	if status := warmup.status(); status != "" {
+		checks["warmup"] = status
+		ready = ready && status != warmupRunning
+	}
+
+	report := gin.H{"status": "ready", "checks": checks}
+	if stats, err := probe.PoolStats(); err == nil {
+		report["pool"] = newPoolStats(stats)
+	}
+	if !ready {
+		report["status"] = "unavailable"
+		respond(c, 503, report, nil)
+		return
+	}
+	respond(c, 200, report, nil)
+}
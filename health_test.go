@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadyz tests the readiness probe against a database that is down, unmigrated and ready
+func TestReadyz(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	probe := NewMockDatabaseProbe(ctrl)
+	probe.EXPECT().PoolStats().Return(sql.DBStats{MaxOpenConnections: 25, OpenConnections: 3, InUse: 1, Idle: 2}, nil).AnyTimes()
+	gomock.InOrder(
+		probe.EXPECT().Ping().Return(errors.New("connection refused")),
+		probe.EXPECT().Ping().Return(nil),
+		probe.EXPECT().Ping().Return(nil),
+		probe.EXPECT().Ping().Return(nil),
+	)
+	gomock.InOrder(
+		probe.EXPECT().SchemaDrift().Return([]schemaDrift{{Table: "user_data", Kind: driftMissingColumn, Column: "import_id"}}, nil),
+		probe.EXPECT().SchemaDrift().Return([]schemaDrift{{Table: "user_data", Kind: driftMissingIndex, Index: "idx_user_data_import_id"}}, nil),
+	)
+
+	gin.SetMode(gin.TestMode)
+	ready := &readiness{}
+	r := gin.New()
+	r.GET("/healthz", healthz)
+	r.GET("/readyz", func(c *gin.Context) {
+		ready.readyz(c, probe)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 503, w.Code)
+	assert.Contains(t, w.Body.String(), `"database":"connection refused"`)
+	assert.Contains(t, w.Body.String(), `"max_open":25`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 503, w.Code)
+	assert.Contains(t, w.Body.String(), `"migrations":"pending"`)
+
+	// Missing indexes don't keep the server from serving, and a complete schema isn't checked again
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/readyz", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"ready"`)
+		assert.Contains(t, w.Body.String(), `"in_use":1`)
+	}
+}
+
+// TestProbesAreOpen tests that the probes need no token and aren't rate limited
+func TestProbesAreOpen(t *testing.T) {
+	assert.Equal(t, "", requiredRole(http.MethodGet, "/healthz"))
+	assert.Equal(t, "", requiredRole(http.MethodGet, "/readyz"))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(rateLimitMiddleware(RateLimitConfig{ReadsPerMinute: 1}))
+	r.GET("/healthz", healthz)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/healthz", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+	}
+}
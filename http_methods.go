@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// totalCountHeader carries the total number of items for HEAD requests on list endpoints
+const totalCountHeader = "X-Total-Count"
+
+// allowedMethods maps every registered path to the methods it supports
+func allowedMethods(r *gin.Engine) map[string][]string {
+	methods := map[string][]string{}
+	for _, route := range r.Routes() {
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+	for path := range methods {
+		methods[path] = append(methods[path], http.MethodOptions)
+		sort.Strings(methods[path])
+	}
+	return methods
+}
+
+// registerOptionsRoutes adds an OPTIONS handler with an Allow header to every registered path
+// and makes unsupported methods return 405 with the same Allow header.
+// It must be called after all other routes are registered.
+func registerOptionsRoutes(r *gin.Engine) {
+	methods := allowedMethods(r)
+	for path, allowed := range methods {
+		allow := strings.Join(allowed, ", ")
+		r.OPTIONS(path, func(c *gin.Context) {
+			c.Header("Allow", allow)
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	// Match the most specific pattern first, like the router does: /api/records/search before /api/records/:id
+	patterns := make([]string, 0, len(methods))
+	for path := range methods {
+		patterns = append(patterns, path)
+	}
+	sort.Slice(patterns, func(i, j int) bool { return moreSpecificPattern(patterns[i], patterns[j]) })
+
+	r.HandleMethodNotAllowed = true
+	r.NoMethod(func(c *gin.Context) {
+		for _, path := range patterns {
+			if pathMatches(path, c.Request.URL.Path) {
+				c.Header("Allow", strings.Join(methods[path], ", "))
+				break
+			}
+		}
+		respondError(c, http.StatusMethodNotAllowed, "Method not allowed")
+	})
+}
+
+// segmentRank orders the segments of route patterns: static segments before :params before *wildcards
+func segmentRank(segment string) int {
+	switch {
+	case strings.HasPrefix(segment, "*"):
+		return 2
+	case strings.HasPrefix(segment, ":"):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// moreSpecificPattern reports whether route pattern a should be matched before b: at the first segment
+// where they differ in kind, a has the more specific one. Patterns alike in kind are ordered by name.
+func moreSpecificPattern(a, b string) bool {
+	aParts := strings.Split(strings.Trim(a, "/"), "/")
+	bParts := strings.Split(strings.Trim(b, "/"), "/")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aRank, bRank := segmentRank(aParts[i]), segmentRank(bParts[i]); aRank != bRank {
+			return aRank < bRank
+		}
+	}
+	return a < b
+}
+
+// pathMatches reports whether a concrete request path matches a gin route pattern
+func pathMatches(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestHeadRecords tests that HEAD returns the total count header without a body
+func TestHeadRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Model(gomock.Any()).Return(mockDB)
+	mockDB.EXPECT().Count(gomock.Any()).DoAndReturn(func(count *int64) *gorm.DB {
+		*count = 42
+		return &gorm.DB{}
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("HEAD", "/api/records", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "42", w.Header().Get(totalCountHeader))
+	assert.Empty(t, w.Body.String())
+}
+
+// TestOptionsAndMethodNotAllowed tests Allow headers on OPTIONS and 405 responses
+func TestOptionsAndMethodNotAllowed(t *testing.T) {
+	disableAuth(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/api/records", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS, POST", w.Header().Get("Allow"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/records", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 405, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS, POST", w.Header().Get("Allow"))
+
+	// The CSV upload is served by the same router
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("OPTIONS", "/upload-csv", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "OPTIONS, POST", w.Header().Get("Allow"))
+
+	// Static paths win over the :id route matching them too, on every run
+	for i := 0; i < 20; i++ {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/api/records/search", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 405, w.Code)
+		assert.Equal(t, "GET, OPTIONS", w.Header().Get("Allow"))
+	}
+}
+
+// TestPathMatches tests matching request paths against route patterns
+func TestPathMatches(t *testing.T) {
+	assert.True(t, pathMatches("/api/records/:id", "/api/records/7"))
+	assert.True(t, pathMatches("/swagger/*any", "/swagger/index.html"))
+	assert.False(t, pathMatches("/api/records/:id", "/api/records"))
+	assert.False(t, pathMatches("/api/logs", "/api/records"))
+}
+
+// TestMoreSpecificPattern tests ordering route patterns by how specifically they match
+func TestMoreSpecificPattern(t *testing.T) {
+	assert.True(t, moreSpecificPattern("/api/records/search", "/api/records/:id"))
+	assert.False(t, moreSpecificPattern("/api/records/:id", "/api/records/search"))
+	assert.True(t, moreSpecificPattern("/api/imports/:id/errors", "/swagger/*any"))
+	assert.True(t, moreSpecificPattern("/api/records/:id", "/api/records/*rest"))
+	assert.True(t, moreSpecificPattern("/api/imports/estimate", "/api/imports/url"))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Import estimate settings
+const (
+	importEstimateSampleJobs = 20      // Recent finished imports used to measure throughput
+	defaultImportThroughput  = 5 << 20 // Bytes per second assumed when there is no history
+	defaultCSVBytesPerRow    = 120     // Average CSV row size assumed when there is no history
+)
+
+// ImportImpact is the estimated resource usage of an import
+type ImportImpact struct {
+	TableGrowthBytes   int64 `json:"table_growth_bytes"`
+	SpoolDiskBytes     int64 `json:"spool_disk_bytes"`
+	MemoryBytes        int64 `json:"memory_bytes"`
+	DBConnections      int   `json:"db_connections"`
+	ExceedsUploadLimit bool  `json:"exceeds_upload_limit"`
+}
+
+// ImportEstimate is the predicted duration and impact of importing a file of a given size
+type ImportEstimate struct {
+	FileSize              int64        `json:"file_size"`
+	EstimatedDurationMs   int64        `json:"estimated_duration_ms"`
+	EstimatedWaitMs       int64        `json:"estimated_wait_ms"` // Time until the queued imports ahead are done
+	EstimatedRows         int64        `json:"estimated_rows"`
+	ThroughputBytesPerSec float64      `json:"throughput_bytes_per_sec"`
+	Basis                 string       `json:"basis"` // "history" or "default" when no import finished yet
+	SampleJobs            int          `json:"sample_jobs"`
+	QueuedImports         int          `json:"queued_imports"`
+	Impact                ImportImpact `json:"impact"`
+}
+
+// estimateImport predicts the import of size bytes from the throughput of finished imports
+func estimateImport(size int64, history []ImportJob, queued int) ImportEstimate {
+	var bytes, rows, durationMs int64
+	samples := 0
+	for _, job := range history {
+		if job.FileSize <= 0 || job.DurationMs <= 0 {
+			continue
+		}
+		bytes += job.FileSize
+		rows += job.RowsProcessed + job.RowsSkipped
+		durationMs += job.DurationMs
+		samples++
+	}
+
+	estimate := ImportEstimate{
+		FileSize:              size,
+		Basis:                 "default",
+		ThroughputBytesPerSec: defaultImportThroughput,
+		SampleJobs:            samples,
+		QueuedImports:         queued,
+	}
+	bytesPerRow := float64(defaultCSVBytesPerRow)
+	averageDuration := time.Duration(0)
+	if samples > 0 {
+		estimate.Basis = "history"
+		estimate.ThroughputBytesPerSec = float64(bytes) / (float64(durationMs) / 1000)
+		averageDuration = time.Duration(durationMs/int64(samples)) * time.Millisecond
+		if rows > 0 {
+			bytesPerRow = float64(bytes) / float64(rows)
+		}
+	}
+
+	estimate.EstimatedDurationMs = int64(float64(size) / estimate.ThroughputBytesPerSec * 1000)
+	estimate.EstimatedWaitMs = int64(queued) * averageDuration.Milliseconds()
+	estimate.EstimatedRows = int64(float64(size) / bytesPerRow)
+
+	connections := ingestMaxWorkers()
+	if connections > appConfig.Database.MaxOpenConns {
+		connections = appConfig.Database.MaxOpenConns
+	}
+	memory := size
+	if memory > maxMultipartMemory {
+		memory = maxMultipartMemory
+	}
+	estimate.Impact = ImportImpact{
+		TableGrowthBytes:   int64(float64(size) * tableGrowthFactor),
+		SpoolDiskBytes:     size,
+		MemoryBytes:        memory,
+		DBConnections:      connections,
+		ExceedsUploadLimit: size > appConfig.Ingestion.MaxUploadBytes,
+	}
+	return estimate
+}
+
+// importEstimateHandler handles GET /api/imports/estimate?size=<bytes>
+func importEstimateHandler(c *gin.Context, imports *importManager) {
+	size, err := strconv.ParseInt(c.Query("size"), 10, 64)
+	if err != nil || size < 1 {
+		log.WithField("size", c.Query("size")).Error("Invalid import estimate size")
+		respondError(c, 400, "Invalid size, expected the file size in bytes")
+		return
+	}
+
+	history, err := imports.store.RecentDone(importEstimateSampleJobs)
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch import history")
+		respondError(c, 500, "Failed to fetch import history")
+		return
+	}
+
+	respond(c, 200, estimateImport(size, history, len(imports.queue)), nil)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEstimateImport tests estimates from history and from the defaults
+func TestEstimateImport(t *testing.T) {
+	history := []ImportJob{
+		{FileSize: 10 << 20, DurationMs: 2000, RowsProcessed: 90000, RowsSkipped: 10000},
+		{FileSize: 30 << 20, DurationMs: 6000, RowsProcessed: 300000},
+		{FileSize: 0, DurationMs: 500}, // Jobs without a recorded size are ignored
+	}
+
+	estimate := estimateImport(100<<20, history, 2)
+	assert.Equal(t, "history", estimate.Basis)
+	assert.Equal(t, 2, estimate.SampleJobs)
+	assert.Equal(t, float64(5<<20), estimate.ThroughputBytesPerSec)
+	assert.Equal(t, int64(20000), estimate.EstimatedDurationMs)
+	assert.Equal(t, int64(8000), estimate.EstimatedWaitMs)
+	assert.Equal(t, int64(1000000), estimate.EstimatedRows)
+	assert.Equal(t, int64(200<<20), estimate.Impact.TableGrowthBytes)
+	assert.False(t, estimate.Impact.ExceedsUploadLimit)
+
+	estimate = estimateImport(defaultImportThroughput, nil, 0)
+	assert.Equal(t, "default", estimate.Basis)
+	assert.Equal(t, int64(1000), estimate.EstimatedDurationMs)
+	assert.Equal(t, int64(0), estimate.EstimatedWaitMs)
+}
+
+// TestImportEstimateEndpoint tests the estimate endpoint next to the import status route
+func TestImportEstimateEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockJobStore(ctrl)
+	store.EXPECT().RecentDone(importEstimateSampleJobs).Return([]ImportJob{{FileSize: 1000, DurationMs: 1000, RowsProcessed: 10}}, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(store, nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/imports/estimate?size=5000", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"estimated_duration_ms":5000`)
+	assert.Contains(t, w.Body.String(), `"estimated_rows":50`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/imports/estimate?size=-1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
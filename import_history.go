@@ -0,0 +1,220 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Limits of the metadata describing an import
+const (
+	importDescriptionLength  = 1000 // Characters of a description
+	importSourceSystemLength = 100  // Characters of a source system
+	importMaxTags            = 20   // Tags of an import
+	importHistoryMaxSize     = 100  // Imports per page of the history
+)
+
+// importTagPattern matches a tag: letters, digits and _ . : -, e.g. backfill-2023
+var importTagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]{0,49}$`)
+
+// importTags are the tags of an import, stored as a comma-separated list
+type importTags []string
+
+// GormDataType is the column type of tags
+func (importTags) GormDataType() string {
+	return "string"
+}
+
+// Value stores the tags comma-separated
+func (t importTags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+// Scan reads comma-separated tags
+func (t *importTags) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into tags", src)
+	}
+	*t = nil
+	if value != "" {
+		*t = strings.Split(value, ",")
+	}
+	return nil
+}
+
+// parseImportTags reads tags given comma-separated, in one or more values, dropping repeated ones
+func parseImportTags(values []string) (importTags, error) {
+	var tags importTags
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			if !importTagPattern.MatchString(tag) {
+				return nil, fmt.Errorf("invalid tag %q, expected up to 50 letters, digits, _ . : and -", tag)
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > importMaxTags {
+		return nil, fmt.Errorf("an import may have at most %d tags", importMaxTags)
+	}
+	return tags, nil
+}
+
+// importMetadata describes what an import loads, so it can be told apart in the history long after
+type importMetadata struct {
+	description  string
+	sourceSystem string // System the file was exported from, e.g. sap-hr
+	businessDate Date   // Date the data is as of, which may differ from the import date
+	tags         importTags
+}
+
+// parseImportMetadata reads the description, source_system, business_date and tags fields of an
+// upload with get, which returns the values of a form field or query parameter
+func parseImportMetadata(get func(name string) []string) (importMetadata, error) {
+	first := func(name string) string {
+		if values := get(name); len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+
+	metadata := importMetadata{description: first("description"), sourceSystem: first("source_system")}
+	if len([]rune(metadata.description)) > importDescriptionLength {
+		return importMetadata{}, fmt.Errorf("description is limited to %d characters", importDescriptionLength)
+	}
+	if len([]rune(metadata.sourceSystem)) > importSourceSystemLength {
+		return importMetadata{}, fmt.Errorf("source_system is limited to %d characters", importSourceSystemLength)
+	}
+	if value := first("business_date"); value != "" {
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return importMetadata{}, fmt.Errorf("invalid business_date %q, expected a YYYY-MM-DD date", value)
+		}
+		metadata.businessDate = newDate(date.Date())
+	}
+	tags, err := parseImportTags(get("tags"))
+	if err != nil {
+		return importMetadata{}, err
+	}
+	metadata.tags = tags
+	return metadata, nil
+}
+
+// apply records the metadata on the job of the import
+func (m importMetadata) apply(job *ImportJob) {
+	job.Description = m.description
+	job.SourceSystem = m.sourceSystem
+	job.BusinessDate = m.businessDate
+	job.Tags = m.tags
+}
+
+// importHistoryFilter narrows the import history down; empty fields match every import
+type importHistoryFilter struct {
+	query        string // Words to find in the description, file name or source system
+	sourceSystem string
+	businessDate string // YYYY-MM-DD
+	tag          string
+	state        string
+}
+
+// likePattern returns a LIKE pattern matching text anywhere, with its wildcards escaped
+func likePattern(text string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+	return "%" + escaped + "%"
+}
+
+// apply adds the conditions of the filter to a query of import_jobs
+func (f importHistoryFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.query != "" {
+		pattern := likePattern(f.query)
+		db = db.Where("description ILIKE ? OR file_name ILIKE ? OR source_system ILIKE ?", pattern, pattern, pattern)
+	}
+	if f.sourceSystem != "" {
+		db = db.Where("source_system = ?", f.sourceSystem)
+	}
+	if f.businessDate != "" {
+		db = db.Where("business_date = ?", f.businessDate)
+	}
+	if f.tag != "" {
+		db = db.Where("(',' || tags || ',') LIKE ?", likePattern(","+f.tag+","))
+	}
+	if f.state != "" {
+		db = db.Where("state = ?", f.state)
+	}
+	return db
+}
+
+// parseImportHistoryFilter reads the filter query parameters of GET /api/imports
+func parseImportHistoryFilter(c *gin.Context) (importHistoryFilter, error) {
+	filter := importHistoryFilter{
+		query:        strings.TrimSpace(c.Query("q")),
+		sourceSystem: c.Query("source_system"),
+		businessDate: c.Query("business_date"),
+		tag:          c.Query("tag"),
+		state:        c.Query("state"),
+	}
+	if filter.tag != "" && !importTagPattern.MatchString(filter.tag) {
+		return importHistoryFilter{}, fmt.Errorf("invalid tag %q", filter.tag)
+	}
+	if filter.businessDate != "" {
+		if _, err := time.Parse(time.DateOnly, filter.businessDate); err != nil {
+			return importHistoryFilter{}, fmt.Errorf("invalid business_date %q, expected a YYYY-MM-DD date", filter.businessDate)
+		}
+	}
+	switch filter.state {
+	case "", importQueued, importRunning, importDone, importFailed, importCancelled:
+	default:
+		return importHistoryFilter{}, fmt.Errorf("unknown state %q", filter.state)
+	}
+	return filter, nil
+}
+
+// listImports handles GET /api/imports, the history of imports newest first, a page at a time
+func listImports(c *gin.Context, store JobStore) {
+	filter, err := parseImportHistoryFilter(c)
+	if err != nil {
+		respondError(c, 400, "Invalid filter", err.Error())
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		respondError(c, 400, "Invalid page number")
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if err != nil || size < 1 || size > importHistoryMaxSize {
+		respondError(c, 400, "Invalid size number", fmt.Sprintf("size must be between 1 and %d", importHistoryMaxSize))
+		return
+	}
+
+	jobs, total, err := store.List(filter, (page-1)*size, size)
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to list import jobs")
+		respondError(c, 500, "Failed to list import jobs")
+		return
+	}
+	if jobs == nil {
+		jobs = []ImportJob{}
+	}
+	c.Header(totalCountHeader, strconv.FormatInt(total, 10))
+	setPageLinks(c, page, size, total)
+	respond(c, 200, jobs, paginationMeta(c.Request.URL, page, size, total))
+}
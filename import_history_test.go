@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestParseImportMetadata tests reading and validating the metadata of an upload
+func TestParseImportMetadata(t *testing.T) {
+	fields := url.Values{
+		"description":   {"  Payroll export for the Q3 audit "},
+		"source_system": {"sap-hr"},
+		"business_date": {"2024-09-30"},
+		"tags":          {"audit, q3-2024", "audit", "backfill_2023"},
+	}
+	metadata, err := parseImportMetadata(func(name string) []string { return fields[name] })
+	require.NoError(t, err)
+	job := &ImportJob{}
+	metadata.apply(job)
+	assert.Equal(t, "Payroll export for the Q3 audit", job.Description)
+	assert.Equal(t, "sap-hr", job.SourceSystem)
+	assert.Equal(t, "2024-09-30", job.BusinessDate.String())
+	assert.Equal(t, importTags{"audit", "q3-2024", "backfill_2023"}, job.Tags)
+
+	metadata, err = parseImportMetadata(func(string) []string { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, importMetadata{}, metadata)
+
+	for name, values := range map[string][]string{
+		"description":   {strings.Repeat("a", importDescriptionLength+1)},
+		"source_system": {strings.Repeat("a", importSourceSystemLength+1)},
+		"business_date": {"30/09/2024"},
+		"tags":          {"two words"},
+	} {
+		_, err := parseImportMetadata(func(field string) []string {
+			if field == name {
+				return values
+			}
+			return nil
+		})
+		assert.Error(t, err, name)
+	}
+	tags := make([]string, importMaxTags+1)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("tag-%d", i)
+	}
+	_, err = parseImportTags(tags)
+	assert.ErrorContains(t, err, "at most 20 tags")
+	_, err = parseImportTags(append(tags[:importMaxTags], "tag-0"))
+	assert.NoError(t, err, "repeated tags are dropped before counting")
+}
+
+// TestImportHistoryFilter tests the conditions the history is searched with
+func TestImportHistoryFilter(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	filter := importHistoryFilter{query: "50%_off", sourceSystem: "sap-hr", businessDate: "2024-09-30", tag: "q3_2024", state: importDone}
+	statement := filter.apply(db.Model(&ImportJob{})).Find(&[]ImportJob{}).Statement
+	assert.Equal(t, `SELECT * FROM "import_jobs" WHERE (description ILIKE $1 OR file_name ILIKE $2 OR source_system ILIKE $3) AND source_system = $4 `+
+		`AND business_date = $5 AND (',' || tags || ',') LIKE $6 AND state = $7`, statement.SQL.String())
+	assert.Equal(t, `%50\%\_off%`, statement.Vars[0])
+	assert.Equal(t, `%,q3\_2024,%`, statement.Vars[5])
+}
+
+// TestListImports tests paging and filtering the import history
+func TestListImports(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockJobStore(ctrl)
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(store, nil))
+
+	store.EXPECT().List(importHistoryFilter{query: "audit", tag: "q3-2024"}, 5, 5).
+		Return([]ImportJob{{ID: 9, FileName: "payroll.csv", Description: "Q3 audit", Tags: importTags{"q3-2024"}}}, int64(6), nil)
+	w := serveRecord(r, "GET", "/api/imports?q=audit&tag=q3-2024&page=2&size=5", "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, "6", w.Header().Get(totalCountHeader))
+	var body struct {
+		Data []ImportJob            `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, importTags{"q3-2024"}, body.Data[0].Tags)
+	assert.Equal(t, 2.0, body.Meta["total_pages"])
+
+	for _, query := range []string{"state=broken", "business_date=Q3", "tag=a,b", "size=101", "page=0"} {
+		assert.Equal(t, 400, serveRecord(r, "GET", "/api/imports?"+query, "").Code, query)
+	}
+}
+
+// TestUploadCSVMetadata tests that the metadata form fields of an upload are stored on its job
+func TestUploadCSVMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sink := &memorySink{}
+	registerSink("memory", func(deps connectorDeps, params map[string]string) (Sink, error) { return sink, nil })
+	defer func() {
+		connectorsMu.Lock()
+		delete(sinkFactories, "memory")
+		connectorsMu.Unlock()
+	}()
+
+	store, final := newRecordingJobStore(ctrl)
+	blobs, _ := newLocalBlobStore(t.TempDir())
+	imports := newImportManager(store, blobs)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/upload-csv", func(c *gin.Context) {
+		uploadCSV(c, NewMockDBHandler(ctrl), imports)
+	})
+	upload := func(fields map[string]string) int {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		for name, value := range fields {
+			writer.WriteField(name, value)
+		}
+		part, _ := writer.CreateFormFile("file", "payroll.csv")
+		part.Write([]byte("ID,FirstName,LastName,Email,Age,Gender,Department,Company,Salary,DateJoined,IsActive\n" +
+			"1,John,Doe,john@example.com,30,Male,IT,ExampleCorp,50000,2020-01-01,true\n"))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/upload-csv?sink=memory", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, 202, upload(map[string]string{"description": "Q3 payroll", "source_system": "sap-hr", "business_date": "2024-09-30", "tags": "audit,q3-2024"}))
+	imports.Wait()
+	job := final()
+	assert.Equal(t, importDone, job.State)
+	assert.Equal(t, "Q3 payroll", job.Description)
+	assert.Equal(t, "sap-hr", job.SourceSystem)
+	assert.Equal(t, newDate(2024, 9, 30), job.BusinessDate)
+	assert.Equal(t, importTags{"audit", "q3-2024"}, job.Tags)
+
+	assert.Equal(t, 400, upload(map[string]string{"tags": "not a tag"}))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Import log settings
+const (
+	importLogsDefaultLimit = 1000  // Log lines returned by GET /api/imports/:id/logs unless limit is given
+	importLogsMaxLimit     = 10000 // Most log lines returned at once
+)
+
+// logger returns the entry to log the import's messages with, tagged with its job ID
+// so GET /api/imports/:id/logs can find them, and with the ID of the request that submitted it
+func (p *importProgress) logger() *logrus.Entry {
+	if p == nil || p.jobID == 0 {
+		return logrus.NewEntry(log)
+	}
+	return log.WithFields(logrus.Fields{"job_id": p.jobID, "request_id": p.requestID})
+}
+
+// logFiles returns the current log file and the rotated backups written to since the given time,
+// oldest first. Backups are named like lumberjack rotates them, e.g. File-2024-05-01T10-00-00.000.log.gz.
+func logFiles(path string, since time.Time) ([]string, error) {
+	ext := filepath.Ext(path)
+	backups, err := filepath.Glob(strings.TrimSuffix(path, ext) + "-*" + ext + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	for _, name := range append(backups, path) {
+		info, err := os.Stat(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Before(since) {
+			continue // Rotated before the import was created
+		}
+		files = append(files, logFile{path: name, modTime: info.ModTime()})
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.path
+	}
+	return paths, nil
+}
+
+// readJobLogs returns up to limit JSON log entries of the job from the files, in the order they were written,
+// and whether more were found
+func readJobLogs(paths []string, jobID uint, limit int) ([]map[string]interface{}, bool, error) {
+	var entries []map[string]interface{}
+	for _, path := range paths {
+		more, err := scanJobLogs(path, jobID, limit, &entries)
+		if err != nil || more {
+			return entries, more, err
+		}
+	}
+	return entries, false, nil
+}
+
+// scanJobLogs appends the job's entries of one log file, which may be gzip-compressed, stopping past limit
+func scanJobLogs(path string, jobID uint, limit int, entries *[]map[string]interface{}) (bool, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil // Removed by a rotation since it was listed
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return false, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	id := strconv.FormatUint(uint64(jobID), 10)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !strings.Contains(string(line), `"job_id":`+id) {
+			continue // Skip decoding lines that can't belong to the job
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue // Not written by the JSON formatter
+		}
+		if number, ok := entry["job_id"].(float64); !ok || uint(number) != jobID {
+			continue
+		}
+		if len(*entries) == limit {
+			return true, nil
+		}
+		*entries = append(*entries, entry)
+	}
+	return false, scanner.Err()
+}
+
+// importLogs handles GET /api/imports/:id/logs, returning the log entries of the import
+// from the log file and its rotated backups
+func importLogs(c *gin.Context, store JobStore, logPath string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, 400, "Invalid import job ID")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(importLogsDefaultLimit)))
+	if err != nil || limit < 1 || limit > importLogsMaxLimit {
+		respondError(c, 400, fmt.Sprintf("limit must be between 1 and %d", importLogsMaxLimit))
+		return
+	}
+
+	job, err := store.Get(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Import job not found")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch import job")
+		respondError(c, 500, "Failed to fetch import job")
+		return
+	}
+
+	paths, err := logFiles(logPath, job.CreatedAt)
+	if err != nil {
+		log.WithError(err).WithField("job_id", job.ID).Error("Failed to list log files")
+		respondError(c, 500, "Failed to read import logs")
+		return
+	}
+	entries, truncated, err := readJobLogs(paths, job.ID, limit)
+	if err != nil {
+		log.WithError(err).WithField("job_id", job.ID).Error("Failed to read import logs")
+		respondError(c, 500, "Failed to read import logs")
+		return
+	}
+	if entries == nil {
+		entries = []map[string]interface{}{}
+	}
+	respond(c, 200, entries, gin.H{"count": len(entries), "truncated": truncated})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestImportLogs tests finding the log entries of an import in the log file and its rotated backups
+func TestImportLogs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "File.log")
+
+	// A compressed backup rotated during the import, one rotated before it, and the current file
+	backup := filepath.Join(dir, "File-2024-05-01T10-00-00.000.log.gz")
+	file, err := os.Create(backup)
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(file)
+	gz.Write([]byte(`{"job_id":1,"level":"info","msg":"Import started"}` + "\n" + `{"job_id":12,"level":"info","msg":"Import started"}` + "\n"))
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, file.Close())
+	old := filepath.Join(dir, "File-2024-04-01T10-00-00.000.log")
+	assert.NoError(t, os.WriteFile(old, []byte(`{"job_id":1,"level":"info","msg":"Earlier import with a reused ID"}`+"\n"), 0o600))
+	assert.NoError(t, os.WriteFile(logPath, []byte("not json\n"+
+		`{"level":"info","msg":"Incoming request"}`+"\n"+
+		`{"job_id":1,"level":"error","msg":"Batch insert failed","rows":2}`+"\n"+
+		`{"job_id":1,"level":"error","msg":"Import failed"}`+"\n"), 0o600))
+
+	created := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(old, created.Add(-time.Hour), created.Add(-time.Hour)))
+	assert.NoError(t, os.Chtimes(backup, created.Add(time.Minute), created.Add(time.Minute)))
+
+	store := NewMockJobStore(ctrl)
+	store.EXPECT().Get(uint(1)).Return(&ImportJob{ID: 1, CreatedAt: created}, nil).Times(2)
+	store.EXPECT().Get(uint(2)).Return(nil, gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/imports/:id/logs", func(c *gin.Context) {
+		importLogs(c, store, logPath)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/imports/1/logs", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var body struct {
+		Data []map[string]interface{} `json:"data"`
+		Meta map[string]interface{}   `json:"meta"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	var messages []interface{}
+	for _, entry := range body.Data {
+		messages = append(messages, entry["msg"])
+	}
+	assert.Equal(t, []interface{}{"Import started", "Batch insert failed", "Import failed"}, messages)
+	assert.Equal(t, false, body.Meta["truncated"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/imports/1/logs?limit=2", nil)
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"count":2`)
+	assert.Contains(t, w.Body.String(), `"truncated":true`)
+
+	for path, code := range map[string]int{"/api/imports/2/logs": 404, "/api/imports/1/logs?limit=0": 400, "/api/imports/x/logs": 400} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, path)
+	}
+}
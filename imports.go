@@ -0,0 +1,756 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Import job states
+const (
+	importQueued    = "queued"
+	importRunning   = "running"
+	importDone      = "done"
+	importFailed    = "failed"
+	importCancelled = "cancelled" // The client waiting for the import disconnected
+)
+
+// Import job manager settings
+const (
+	importQueueSize        = 16               // Max imports waiting for a worker
+	importWorkers          = 1                // Imports run one at a time; each one already inserts chunks in parallel
+	importProgressInterval = 2 * time.Second  // How often the row counts and heartbeat of a running import are saved
+	importHeartbeatTimeout = 30 * time.Second // Running imports without a heartbeat for this long are considered dead
+	importMaxRowErrors     = 100000           // Rejected rows kept per import for the error report
+	importRowErrorSamples  = 10               // Rejected rows included in the import report
+	importRowLogThreshold  = 100              // Rejected rows logged individually per import
+	importRowLogSampleRate = 100              // Beyond the threshold, one in this many rejected rows is logged
+)
+
+// importWorkerID identifies the instance running an import, e.g. "api-7f9c:1234"
+var importWorkerID = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}()
+
+// errImportQueueFull is returned when no more imports can be queued
+var errImportQueueFull = errors.New("import queue is full, try again later")
+
+// errClientDisconnected is the cause of imports cancelled because the client waiting for them went away
+var errClientDisconnected = errors.New("client disconnected")
+
+// ImportJob tracks an asynchronous CSV import in the import_jobs table
+type ImportJob struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	FileName      string     `gorm:"size:255" json:"file_name"`
+	FileSize      int64      `json:"file_size"`
+	Source        string     `gorm:"size:50" json:"source"`
+	Sink          string     `gorm:"size:50" json:"sink"`
+	State         string     `gorm:"size:10;index" json:"state"`
+	RowsProcessed int64      `json:"rows_processed"`
+	RowsSkipped   int64      `json:"rows_skipped"`
+	DurationMs    int64      `json:"duration_ms"`
+	Error         string     `gorm:"type:text" json:"error,omitempty"`
+	Report        string     `gorm:"type:text" json:"-"` // JSON ingestion metrics of the finished import
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	Worker        string     `gorm:"size:255" json:"worker,omitempty"` // Instance running the import
+	HeartbeatAt   *time.Time `json:"heartbeat_at"`                     // Last time the running import saved its progress
+	RequestID     string     `gorm:"size:128" json:"request_id"`       // X-Request-ID of the request that submitted the import
+
+	// What the import loads, given with the upload so it can be found in the history later
+	Description  string     `gorm:"type:text" json:"description,omitempty"`
+	SourceSystem string     `gorm:"size:100;index" json:"source_system,omitempty"`
+	BusinessDate Date       `gorm:"type:date;index" json:"business_date"`
+	Tags         importTags `gorm:"size:1100" json:"tags,omitempty"`
+}
+
+// TableName specifies the name of the table in the database
+func (ImportJob) TableName() string {
+	return "import_jobs"
+}
+
+// JobStore interface defines how import jobs are persisted
+type JobStore interface {
+	Create(job *ImportJob) error
+	Save(job *ImportJob) error
+	Get(id uint) (*ImportJob, error)
+	RecentDone(limit int) ([]ImportJob, error)
+	List(filter importHistoryFilter, offset, limit int) ([]ImportJob, int64, error)
+	SaveRowErrors(rowErrors []ImportRowError) error
+	RowErrors(jobID uint) ([]ImportRowError, error)
+	FailStale(before time.Time, reason string) (int64, error)
+}
+
+// GormJobStore is a concrete implementation of JobStore using GORM
+type GormJobStore struct {
+	db *gorm.DB
+}
+
+// Create inserts a new import job and fills in its ID
+func (store *GormJobStore) Create(job *ImportJob) error {
+	return store.db.Create(job).Error
+}
+
+// Save updates every column of an existing import job
+func (store *GormJobStore) Save(job *ImportJob) error {
+	return store.db.Save(job).Error
+}
+
+// Get loads an import job by ID, returning gorm.ErrRecordNotFound when it doesn't exist
+func (store *GormJobStore) Get(id uint) (*ImportJob, error) {
+	var job ImportJob
+	if err := store.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RecentDone loads the most recently finished successful import jobs
+func (store *GormJobStore) RecentDone(limit int) ([]ImportJob, error) {
+	var jobs []ImportJob
+	err := store.db.Where("state = ?", importDone).Order("finished_at DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// List loads a page of the import jobs matching filter, newest first, and the number of matching jobs
+func (store *GormJobStore) List(filter importHistoryFilter, offset, limit int) ([]ImportJob, int64, error) {
+	var total int64
+	if err := filter.apply(store.db.Model(&ImportJob{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var jobs []ImportJob
+	err := filter.apply(store.db).Order("id DESC").Offset(offset).Limit(limit).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// SaveRowErrors inserts the rejected rows of an import
+func (store *GormJobStore) SaveRowErrors(rowErrors []ImportRowError) error {
+	return store.db.CreateInBatches(rowErrors, 1000).Error
+}
+
+// RowErrors loads the rejected rows of an import ordered by line
+func (store *GormJobStore) RowErrors(jobID uint) ([]ImportRowError, error) {
+	var rowErrors []ImportRowError
+	err := store.db.Where("job_id = ?", jobID).Order("line ASC").Find(&rowErrors).Error
+	return rowErrors, err
+}
+
+// FailStale marks running imports whose last heartbeat is older than before as failed, e.g. after their
+// instance crashed, keeping the row counts they saved. It returns the number of jobs marked failed.
+func (store *GormJobStore) FailStale(before time.Time, reason string) (int64, error) {
+	tx := store.db.Model(&ImportJob{}).
+		Where("state = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", importRunning, before).
+		Updates(map[string]interface{}{"state": importFailed, "error": reason, "finished_at": time.Now()})
+	return tx.RowsAffected, tx.Error
+}
+
+// importProgress counts the rows of a running import and records why rows were rejected;
+// a nil progress records nothing
+type importProgress struct {
+	jobID      uint     // Logged with rejected rows and stored with every row
+	requestID  string   // Request that submitted the import, logged with its messages
+	sourceFile string   // Name of the imported file, stored with every row
+	evolved    []string // Columns added by schema evolution that the import writes to
+	processed  atomic.Int64
+	skipped    atomic.Int64
+
+	mu               sync.Mutex
+	rowErrors        []ImportRowError
+	rowErrorsDropped int            // Rejected rows beyond importMaxRowErrors, counted but not kept
+	coerced          map[string]int // Imported values changed to fit their column, per column
+
+	trackIDs        bool               // Record the IDs of written rows, requested with return_ids=true
+	idRanges        []generatedIDRange // Lines of written rows and the IDs they got
+	idRangesDropped int                // Written rows beyond importMaxIDRanges, counted but not kept
+
+	committed        []lineRange // Lines of the rows committed, per chunk
+	committedDropped int         // Committed rows beyond importMaxCommittedRanges, counted but not kept
+}
+
+// addProcessed counts rows written to the database
+func (p *importProgress) addProcessed(n int) {
+	if p != nil {
+		p.processed.Add(int64(n))
+	}
+}
+
+// coerce counts values of an imported row that were changed to fit their columns
+func (p *importProgress) coerce(columns ...string) {
+	if p == nil || len(columns) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.coerced == nil {
+		p.coerced = map[string]int{}
+	}
+	for _, column := range columns {
+		p.coerced[column]++
+	}
+}
+
+// coercions returns a copy of the coerced value counts per column
+func (p *importProgress) coercions() map[string]int {
+	coercions := map[string]int{}
+	if p == nil {
+		return coercions
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for column, count := range p.coerced {
+		coercions[column] = count
+	}
+	return coercions
+}
+
+// reject counts a row that was not written and records the line, column and reason.
+// The first rejected rows of an import are logged, later ones sampled, so a bad file can't flood the log.
+func (p *importProgress) reject(line int, column, reason string, record []string) {
+	if p == nil {
+		return
+	}
+	rejected := p.skipped.Add(1)
+	if rejected <= importRowLogThreshold || rejected%importRowLogSampleRate == 0 {
+		log.WithFields(logrus.Fields{
+			"job_id":        p.jobID,
+			"line":          line,
+			"column":        column,
+			"reason":        reason,
+			"rejected_rows": rejected,
+			"sampled":       rejected > importRowLogThreshold,
+		}).Warn("Rejected import row")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.rowErrors) >= importMaxRowErrors {
+		p.rowErrorsDropped++
+		return
+	}
+	// Values of columns added by schema evolution are left out, matching the header of the rejects CSV
+	record = record[:min(len(record), len(csvColumns))]
+	p.rowErrors = append(p.rowErrors, ImportRowError{Line: line, Column: column, Reason: reason, Record: encodeCSVRecord(record)})
+}
+
+// rejectedRows returns the recorded row errors sorted by line, and how many more were not kept
+func (p *importProgress) rejectedRows() ([]ImportRowError, int) {
+	if p == nil {
+		return nil, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rowErrors := append([]ImportRowError(nil), p.rowErrors...)
+	sort.Slice(rowErrors, func(i, j int) bool { return rowErrors[i].Line < rowErrors[j].Line })
+	return rowErrors, p.rowErrorsDropped
+}
+
+// importOptions are the per-upload settings of an import
+type importOptions struct {
+	preserveOrder bool
+	overflow      *overflowHandler
+	dedup         *deduplicator
+	sheet         string        // Workbook sheet of XLSX uploads, the first one when empty
+	columns       columnMapping // Header names of columns whose names differ from the csvColumns names
+	wait          bool          // Respond once the import is finished instead of right after queueing it
+	returnIDs     bool          // Report the IDs of the written rows by file line
+	validation    string        // lenient or strict
+	dateFormats   []string      // Names of the formats dates are parsed with, defaultDateFormats when empty
+	format        string        // File format the body must have, detected from its content when empty
+}
+
+// importTask is a queued import of the CSV read from source and written to sink
+type importTask struct {
+	job     *ImportJob
+	source  Source
+	sink    Sink
+	options importOptions
+	tags    queryTags       // Query tags of the submitting request, extended with the job ID while running
+	ctx     context.Context // Context of the request waiting for the import, nil for background imports
+	done    chan struct{}   // Closed once the import is finished, nil for background imports
+}
+
+// importManager runs queued imports in the background and records their state in the store.
+// Uploads are kept in blobs until their import is finished.
+type importManager struct {
+	store  JobStore
+	blobs  BlobStore
+	queue  chan importTask
+	wg     sync.WaitGroup          // Tracks imports that were submitted but not finished
+	ctx    context.Context         // Cancelled on shutdown, aborting the running imports
+	cancel context.CancelCauseFunc // Cancels ctx with the reason
+}
+
+// newImportManager creates an import manager and starts its workers
+func newImportManager(store JobStore, blobs BlobStore) *importManager {
+	m := &importManager{store: store, blobs: blobs, queue: make(chan importTask, importQueueSize)}
+	m.ctx, m.cancel = context.WithCancelCause(context.Background())
+	for i := 0; i < importWorkers; i++ {
+		go m.worker()
+	}
+	return m
+}
+
+// submit records the task's job as queued and hands it to the workers.
+// It returns a copy of the job as created, since the workers update the original.
+func (m *importManager) submit(task importTask) (ImportJob, error) {
+	task.job.State = importQueued
+	if err := m.store.Create(task.job); err != nil {
+		releaseSource(task.source)
+		return ImportJob{}, fmt.Errorf("failed to create import job: %w", err)
+	}
+	created := *task.job
+
+	m.wg.Add(1)
+	select {
+	case m.queue <- task:
+		return created, nil
+	default:
+		m.finish(task, nil, errImportQueueFull)
+		return ImportJob{}, errImportQueueFull
+	}
+}
+
+// Wait blocks until every submitted import has finished
+func (m *importManager) Wait() {
+	m.wg.Wait()
+}
+
+// Shutdown waits for the submitted imports to finish until ctx is done, then cancels the remaining ones.
+// Cancelled imports stop after the chunks being inserted and are marked failed.
+func (m *importManager) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		m.cancel(errShuttingDown)
+		return nil
+	case <-ctx.Done():
+	}
+	m.cancel(errShuttingDown)
+	<-done
+	return ctx.Err()
+}
+
+// worker runs queued imports one after another
+func (m *importManager) worker() {
+	for task := range m.queue {
+		m.run(task)
+	}
+}
+
+// run executes a single import, periodically saving its progress
+func (m *importManager) run(task importTask) {
+	job := task.job
+	importCtx, cancel := m.importContext(task)
+	defer cancel()
+	if importCtx.Err() != nil {
+		m.finish(task, nil, fmt.Errorf("import cancelled: %w", context.Cause(importCtx)))
+		return
+	}
+	started := time.Now()
+	job.State = importRunning
+	job.StartedAt = &started
+	job.Worker = importWorkerID
+	job.HeartbeatAt = &started
+	m.save(job)
+	log.WithFields(logrus.Fields{"job_id": job.ID, "request_id": job.RequestID}).Info("Import started")
+
+	// Tag the import's queries with its job so its load can be told apart from the API's.
+	// Shutdown cancels reading, while the batches being written are committed rather than cut off.
+	ctx := withQueryTags(importCtx, task.tags)
+	ctx = withQueryTags(ctx, queryTags{"job_id": strconv.FormatUint(uint64(job.ID), 10)})
+	sink := task.sink
+	if scoped, ok := sink.(contextSink); ok {
+		sink = scoped.WithContext(context.WithoutCancel(ctx))
+	}
+
+	// Write the values of header columns that were added to the table by schema evolution
+	evolved, err := loadEvolvedColumns()
+	if err != nil {
+		m.finish(task, nil, fmt.Errorf("failed to load the evolved columns: %w", err))
+		return
+	}
+
+	// Save the row counts and a heartbeat while the import runs, so GET /api/imports/:id shows progress
+	// and other instances can tell the import is still alive
+	progress := &importProgress{jobID: job.ID, requestID: job.RequestID, sourceFile: job.FileName, evolved: evolved, trackIDs: task.options.returnIDs}
+	stopHeartbeat := m.heartbeat(job, started, progress)
+
+	// Strict imports read the whole file once before writing any row, and fail without writing if a row is invalid
+	if task.options.validation == validationStrict {
+		source, err := m.spoolSource(ctx, task.source)
+		if err != nil {
+			stopHeartbeat()
+			m.finish(task, nil, err)
+			return
+		}
+		task.source = source
+		if err := validateSource(ctx, task, progress); err != nil {
+			stopHeartbeat()
+			job.RowsSkipped = progress.skipped.Load()
+			m.saveRowErrors(job, progress)
+			rowErrors, dropped := progress.rejectedRows()
+			m.finish(task, map[string]interface{}{
+				"validation":        validationStrict,
+				"row_errors":        len(rowErrors) + dropped,
+				"row_error_samples": rowErrors[:min(len(rowErrors), importRowErrorSamples)],
+			}, err)
+			return
+		}
+	}
+
+	file, err := task.source.Open(ctx)
+	if err != nil {
+		stopHeartbeat()
+		m.finish(task, nil, fmt.Errorf("failed to open source: %w", err))
+		return
+	}
+	defer file.Close()
+
+	metrics, err := runImport(ctx, file, sink, task.options, progress)
+	stopHeartbeat()
+
+	job.RowsProcessed = progress.processed.Load()
+	job.RowsSkipped = progress.skipped.Load()
+	m.saveRowErrors(job, progress)
+	m.finish(task, metrics, err)
+}
+
+// heartbeat saves the row counts of the running import and a heartbeat every importProgressInterval
+// until the returned function is called
+func (m *importManager) heartbeat(job *ImportJob, started time.Time, progress *importProgress) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(importProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				heartbeat := time.Now()
+				snapshot := *job
+				snapshot.HeartbeatAt = &heartbeat
+				snapshot.RowsProcessed = progress.processed.Load()
+				snapshot.RowsSkipped = progress.skipped.Load()
+				snapshot.DurationMs = time.Since(started).Milliseconds()
+				m.save(&snapshot)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// saveRowErrors keeps the rejected rows of the import for GET /api/imports/:id/errors
+func (m *importManager) saveRowErrors(job *ImportJob, progress *importProgress) {
+	rowErrors, _ := progress.rejectedRows()
+	if len(rowErrors) == 0 {
+		return
+	}
+	for i := range rowErrors {
+		rowErrors[i].JobID = job.ID
+	}
+	if err := m.store.SaveRowErrors(rowErrors); err != nil {
+		log.WithError(err).WithField("job_id", job.ID).Error("Failed to save import row errors")
+	}
+}
+
+// importContext returns the context the import runs in, cancelled on shutdown
+// and, for imports a client waits for, when the client disconnects
+func (m *importManager) importContext(task importTask) (context.Context, context.CancelFunc) {
+	if task.ctx == nil {
+		return m.ctx, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(m.ctx)
+	if task.ctx.Err() != nil {
+		cancel(errClientDisconnected)
+		return ctx, func() {}
+	}
+	stop := context.AfterFunc(task.ctx, func() { cancel(errClientDisconnected) })
+	return ctx, func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// finish records the final state of an import and removes its spooled upload
+func (m *importManager) finish(task importTask, metrics map[string]interface{}, err error) {
+	if task.done != nil {
+		defer close(task.done)
+	}
+	defer m.wg.Done()
+	defer releaseSource(task.source)
+
+	job := task.job
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.HeartbeatAt = &finished
+	if job.StartedAt != nil {
+		job.DurationMs = finished.Sub(*job.StartedAt).Milliseconds()
+	}
+	if metrics != nil {
+		if report, marshalErr := json.Marshal(metrics); marshalErr == nil {
+			job.Report = string(report)
+		}
+	}
+
+	fields := logrus.Fields{"job_id": job.ID, "request_id": job.RequestID, "rows_processed": job.RowsProcessed, "rows_skipped": job.RowsSkipped}
+	if errors.Is(err, errClientDisconnected) {
+		job.State = importCancelled
+		job.Error = err.Error()
+		log.WithFields(fields).Warn("Import cancelled")
+	} else if err != nil {
+		job.State = importFailed
+		job.Error = err.Error()
+		log.WithFields(fields).WithError(err).Error("Import failed")
+	} else {
+		job.State = importDone
+		log.WithFields(fields).Info("Import completed")
+	}
+	m.save(job)
+}
+
+// save persists the job, logging failures since the import itself can continue
+func (m *importManager) save(job *ImportJob) {
+	if err := m.store.Save(job); err != nil {
+		log.WithError(err).WithField("job_id", job.ID).Error("Failed to save import job")
+	}
+}
+
+// failStaleJobs marks running imports whose instance stopped sending heartbeats as failed
+func (m *importManager) failStaleJobs() {
+	failed, err := m.store.FailStale(time.Now().Add(-importHeartbeatTimeout), "import stopped: its worker stopped sending heartbeats")
+	if err != nil {
+		log.WithError(err).Error("Failed to check for stale imports")
+		return
+	}
+	if failed > 0 {
+		log.WithField("jobs", failed).Warn("Marked stale imports as failed")
+	}
+}
+
+// watchStaleJobs checks for stale imports now and then every interval until shutdown,
+// e.g. imports left running by a crashed instance
+func (m *importManager) watchStaleJobs(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.failStaleJobs()
+		select {
+		case <-ticker.C:
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// storeUpload copies the upload to the blob store so it outlives the request, returning its key
+func (m *importManager) storeUpload(ctx context.Context, file io.Reader, size int64) (string, error) {
+	key := "uploads/" + uuid.NewString() + ".csv"
+	if err := m.blobs.Put(ctx, key, file, size); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// spoolSource copies a source that can't be read twice, such as a URL or an object of another bucket, to
+// the blob store, so strict imports download it once and write the same file they validated. Uploads and
+// blobs are already stored and returned as they are.
+func (m *importManager) spoolSource(ctx context.Context, source Source) (Source, error) {
+	if _, ok := source.(*blobSource); ok {
+		return source, nil
+	}
+
+	file, err := source.Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source: %w", err)
+	}
+	defer file.Close()
+
+	key, err := m.storeUpload(ctx, file, -1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store source: %w", err)
+	}
+	releaseSource(source)
+	return &blobSource{blobs: m.blobs, key: key, remove: true}, nil
+}
+
+// releaseSource removes the data a source keeps only for its import
+func releaseSource(source Source) {
+	if r, ok := source.(releaser); ok {
+		r.Release()
+	}
+}
+
+// importRequest is the body of POST /api/imports
+type importRequest struct {
+	Source       string            `json:"source" binding:"required"`
+	SourceParams map[string]string `json:"source_params"`
+	Sink         string            `json:"sink"`
+	SinkParams   map[string]string `json:"sink_params"`
+	Tags         []string          `json:"tags"` // e.g. ["backfill-2023"], to find the import and its records by
+}
+
+// createImport handles POST /api/imports, queueing an import from a registered source to a registered sink
+func createImport(c *gin.Context, dbHandler DBHandler, imports *importManager) {
+	var req importRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid import request", err.Error())
+		return
+	}
+	queueImport(c, dbHandler, imports, req)
+}
+
+// urlImportRequest is the body of POST /api/imports/url
+type urlImportRequest struct {
+	URL        string            `json:"url" binding:"required"`
+	SHA256     string            `json:"sha256"`    // Hex digest the file must have
+	MaxBytes   int64             `json:"max_bytes"` // Lowers the size limit of the download
+	Timeout    string            `json:"timeout"`   // How long the download may take, e.g. 2h
+	Sink       string            `json:"sink"`
+	SinkParams map[string]string `json:"sink_params"`
+	Tags       []string          `json:"tags"`
+}
+
+// createURLImport handles POST /api/imports/url, queueing an import streamed from a remote file
+func createURLImport(c *gin.Context, dbHandler DBHandler, imports *importManager) {
+	var req urlImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid import request", err.Error())
+		return
+	}
+	params := map[string]string{"url": req.URL, "sha256": req.SHA256, "timeout": req.Timeout}
+	if req.MaxBytes != 0 {
+		params["max_bytes"] = strconv.FormatInt(req.MaxBytes, 10)
+	}
+	queueImport(c, dbHandler, imports, importRequest{Source: "url", SourceParams: params, Sink: req.Sink, SinkParams: req.SinkParams, Tags: req.Tags})
+}
+
+// queueImport creates the source and sink of req and queues the import
+func queueImport(c *gin.Context, dbHandler DBHandler, imports *importManager, req importRequest) {
+	if req.Sink == "" {
+		req.Sink = "postgres"
+	}
+
+	options, ok := parseImportOptions(c)
+	if !ok {
+		return
+	}
+	tags, err := parseImportTags(req.Tags)
+	if err != nil {
+		respondError(c, 400, "Invalid import metadata", err.Error())
+		return
+	}
+
+	deps := connectorDeps{dbHandler: dbHandler, blobs: imports.blobs}
+	source, err := newSource(req.Source, deps, req.SourceParams)
+	if err != nil {
+		respondError(c, 400, "Invalid source", err.Error())
+		return
+	}
+	sink, err := newSink(req.Sink, deps, req.SinkParams)
+	if err != nil {
+		respondError(c, 400, "Invalid sink", err.Error())
+		return
+	}
+
+	// Name the job after what it reads, e.g. the URL, blob key or object
+	name := req.SourceParams["url"]
+	if name == "" {
+		name = req.SourceParams["key"]
+	}
+	if object, ok := source.(*objectSource); ok {
+		name = object.name()
+	}
+
+	submitImport(c, imports, importTask{
+		job:     &ImportJob{FileName: name, Source: req.Source, Sink: req.Sink, Tags: tags},
+		source:  source,
+		sink:    sink,
+		options: options,
+	})
+}
+
+// importStatus handles GET /api/imports/:id
+func importStatus(c *gin.Context, store JobStore) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, 400, "Invalid import job ID")
+		return
+	}
+
+	job, err := store.Get(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Import job not found")
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch import job")
+		respondError(c, 500, "Failed to fetch import job")
+		return
+	}
+
+	// Running imports report the time spent so far, and whether their worker still sends heartbeats
+	stale := false
+	if job.State == importRunning && job.StartedAt != nil {
+		job.DurationMs = time.Since(*job.StartedAt).Milliseconds()
+		stale = job.HeartbeatAt == nil || time.Since(*job.HeartbeatAt) > importHeartbeatTimeout
+	}
+
+	respond(c, 200, job, gin.H{"report": jobReport(job), "stale": stale})
+}
+
+// columnKeyedMetrics are the import metrics keyed by column name, which are data rather than field names
+var columnKeyedMetrics = []string{"coercions", "overflow_truncated"}
+
+// jobReport decodes the ingestion metrics of a finished import, nil while it runs
+func jobReport(job *ImportJob) map[string]interface{} {
+	var report map[string]interface{}
+	if job.Report != "" {
+		if err := json.Unmarshal([]byte(job.Report), &report); err != nil {
+			log.WithError(err).WithField("job_id", job.ID).Error("Failed to decode import report")
+		}
+	}
+
+	// Decode the counts by column as they were written, so the JSON casing policy keeps the column names
+	for _, key := range columnKeyedMetrics {
+		counts, ok := report[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		typed := make(map[string]float64, len(counts))
+		for name, count := range counts {
+			typed[name], _ = count.(float64)
+		}
+		report[key] = typed
+	}
+	return report
+}
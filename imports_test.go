@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestImportStatus tests the import job status endpoint
+func TestImportStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := time.Now().Add(-time.Second)
+	store := NewMockJobStore(ctrl)
+	store.EXPECT().Get(uint(7)).Return(&ImportJob{
+		ID: 7, State: importDone, RowsProcessed: 10, RowsSkipped: 2, DurationMs: 900,
+		StartedAt: &started, Report: `{"duplicates_dropped":1}`,
+	}, nil)
+	store.EXPECT().Get(uint(8)).Return(nil, gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/imports/:id", func(c *gin.Context) {
+		importStatus(c, store)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/imports/7", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"state":"done"`)
+	assert.Contains(t, w.Body.String(), `"rows_skipped":2`)
+	assert.Contains(t, w.Body.String(), `"duration_ms":900`)
+	assert.Contains(t, w.Body.String(), `"duplicates_dropped":1`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/imports/8", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/imports/abc", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+// TestImportStatusStale tests that running imports without a recent heartbeat are reported as stale
+func TestImportStatusStale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := time.Now().Add(-time.Hour)
+	recent := time.Now()
+	old := time.Now().Add(-2 * importHeartbeatTimeout)
+	store := NewMockJobStore(ctrl)
+	store.EXPECT().Get(uint(1)).Return(&ImportJob{ID: 1, State: importRunning, StartedAt: &started, HeartbeatAt: &recent, Worker: "api-1:7"}, nil)
+	store.EXPECT().Get(uint(2)).Return(&ImportJob{ID: 2, State: importRunning, StartedAt: &started, HeartbeatAt: &old}, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/imports/:id", func(c *gin.Context) {
+		importStatus(c, store)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/imports/1", nil)
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"stale":false`)
+	assert.Contains(t, w.Body.String(), `"worker":"api-1:7"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/imports/2", nil)
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"stale":true`)
+}
+
+// TestFailStaleJobs tests that imports whose heartbeat stopped are marked failed
+func TestFailStaleJobs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockJobStore(ctrl)
+	store.EXPECT().FailStale(gomock.Any(), gomock.Any()).DoAndReturn(func(before time.Time, reason string) (int64, error) {
+		assert.WithinDuration(t, time.Now().Add(-importHeartbeatTimeout), before, time.Second)
+		assert.Contains(t, reason, "stopped sending heartbeats")
+		return 2, nil
+	})
+
+	hook := logtest.NewLocal(log)
+	defer log.ReplaceHooks(make(logrus.LevelHooks))
+	(&importManager{store: store}).failStaleJobs()
+	assert.Equal(t, "Marked stale imports as failed", hook.LastEntry().Message)
+	assert.Equal(t, int64(2), hook.LastEntry().Data["jobs"])
+}
+
+// TestImportManagerFailures tests that unreadable uploads and a full queue mark the job failed
+func TestImportManagerFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store, final := newRecordingJobStore(ctrl)
+	blobs, err := newLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	overflow, _ := newOverflowHandler("")
+	task := func(key string) importTask {
+		return importTask{job: &ImportJob{}, source: &blobSource{blobs: blobs, key: key, remove: true}, options: importOptions{overflow: overflow}}
+	}
+
+	// The stored upload disappeared before the import started
+	imports := newImportManager(store, blobs)
+	_, err = imports.submit(task("uploads/missing.csv"))
+	assert.NoError(t, err)
+	imports.Wait()
+	assert.Equal(t, importFailed, final().State)
+	assert.Contains(t, final().Error, "failed to open source")
+
+	// No worker is free and the queue has no room
+	full := &importManager{store: store, blobs: blobs, queue: make(chan importTask)}
+	key, err := full.storeUpload(context.Background(), strings.NewReader("ID\n"), 3)
+	assert.NoError(t, err)
+	_, err = full.submit(task(key))
+	assert.ErrorIs(t, err, errImportQueueFull)
+	assert.Equal(t, importFailed, final().State)
+
+	// The stored upload is removed once the job is finished
+	_, err = blobs.Get(context.Background(), key)
+	assert.ErrorIs(t, err, errBlobNotFound)
+}
+
+// TestImportRowErrors tests downloading the rejected rows of an import as CSV
+func TestImportRowErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockJobStore(ctrl)
+	store.EXPECT().Get(uint(3)).Return(&ImportJob{ID: 3, State: importDone}, nil)
+	store.EXPECT().RowErrors(uint(3)).Return([]ImportRowError{
+		{Line: 4, Column: "age", Reason: `invalid age "old"`, Record: encodeCSVRecord([]string{"3", "Jim", "Doe, Jr", "jim@example.com", "old", "Male", "IT", "Corp", "40000", "2022-01-01", "true"})},
+	}, nil)
+	store.EXPECT().Get(uint(4)).Return(nil, gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/imports/:id/errors", func(c *gin.Context) {
+		importRowErrors(c, store)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/imports/3/errors", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "line,error_column,error_reason,id,first_name,last_name,email,age,gender,department,company,salary,date_joined,is_active\n"+
+		`4,age,"invalid age ""old""",3,Jim,"Doe, Jr",jim@example.com,old,Male,IT,Corp,40000,2022-01-01,true`+"\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/imports/4/errors", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+}
+
+// TestRejectedRowLogging tests that rejected rows are logged with their job and position, sampled past the threshold
+func TestRejectedRowLogging(t *testing.T) {
+	hook := logtest.NewLocal(log)
+	defer log.ReplaceHooks(make(logrus.LevelHooks))
+
+	progress := &importProgress{jobID: 5}
+	for line := 2; line < importRowLogThreshold+2*importRowLogSampleRate+2; line++ {
+		progress.reject(line, "age", "invalid age", []string{"1"})
+	}
+
+	assert.Len(t, hook.AllEntries(), importRowLogThreshold+2)
+	first := hook.AllEntries()[0]
+	assert.Equal(t, "Rejected import row", first.Message)
+	assert.Equal(t, logrus.Fields{"job_id": uint(5), "line": 2, "column": "age", "reason": "invalid age", "rejected_rows": int64(1), "sampled": false}, first.Data)
+	assert.Equal(t, true, hook.LastEntry().Data["sampled"])
+}
+
+// TestImportWait tests that waiting clients get the finished job and that disconnecting cancels the import
+func TestImportWait(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store, final := newRecordingJobStore(ctrl)
+	overflow, _ := newOverflowHandler("")
+	imports := newImportManager(store, nil)
+	defer imports.Shutdown(context.Background())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/import", func(c *gin.Context) {
+		options, ok := parseImportOptions(c)
+		if !ok {
+			return
+		}
+		options.overflow = overflow
+		submitImport(c, imports, importTask{job: &ImportJob{}, source: stringSource("ID,First Name,Last Name,Email,Age,Gender,Department,Company,Salary,Date Joined,Is Active\n"), sink: &memorySink{}, options: options})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/import?wait=true", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"state":"done"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/import?wait=maybe", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// The client goes away while its import is running
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	_, err := imports.submit(importTask{job: &ImportJob{}, source: endlessSource{}, sink: &memorySink{}, options: importOptions{overflow: overflow}, ctx: ctx, done: done})
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, importCancelled, final().State)
+	assert.Equal(t, "import cancelled: client disconnected", final().Error)
+}
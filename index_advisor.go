@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	indexAdvisorMinUses        = 20        // Queries filtering or sorting by a column before an index is suggested
+	indexAdvisorMinRows        = 10000     // Smaller tables are scanned quickly enough without an index
+	indexAdvisorMaxSelectivity = 0.05      // Largest share of rows a value may match for an index to pay off in filters
+	indexAdvisorInterval       = time.Hour // How often suggested indexes are created with auto_index
+)
+
+// columnUsage counts the /api/records queries filtering or sorting by a column
+type columnUsage struct {
+	Filters    int64
+	Sorts      int64
+	LastUsedAt time.Time
+}
+
+// indexUsageTracker counts the columns /api/records queries filter and sort by since the instance started
+type indexUsageTracker struct {
+	mu      sync.Mutex
+	since   time.Time
+	columns map[string]*columnUsage
+}
+
+// recordQueryUsage is fed by the records endpoints and read by the index advisor
+var recordQueryUsage = newIndexUsageTracker()
+
+func newIndexUsageTracker() *indexUsageTracker {
+	return &indexUsageTracker{since: time.Now(), columns: map[string]*columnUsage{}}
+}
+
+// record counts the filtered columns of a query and the first column it is sorted by; the id is left
+// out, since the primary key indexes it
+func (t *indexUsageTracker) record(filters []recordFilter, order string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	use := func(column string) *columnUsage {
+		usage := t.columns[column]
+		if usage == nil {
+			usage = &columnUsage{}
+			t.columns[column] = usage
+		}
+		usage.LastUsedAt = now
+		return usage
+	}
+
+	filtered := map[string]bool{}
+	for _, filter := range filters {
+		column := strings.Fields(filter.condition)[0]
+		if !filtered[column] { // min_age and max_age filter one column in one query
+			filtered[column] = true
+			use(column).Filters++
+		}
+	}
+	if column := strings.Fields(order)[0]; column != "id" {
+		use(column).Sorts++
+	}
+}
+
+// snapshot returns a copy of the counts and when counting started
+func (t *indexUsageTracker) snapshot() (map[string]columnUsage, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	columns := make(map[string]columnUsage, len(t.columns))
+	for column, usage := range t.columns {
+		columns[column] = *usage
+	}
+	return columns, t.since
+}
+
+// tableIndex is an index of the user_data table by its leading column
+type tableIndex struct {
+	Name   string
+	Column string
+	Valid  bool // False for an index whose concurrent build failed, which queries don't use
+}
+
+// columnStat holds the planner statistics of a column, as gathered by ANALYZE
+type columnStat struct {
+	Column       string
+	NDistinct    float64 // Distinct values, or minus their share of the rows when they grow with the table
+	NullFraction float64
+}
+
+// tableIndexStats are the indexes and column statistics of the user_data table
+type tableIndexStats struct {
+	Rows    int64 // Estimated from the last VACUUM or ANALYZE, -1 if the table was never analyzed
+	Indexes []tableIndex
+	Columns []columnStat
+}
+
+// indexes reads the statistics the advisor works from and creates the indexes it suggests
+var indexes IndexStore
+
+// IndexStore reads the indexes and column statistics of the user_data table and creates indexes on it
+type IndexStore interface {
+	Stats() (*tableIndexStats, error)
+	Create(column string) (string, error)
+}
+
+// GormIndexStore is a concrete implementation of IndexStore using GORM
+type GormIndexStore struct {
+	db *gorm.DB
+}
+
+// WithContext returns a store whose queries use ctx and carry its query tags
+func (s *GormIndexStore) WithContext(ctx context.Context) IndexStore {
+	return &GormIndexStore{db: s.db.WithContext(ctx)}
+}
+
+// Stats reads the indexes of the user_data table and its column statistics from the catalog
+func (s *GormIndexStore) Stats() (*tableIndexStats, error) {
+	table := UserData{}.TableName()
+	stats := &tableIndexStats{}
+	if err := s.db.Raw("SELECT reltuples::bigint FROM pg_class WHERE oid = ?::regclass", table).Scan(&stats.Rows).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Raw(`SELECT i.relname AS name, a.attname AS column, ix.indisvalid AS valid
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = ix.indkey[0]
+		WHERE ix.indrelid = ?::regclass
+		ORDER BY i.relname`, table).Scan(&stats.Indexes).Error; err != nil {
+		return nil, err
+	}
+	err := s.db.Raw(`SELECT attname AS column, n_distinct, null_frac AS null_fraction
+		FROM pg_stats WHERE schemaname = current_schema() AND tablename = ?`, table).Scan(&stats.Columns).Error
+	return stats, err
+}
+
+// Create builds the advisor's index on column without blocking writes to the table, replacing an
+// invalid one left by a failed build, and returns its name
+func (s *GormIndexStore) Create(column string) (string, error) {
+	name := advisedIndexName(column)
+	var valid []bool
+	if err := s.db.Raw("SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass(?)", name).Scan(&valid).Error; err != nil {
+		return "", err
+	}
+	if len(valid) > 0 && !valid[0] {
+		if err := s.db.Exec("DROP INDEX CONCURRENTLY IF EXISTS " + name).Error; err != nil {
+			return "", err
+		}
+	}
+	return name, s.db.Exec(advisedIndexStatement(column)).Error
+}
+
+// advisedIndexName names the index the advisor creates on column
+func advisedIndexName(column string) string {
+	return "idx_" + UserData{}.TableName() + "_" + column
+}
+
+// advisedIndexStatement returns the statement creating the advisor's index on column. Only the column
+// names of recordFilterParams and recordSortColumns are ever passed.
+func advisedIndexStatement(column string) string {
+	return fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s (%s)", advisedIndexName(column), UserData{}.TableName(), column)
+}
+
+// indexAdvice is the advisor's verdict on indexing a column the records endpoints filter or sort by
+type indexAdvice struct {
+	Column         string    `json:"column"`
+	Filters        int64     `json:"filters"`
+	Sorts          int64     `json:"sorts"`
+	LastUsedAt     time.Time `json:"last_used_at"`
+	Indexes        []string  `json:"indexes"`         // Valid indexes leading with the column
+	DistinctValues *float64  `json:"distinct_values"` // Estimated, null before the table is analyzed
+	NullFraction   *float64  `json:"null_fraction"`
+	Selectivity    *float64  `json:"selectivity"` // Estimated share of the rows matching one value
+	Suggested      bool      `json:"suggested"`
+	Reason         string    `json:"reason"`
+	Statement      string    `json:"statement,omitempty"` // Creates the suggested index
+}
+
+// adviseIndexes decides for each used column whether an index is worth its cost on writes: the column
+// must be used often enough, not be indexed yet and be in a table large enough for scans to hurt, and
+// either be sorted by or have values selective enough for filters to skip most rows.
+// Columns are ordered by how often they are used.
+func adviseIndexes(usage map[string]columnUsage, stats *tableIndexStats) []indexAdvice {
+	indexes := map[string][]string{}
+	for _, index := range stats.Indexes {
+		if index.Valid {
+			indexes[index.Column] = append(indexes[index.Column], index.Name)
+		}
+	}
+	columnStats := map[string]columnStat{}
+	for _, stat := range stats.Columns {
+		columnStats[stat.Column] = stat
+	}
+
+	advice := make([]indexAdvice, 0, len(usage))
+	for column, used := range usage {
+		a := indexAdvice{Column: column, Filters: used.Filters, Sorts: used.Sorts, LastUsedAt: used.LastUsedAt, Indexes: indexes[column]}
+		if a.Indexes == nil {
+			a.Indexes = []string{}
+		}
+		stat, analyzed := columnStats[column]
+		if analyzed && stats.Rows > 0 {
+			distinct := stat.NDistinct
+			if distinct < 0 {
+				distinct = -distinct * float64(stats.Rows)
+			}
+			distinct = max(distinct, 1)
+			selectivity := (1 - stat.NullFraction) / distinct
+			a.DistinctValues, a.NullFraction, a.Selectivity = &distinct, &stat.NullFraction, &selectivity
+		}
+
+		switch {
+		case len(a.Indexes) > 0:
+			a.Reason = "already indexed by " + strings.Join(a.Indexes, ", ")
+		case used.Filters+used.Sorts < indexAdvisorMinUses:
+			a.Reason = fmt.Sprintf("used by %d queries, fewer than the %d needed for a suggestion", used.Filters+used.Sorts, indexAdvisorMinUses)
+		case !analyzed || stats.Rows < 0:
+			a.Reason = "the table has no statistics yet; they are gathered by autovacuum or ANALYZE"
+		case stats.Rows < indexAdvisorMinRows:
+			a.Reason = fmt.Sprintf("the table has about %d rows, few enough to scan", stats.Rows)
+		case used.Sorts < indexAdvisorMinUses && *a.Selectivity > indexAdvisorMaxSelectivity:
+			a.Reason = fmt.Sprintf("a value matches about %.0f%% of the rows, too many for an index to help filters", *a.Selectivity*100)
+		default:
+			a.Suggested = true
+			a.Reason = fmt.Sprintf("filtered by %d and sorted by %d queries without an index", used.Filters, used.Sorts)
+			a.Statement = advisedIndexStatement(column)
+		}
+		advice = append(advice, a)
+	}
+	sort.Slice(advice, func(i, j int) bool {
+		ui, uj := advice[i].Filters+advice[i].Sorts, advice[j].Filters+advice[j].Sorts
+		if ui != uj {
+			return ui > uj
+		}
+		return advice[i].Column < advice[j].Column
+	})
+	return advice
+}
+
+// currentIndexAdvice combines the tracked usage with the table's statistics
+func currentIndexAdvice(store IndexStore) ([]indexAdvice, time.Time, error) {
+	usage, since := recordQueryUsage.snapshot()
+	stats, err := store.Stats()
+	if err != nil {
+		return nil, since, err
+	}
+	return adviseIndexes(usage, stats), since, nil
+}
+
+// indexAdviceHandler handles GET /api/admin/db/index-advice, reporting the columns /api/records queries
+// use and which of them should be indexed
+func indexAdviceHandler(c *gin.Context, store IndexStore) {
+	advice, since, err := currentIndexAdvice(store)
+	if err != nil {
+		log.WithError(err).Error("Failed to read index statistics")
+		respondError(c, 500, "Failed to read index statistics", err.Error())
+		return
+	}
+	suggested := 0
+	for _, a := range advice {
+		if a.Suggested {
+			suggested++
+		}
+	}
+	respond(c, 200, advice, map[string]interface{}{"since": since, "suggested": suggested, "auto_index": appConfig.Database.AutoIndex})
+}
+
+// applyIndexAdvice creates the suggested indexes one at a time and returns the names of those created
+func applyIndexAdvice(store IndexStore) ([]string, error) {
+	advice, _, err := currentIndexAdvice(store)
+	if err != nil {
+		return nil, err
+	}
+	created := []string{}
+	for _, a := range advice {
+		if !a.Suggested {
+			continue
+		}
+		started := time.Now()
+		name, err := store.Create(a.Column)
+		if err != nil {
+			return created, fmt.Errorf("failed to create the index on %s: %w", a.Column, err)
+		}
+		log.WithFields(logrus.Fields{"index": name, "column": a.Column, "filters": a.Filters, "sorts": a.Sorts,
+			"duration_ms": time.Since(started).Milliseconds()}).Warn("Created advised index")
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// applyIndexAdviceHandler handles POST /api/admin/db/index-advice/apply, creating the suggested indexes
+func applyIndexAdviceHandler(c *gin.Context, store IndexStore) {
+	created, err := applyIndexAdvice(store)
+	if err != nil {
+		log.WithError(err).Error("Failed to create advised indexes")
+		respondError(c, 500, "Failed to create advised indexes", err.Error())
+		return
+	}
+	respond(c, 200, created, map[string]interface{}{"count": len(created)})
+}
+
+// autoCreateIndexes creates the suggested indexes every interval, for deployments with auto_index set
+func autoCreateIndexes(store IndexStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if enabled, _ := readOnly.state(); enabled {
+			continue
+		}
+		if _, err := applyIndexAdvice(store); err != nil {
+			log.WithError(err).Error("Failed to create advised indexes")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIndexUsageTracker tests counting each filtered column once per query and the leading sort column
+func TestIndexUsageTracker(t *testing.T) {
+	tracker := newIndexUsageTracker()
+	tracker.record([]recordFilter{{"age >= ?", 30.0}, {"age <= ?", 40.0}, {"department = ?", "IT"}}, "salary DESC, id ASC")
+	tracker.record([]recordFilter{{"department = ?", "HR"}}, "id ASC")
+
+	usage, _ := tracker.snapshot()
+	assert.Len(t, usage, 3)
+	assert.Equal(t, int64(1), usage["age"].Filters)
+	assert.Equal(t, int64(2), usage["department"].Filters)
+	assert.Equal(t, int64(0), usage["salary"].Filters)
+	assert.Equal(t, int64(1), usage["salary"].Sorts)
+	assert.NotContains(t, usage, "id")
+}
+
+// TestAdviseIndexes tests which columns get an index suggested, and why the others don't
+func TestAdviseIndexes(t *testing.T) {
+	usage := map[string]columnUsage{
+		"email":      {Filters: 50},
+		"company":    {Filters: 40},
+		"is_active":  {Filters: 30},
+		"salary":     {Filters: 1, Sorts: 25},
+		"department": {Filters: 5},
+		"last_name":  {Filters: 20},
+	}
+	stats := &tableIndexStats{
+		Rows: 100000,
+		Indexes: []tableIndex{
+			{Name: "idx_user_data_email", Column: "email", Valid: true},
+			{Name: "idx_user_data_company", Column: "company", Valid: false},
+		},
+		Columns: []columnStat{
+			{Column: "email", NDistinct: -1},
+			{Column: "company", NDistinct: 500, NullFraction: 0.1},
+			{Column: "is_active", NDistinct: 2},
+			{Column: "salary", NDistinct: 40},
+			{Column: "department", NDistinct: 20},
+		},
+	}
+
+	advice := adviseIndexes(usage, stats)
+	require.Len(t, advice, 6)
+	byColumn := map[string]indexAdvice{}
+	for _, a := range advice {
+		byColumn[a.Column] = a
+	}
+	assert.Equal(t, "email", advice[0].Column, "most used first")
+
+	assert.False(t, byColumn["email"].Suggested)
+	assert.Equal(t, []string{"idx_user_data_email"}, byColumn["email"].Indexes)
+	assert.Contains(t, byColumn["email"].Reason, "already indexed")
+
+	company := byColumn["company"]
+	assert.True(t, company.Suggested, "invalid indexes don't count")
+	assert.InDelta(t, 0.0018, *company.Selectivity, 0.0001)
+	assert.Equal(t, "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_user_data_company ON user_data (company)", company.Statement)
+
+	assert.False(t, byColumn["is_active"].Suggested)
+	assert.Contains(t, byColumn["is_active"].Reason, "50%")
+
+	assert.True(t, byColumn["salary"].Suggested, "sorted by often enough, though not selective")
+
+	assert.False(t, byColumn["department"].Suggested)
+	assert.Contains(t, byColumn["department"].Reason, "fewer than the 20")
+
+	assert.False(t, byColumn["last_name"].Suggested)
+	assert.Contains(t, byColumn["last_name"].Reason, "no statistics")
+	assert.Nil(t, byColumn["last_name"].Selectivity)
+
+	stats.Rows = 500
+	for _, a := range adviseIndexes(usage, stats) {
+		assert.False(t, a.Suggested, "small tables are scanned: %s", a.Column)
+	}
+}
+
+// TestIndexAdviceEndpoints tests reporting the advice and creating the suggested indexes
+func TestIndexAdviceEndpoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := recordQueryUsage
+	defer func() { recordQueryUsage = previous }()
+	recordQueryUsage = newIndexUsageTracker()
+	for i := 0; i < indexAdvisorMinUses; i++ {
+		recordQueryUsage.record([]recordFilter{{"company = ?", "Acme"}, {"is_active = ?", true}}, "id ASC")
+	}
+
+	stats := &tableIndexStats{Rows: 50000, Columns: []columnStat{{Column: "company", NDistinct: 1000}, {Column: "is_active", NDistinct: 2}}}
+	store := NewMockIndexStore(ctrl)
+	store.EXPECT().Stats().Return(stats, nil).Times(2)
+	store.EXPECT().Create("company").Return("idx_user_data_company", nil)
+	store.EXPECT().Stats().Return(nil, errors.New("connection refused"))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/admin/db/index-advice", func(c *gin.Context) { indexAdviceHandler(c, store) })
+	r.POST("/api/admin/db/index-advice/apply", func(c *gin.Context) { applyIndexAdviceHandler(c, store) })
+
+	w := serveRecord(r, "GET", "/api/admin/db/index-advice", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"suggested":1`)
+	assert.Contains(t, w.Body.String(), `"column":"company"`)
+
+	w = serveRecord(r, "POST", "/api/admin/db/index-advice/apply", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":["idx_user_data_company"]`)
+
+	w = serveRecord(r, "GET", "/api/admin/db/index-advice", "")
+	assert.Equal(t, 500, w.Code)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported JSON casing policies for response field names
+const (
+	casingSnake = "snake"
+	casingCamel = "camel"
+)
+
+// renderJSON writes obj as JSON using the configured casing policy.
+// Models and handlers use snake_case keys; other policies are applied on render.
+func renderJSON(c *gin.Context, code int, obj interface{}) {
+	c.JSON(code, casedValue(obj))
+}
+
+// casedValue returns obj with its field names in the configured casing, ready to be marshalled
+func casedValue(obj interface{}) interface{} {
+	if appConfig.Server.JSONCasing != casingCamel {
+		return obj
+	}
+	return renameFields(reflect.ValueOf(obj), snakeToCamel)
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	objectType        = reflect.TypeOf(map[string]interface{}{})
+)
+
+// renameFields walks value the way encoding/json would and renames the field names with rename: the
+// JSON names of struct fields and the keys of map[string]interface{} objects such as the envelope's
+// meta and gin.H. The keys of other maps are data, e.g. the column names of an import's coercions or
+// the roles by certificate subject, and are kept as they are. Values that marshal themselves are
+// returned unchanged.
+func renameFields(value reflect.Value, rename func(string) string) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	if value.Type().Implements(jsonMarshalerType) || value.Type().Implements(textMarshalerType) {
+		return value.Interface()
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return renameFields(value.Elem(), rename)
+	case reflect.Struct:
+		object := map[string]interface{}{}
+		renameStructFields(value, rename, object)
+		return object
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		if !value.Type().ConvertibleTo(objectType) {
+			return value.Interface()
+		}
+		object := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			object[rename(iter.Key().String())] = renameFields(iter.Value(), rename)
+		}
+		return object
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && (value.IsNil() || value.Type().Elem().Kind() == reflect.Uint8) {
+			return value.Interface() // null, or base64 for []byte
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = renameFields(value.Index(i), rename)
+		}
+		return items
+	default:
+		return value.Interface()
+	}
+}
+
+// renameStructFields adds the exported fields of a struct to object under their renamed JSON names,
+// promoting the fields of embedded structs unless the outer struct has a field of the same name
+func renameStructFields(value reflect.Value, rename func(string) string, object map[string]interface{}) {
+	var embedded []reflect.Value
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldValue := value.Field(i)
+
+		if field.Anonymous && name == "" {
+			if fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					continue
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.Kind() == reflect.Struct {
+				embedded = append(embedded, fieldValue)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if strings.Contains(","+options+",", ",omitempty,") && emptyJSONValue(fieldValue) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		object[rename(name)] = renameFields(fieldValue, rename)
+	}
+
+	for _, fieldValue := range embedded {
+		promoted := map[string]interface{}{}
+		renameStructFields(fieldValue, rename, promoted)
+		for name, item := range promoted {
+			if _, ok := object[name]; !ok {
+				object[name] = item
+			}
+		}
+	}
+}
+
+// emptyJSONValue reports whether omitempty leaves out value
+func emptyJSONValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return value.IsZero()
+	default:
+		return false
+	}
+}
+
+// snakeToCamel converts snake_case to camelCase, e.g. first_name -> firstName
+func snakeToCamel(key string) string {
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSnakeToCamel tests snake_case to camelCase conversion
+func TestSnakeToCamel(t *testing.T) {
+	assert.Equal(t, "firstName", snakeToCamel("first_name"))
+	assert.Equal(t, "id", snakeToCamel("id"))
+	assert.Equal(t, "latencyThresholdMs", snakeToCamel("latency_threshold_ms"))
+}
+
+// TestRenderJSON tests that records are rendered with the configured casing
+func TestRenderJSON(t *testing.T) {
+	previous := appConfig.Server.JSONCasing
+	defer func() { appConfig.Server.JSONCasing = previous }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/record", func(c *gin.Context) {
+		renderJSON(c, 200, []UserDatas{{ID: 9007199254740993, FirstName: "John", IsActive: true}})
+	})
+
+	appConfig.Server.JSONCasing = casingSnake
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/record", nil)
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"first_name":"John"`)
+
+	appConfig.Server.JSONCasing = casingCamel
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"firstName":"John"`)
+	assert.Contains(t, w.Body.String(), `"isActive":true`)
+	assert.Contains(t, w.Body.String(), `"id":9007199254740993`)
+}
+
+// TestRenderJSONKeepsDataKeys tests that camel casing renames field names but not the keys of data maps
+func TestRenderJSONKeepsDataKeys(t *testing.T) {
+	previous := appConfig.Server.JSONCasing
+	defer func() { appConfig.Server.JSONCasing = previous }()
+	appConfig.Server.JSONCasing = casingCamel
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/import", func(c *gin.Context) {
+		job := &ImportJob{Report: `{"rows_inserted":2,"coercions":{"is_active":1},"overflow_truncated":{"first_name":3}}`}
+		respond(c, 200, gin.H{
+			"client_cert_roles": map[string]string{"CN=billing_sync,O=Acme": roleUploader},
+			"tags":              []string{"needs_review"},
+		}, gin.H{"report": jobReport(job), "group_by": "department"})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/import", nil)
+	r.ServeHTTP(w, req)
+	body := w.Body.String()
+	assert.Contains(t, body, `"clientCertRoles":{"CN=billing_sync,O=Acme":"uploader"}`)
+	assert.Contains(t, body, `"tags":["needs_review"]`)
+	assert.Contains(t, body, `"groupBy":"department"`)
+	assert.Contains(t, body, `"requestId":`)
+	assert.Contains(t, body, `"rowsInserted":2`)
+	assert.Contains(t, body, `"coercions":{"is_active":1}`)
+	assert.Contains(t, body, `"overflowTruncated":{"first_name":3}`)
+}
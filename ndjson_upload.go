@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readNDJSONChunk reads newline-delimited JSON objects, one record each, in chunks and sends them to a
+// channel like readCSVChunk. Object keys are matched to the columns like header names, so each object
+// may hold its fields in any order and leave some out; keys that match no column are reported in stats.
+// The line of a record is its position in the file, which is its file line unless there are blank lines.
+func readNDJSONChunk(file io.Reader, mapping columnMapping, evolved []string, chunkSize int, ch chan<- csvChunk, stats *readerStats) {
+	defer close(ch)
+
+	reader := bufio.NewReader(file)
+	if head, _ := reader.Peek(len(utf8BOM)); bytes.Equal(head, utf8BOM) {
+		reader.Discard(len(utf8BOM))
+	}
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber() // Keep numbers as written, e.g. salaries with their decimals
+
+	positions := ndjsonColumnPositions(mapping, evolved)
+	skipped := map[string]bool{}
+	defer func() {
+		unmapped := make([]string, 0, len(skipped))
+		for key := range skipped {
+			unmapped = append(unmapped, key)
+		}
+		sort.Strings(unmapped)
+		stats.skipColumns(unmapped)
+	}()
+
+	chunk := csvChunk{records: make([][]string, 0, chunkSize), lines: make([]int, 0, chunkSize)}
+	for line := 1; ; line++ {
+		var object map[string]interface{}
+		err := decoder.Decode(&object)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil && object == nil {
+			err = errors.New("null is not a record")
+		}
+		if err != nil {
+			err = fmt.Errorf("invalid JSON record %d: %w", line, err)
+			stats.logger().WithError(err).Error("Error reading NDJSON file")
+			stats.fail(err)
+			return
+		}
+
+		record := make([]string, len(csvColumns)+len(evolved))
+		for key, value := range object {
+			position, ok := positions[normalizeHeader(key)]
+			if !ok {
+				skipped[key] = true
+				continue
+			}
+			record[position] = ndjsonValue(value)
+		}
+
+		chunk.records = append(chunk.records, record)
+		chunk.lines = append(chunk.lines, line)
+		if len(chunk.records) == chunkSize {
+			sendChunk(ch, chunk, stats)
+			chunk = csvChunk{records: make([][]string, 0, chunkSize), lines: make([]int, 0, chunkSize)}
+		}
+	}
+	if len(chunk.records) > 0 {
+		sendChunk(ch, chunk, stats) // Send the last chunk
+	}
+}
+
+// ndjsonColumnPositions returns the position in a record of each normalized key: the csvColumns, under
+// their mapped names when the mapping has one, followed by the added columns of schema evolution
+func ndjsonColumnPositions(mapping columnMapping, evolved []string) map[string]int {
+	positions := make(map[string]int, len(csvColumns)+len(evolved))
+	for name, position := range csvColumns {
+		if mapped, ok := mapping[name]; ok {
+			name = mapped
+		}
+		positions[normalizeHeader(name)] = position
+	}
+	for i, column := range evolved {
+		positions[normalizeHeader(column)] = len(csvColumns) + i
+	}
+	return positions
+}
+
+// ndjsonValue converts a JSON value to the text a CSV file would hold; null becomes an empty value, and
+// arrays and objects keep their JSON text, which the validation of their column rejects
+func ndjsonValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		text, _ := json.Marshal(v)
+		return string(text)
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// checkNDJSONBody tells whether the body, once decompressed, starts with the '{' of a JSON object,
+// without consuming it, describing what it starts with otherwise
+func checkNDJSONBody(body *bufio.Reader) error {
+	head, _ := body.Peek(formatSniffLength)
+	if bytes.HasPrefix(head, gzipMagic) {
+		gz, err := gzip.NewReader(bytes.NewReader(head))
+		if err != nil {
+			return fmt.Errorf("failed to decompress gzip body: %w", err)
+		}
+		head, _ = io.ReadAll(gz) // Ends with an unexpected EOF where the peeked bytes are cut off
+	}
+
+	text := bytes.TrimLeft(bytes.TrimPrefix(head, utf8BOM), " \t\r\n")
+	switch {
+	case len(text) == 0:
+		return errors.New("the body is empty")
+	case text[0] == '[':
+		return errors.New("the body is a JSON array, send one object per line instead")
+	case text[0] != '{':
+		return fmt.Errorf("the body starts with %q instead of a JSON object", text[:min(len(text), 20)])
+	}
+	return nil
+}
+
+// POST handler for newline-delimited JSON uploads, queueing an asynchronous import job like uploadCSV.
+// The body holds one JSON object per line, optionally gzip-compressed.
+func uploadJSON(c *gin.Context, dbHandler DBHandler, imports *importManager) {
+	// Reject uploads that cannot fit before reading the body
+	if !preflightUpload(c, tableSizer) {
+		return
+	}
+
+	options, ok := parseImportOptions(c)
+	if !ok {
+		return
+	}
+
+	// column_mapping names object keys that differ from the field names, e.g. {"first_name":"givenName"}
+	columns, err := parseColumnMapping(c.Query("column_mapping"))
+	if err != nil {
+		respondError(c, 400, "Invalid column mapping", err.Error())
+		return
+	}
+	options.columns = columns
+
+	// The body is the file, so the metadata of the import is given as query parameters
+	metadata, err := parseImportMetadata(c.QueryArray)
+	if err != nil {
+		respondError(c, 400, "Invalid import metadata", err.Error())
+		return
+	}
+
+	sinkName, sink, ok := uploadSink(c, dbHandler, imports)
+	if !ok {
+		return
+	}
+
+	// The body is always read as NDJSON, so anything else is rejected before it is stored
+	buffered := bufio.NewReader(c.Request.Body)
+	if err := checkNDJSONBody(buffered); err != nil {
+		respondError(c, http.StatusUnsupportedMediaType, "NDJSON required", err.Error())
+		return
+	}
+	options.format = fileFormatNDJSON
+
+	// Copy the body to the blob store while it is received, so the import can read it after the request
+	body := &countingReader{r: buffered}
+	key, err := imports.storeUpload(c.Request.Context(), body, c.Request.ContentLength)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondError(c, 413, "Upload too large", fmt.Sprintf("uploads are limited to %d bytes", tooLarge.Limit))
+		return
+	}
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to store upload")
+		respondError(c, 500, "Failed to store upload", err.Error())
+		return
+	}
+
+	// Queue the import and return immediately; progress is reported by GET /api/imports/:id
+	job := &ImportJob{FileName: c.DefaultQuery("file_name", "upload.ndjson"), FileSize: body.n, Source: "upload", Sink: sinkName}
+	metadata.apply(job)
+	submitImport(c, imports, importTask{
+		job:     job,
+		source:  &blobSource{blobs: imports.blobs, key: key, remove: true},
+		sink:    sink,
+		options: options,
+	})
+}
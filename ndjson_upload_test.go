@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReadNDJSONChunk tests reading objects into records in the order of csvColumns, whatever the order
+// and names of their keys
+func TestReadNDJSONChunk(t *testing.T) {
+	input := "\ufeff" + `{"email": "jane@example.com", "First Name": "Jane", "age": 30, "salary": 50000.50, "is_active": true, "badge": 7}` + "\n" +
+		"\n" +
+		`{"email": "jim@example.com", "givenName": "Jim", "age": null, "department": {"name": "HR"}, "nickname": "J"}` + "\n" +
+		`{"email": "joe@example.com", "givenName": "Joe", "cost_center": "CC-1"}`
+
+	ch := make(chan csvChunk, 4)
+	stats := &readerStats{}
+	readNDJSONChunk(strings.NewReader(input), columnMapping{"first_name": "givenName"}, []string{"cost_center"}, 2, ch, stats)
+	require.NoError(t, stats.Err())
+
+	var chunks []csvChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, 2)
+	assert.Equal(t, []string{"", "", "", "jane@example.com", "30", "", "", "", "50000.50", "", "true", ""}, chunks[0].records[0])
+	assert.Equal(t, []string{"", "Jim", "", "jim@example.com", "", "", `{"name":"HR"}`, "", "", "", "", ""}, chunks[0].records[1])
+	assert.Equal(t, []int{1, 2}, chunks[0].lines, "lines count records, not blank lines")
+	assert.Equal(t, "CC-1", chunks[1].records[0][len(csvColumns)])
+	assert.Equal(t, []string{"First Name", "badge", "nickname"}, stats.unmappedColumns(), "mapped names replace the field names")
+
+	// Malformed records stop the import
+	ch = make(chan csvChunk, 4)
+	stats = &readerStats{}
+	readNDJSONChunk(strings.NewReader(`{"email": "jane@example.com"}`+"\n"+`{"email": `+"\n"), nil, nil, 10, ch, stats)
+	assert.ErrorContains(t, stats.Err(), "invalid JSON record 2")
+	<-ch
+	_, open := <-ch
+	assert.False(t, open)
+
+	stats = &readerStats{}
+	readNDJSONChunk(strings.NewReader(`["jane@example.com"]`), nil, nil, 10, make(chan csvChunk, 1), stats)
+	assert.ErrorContains(t, stats.Err(), "invalid JSON record 1")
+}
+
+// TestUploadJSON tests that NDJSON uploads are imported through the same pipeline as CSV files
+func TestUploadJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDBHandler := NewMockDBHandler(ctrl)
+	expectTransactions(mockDBHandler)
+	mockDBHandler.EXPECT().CreateInBatches(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	store, final := newRecordingJobStore(ctrl)
+	blobs, _ := newLocalBlobStore(t.TempDir())
+	imports := newImportManager(store, blobs)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/upload-json", func(c *gin.Context) {
+		uploadJSON(c, mockDBHandler, imports)
+	})
+
+	body := `{"first_name": "John", "last_name": "Doe", "email": "johndoe@example.com", "age": 30, "salary": 50000, "date_joined": "2020-01-01", "is_active": true}` + "\n" +
+		`{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "age": 28, "salary": 45000}` + "\n" +
+		`{"first_name": "Jim", "last_name": "Doe", "email": "jim@example.com", "age": "old", "salary": 40000}` + "\n"
+	req := httptest.NewRequest(http.MethodPost, "/upload-json?file_name=hr.ndjson&source_system=workday&tags=hr,monthly", strings.NewReader(body))
+	req.Header.Set("Content-Type", ndjsonContentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"file_name":"hr.ndjson"`)
+
+	imports.Wait()
+	job := final()
+	assert.Equal(t, importDone, job.State)
+	assert.Equal(t, int64(len(body)), job.FileSize)
+	assert.Equal(t, int64(2), job.RowsProcessed)
+	assert.Equal(t, int64(1), job.RowsSkipped)
+	assert.Contains(t, job.Report, `"file_format":"ndjson"`)
+	assert.Contains(t, job.Report, `"line":3,"column":"age","reason":"invalid age \"old\""`)
+	assert.Equal(t, "workday", job.SourceSystem)
+	assert.Equal(t, importTags{"hr", "monthly"}, job.Tags)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload-json?column_mapping=nope", strings.NewReader(body)))
+	assert.Equal(t, 400, w.Code)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload-json?business_date=yesterday", strings.NewReader(body)))
+	assert.Equal(t, 400, w.Code)
+
+	// Bodies that aren't NDJSON are rejected rather than read as CSV
+	for content, details := range map[string]string{
+		`[{"first_name": "John", "email": "john@example.com"}]`: "JSON array",
+		"first_name,email\nJohn,john@example.com\n":             "instead of a JSON object",
+		" \n": "empty",
+	} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload-json", strings.NewReader(content)))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, content)
+		assert.Contains(t, w.Body.String(), "NDJSON required")
+		assert.Contains(t, w.Body.String(), details)
+	}
+}
+
+// TestCheckNDJSONBody tests that compressed bodies are checked by their decompressed content
+func TestCheckNDJSONBody(t *testing.T) {
+	compressed := func(text string) *bufio.Reader {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(text))
+		gz.Close()
+		return bufio.NewReader(&buf)
+	}
+	assert.NoError(t, checkNDJSONBody(compressed("\ufeff{\"first_name\": \"Jane\"}\n")))
+	assert.ErrorContains(t, checkNDJSONBody(compressed(`[{"first_name": "Jane"}]`)), "JSON array")
+
+	// The checked bytes are still read by the import
+	body := bufio.NewReader(strings.NewReader(`{"first_name": "Jane"}`))
+	assert.NoError(t, checkNDJSONBody(body))
+	rest, _ := io.ReadAll(body)
+	assert.Equal(t, `{"first_name": "Jane"}`, string(rest))
+}
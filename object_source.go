@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// objectSource streams the CSV of an import from an object in an S3 or GCS bucket, for files too large to
+// send through an upload. Unlike the blob source, it reads any bucket the source is configured for with
+// its own credentials, not the artifact storage.
+type objectSource struct {
+	client  *minio.Client
+	backend string
+	bucket  string
+	key     string
+}
+
+// objectSourceFactory returns the factory of the s3 or gcs source, reading its settings from the
+// configuration when an import is created
+func objectSourceFactory(backend string) SourceFactory {
+	return func(deps connectorDeps, params map[string]string) (Source, error) {
+		return newObjectSource(backend, appConfig.Sources.objectSource(backend), params)
+	}
+}
+
+// newObjectSource creates a source for the "bucket" and "key" parameters. The bucket must be one of the
+// configured buckets of the backend, so imports can't read whatever else its credentials may reach.
+func newObjectSource(backend string, config ObjectSourceConfig, params map[string]string) (Source, error) {
+	bucket, key := params["bucket"], params["key"]
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("%s source requires a bucket and a key", backend)
+	}
+	if len(config.Buckets) == 0 {
+		return nil, fmt.Errorf("%s source has no buckets configured", backend)
+	}
+	if !slices.Contains(config.Buckets, bucket) {
+		return nil, fmt.Errorf("bucket %q is not allowed for the %s source, expected one of %v", bucket, backend, config.Buckets)
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultStorageEndpoints[backend]
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: !config.Insecure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", backend, err)
+	}
+	return &objectSource{client: client, backend: backend, bucket: bucket, key: key}, nil
+}
+
+// Open checks that the object exists and returns it; the object is downloaded as the import reads it
+func (s *objectSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchKey", "NoSuchBucket":
+			return nil, fmt.Errorf("%w: %s", errBlobNotFound, s.name())
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", s.name(), err)
+	}
+	return object, nil
+}
+
+// name returns the URL of the object, e.g. s3://exports/users.csv
+func (s *objectSource) name() string {
+	return s.backend + "://" + s.bucket + "/" + s.key
+}
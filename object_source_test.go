@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObjectStore serves the objects of one bucket over the path-style S3 API, enough for GetObject
+func fakeObjectStore(t *testing.T, bucket string, objects map[string]string) ObjectSourceConfig {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := objects[strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")]
+		if !ok || !strings.HasPrefix(r.URL.Path, "/"+bucket+"/") {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(404)
+			if r.Method != http.MethodHead {
+				io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method != http.MethodHead {
+			io.WriteString(w, data)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return ObjectSourceConfig{
+		Buckets:   []string{bucket},
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		AccessKey: "key",
+		SecretKey: "secret",
+		Insecure:  true,
+	}
+}
+
+// TestObjectSource tests streaming objects from the configured buckets only
+func TestObjectSource(t *testing.T) {
+	config := fakeObjectStore(t, "exports", map[string]string{"2024/users.csv": "ID\n1\n"})
+
+	source, err := newObjectSource(storageS3, config, map[string]string{"bucket": "exports", "key": "2024/users.csv"})
+	require.NoError(t, err)
+	body, err := source.Open(context.Background())
+	require.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	body.Close()
+	assert.Equal(t, "ID\n1\n", string(data))
+
+	source, _ = newObjectSource(storageS3, config, map[string]string{"bucket": "exports", "key": "missing.csv"})
+	_, err = source.Open(context.Background())
+	assert.ErrorIs(t, err, errBlobNotFound)
+	assert.ErrorContains(t, err, "s3://exports/missing.csv")
+
+	_, err = newObjectSource(storageS3, config, map[string]string{"bucket": "payroll", "key": "users.csv"})
+	assert.ErrorContains(t, err, `bucket "payroll" is not allowed`)
+	_, err = newObjectSource(storageS3, config, map[string]string{"bucket": "exports"})
+	assert.ErrorContains(t, err, "requires a bucket and a key")
+	_, err = newObjectSource(storageGCS, ObjectSourceConfig{}, map[string]string{"bucket": "exports", "key": "users.csv"})
+	assert.ErrorContains(t, err, "gcs source has no buckets configured")
+
+	source, err = newObjectSource(storageGCS, ObjectSourceConfig{Buckets: []string{"exports"}}, map[string]string{"bucket": "exports", "key": "users.csv"})
+	require.NoError(t, err)
+	assert.Equal(t, "storage.googleapis.com", source.(*objectSource).client.EndpointURL().Host)
+}
+
+// TestCreateObjectImport tests queueing an import streamed from an S3 object
+func TestCreateObjectImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sink := &memorySink{}
+	registerSink("memory", func(deps connectorDeps, params map[string]string) (Sink, error) { return sink, nil })
+	defer func() {
+		connectorsMu.Lock()
+		delete(sinkFactories, "memory")
+		connectorsMu.Unlock()
+	}()
+
+	csvData := "ID,FirstName,LastName,Email,Age,Gender,Department,Company,Salary,DateJoined,IsActive\n" +
+		"1,John,Doe,john@example.com,30,Male,IT,ExampleCorp,50000,2020-01-01,true\n"
+	previous := appConfig.Sources
+	defer func() { appConfig.Sources = previous }()
+	appConfig.Sources.S3 = fakeObjectStore(t, "exports", map[string]string{"users.csv": csvData})
+
+	store, final := newRecordingJobStore(ctrl)
+	imports := newImportManager(store, nil)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/imports", func(c *gin.Context) {
+		createImport(c, NewMockDBHandler(ctrl), imports)
+	})
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/imports", strings.NewReader(body))
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, 202, post(`{"source": "s3", "source_params": {"bucket": "exports", "key": "users.csv"}, "sink": "memory"}`))
+	imports.Wait()
+	assert.Equal(t, importDone, final().State)
+	assert.Equal(t, "s3://exports/users.csv", final().FileName)
+	require.Len(t, sink.users, 1)
+	assert.Equal(t, "John", sink.users[0].FirstName)
+
+	assert.Equal(t, 400, post(`{"source": "s3", "source_params": {"bucket": "payroll", "key": "users.csv"}, "sink": "memory"}`))
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swaggo/swag"
+)
+
+// openAPIVersion is the version of the API described by the spec
+const openAPIVersion = "1.0.0"
+
+// registerSwaggerOnce registers the spec with swag, which refuses a second registration of the same name
+var registerSwaggerOnce sync.Once
+
+// openAPIDoc serves the hand-built spec to the Swagger UI of /swagger/*
+type openAPIDoc struct{}
+
+// ReadDoc returns the spec as JSON
+func (openAPIDoc) ReadDoc() string {
+	doc, err := json.Marshal(openAPISpec())
+	if err != nil {
+		log.WithError(err).Error("Failed to encode the OpenAPI spec")
+		return "{}"
+	}
+	return string(doc)
+}
+
+// registerSwagger makes the spec available to the Swagger UI
+func registerSwagger() {
+	registerSwaggerOnce.Do(func() {
+		swag.Register(swag.Name, openAPIDoc{})
+	})
+}
+
+// schemaRef refers to a schema of the spec's components
+func schemaRef(name string) gin.H {
+	return gin.H{"$ref": "#/components/schemas/" + name}
+}
+
+// queryParam describes an optional query parameter
+func queryParam(name, typ, description string) gin.H {
+	return gin.H{"name": name, "in": "query", "description": description, "schema": gin.H{"type": typ}}
+}
+
+// idParam describes the :id path parameter
+func idParam(description string) gin.H {
+	return gin.H{"name": "id", "in": "path", "required": true, "description": description, "schema": gin.H{"type": "integer"}}
+}
+
+// savedFilterNameParam describes the :name path parameter of the saved filters
+func savedFilterNameParam() gin.H {
+	return gin.H{"name": "name", "in": "path", "required": true, "description": "Name of the filter, e.g. my_team", "schema": gin.H{"type": "string"}}
+}
+
+// includeParam describes the include query parameter of the record endpoints
+func includeParam() gin.H {
+	return queryParam("include", "string", "provenance adds the import, file and line each record was written from")
+}
+
+// jsonBody describes a required JSON request body of the given schema
+func jsonBody(schema gin.H) gin.H {
+	return gin.H{"required": true, "content": gin.H{"application/json": gin.H{"schema": schema}}}
+}
+
+// envelopeResponse describes a response wrapping data of the given schema in the envelope
+func envelopeResponse(description string, data gin.H) gin.H {
+	return gin.H{"description": description, "content": gin.H{"application/json": gin.H{"schema": gin.H{
+		"allOf": []gin.H{schemaRef("Envelope"), {"properties": gin.H{"data": data}}},
+	}}}}
+}
+
+// errorResponse describes an error response
+func errorResponse(description string) gin.H {
+	return gin.H{"description": description, "content": gin.H{"application/json": gin.H{"schema": schemaRef("Envelope")}}}
+}
+
+// recordFilterParameters describes the filter query parameters of /api/records, read from recordFilterParams
+func recordFilterParameters() []gin.H {
+	names := make([]string, 0, len(recordFilterParams))
+	for name := range recordFilterParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]gin.H, len(names))
+	for i, name := range names {
+		switch {
+		case strings.HasPrefix(name, "min_") || strings.HasPrefix(name, "max_"):
+			params[i] = queryParam(name, "number", "Records with at least or at most this value")
+		case name == "is_active":
+			params[i] = queryParam(name, "boolean", "Active or inactive records")
+		case name == "tag":
+			params[i] = queryParam(name, "string", "Records written by imports with this tag")
+		case strings.HasPrefix(name, "joined_"):
+			params[i] = queryParam(name, "string", "Records joined on or after, or on or before, this YYYY-MM-DD date")
+		default:
+			params[i] = queryParam(name, "string", "Records with exactly this value")
+		}
+	}
+	return params
+}
+
+// importParameters are the query parameters shared by the import endpoints
+func importParameters() []gin.H {
+	return []gin.H{
+		queryParam("preserve_order", "boolean", "Insert chunks one at a time so IDs follow the file order"),
+		queryParam("dedup_key", "string", "Column or comma-separated columns identifying repeated rows to drop"),
+		queryParam("overflow", "string", "reject (default) or truncate values longer than their column"),
+		queryParam("wait", "boolean", "Respond once the import is finished; disconnecting cancels it"),
+		queryParam("return_ids", "boolean", "Report the IDs of the written rows by file line"),
+		queryParam("sink", "string", "Registered sink to write to, postgres by default"),
+		queryParam("mode", "string", "insert (default) or upsert on email"),
+		queryParam("validation", "string", "lenient skips rows failing validation, strict rejects the file; the configured mode by default"),
+		queryParam("date_formats", "string", "Comma-separated date formats tried in order, yyyy-mm-dd,dd/mm/yyyy,mm-dd-yyyy by default"),
+	}
+}
+
+// openAPISpec builds the OpenAPI 3 description of the main routes
+func openAPISpec() gin.H {
+	recordList := gin.H{"type": "array", "items": schemaRef("Record")}
+	recordResponses := gin.H{
+		"200": envelopeResponse("The record", schemaRef("Record")),
+		"400": errorResponse("Invalid id or body"),
+		"404": errorResponse("No record with this id"),
+	}
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":       "User data ingestion API",
+			"version":     openAPIVersion,
+			"description": "Imports CSV, XLSX and NDJSON files into PostgreSQL and serves the records. Responses are wrapped in an envelope with data, meta and errors.",
+		},
+		"paths": gin.H{
+			"/upload-csv": gin.H{"post": gin.H{
+				"summary":    "Upload a CSV or XLSX file and queue its import",
+				"parameters": importParameters(),
+				"requestBody": gin.H{"required": true, "content": gin.H{"multipart/form-data": gin.H{"schema": gin.H{
+					"type":     "object",
+					"required": []string{"file"},
+					"properties": gin.H{
+						"file":           gin.H{"type": "string", "format": "binary", "description": "CSV or XLSX file, optionally gzip-compressed"},
+						"sheet":          gin.H{"type": "string", "description": "Workbook sheet to import, the first one by default"},
+						"column_mapping": gin.H{"type": "string", "description": `JSON object mapping fields to header names, e.g. {"first_name": "Given Name"}`},
+						"date_formats":   gin.H{"type": "string", "description": "Comma-separated date formats tried in order, like the date_formats of imports"},
+						"description":    gin.H{"type": "string", "maxLength": importDescriptionLength, "description": "What the file contains, shown in the import history"},
+						"source_system":  gin.H{"type": "string", "maxLength": importSourceSystemLength, "description": "System the file was exported from, e.g. sap-hr"},
+						"business_date":  gin.H{"type": "string", "format": "date", "description": "Date the data is as of"},
+						"tags":           gin.H{"type": "string", "description": "Comma-separated tags, e.g. audit,q3-2024"},
+					},
+				}}}},
+				"responses": gin.H{
+					"200": envelopeResponse("The finished import, with wait=true", schemaRef("ImportJob")),
+					"202": envelopeResponse("The queued import", schemaRef("ImportJob")),
+					"400": errorResponse("Invalid file or parameters"),
+					"413": errorResponse("The upload is too large"),
+					"507": errorResponse("Not enough disk or database space for the upload"),
+					"503": errorResponse("The import queue is full"),
+				},
+			}},
+			"/upload-json": gin.H{"post": gin.H{
+				"summary": "Upload newline-delimited JSON records and queue their import",
+				"parameters": append(importParameters(),
+					queryParam("column_mapping", "string", `JSON object mapping fields to object keys, e.g. {"first_name": "givenName"}`),
+					queryParam("file_name", "string", "Name the import and the provenance of its records refer to, upload.ndjson by default"),
+					queryParam("description", "string", "What the records contain, shown in the import history"),
+					queryParam("source_system", "string", "System the records were exported from, e.g. sap-hr"),
+					queryParam("business_date", "string", "YYYY-MM-DD date the data is as of"),
+					queryParam("tags", "string", "Comma-separated tags, e.g. audit,q3-2024"),
+				),
+				"requestBody": gin.H{"required": true, "content": gin.H{"application/x-ndjson": gin.H{"schema": gin.H{
+					"type":        "string",
+					"description": "One JSON object per line with the record fields, optionally gzip-compressed",
+				}}}},
+				"responses": gin.H{
+					"200": envelopeResponse("The finished import, with wait=true", schemaRef("ImportJob")),
+					"202": envelopeResponse("The queued import", schemaRef("ImportJob")),
+					"400": errorResponse("Invalid parameters"),
+					"413": errorResponse("The upload is too large"),
+					"507": errorResponse("Not enough disk or database space for the upload"),
+					"503": errorResponse("The import queue is full"),
+				},
+			}},
+			"/api/imports": gin.H{"get": gin.H{
+				"summary": "Search the history of imports, newest first",
+				"parameters": []gin.H{
+					queryParam("q", "string", "Words to find in the description, file name or source system"),
+					queryParam("source_system", "string", "Imports from exactly this source system"),
+					queryParam("business_date", "string", "Imports of data as of this YYYY-MM-DD date"),
+					queryParam("tag", "string", "Imports with this tag"),
+					queryParam("state", "string", "Imports in this state"),
+					queryParam("page", "integer", "Page number, 1 by default"),
+					queryParam("size", "integer", "Imports per page, 20 by default and at most 100"),
+				},
+				"responses": gin.H{
+					"200": envelopeResponse("A page of import jobs", gin.H{"type": "array", "items": schemaRef("ImportJob")}),
+					"400": errorResponse("Invalid filter or paging parameters"),
+				},
+			}},
+			"/api/imports/{id}": gin.H{"get": gin.H{
+				"summary":    "Get the state and report of an import",
+				"parameters": []gin.H{idParam("Import job ID")},
+				"responses": gin.H{
+					"200": envelopeResponse("The import job; meta.report holds the ingestion metrics", schemaRef("ImportJob")),
+					"404": errorResponse("No import with this id"),
+				},
+			}},
+			"/api/imports/{id}/errors": gin.H{"get": gin.H{
+				"summary":    "Download the rejected rows of an import as CSV",
+				"parameters": []gin.H{idParam("Import job ID")},
+				"responses": gin.H{
+					"200": gin.H{"description": "The rejected rows", "content": gin.H{"text/csv": gin.H{"schema": gin.H{"type": "string"}}}},
+					"404": errorResponse("No import with this id"),
+				},
+			}},
+			"/api/imports/{id}/logs": gin.H{"get": gin.H{
+				"summary":    "Get the log entries of an import",
+				"parameters": []gin.H{idParam("Import job ID"), queryParam("limit", "integer", "Most entries to return, 1000 by default")},
+				"responses": gin.H{
+					"200": envelopeResponse("The log entries, oldest first", gin.H{"type": "array", "items": gin.H{"type": "object"}}),
+					"404": errorResponse("No import with this id"),
+				},
+			}},
+			"/api/upload-streams": gin.H{"post": gin.H{
+				"summary": "Open a streaming upload, whose rows are sent in batches",
+				"requestBody": jsonBody(gin.H{"type": "object", "properties": gin.H{
+					"name":         gin.H{"type": "string", "description": "Recorded as the source file of the rows"},
+					"date_formats": gin.H{"type": "string", "description": "Formats of the date_joined values, e.g. dd/mm/yyyy,yyyy-mm-dd"},
+				}}),
+				"responses": gin.H{
+					"201": envelopeResponse("The stream, with a watermark of 0", gin.H{"type": "object"}),
+					"400": errorResponse("Invalid name or date_formats"),
+				},
+			}},
+			"/api/upload-streams/{id}": gin.H{"get": gin.H{
+				"summary":    "Get the watermark and row counts of a streaming upload",
+				"parameters": []gin.H{idParam("Upload stream ID")},
+				"responses": gin.H{
+					"200": envelopeResponse("The stream; watermark is the number of rows committed", gin.H{"type": "object"}),
+					"404": errorResponse("No upload stream with this id"),
+				},
+			}},
+			"/api/upload-streams/{id}/batches": gin.H{"post": gin.H{
+				"summary":     "Send batches of rows, one JSON object {\"offset\": 0, \"rows\": [...]} per line, and receive an acknowledgement per committed batch",
+				"parameters":  []gin.H{idParam("Upload stream ID")},
+				"requestBody": gin.H{"required": true, "content": gin.H{"application/x-ndjson": gin.H{"schema": gin.H{"type": "string"}}}},
+				"responses": gin.H{
+					"200": gin.H{"description": "An envelope per line: the acknowledgement of each batch with the watermark, or an error with the watermark to resume from", "content": gin.H{"application/x-ndjson": gin.H{"schema": gin.H{"type": "string"}}}},
+					"404": errorResponse("No upload stream with this id"),
+					"409": errorResponse("The stream is completed"),
+				},
+			}},
+			"/api/upload-streams/{id}/complete": gin.H{"post": gin.H{
+				"summary":    "Complete a streaming upload; no more batches are accepted",
+				"parameters": []gin.H{idParam("Upload stream ID")},
+				"responses": gin.H{
+					"200": envelopeResponse("The completed stream", gin.H{"type": "object"}),
+					"404": errorResponse("No upload stream with this id"),
+				},
+			}},
+			"/api/records": gin.H{
+				"get": gin.H{
+					"summary": "List records a page at a time",
+					"parameters": append([]gin.H{
+						queryParam("page", "integer", "Page number, 1 by default"),
+						queryParam("size", "integer", "Records per page, 10 by default"),
+						queryParam("cursor", "integer", "ID of the last record of the previous page for keyset pagination, 0 for the first page; replaces page"),
+						queryParam("sort", "string", "Fields to order by, e.g. salary:desc,last_name:asc"),
+						queryParam("filter", "string", "Name of a saved filter whose parameters apply unless given in the request"),
+						includeParam(),
+					}, recordFilterParameters()...),
+					"responses": gin.H{
+						"200": envelopeResponse("The page; meta holds total, total_pages and the next and prev links, or next_cursor and next with cursor", recordList),
+						"400": errorResponse("Invalid page, filter or sort"),
+						"404": errorResponse("No filter is saved under this name"),
+					},
+				},
+				"post": gin.H{
+					"summary":     "Create a record",
+					"requestBody": jsonBody(schemaRef("Record")),
+					"responses": gin.H{
+						"201": envelopeResponse("The created record", schemaRef("Record")),
+						"400": errorResponse("Invalid body"),
+						"409": errorResponse("The email already exists"),
+					},
+				},
+			},
+			"/api/saved-filters": gin.H{"get": gin.H{
+				"summary": "List the saved filters of the authenticated user",
+				"responses": gin.H{
+					"200": envelopeResponse("The filters by name", gin.H{"type": "array", "items": schemaRef("SavedFilter")}),
+					"401": errorResponse("Not authenticated"),
+				},
+			}},
+			"/api/saved-filters/{name}": gin.H{
+				"get": gin.H{"summary": "Get a saved filter", "parameters": []gin.H{savedFilterNameParam()}, "responses": gin.H{
+					"200": envelopeResponse("The filter", schemaRef("SavedFilter")),
+					"404": errorResponse("No filter is saved under this name"),
+				}},
+				"put": gin.H{
+					"summary":    "Save a filter under a name, replacing the one saved under it",
+					"parameters": []gin.H{savedFilterNameParam()},
+					"requestBody": jsonBody(gin.H{"type": "object", "required": []string{"query"}, "properties": gin.H{
+						"query": gin.H{"type": "string", "description": "Filter, sort, size and include parameters of /api/records, e.g. department=IT&sort=salary:desc"},
+					}}),
+					"responses": gin.H{
+						"200": envelopeResponse("The saved filter", schemaRef("SavedFilter")),
+						"400": errorResponse("Invalid name or query"),
+					},
+				},
+				"delete": gin.H{"summary": "Delete a saved filter", "parameters": []gin.H{savedFilterNameParam()}, "responses": gin.H{
+					"204": gin.H{"description": "Deleted"},
+					"404": errorResponse("No filter is saved under this name"),
+				}},
+			},
+			"/api/records/search": gin.H{"get": gin.H{
+				"summary": "Search the records by name and email, best matches first",
+				"parameters": []gin.H{
+					queryParam("q", "string", "Words to search for in the first and last name and email; typos and partial words match"),
+					queryParam("page", "integer", "Page number, 1 by default"),
+					queryParam("size", "integer", "Results per page, 10 by default and at most 100"),
+				},
+				"responses": gin.H{
+					"200": envelopeResponse("The page of matches, each record with its score from 0 to 1; meta holds total, total_pages and the next and prev links",
+						gin.H{"type": "array", "items": gin.H{"allOf": []gin.H{schemaRef("Record"), {"type": "object", "properties": gin.H{"score": gin.H{"type": "number"}}}}}}),
+					"400": errorResponse("Missing or too long query, or invalid page or size"),
+				},
+			}},
+			"/api/records/{id}": gin.H{
+				"get":    gin.H{"summary": "Get a record", "parameters": []gin.H{idParam("Record ID"), includeParam()}, "responses": recordResponses},
+				"put":    gin.H{"summary": "Replace a record", "parameters": []gin.H{idParam("Record ID")}, "requestBody": jsonBody(schemaRef("Record")), "responses": recordResponses},
+				"patch":  gin.H{"summary": "Change fields of a record", "parameters": []gin.H{idParam("Record ID")}, "requestBody": jsonBody(gin.H{"type": "object"}), "responses": recordResponses},
+				"delete": gin.H{"summary": "Delete a record", "parameters": []gin.H{idParam("Record ID")}, "responses": gin.H{"204": gin.H{"description": "Deleted"}, "404": errorResponse("No record with this id")}},
+			},
+			"/api/compare": gin.H{"post": gin.H{
+				"summary": "Diff the records by key against an uploaded file or a snapshot",
+				"parameters": []gin.H{
+					queryParam("snapshot", "integer", "Snapshot ID to compare with instead of a file"),
+					queryParam("key", "string", "Column or comma-separated columns identifying a record, email by default"),
+					queryParam("samples", "integer", "Records of each kind of difference to return, 10 by default"),
+				},
+				"requestBody": gin.H{"content": gin.H{"multipart/form-data": gin.H{"schema": gin.H{
+					"type": "object",
+					"properties": gin.H{
+						"file":           gin.H{"type": "string", "format": "binary", "description": "CSV or XLSX file, optionally gzip-compressed"},
+						"sheet":          gin.H{"type": "string", "description": "Workbook sheet to compare, the first one by default"},
+						"column_mapping": gin.H{"type": "string", "description": `JSON object mapping fields to header names, e.g. {"first_name": "Given Name"}`},
+					},
+				}}}},
+				"responses": gin.H{
+					"200": envelopeResponse("Counts of added, removed, changed and unchanged records with samples of each", gin.H{"type": "object"}),
+					"400": errorResponse("Invalid file, key or parameters"),
+					"404": errorResponse("No snapshot with this id"),
+					"413": errorResponse("Too many rows to compare"),
+				},
+			}},
+			"/healthz": gin.H{"get": gin.H{
+				"summary":  "Check that the process is up",
+				"security": []gin.H{},
+				"responses": gin.H{
+					"200": envelopeResponse("The process is up", gin.H{"type": "object"}),
+				},
+			}},
+			"/readyz": gin.H{"get": gin.H{
+				"summary":  "Check that the database answers and is migrated, with the connection pool statistics",
+				"security": []gin.H{},
+				"responses": gin.H{
+					"200": envelopeResponse("Ready to take traffic", gin.H{"type": "object"}),
+					"503": envelopeResponse("Not ready; data.checks holds the failed check", gin.H{"type": "object"}),
+				},
+			}},
+			"/api/version": gin.H{"get": gin.H{
+				"summary": "Report the version, commit, build time, Go runtime and enabled features of the running build",
+				"responses": gin.H{
+					"200": envelopeResponse("Build information", gin.H{"type": "object"}),
+				},
+			}},
+			"/api/logs": gin.H{"get": gin.H{
+				"summary": "Count the entries of the log file by level",
+				"responses": gin.H{
+					"200": envelopeResponse("Entries per level", gin.H{"type": "object", "additionalProperties": gin.H{"type": "integer"}}),
+				},
+			}},
+		},
+		"components": gin.H{
+			"securitySchemes": gin.H{
+				"bearerAuth": gin.H{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKeyAuth": gin.H{"type": "apiKey", "in": "header", "name": apiKeyHeader},
+			},
+			"schemas": gin.H{
+				"Envelope": gin.H{"type": "object", "properties": gin.H{
+					"data":   gin.H{},
+					"meta":   gin.H{"type": "object", "properties": gin.H{"request_id": gin.H{"type": "string"}}},
+					"errors": gin.H{"type": "array", "items": schemaRef("Error")},
+				}},
+				"Error": gin.H{"type": "object", "properties": gin.H{
+					"message": gin.H{"type": "string"},
+					"field":   gin.H{"type": "string", "description": "Invalid field of a validation error"},
+					"details": gin.H{"type": "string"},
+				}},
+				"Record": gin.H{"type": "object", "required": []string{"first_name", "email"}, "properties": gin.H{
+					"id":          gin.H{"type": "integer", "readOnly": true},
+					"first_name":  gin.H{"type": "string", "maxLength": 100},
+					"last_name":   gin.H{"type": "string", "maxLength": 100},
+					"email":       gin.H{"type": "string", "format": "email", "maxLength": 150},
+					"age":         gin.H{"type": "integer", "minimum": 0, "maximum": 150},
+					"gender":      gin.H{"type": "string", "maxLength": 10},
+					"department":  gin.H{"type": "string", "maxLength": 100},
+					"company":     gin.H{"type": "string", "maxLength": 100},
+					"salary":      gin.H{"type": "number", "minimum": 0},
+					"date_joined": gin.H{"type": "string", "format": "date", "nullable": true},
+					"is_active":   gin.H{"type": "boolean"},
+				}},
+				"ImportJob": gin.H{"type": "object", "properties": gin.H{
+					"id":             gin.H{"type": "integer"},
+					"file_name":      gin.H{"type": "string"},
+					"file_size":      gin.H{"type": "integer"},
+					"source":         gin.H{"type": "string"},
+					"sink":           gin.H{"type": "string"},
+					"state":          gin.H{"type": "string", "enum": []string{importQueued, importRunning, importDone, importFailed, importCancelled}},
+					"rows_processed": gin.H{"type": "integer"},
+					"rows_skipped":   gin.H{"type": "integer"},
+					"duration_ms":    gin.H{"type": "integer"},
+					"error":          gin.H{"type": "string"},
+					"created_at":     gin.H{"type": "string", "format": "date-time"},
+					"started_at":     gin.H{"type": "string", "format": "date-time", "nullable": true},
+					"finished_at":    gin.H{"type": "string", "format": "date-time", "nullable": true},
+					"worker":         gin.H{"type": "string"},
+					"heartbeat_at":   gin.H{"type": "string", "format": "date-time", "nullable": true},
+					"request_id":     gin.H{"type": "string"},
+					"description":    gin.H{"type": "string"},
+					"source_system":  gin.H{"type": "string"},
+					"business_date":  gin.H{"type": "string", "format": "date", "nullable": true},
+					"tags":           gin.H{"type": "array", "items": gin.H{"type": "string"}},
+				}},
+				"SavedFilter": gin.H{"type": "object", "properties": gin.H{
+					"id":         gin.H{"type": "integer"},
+					"name":       gin.H{"type": "string", "maxLength": 100},
+					"query":      gin.H{"type": "string", "description": "URL-encoded query parameters of /api/records"},
+					"created_at": gin.H{"type": "string", "format": "date-time"},
+					"updated_at": gin.H{"type": "string", "format": "date-time"},
+				}},
+			},
+		},
+		"security": []gin.H{{"bearerAuth": []string{}}, {"apiKeyAuth": []string{}}},
+	}
+}
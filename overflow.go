@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Policies for values longer than their varchar column
+const (
+	overflowReject   = "reject"   // Skip the whole row
+	overflowTruncate = "truncate" // Cut the value to the column size and keep the row
+)
+
+// csvColumnSizes is the declared size of each varchar column of UserData, in characters
+var csvColumnSizes = map[string]int{
+	"first_name": 100,
+	"last_name":  100,
+	"email":      150,
+	"gender":     10,
+	"department": 100,
+	"company":    100,
+}
+
+// overflowHandler applies the length-overflow policy to upload rows and counts what it changed,
+// so one overlong cell doesn't fail the batch insert of the whole chunk.
+type overflowHandler struct {
+	mu        sync.Mutex
+	policy    string
+	rejected  int
+	truncated map[string]int // Truncated values per column
+}
+
+// newOverflowHandler parses the overflow policy; an empty policy means reject
+func newOverflowHandler(policy string) (*overflowHandler, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "":
+		policy = overflowReject
+	case overflowReject, overflowTruncate:
+	default:
+		return nil, fmt.Errorf("unsupported overflow policy %q, expected reject or truncate", policy)
+	}
+	return &overflowHandler{policy: policy, truncated: map[string]int{}}, nil
+}
+
+// apply checks every varchar column of the record, truncating overlong values in place
+// under the truncate policy. It returns false and the first overlong column when the row must be rejected.
+func (o *overflowHandler) apply(record []string) (string, bool) {
+	var overlong []string
+	for name, size := range csvColumnSizes {
+		index := csvColumns[name]
+		if index < len(record) && utf8.RuneCountInString(record[index]) > size {
+			overlong = append(overlong, name)
+		}
+	}
+	if len(overlong) == 0 {
+		return "", true
+	}
+	sort.Slice(overlong, func(i, j int) bool { return csvColumns[overlong[i]] < csvColumns[overlong[j]] })
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.policy == overflowReject {
+		o.rejected++
+		return overlong[0], false
+	}
+	for _, name := range overlong {
+		record[csvColumns[name]] = truncateRunes(record[csvColumns[name]], csvColumnSizes[name])
+		o.truncated[name]++
+	}
+	return "", true
+}
+
+// truncateRunes cuts s to at most n characters without splitting a multi-byte character
+func truncateRunes(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+// truncations returns a copy of the truncated value counts per column
+func (o *overflowHandler) truncations() map[string]int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	truncated := make(map[string]int, len(o.truncated))
+	for name, count := range o.truncated {
+		truncated[name] = count
+	}
+	return truncated
+}
+
+// report returns the overflow counts and a warning per truncated column for the upload response
+func (o *overflowHandler) report() map[string]interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	columns := make([]string, 0, len(o.truncated))
+	truncated := make(map[string]int, len(o.truncated))
+	for name, count := range o.truncated {
+		columns = append(columns, name)
+		truncated[name] = count
+	}
+	sort.Strings(columns)
+
+	warnings := make([]string, 0, len(columns))
+	for _, name := range columns {
+		warnings = append(warnings, fmt.Sprintf("%d %s value(s) truncated to %d characters",
+			o.truncated[name], name, csvColumnSizes[name]))
+	}
+
+	return map[string]interface{}{
+		"overflow_policy":        o.policy,
+		"overflow_rows_rejected": o.rejected,
+		"overflow_truncated":     truncated,
+		"warnings":               warnings,
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/schema"
+)
+
+// TestCSVColumnSizesMatchSchema tests that the overflow sizes follow the UserData column sizes
+func TestCSVColumnSizesMatchSchema(t *testing.T) {
+	s, err := schema.Parse(&UserData{}, &sync.Map{}, schema.NamingStrategy{})
+	assert.NoError(t, err)
+
+	for _, field := range s.Fields {
+		// The embedded provenance isn't read from the file
+		if field.DataType == schema.String && field.Size > 0 && len(field.BindNames) == 1 {
+			assert.Equal(t, field.Size, csvColumnSizes[field.DBName], field.DBName)
+		}
+	}
+	assert.Len(t, csvColumnSizes, 6)
+}
+
+// TestOverflowHandler tests the reject and truncate policies
+func TestOverflowHandler(t *testing.T) {
+	row := func(first, gender string) []string {
+		return []string{"1", first, "Doe", "john@example.com", "30", gender, "IT", "Corp", "50000", "2020-01-01", "true"}
+	}
+
+	reject, err := newOverflowHandler("")
+	assert.NoError(t, err)
+	_, ok := reject.apply(row("John", "Male"))
+	assert.True(t, ok)
+	column, ok := reject.apply(row(strings.Repeat("a", 101), strings.Repeat("b", 11)))
+	assert.False(t, ok)
+	assert.Equal(t, "first_name", column)
+	assert.Equal(t, 1, reject.report()["overflow_rows_rejected"])
+
+	truncate, err := newOverflowHandler("Truncate")
+	assert.NoError(t, err)
+	record := row(strings.Repeat("é", 120), "Nonbinary person")
+	_, ok = truncate.apply(record)
+	assert.True(t, ok)
+	assert.Equal(t, strings.Repeat("é", 100), record[1])
+	assert.Equal(t, "Nonbinary ", record[5])
+
+	report := truncate.report()
+	assert.Equal(t, map[string]int{"first_name": 1, "gender": 1}, report["overflow_truncated"])
+	assert.Equal(t, []string{
+		"1 first_name value(s) truncated to 100 characters",
+		"1 gender value(s) truncated to 10 characters",
+	}, report["warnings"])
+
+	_, err = newOverflowHandler("ignore")
+	assert.Error(t, err)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// tableGrowthFactor is the estimated table bytes per uploaded CSV byte (tuples + indexes)
+const tableGrowthFactor = 2.0
+
+// Headers reporting the table size quota on upload responses
+const (
+	quotaLimitHeader     = "X-Quota-Limit"
+	quotaUsedHeader      = "X-Quota-Used"
+	quotaRemainingHeader = "X-Quota-Remaining"
+	quotaWarningHeader   = "X-Quota-Warning"
+)
+
+// uploadLimits are the limits an upload is checked against before processing
+type uploadLimits struct {
+	maxUploadBytes   int64
+	minFreeDiskBytes int64
+	maxTableBytes    int64
+	quotaWarnings    []int // Percentages of maxTableBytes at which uploads are warned
+}
+
+// configuredUploadLimits returns the preflight limits of the ingestion configuration
+func configuredUploadLimits() uploadLimits {
+	return uploadLimits{
+		maxUploadBytes:   appConfig.Ingestion.MaxUploadBytes,
+		minFreeDiskBytes: appConfig.Ingestion.MinFreeDiskBytes,
+		maxTableBytes:    appConfig.Ingestion.MaxTableBytes,
+		quotaWarnings:    appConfig.Ingestion.QuotaWarnings,
+	}
+}
+
+// estimatedTableBytes is the table size expected after importing an upload of contentLength bytes
+func estimatedTableBytes(current, contentLength int64) int64 {
+	return current + int64(float64(contentLength)*tableGrowthFactor)
+}
+
+// quotaWarning returns the highest warning threshold, in percent of the table size quota, that a table
+// of size bytes reaches, or 0 when it reaches none
+func quotaWarning(limits uploadLimits, size int64) int {
+	reached := 0
+	for _, percent := range limits.quotaWarnings {
+		if size*100 >= limits.maxTableBytes*int64(percent) && percent > reached {
+			reached = percent
+		}
+	}
+	return reached
+}
+
+// preflightError describes why an upload was rejected before processing
+type preflightError struct {
+	status  int
+	message string
+	details string
+}
+
+// checkUploadPreflight validates an upload of contentLength bytes against the limits.
+// freeDisk and tableSize are only called when the corresponding check applies.
+func checkUploadPreflight(limits uploadLimits, contentLength int64, freeDisk func() (uint64, error), tableSize func() (int64, error)) *preflightError {
+	if contentLength > limits.maxUploadBytes {
+		return &preflightError{
+			status:  http.StatusRequestEntityTooLarge,
+			message: "Upload too large",
+			details: fmt.Sprintf("upload is %d bytes, limit is %d bytes", contentLength, limits.maxUploadBytes),
+		}
+	}
+
+	// Unknown length (chunked upload): the size based checks can't be estimated
+	if contentLength < 0 {
+		return nil
+	}
+
+	free, err := freeDisk()
+	if err != nil {
+		log.WithError(err).Warn("Failed to check free disk space, skipping check")
+	} else if int64(free)-contentLength < limits.minFreeDiskBytes {
+		return &preflightError{
+			status:  http.StatusInsufficientStorage,
+			message: "Insufficient temporary disk space for upload",
+			details: fmt.Sprintf("upload needs %d bytes, %d bytes free, %d bytes reserved", contentLength, free, limits.minFreeDiskBytes),
+		}
+	}
+
+	if limits.maxTableBytes > 0 {
+		current, err := tableSize()
+		if err != nil {
+			log.WithError(err).Warn("Failed to check table size, skipping check")
+			return nil
+		}
+		estimated := estimatedTableBytes(current, contentLength)
+		if estimated > limits.maxTableBytes {
+			return &preflightError{
+				status:  http.StatusInsufficientStorage,
+				message: "Import would exceed the table size quota",
+				details: fmt.Sprintf("table is %d bytes, estimated %d bytes after import, quota is %d bytes", current, estimated, limits.maxTableBytes),
+			}
+		}
+	}
+
+	return nil
+}
+
+// tableSizer measures the table the quota applies to; nil in tests, where the quota can't be checked
+var tableSizer TableSizer
+
+// TableSizer measures the on-disk size of the user_data table
+type TableSizer interface {
+	TableSize() (int64, error)
+}
+
+// GormTableSizer is a concrete implementation of TableSizer using GORM
+type GormTableSizer struct {
+	db *gorm.DB
+}
+
+// WithContext returns a sizer whose queries use ctx and carry its query tags
+func (s *GormTableSizer) WithContext(ctx context.Context) TableSizer {
+	return &GormTableSizer{db: s.db.WithContext(ctx)}
+}
+
+// TableSize returns the on-disk size of the user_data table including indexes
+func (s *GormTableSizer) TableSize() (int64, error) {
+	var size int64
+	err := s.db.Raw("SELECT pg_total_relation_size(?)", UserData{}.TableName()).Scan(&size).Error
+	return size, err
+}
+
+// preflightUpload rejects the request with a clear error if the upload can't fit.
+// It returns false when a response has already been written.
+func preflightUpload(c *gin.Context, sizer TableSizer) bool {
+	contentLength := c.Request.ContentLength
+	freeDisk := func() (uint64, error) { return freeDiskBytes(os.TempDir()) }
+	tableBytes := int64(-1)
+	tableSize := func() (int64, error) {
+		if sizer == nil {
+			return 0, errors.New("no table sizer is configured")
+		}
+		size, err := contextStore(c.Request.Context(), sizer).TableSize()
+		if err == nil {
+			tableBytes = size
+		}
+		return size, err
+	}
+
+	limits := configuredUploadLimits()
+	perr := checkUploadPreflight(limits, contentLength, freeDisk, tableSize)
+	if tableBytes >= 0 {
+		setQuotaHeaders(c, limits, tableBytes, estimatedTableBytes(tableBytes, contentLength))
+	}
+	if perr != nil {
+		requestLogger(c).WithFields(logrus.Fields{"content_length": contentLength, "reason": perr.details}).Error(perr.message)
+		respondError(c, perr.status, perr.message, perr.details)
+		return false
+	}
+
+	// Enforce the limit for chunked uploads that don't declare a length
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.maxUploadBytes)
+	return true
+}
+
+// setQuotaHeaders reports the table size quota on an upload's response: the quota, the estimated table
+// size after the upload and what remains, and in X-Quota-Warning the highest warning threshold the
+// estimate reaches. Uploads taking the table past a threshold are logged, so they can be alerted on.
+func setQuotaHeaders(c *gin.Context, limits uploadLimits, current, estimated int64) {
+	c.Header(quotaLimitHeader, strconv.FormatInt(limits.maxTableBytes, 10))
+	c.Header(quotaUsedHeader, strconv.FormatInt(estimated, 10))
+	c.Header(quotaRemainingHeader, strconv.FormatInt(max(limits.maxTableBytes-estimated, 0), 10))
+
+	warning := quotaWarning(limits, estimated)
+	if warning == 0 {
+		return
+	}
+	c.Header(quotaWarningHeader, strconv.Itoa(warning))
+	if quotaWarning(limits, current) < warning {
+		requestLogger(c).WithFields(logrus.Fields{
+			"table_bytes":     current,
+			"estimated_bytes": estimated,
+			"quota_bytes":     limits.maxTableBytes,
+			"threshold":       warning,
+		}).Warn("Upload takes the table past a quota warning threshold")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCheckUploadPreflight tests size, disk space and table quota checks
+func TestCheckUploadPreflight(t *testing.T) {
+	limits := uploadLimits{maxUploadBytes: 1000, minFreeDiskBytes: 100, maxTableBytes: 5000}
+	freeDisk := func(free uint64) func() (uint64, error) {
+		return func() (uint64, error) { return free, nil }
+	}
+	tableSize := func(size int64) func() (int64, error) {
+		return func() (int64, error) { return size, nil }
+	}
+
+	// Within all limits
+	assert.Nil(t, checkUploadPreflight(limits, 500, freeDisk(10000), tableSize(1000)))
+
+	// Upload larger than the max upload size
+	perr := checkUploadPreflight(limits, 2000, freeDisk(10000), tableSize(0))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, perr.status)
+
+	// Not enough disk left after buffering the upload
+	perr = checkUploadPreflight(limits, 500, freeDisk(550), tableSize(0))
+	assert.Equal(t, http.StatusInsufficientStorage, perr.status)
+	assert.Contains(t, perr.message, "disk space")
+
+	// Estimated table growth exceeds the quota
+	perr = checkUploadPreflight(limits, 500, freeDisk(10000), tableSize(4500))
+	assert.Equal(t, http.StatusInsufficientStorage, perr.status)
+	assert.Contains(t, perr.message, "quota")
+
+	// Failing probes and unknown lengths don't block the upload
+	failingDisk := func() (uint64, error) { return 0, errors.New("statfs failed") }
+	assert.Nil(t, checkUploadPreflight(limits, 500, failingDisk, tableSize(0)))
+	assert.Nil(t, checkUploadPreflight(limits, -1, freeDisk(0), tableSize(0)))
+}
+
+// TestConfiguredUploadLimits tests that the preflight checks use the configured limits
+func TestConfiguredUploadLimits(t *testing.T) {
+	previous := appConfig.Ingestion
+	defer func() { appConfig.Ingestion = previous }()
+	appConfig.Ingestion.MaxUploadBytes = 1000
+	appConfig.Ingestion.MinFreeDiskBytes = 0
+	appConfig.Ingestion.MaxTableBytes = 5000
+	appConfig.Ingestion.QuotaWarnings = []int{80, 90}
+
+	limits := configuredUploadLimits()
+	assert.Equal(t, uploadLimits{maxUploadBytes: 1000, maxTableBytes: 5000, quotaWarnings: []int{80, 90}}, limits)
+	perr := checkUploadPreflight(limits, 500, func() (uint64, error) { return 10000, nil }, func() (int64, error) { return 4500, nil })
+	assert.Contains(t, perr.message, "quota")
+}
+
+// TestQuotaWarningHeaders tests that uploads report the table size quota and warn as it nears
+func TestQuotaWarningHeaders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := appConfig.Ingestion
+	defer func() { appConfig.Ingestion = previous }()
+	appConfig.Ingestion.MaxUploadBytes = 1000
+	appConfig.Ingestion.MinFreeDiskBytes = 0
+	appConfig.Ingestion.MaxTableBytes = 10000
+	appConfig.Ingestion.QuotaWarnings = []int{80, 90}
+
+	tableBytes := int64(0)
+	sizer := NewMockTableSizer(ctrl)
+	sizer.EXPECT().TableSize().DoAndReturn(func() (int64, error) { return tableBytes, nil }).AnyTimes()
+
+	gin.SetMode(gin.TestMode)
+	upload := func(size int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("POST", "/upload-csv", strings.NewReader(strings.Repeat("x", size)))
+		if preflightUpload(c, sizer) {
+			c.Status(200)
+		}
+		return w
+	}
+
+	// Far from the quota, usage is reported without a warning
+	w := upload(500)
+	assert.Equal(t, "10000", w.Header().Get(quotaLimitHeader))
+	assert.Equal(t, "1000", w.Header().Get(quotaUsedHeader))
+	assert.Equal(t, "9000", w.Header().Get(quotaRemainingHeader))
+	assert.Empty(t, w.Header().Get(quotaWarningHeader))
+
+	// The warning names the highest threshold the estimated size reaches
+	tableBytes = 7000
+	w = upload(500)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "80", w.Header().Get(quotaWarningHeader))
+	tableBytes = 8500
+	w = upload(500)
+	assert.Equal(t, "90", w.Header().Get(quotaWarningHeader))
+	assert.Equal(t, "500", w.Header().Get(quotaRemainingHeader))
+
+	// Rejected uploads report the quota too
+	tableBytes = 9500
+	w = upload(500)
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+	assert.Equal(t, "0", w.Header().Get(quotaRemainingHeader))
+
+	// Without a quota nothing is reported
+	appConfig.Ingestion.MaxTableBytes = 0
+	w = upload(500)
+	assert.Empty(t, w.Header().Get(quotaLimitHeader))
+}
+
+// TestFreeDiskBytes tests that free space can be read for the temp directory
+func TestFreeDiskBytes(t *testing.T) {
+	free, err := freeDiskBytes(".")
+	assert.NoError(t, err)
+	assert.Greater(t, free, uint64(0))
+}
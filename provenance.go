@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// includeProvenance is the include query value that adds the provenance to returned records
+const includeProvenance = "provenance"
+
+// recordProvenance traces a record to the import and the file line it was written from.
+// Records created through the API have none.
+type recordProvenance struct {
+	ImportID        *uint   `gorm:"index" json:"import_id"`
+	SourceFile      *string `gorm:"size:255" json:"source_file"`
+	SourceRowNumber *int    `json:"source_row_number"`
+}
+
+// provenance returns the provenance of a row read from line of the import's file;
+// rows written without an import job, e.g. in tests, get none
+func (p *importProgress) provenance(line int) recordProvenance {
+	if p == nil || p.jobID == 0 {
+		return recordProvenance{}
+	}
+	provenance := recordProvenance{ImportID: &p.jobID, SourceRowNumber: &line}
+	if p.sourceFile != "" {
+		provenance.SourceFile = &p.sourceFile
+	}
+	return provenance
+}
+
+// recordWithProvenance is a record as returned with include=provenance
+type recordWithProvenance struct {
+	UserDatas
+	Provenance recordProvenance `json:"provenance"`
+}
+
+// parseInclude reads the comma-separated include query parameter, responding with 400 when it names
+// something that can't be included. It reports whether the provenance was requested.
+func parseInclude(c *gin.Context) (bool, bool) {
+	provenance := false
+	for _, name := range strings.Split(c.Query("include"), ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case includeProvenance:
+			provenance = true
+		default:
+			respondError(c, 400, "Invalid include", fmt.Sprintf("unknown include %q, expected %q", name, includeProvenance))
+			return false, false
+		}
+	}
+	return provenance, true
+}
+
+// withProvenance adds the provenance to records for responses to include=provenance
+func withProvenance(records []UserDatas) []recordWithProvenance {
+	views := make([]recordWithProvenance, len(records))
+	for i, record := range records {
+		views[i] = recordWithProvenance{UserDatas: record, Provenance: record.Provenance}
+	}
+	return views
+}
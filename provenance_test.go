@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestImportProvenance tests that imported rows carry their job, file name and line
+func TestImportProvenance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store, _ := newRecordingJobStore(ctrl)
+	overflow, _ := newOverflowHandler("")
+	csvData := "ID,First Name,Last Name,Email,Age,Gender,Department,Company,Salary,Date Joined,Is Active\n" +
+		"1,John,Doe,john@example.com,30,Male,IT,Corp,50000,2020-01-01,true\n" +
+		"2,Jane,Doe,jane@example.com,old,Female,HR,Corp,60000,2021-01-01,true\n" +
+		"3,Jim,Doe,jim@example.com,40,Male,IT,Corp,40000,2022-01-01,true\n"
+	sink := &memorySink{}
+	imports := newImportManager(store, nil)
+	_, err := imports.submit(importTask{job: &ImportJob{FileName: "users.csv"}, source: stringSource(csvData), sink: sink, options: importOptions{overflow: overflow, preserveOrder: true}})
+	assert.NoError(t, err)
+	imports.Wait()
+
+	assert.Len(t, sink.users, 2)
+	for i, line := range []int{2, 4} {
+		provenance := sink.users[i].Provenance
+		assert.Equal(t, uint(1), *provenance.ImportID)
+		assert.Equal(t, "users.csv", *provenance.SourceFile)
+		assert.Equal(t, line, *provenance.SourceRowNumber)
+	}
+
+	// Rows written without an import job have no provenance
+	assert.Equal(t, recordProvenance{}, (*importProgress)(nil).provenance(2))
+}
+
+// TestRecordProvenance tests that the provenance of records is only returned with include=provenance
+func TestRecordProvenance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	importID, sourceFile, line := uint(3), "users.csv", 12
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().First(gomock.Any(), 7).DoAndReturn(func(dest interface{}, conds ...interface{}) *gorm.DB {
+		*dest.(*UserDatas) = UserDatas{ID: 7, FirstName: "John", Email: "john@example.com",
+			Provenance: recordProvenance{ImportID: &importID, SourceFile: &sourceFile, SourceRowNumber: &line}}
+		return &gorm.DB{}
+	}).Times(2)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/records/:id", func(c *gin.Context) {
+		getRecord(c, mockDB)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/records/7", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.NotContains(t, w.Body.String(), "provenance")
+	assert.NotContains(t, w.Body.String(), "import_id")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/records/7?include=provenance", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"provenance":{"import_id":3,"source_file":"users.csv","source_row_number":12}`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/records/7?include=history", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+// TestRecordListProvenance tests that include=provenance isn't taken for a filter of the records list
+func TestRecordListProvenance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	importID := uint(3)
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Model(gomock.Any()).Return(mockDB)
+	mockDB.EXPECT().Count(gomock.Any()).DoAndReturn(func(count *int64) *gorm.DB {
+		*count = 1
+		return &gorm.DB{}
+	})
+	mockDB.EXPECT().Offset(0).Return(mockDB)
+	mockDB.EXPECT().Limit(10).Return(mockDB)
+	mockDB.EXPECT().Order("id ASC").Return(mockDB)
+	mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(func(dest interface{}, conds ...interface{}) *gorm.DB {
+		*dest.(*[]UserDatas) = []UserDatas{{ID: 7, Provenance: recordProvenance{ImportID: &importID}}}
+		return &gorm.DB{}
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/records?include=provenance", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"provenance":{"import_id":3`)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// queryTags are key/value pairs identifying the request or import a query was issued for
+type queryTags map[string]string
+
+// queryTagsKey is the context key of the query tags
+type queryTagsKey struct{}
+
+// withQueryTags returns a context carrying the tags of ctx extended by tags
+func withQueryTags(ctx context.Context, tags queryTags) context.Context {
+	merged := queryTags{}
+	for key, value := range queryTagsFrom(ctx) {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, queryTagsKey{}, merged)
+}
+
+// queryTagsFrom returns the tags carried by ctx
+func queryTagsFrom(ctx context.Context) queryTags {
+	if ctx == nil {
+		return nil
+	}
+	tags, _ := ctx.Value(queryTagsKey{}).(queryTags)
+	return tags
+}
+
+// comment formats the tags as an SQL comment in the sqlcommenter format, e.g. /*job_id='12',route='%2Fupload-csv'*/.
+// Values are URL-encoded, so they can't end the comment or contain quotes.
+func (t queryTags) comment() string {
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = url.QueryEscape(key) + "='" + url.QueryEscape(t[key]) + "'"
+	}
+	return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+// queryTagsMiddleware tags the queries of a request with its request ID and route
+func queryTagsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := withQueryTags(c.Request.Context(), queryTags{
+			"request_id": c.GetString(requestIDContextKey),
+			"route":      c.FullPath(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// queryTagger is a GORM plugin prefixing every statement with a comment carrying the tags of its context,
+// so load seen in pg_stat_activity and pg_stat_statements can be attributed to endpoints and imports
+type queryTagger struct{}
+
+// Name identifies the plugin
+func (queryTagger) Name() string {
+	return "query_tagger"
+}
+
+// Initialize registers the tagging callbacks before each statement is built and executed
+func (queryTagger) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	register := []error{
+		callbacks.Create().Before("gorm:create").Register("query_tagger:create", tagStatement("INSERT")),
+		callbacks.Query().Before("gorm:query").Register("query_tagger:query", tagStatement("SELECT")),
+		callbacks.Update().Before("gorm:update").Register("query_tagger:update", tagStatement("UPDATE")),
+		callbacks.Delete().Before("gorm:delete").Register("query_tagger:delete", tagStatement("DELETE")),
+		callbacks.Row().Before("gorm:row").Register("query_tagger:row", tagStatement("SELECT")),
+		callbacks.Raw().Before("gorm:raw").Register("query_tagger:raw", tagStatement("")),
+	}
+	for _, err := range register {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagStatement returns a callback adding the comment in front of raw SQL,
+// or in front of the named clause of statements GORM has yet to build
+func tagStatement(clauseName string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		tags := queryTagsFrom(db.Statement.Context)
+		if len(tags) == 0 {
+			return
+		}
+		comment := tags.comment()
+
+		stmt := db.Statement
+		if stmt.SQL.Len() > 0 {
+			sql := stmt.SQL.String()
+			if strings.HasPrefix(sql, "/*") {
+				return
+			}
+			stmt.SQL.Reset()
+			stmt.SQL.WriteString(comment + " " + sql)
+			return
+		}
+		if clauseName == "" {
+			return
+		}
+		c := stmt.Clauses[clauseName]
+		c.Name = clauseName
+		c.BeforeExpression = clause.Expr{SQL: comment}
+		stmt.Clauses[clauseName] = c
+	}
+}
+
+// WithContext returns a database whose queries use ctx and carry its query tags
+func (g *GormDatabase) WithContext(ctx context.Context) Database {
+	return &GormDatabase{DB: g.DB.WithContext(ctx)}
+}
+
+// WithContext returns a handler whose queries use ctx and carry its query tags
+func (handler *GormDBHandler) WithContext(ctx context.Context) DBHandler {
+	return &GormDBHandler{db: handler.db.WithContext(ctx), conn: handler.conn}
+}
+
+// requestDatabase scopes db to the request, so its queries are tagged and cancelled with it.
+// Implementations without WithContext, such as mocks, are used as they are.
+func requestDatabase(c *gin.Context, db Database) Database {
+	if scoped, ok := db.(interface {
+		WithContext(ctx context.Context) Database
+	}); ok {
+		return scoped.WithContext(c.Request.Context())
+	}
+	return db
+}
+
+// contextDBHandler scopes dbHandler to ctx when the implementation supports it
+func contextDBHandler(ctx context.Context, dbHandler DBHandler) DBHandler {
+	return contextStore(ctx, dbHandler)
+}
+
+// contextStore scopes a store of the database, such as a DBHandler or SnapshotStore, to ctx
+// when the implementation supports it
+func contextStore[T any](ctx context.Context, store T) T {
+	if scoped, ok := any(store).(interface {
+		WithContext(ctx context.Context) T
+	}); ok {
+		return scoped.WithContext(ctx)
+	}
+	return store
+}
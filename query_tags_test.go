@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestQueryTagsComment tests the sqlcommenter formatting and escaping of query tags
+func TestQueryTagsComment(t *testing.T) {
+	tags := queryTags{"route": "/api/records/:id", "request_id": "abc", "job_id": "*/ DROP TABLE user_data; --'"}
+	assert.Equal(t, "/*job_id='%2A%2F+DROP+TABLE+user_data%3B+--%27',request_id='abc',route='%2Fapi%2Frecords%2F%3Aid'*/", tags.comment())
+
+	ctx := withQueryTags(context.Background(), queryTags{"request_id": "abc"})
+	ctx = withQueryTags(ctx, queryTags{"job_id": "7"})
+	assert.Equal(t, queryTags{"request_id": "abc", "job_id": "7"}, queryTagsFrom(ctx))
+	assert.Nil(t, queryTagsFrom(context.Background()))
+}
+
+// TestQueryTagger tests that statements built by GORM and raw SQL are prefixed with the tags of their context
+func TestQueryTagger(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(queryTagger{}))
+
+	ctx := withQueryTags(context.Background(), queryTags{"request_id": "abc"})
+	stmt := db.WithContext(ctx).Find(&[]UserDatas{}).Statement
+	assert.Equal(t, `/*request_id='abc'*/ SELECT * FROM "user_data"`, stmt.SQL.String())
+
+	stmt = db.WithContext(ctx).Create(&UserData{FirstName: "Jane"}).Statement
+	assert.Contains(t, stmt.SQL.String(), `/*request_id='abc'*/ INSERT INTO "user_data"`)
+
+	stmt = db.WithContext(ctx).Exec("SELECT 1").Statement
+	assert.Equal(t, `/*request_id='abc'*/ SELECT 1`, stmt.SQL.String())
+
+	// Untagged contexts leave statements unchanged
+	stmt = db.Find(&[]UserDatas{}).Statement
+	assert.Equal(t, `SELECT * FROM "user_data"`, stmt.SQL.String())
+}
+
+// TestQueryTagsMiddleware tests that the request ID and route are put on the request context
+func TestQueryTagsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestIDMiddleware(), queryTagsMiddleware())
+	var tags queryTags
+	r.GET("/api/records/:id", func(c *gin.Context) {
+		tags = queryTagsFrom(c.Request.Context())
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/records/7", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, queryTags{"request_id": "req-1", "route": "/api/records/:id"}, tags)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// rateLimitSweepInterval is how often buckets of clients that went quiet are dropped
+const rateLimitSweepInterval = time.Minute
+
+// tokenBucket holds the tokens a client has left and when they were last refilled
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// clientRateLimiter allows each client perMinute requests a minute, refilled continuously,
+// so a client may burst up to a minute's worth of requests and then sustain the rate
+type clientRateLimiter struct {
+	name      string
+	perMinute int
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// newClientRateLimiter creates a limiter of perMinute requests a minute per client
+func newClientRateLimiter(name string, perMinute int) *clientRateLimiter {
+	return &clientRateLimiter{name: name, perMinute: perMinute, buckets: map[string]*tokenBucket{}, now: time.Now}
+}
+
+// allow takes a token of the client's bucket, or returns how long until the next token is available
+func (l *clientRateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity := float64(l.perMinute)
+	refill := capacity / time.Minute.Seconds() // Tokens per second
+	l.sweep(now, capacity, refill)
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*refill)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / refill * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled completely, which behave like new ones, so the map stays bounded
+func (l *clientRateLimiter) sweep(now time.Time, capacity, refill float64) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for client, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*refill >= capacity {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// clientConcurrency bounds the in-flight requests of each client
+type clientConcurrency struct {
+	limit int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// newClientConcurrency creates a limiter of limit in-flight requests per client
+func newClientConcurrency(limit int) *clientConcurrency {
+	return &clientConcurrency{limit: limit, active: map[string]int{}}
+}
+
+// acquire takes a slot of the client, reporting false when all of them are in use
+func (l *clientConcurrency) acquire(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[client] >= l.limit {
+		return false
+	}
+	l.active[client]++
+	return true
+}
+
+// release frees a slot of the client
+func (l *clientConcurrency) release(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[client]--; l.active[client] <= 0 {
+		delete(l.active, client)
+	}
+}
+
+// clientKey identifies the client of a request: the authenticated principal, e.g. an API key or user,
+// or the client IP on an open API
+func clientKey(c *gin.Context) string {
+	if principal, ok := c.Get(principalContextKey); ok {
+		return principal.(*Principal).Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// rejectRateLimited answers with 429 and a Retry-After header of at least a second
+func rejectRateLimited(c *gin.Context, client, limiter string, retryAfter time.Duration) {
+	requestLogger(c).WithFields(logrus.Fields{"route": c.FullPath(), "client": client, "limiter": limiter}).Warn("Rejected request over the client rate limit")
+	c.Header("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+	respondError(c, 429, "Too many requests", "rate limit of "+limiter+" exceeded")
+	c.Abort()
+}
+
+// rateLimitMiddleware limits the reads and writes of each client per minute; a zero rate doesn't limit.
+// It runs after authentication so authenticated clients are limited per user rather than per IP.
+func rateLimitMiddleware(config RateLimitConfig) gin.HandlerFunc {
+	var reads, writes *clientRateLimiter
+	if config.ReadsPerMinute > 0 {
+		reads = newClientRateLimiter("reads", config.ReadsPerMinute)
+	}
+	if config.WritesPerMinute > 0 {
+		writes = newClientRateLimiter("writes", config.WritesPerMinute)
+	}
+
+	return func(c *gin.Context) {
+		limiter := reads
+		if isWriteMethod(c.Request.Method) {
+			limiter = writes
+		}
+		if limiter == nil || probePaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		client := clientKey(c)
+		if ok, retryAfter := limiter.allow(client); !ok {
+			rejectRateLimited(c, client, limiter.name, retryAfter)
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientUploadLimit bounds the concurrent uploads of each client; a zero limit doesn't limit
+func clientUploadLimit(limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	uploads := newClientConcurrency(limit)
+
+	return func(c *gin.Context) {
+		client := clientKey(c)
+		if !uploads.acquire(client) {
+			rejectRateLimited(c, client, "concurrent uploads", time.Second)
+			return
+		}
+		defer uploads.release(client)
+		c.Next()
+	}
+}
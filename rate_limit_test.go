@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClientRateLimiter tests that each client gets its own bucket, refilled at the configured rate
+func TestClientRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newClientRateLimiter("reads", 60)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 60; i++ {
+		ok, _ := limiter.allow("ip:10.0.0.1")
+		assert.True(t, ok)
+	}
+	ok, retryAfter := limiter.allow("ip:10.0.0.1")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, retryAfter)
+
+	// Other clients are unaffected, and a token is back after a second
+	ok, _ = limiter.allow("ip:10.0.0.2")
+	assert.True(t, ok)
+	now = now.Add(time.Second)
+	ok, _ = limiter.allow("ip:10.0.0.1")
+	assert.True(t, ok)
+
+	// Buckets that refilled completely are dropped
+	now = now.Add(2 * rateLimitSweepInterval)
+	limiter.allow("ip:10.0.0.3")
+	assert.Len(t, limiter.buckets, 1)
+}
+
+// TestRateLimitMiddleware tests rejecting reads, writes and concurrent uploads over the client limits
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set(principalContextKey, &Principal{Subject: "user:" + user})
+		}
+	}, rateLimitMiddleware(RateLimitConfig{ReadsPerMinute: 2, WritesPerMinute: 1}))
+	r.GET("/read", func(c *gin.Context) { c.Status(200) })
+	r.POST("/write", func(c *gin.Context) { c.Status(200) })
+
+	send := func(method, path, user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("X-Test-User", user)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, 200, send("GET", "/read", "").Code)
+	assert.Equal(t, 200, send("GET", "/read", "").Code)
+	w := send("GET", "/read", "")
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	// Writes have their own budget, and authenticated users are limited apart from their IP
+	assert.Equal(t, 200, send("POST", "/write", "").Code)
+	assert.Equal(t, 429, send("POST", "/write", "").Code)
+	assert.Equal(t, 200, send("GET", "/read", "alice").Code)
+
+	// A client may run a limited number of uploads at once
+	release := make(chan struct{})
+	started := make(chan struct{})
+	uploads := gin.New()
+	uploads.POST("/upload", clientUploadLimit(1), func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(202)
+	})
+	go uploads.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/upload", nil))
+	<-started
+	w = httptest.NewRecorder()
+	uploads.ServeHTTP(w, httptest.NewRequest("POST", "/upload", nil))
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	close(release)
+}
+
+// TestTrustedProxies tests that X-Forwarded-For only sets the client IP when sent by a trusted proxy, so
+// clients can't escape their rate limits by rotating it
+func TestTrustedProxies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := appConfig.Server.TrustedProxies
+	defer func() { appConfig.Server.TrustedProxies = previous }()
+	gin.SetMode(gin.TestMode)
+	client := func() string {
+		r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+		r.GET("/client", func(c *gin.Context) { c.String(200, clientKey(c)) })
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/client", nil)
+		req.RemoteAddr = "203.0.113.7:41000"
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	appConfig.Server.TrustedProxies = nil
+	assert.Equal(t, "ip:203.0.113.7", client())
+	appConfig.Server.TrustedProxies = []string{"203.0.113.0/24"}
+	assert.Equal(t, "ip:198.51.100.1", client())
+}
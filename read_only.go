@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyPath is the admin endpoint switching read-only mode, the only write allowed while it is on
+const readOnlyPath = "/api/admin/read-only"
+
+// readOnlyMode rejects writes while enabled, e.g. on replicas or during a data freeze
+type readOnlyMode struct {
+	mu      sync.RWMutex
+	enabled bool
+	reason  string
+}
+
+// Global read-only switch, set from the configuration at startup and by the admin endpoint
+var readOnly = &readOnlyMode{}
+
+// set turns read-only mode on or off with the reason reported to rejected clients
+func (m *readOnlyMode) set(enabled bool, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = enabled
+	m.reason = reason
+	if !enabled {
+		m.reason = ""
+	}
+}
+
+// state returns whether read-only mode is on and why
+func (m *readOnlyMode) state() (bool, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled, m.reason
+}
+
+// isWriteMethod reports whether requests with method may change data
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// readOnlyMiddleware answers writes with 503 while read-only mode is on.
+// Uploads, record changes and admin operations such as cancelling queries are all rejected;
+// issuing tokens and comparing datasets, which write nothing, are still allowed and imports that were already queued still run.
+func readOnlyMiddleware(mode *readOnlyMode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled, reason := mode.state()
+		if !enabled || !isWriteMethod(c.Request.Method) || c.FullPath() == readOnlyPath || c.FullPath() == comparePath || strings.HasPrefix(c.FullPath(), "/api/auth/") {
+			c.Next()
+			return
+		}
+		if reason == "" {
+			reason = "writes are disabled"
+		}
+		respondError(c, 503, "Service is in read-only mode", reason)
+		c.Abort()
+	}
+}
+
+// readOnlyRequest is the body of PUT /api/admin/read-only
+type readOnlyRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Reason  string `json:"reason"`
+}
+
+// readOnlyStatus handles GET /api/admin/read-only
+func readOnlyStatus(c *gin.Context, mode *readOnlyMode) {
+	enabled, reason := mode.state()
+	respond(c, 200, gin.H{"enabled": enabled, "reason": reason}, nil)
+}
+
+// setReadOnly handles PUT /api/admin/read-only, switching read-only mode at runtime
+func setReadOnly(c *gin.Context, mode *readOnlyMode) {
+	var req readOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid request", err.Error())
+		return
+	}
+
+	mode.set(*req.Enabled, req.Reason)
+	log.WithField("reason", req.Reason).Warnf("Read-only mode set to %t", *req.Enabled)
+	readOnlyStatus(c, mode)
+}
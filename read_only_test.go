@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadOnlyMode tests that writes are rejected with the reason while reads and the switch keep working
+func TestReadOnlyMode(t *testing.T) {
+	disableAuth(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer readOnly.set(false, "")
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("PUT", readOnlyPath, `{"enabled":true,"reason":"data freeze until Monday"}`)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"enabled":true`)
+
+	for _, write := range [][2]string{
+		{"POST", "/upload-csv"},
+		{"POST", "/api/imports"},
+		{"POST", "/api/records"},
+		{"DELETE", "/api/records/7"},
+		{"POST", "/api/admin/db/cancel/101"},
+	} {
+		w = send(write[0], write[1], "")
+		assert.Equal(t, 503, w.Code, write[1])
+		assert.Contains(t, w.Body.String(), "data freeze until Monday", write[1])
+	}
+
+	// Reads are unaffected
+	w = send("GET", "/api/admin/config", "")
+	assert.Equal(t, 200, w.Code)
+
+	assert.Equal(t, 400, send("PUT", readOnlyPath, `{"reason":"no flag"}`).Code)
+	assert.Equal(t, 200, send("PUT", readOnlyPath, `{"enabled":false}`).Code)
+	enabled, reason := readOnly.state()
+	assert.False(t, enabled)
+	assert.Empty(t, reason)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recordFilter is a WHERE condition with its single argument
+type recordFilter struct {
+	condition string
+	arg       interface{}
+}
+
+// filterParam describes a whitelisted filter query parameter.
+// Only these conditions are ever used in the query; values are passed as arguments.
+type filterParam struct {
+	condition string
+	parse     func(value string) (interface{}, error)
+}
+
+// parseFilterString accepts any text value
+func parseFilterString(value string) (interface{}, error) {
+	return value, nil
+}
+
+// parseFilterNumber accepts a number such as 50000 or 1234.5
+func parseFilterNumber(value string) (interface{}, error) {
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseFilterBool accepts true or false
+func parseFilterBool(value string) (interface{}, error) {
+	return strconv.ParseBool(value)
+}
+
+// parseFilterDate accepts a YYYY-MM-DD date
+func parseFilterDate(value string) (interface{}, error) {
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		return nil, fmt.Errorf("expected a YYYY-MM-DD date")
+	}
+	return value, nil
+}
+
+// parseFilterTag accepts an import tag and matches it in the comma-separated tags of import_jobs
+func parseFilterTag(value string) (interface{}, error) {
+	if !importTagPattern.MatchString(value) {
+		return nil, fmt.Errorf("expected up to 50 letters, digits, _ . : and -")
+	}
+	return likePattern("," + value + ","), nil
+}
+
+// recordFilterParams maps the filter query parameters of /api/records to their conditions
+var recordFilterParams = map[string]filterParam{
+	"first_name":    {"first_name = ?", parseFilterString},
+	"last_name":     {"last_name = ?", parseFilterString},
+	"email":         {"email = ?", parseFilterString},
+	"gender":        {"gender = ?", parseFilterString},
+	"department":    {"department = ?", parseFilterString},
+	"company":       {"company = ?", parseFilterString},
+	"min_age":       {"age >= ?", parseFilterNumber},
+	"max_age":       {"age <= ?", parseFilterNumber},
+	"min_salary":    {"salary >= ?", parseFilterNumber},
+	"max_salary":    {"salary <= ?", parseFilterNumber},
+	"is_active":     {"is_active = ?", parseFilterBool},
+	"joined_after":  {"date_joined >= ?", parseFilterDate},
+	"joined_before": {"date_joined <= ?", parseFilterDate},
+	// Records carry the tags of the import that wrote them
+	"tag": {"import_id IN (SELECT id FROM import_jobs WHERE (',' || tags || ',') LIKE ?)", parseFilterTag},
+}
+
+// recordListParams are the non-filter query parameters of /api/records
+var recordListParams = map[string]bool{"page": true, "size": true, "sort": true, "include": true, "cursor": true, "stream": true}
+
+// recordSortColumns are the columns /api/records can be sorted by.
+// Only these names are ever used in the ORDER BY clause.
+var recordSortColumns = map[string]bool{
+	"id": true, "first_name": true, "last_name": true, "email": true, "age": true, "gender": true,
+	"department": true, "company": true, "salary": true, "date_joined": true, "is_active": true,
+}
+
+// parseRecordSort translates a sort parameter such as salary:desc,last_name:asc into an ORDER BY clause.
+// The id is always the last key, so pages are stable when the sort values repeat.
+func parseRecordSort(sort string) (string, error) {
+	if sort == "" {
+		return "id ASC", nil
+	}
+
+	var keys []string
+	seen := map[string]bool{}
+	for _, field := range strings.Split(sort, ",") {
+		column, direction, _ := strings.Cut(strings.TrimSpace(field), ":")
+		if !recordSortColumns[column] {
+			return "", fmt.Errorf("cannot sort by %q", column)
+		}
+		if seen[column] {
+			return "", fmt.Errorf("column %q is sorted by more than once", column)
+		}
+		seen[column] = true
+
+		switch strings.ToLower(direction) {
+		case "", "asc":
+			keys = append(keys, column+" ASC")
+		case "desc":
+			keys = append(keys, column+" DESC")
+		default:
+			return "", fmt.Errorf("invalid sort direction %q for %s, expected asc or desc", direction, column)
+		}
+	}
+	if !seen["id"] {
+		keys = append(keys, "id ASC")
+	}
+	return strings.Join(keys, ", "), nil
+}
+
+// parseRecordFilters translates the filter query parameters into conditions, which all have to match.
+// Unknown parameters are rejected, so a misspelt filter doesn't silently return every record.
+func parseRecordFilters(query url.Values) ([]recordFilter, error) {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var filters []recordFilter
+	for _, name := range names {
+		if recordListParams[name] {
+			continue
+		}
+		param, ok := recordFilterParams[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %q, expected one of %s", name, strings.Join(recordFilterNames(), ", "))
+		}
+		if len(query[name]) > 1 {
+			return nil, fmt.Errorf("filter %q may only be given once", name)
+		}
+		arg, err := param.parse(query.Get(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q: %v", name, query.Get(name), err)
+		}
+		filters = append(filters, recordFilter{condition: param.condition, arg: arg})
+	}
+	return filters, nil
+}
+
+// recordFilterNames returns the sorted names of the filter query parameters
+func recordFilterNames() []string {
+	names := make([]string, 0, len(recordFilterParams))
+	for name := range recordFilterParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyRecordFilters adds the filters to the query as WHERE conditions
+func applyRecordFilters(db Database, filters []recordFilter) Database {
+	for _, filter := range filters {
+		db = db.Where(filter.condition, filter.arg)
+	}
+	return db
+}
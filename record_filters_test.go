@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestParseRecordFilters tests translating whitelisted query parameters into conditions
+func TestParseRecordFilters(t *testing.T) {
+	query, _ := url.ParseQuery("department=IT&company=Acme&min_salary=50000&is_active=true&joined_after=2021-01-01&tag=backfill_2023&page=2&size=5")
+	filters, err := parseRecordFilters(query)
+	assert.NoError(t, err)
+	assert.Equal(t, []recordFilter{
+		{"company = ?", "Acme"},
+		{"department = ?", "IT"},
+		{"is_active = ?", true},
+		{"date_joined >= ?", "2021-01-01"},
+		{"salary >= ?", 50000.0},
+		{"import_id IN (SELECT id FROM import_jobs WHERE (',' || tags || ',') LIKE ?)", `%,backfill\_2023,%`},
+	}, filters)
+
+	for _, raw := range []string{
+		"salary=50000",                // Unknown filter
+		"min_salary=lots",             // Not a number
+		"is_active=maybe",             // Not a boolean
+		"joined_after=01/01/2021",     // Not a date
+		"department=IT&department=HR", // Repeated
+		"tag=backfill,2023",           // Not a tag
+	} {
+		query, _ := url.ParseQuery(raw)
+		_, err := parseRecordFilters(query)
+		assert.Error(t, err, raw)
+	}
+}
+
+// TestFilteredRecords tests that filters and sorting are combined with pagination on /api/records
+func TestFilteredRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	gomock.InOrder(
+		mockDB.EXPECT().Model(gomock.Any()).Return(mockDB),
+		mockDB.EXPECT().Where("department = ?", "IT").Return(mockDB),
+		mockDB.EXPECT().Where("salary >= ?", 50000.0).Return(mockDB),
+		mockDB.EXPECT().Count(gomock.Any()).DoAndReturn(func(count *int64) *gorm.DB {
+			*count = 12
+			return &gorm.DB{}
+		}),
+		mockDB.EXPECT().Where("department = ?", "IT").Return(mockDB),
+		mockDB.EXPECT().Where("salary >= ?", 50000.0).Return(mockDB),
+		mockDB.EXPECT().Offset(5).Return(mockDB),
+		mockDB.EXPECT().Limit(5).Return(mockDB),
+		mockDB.EXPECT().Order("salary DESC, id ASC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).Return(&gorm.DB{}),
+	)
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/records?department=IT&min_salary=50000&sort=salary:desc&page=2&size=5", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "12", w.Header().Get(totalCountHeader))
+	assert.Contains(t, w.Body.String(), `"total_pages":3`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/records?dept=IT", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown filter")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/records?sort=password", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+// TestParseRecordSort tests translating the sort parameter into a whitelisted ORDER BY clause
+func TestParseRecordSort(t *testing.T) {
+	for sort, order := range map[string]string{
+		"":                           "id ASC",
+		"salary:desc,last_name:asc":  "salary DESC, last_name ASC, id ASC",
+		"age, id:desc":               "age ASC, id DESC",
+		"date_joined:DESC":           "date_joined DESC, id ASC",
+		"is_active:desc,company:asc": "is_active DESC, company ASC, id ASC",
+	} {
+		parsed, err := parseRecordSort(sort)
+		assert.NoError(t, err, sort)
+		assert.Equal(t, order, parsed, sort)
+	}
+
+	for _, sort := range []string{
+		"password:asc",
+		"salary:sideways",
+		"salary; DROP TABLE user_data",
+		"(CASE WHEN 1=1 THEN id END)",
+		"age,age:desc",
+		",",
+	} {
+		_, err := parseRecordSort(sort)
+		assert.Error(t, err, sort)
+	}
+}
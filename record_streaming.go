@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	recordStreamThreshold = 1000 // Page sizes above which /api/records streams its response instead of building it in memory
+	recordStreamBatchSize = 500  // Records read from the database at a time while streaming
+	ndjsonContentType     = "application/x-ndjson"
+)
+
+// parseRecordStream reads the stream query parameter, responding with 400 when it is invalid. Pages larger
+// than recordStreamThreshold are streamed unless stream=false.
+func parseRecordStream(c *gin.Context, size int) (bool, bool) {
+	value, ok := c.GetQuery("stream")
+	if !ok || value == "" {
+		return size > recordStreamThreshold, true
+	}
+	stream, err := strconv.ParseBool(value)
+	if err != nil {
+		respondError(c, 400, "Invalid stream value", err.Error())
+		return false, false
+	}
+	return stream, true
+}
+
+// readRecordBatches reads up to limit records of the query from offset in batches of recordStreamBatchSize,
+// passing each batch to fn. Pages sorted by id are read with FindInBatches, which continues each batch
+// after the last id read; other sorts read each batch with its own OFFSET. newQuery returns a fresh query
+// for each batch, since GORM statements must not be reused.
+func readRecordBatches(newQuery func() Database, order string, offset, limit int, fn func(records []UserDatas) error) error {
+	if order == "id ASC" {
+		batch := []UserDatas{}
+		return newQuery().Offset(offset).Limit(limit).FindInBatches(&batch, recordStreamBatchSize, func(*gorm.DB, int) error {
+			return fn(batch)
+		}).Error
+	}
+
+	for read := 0; read < limit; {
+		size := min(recordStreamBatchSize, limit-read)
+		batch := []UserDatas{}
+		if err := newQuery().Offset(offset + read).Limit(size).Order(order).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		if len(batch) < size {
+			return nil
+		}
+		read += size
+	}
+	return nil
+}
+
+// recordStream writes a list of records to the client as the batches are read: a JSON envelope whose data
+// array is written record by record, or NDJSON, one record per line, when the client accepts
+// application/x-ndjson. Only the batch being written is held in memory.
+type recordStream struct {
+	c          *gin.Context
+	ndjson     bool
+	provenance bool
+	limit      int // Records to write; those beyond only tell that there is a next page
+	written    int
+	more       bool
+	lastID     int
+}
+
+// newRecordStream starts the response, writing at most limit records
+func newRecordStream(c *gin.Context, limit int, provenance bool) *recordStream {
+	s := &recordStream{c: c, ndjson: strings.Contains(c.GetHeader("Accept"), ndjsonContentType), provenance: provenance, limit: limit}
+	if s.ndjson {
+		c.Header("Content-Type", ndjsonContentType)
+		c.Status(200)
+		return s
+	}
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(200)
+	c.Writer.WriteString(`{"data":[`)
+	return s
+}
+
+// write sends a batch of records to the client, stopping when it is gone
+func (s *recordStream) write(records []UserDatas) error {
+	for _, record := range records {
+		if s.written == s.limit {
+			s.more = true
+			break
+		}
+		var item interface{} = record
+		if s.provenance {
+			item = recordWithProvenance{UserDatas: record, Provenance: record.Provenance}
+		}
+		data, err := casedJSON(item)
+		if err != nil {
+			return err
+		}
+		if !s.ndjson && s.written > 0 {
+			s.c.Writer.WriteString(",")
+		}
+		s.c.Writer.Write(data)
+		if s.ndjson {
+			s.c.Writer.WriteString("\n")
+		}
+		s.written++
+		s.lastID = record.ID
+	}
+	s.c.Writer.Flush()
+	return s.c.Request.Context().Err()
+}
+
+// end finishes the response with its meta, or with the error that cut the list short. NDJSON responses
+// only get a last line holding the error, since their pagination is in the headers.
+func (s *recordStream) end(meta map[string]interface{}, err error) {
+	var errs []apiError
+	if err != nil {
+		if s.c.Request.Context().Err() != nil {
+			return // The client is gone
+		}
+		requestLogger(s.c).WithError(err).WithField("records_written", s.written).Error("Failed to stream records")
+		errs = []apiError{{Message: "Failed to fetch records", Details: "the list ended early; fetch the remaining records again"}}
+	} else {
+		requestLogger(s.c).WithField("records_count", s.written).Info("Records streamed successfully")
+	}
+
+	if s.ndjson {
+		if errs != nil {
+			if data, marshalErr := casedJSON(envelope{Meta: responseMeta(s.c, nil), Errors: errs}); marshalErr == nil {
+				s.c.Writer.Write(append(data, '\n'))
+			}
+		}
+		return
+	}
+	if errs == nil {
+		errs = []apiError{}
+	}
+	meta = responseMeta(s.c, meta)
+	meta["streamed"] = true
+	tail, marshalErr := casedJSON(struct {
+		Meta   map[string]interface{} `json:"meta"`
+		Errors []apiError             `json:"errors"`
+	}{meta, errs})
+	if marshalErr != nil {
+		return
+	}
+	s.c.Writer.WriteString("],")
+	s.c.Writer.Write(tail[1:]) // The fields of the tail object continue the envelope
+}
+
+// casedJSON marshals obj with the configured JSON casing
+func casedJSON(obj interface{}) ([]byte, error) {
+	return json.Marshal(casedValue(obj))
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// findBatches fills the destination of a FindInBatches call with each batch of ids in turn, then
+// returns err, as GORM does when a batch fails
+func findBatches(err error, batches ...[]int) func(dest interface{}, batchSize int, fc func(tx *gorm.DB, batch int) error) *gorm.DB {
+	return func(dest interface{}, batchSize int, fc func(tx *gorm.DB, batch int) error) *gorm.DB {
+		for i, ids := range batches {
+			records := []UserDatas{}
+			for _, id := range ids {
+				records = append(records, UserDatas{ID: id, FirstName: "User"})
+			}
+			*dest.(*[]UserDatas) = records
+			if fcErr := fc(nil, i+1); fcErr != nil {
+				return &gorm.DB{Error: fcErr}
+			}
+		}
+		return &gorm.DB{Error: err}
+	}
+}
+
+// serveRecordAccepting sends a GET request accepting the given content type
+func serveRecordAccepting(r *gin.Engine, path, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("Accept", accept)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// countRecords answers the count query of a page with total
+func countRecords(mockDB *MockDatabase, total int64) {
+	mockDB.EXPECT().Model(gomock.Any()).Return(mockDB)
+	mockDB.EXPECT().Count(gomock.Any()).DoAndReturn(func(count *int64) *gorm.DB {
+		*count = total
+		return &gorm.DB{}
+	})
+}
+
+// TestStreamRecords tests streaming pages sorted by id in batches as a JSON envelope, and that large pages
+// are streamed unless stream=false
+func TestStreamRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	gomock.InOrder(
+		mockDB.EXPECT().Model(gomock.Any()).Return(mockDB),
+		mockDB.EXPECT().Count(gomock.Any()).DoAndReturn(func(count *int64) *gorm.DB {
+			*count = 7
+			return &gorm.DB{}
+		}),
+		mockDB.EXPECT().Offset(3).Return(mockDB),
+		mockDB.EXPECT().Limit(3).Return(mockDB),
+		mockDB.EXPECT().FindInBatches(gomock.Any(), recordStreamBatchSize, gomock.Any()).DoAndReturn(findBatches(nil, []int{4, 5}, []int{6})),
+	)
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := serveRecord(r, "GET", "/api/records?page=2&size=3&stream=true", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "7", w.Header().Get(totalCountHeader))
+	var body struct {
+		Data   []UserDatas            `json:"data"`
+		Meta   map[string]interface{} `json:"meta"`
+		Errors []apiError             `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	assert.Len(t, body.Data, 3)
+	assert.Equal(t, 6, body.Data[2].ID)
+	assert.Equal(t, true, body.Meta["streamed"])
+	assert.Equal(t, 7.0, body.Meta["total"])
+	assert.Equal(t, "/api/records?page=3&size=3&stream=true", body.Meta["next"])
+	assert.NotEmpty(t, body.Meta["request_id"])
+	assert.Empty(t, body.Errors)
+
+	// Pages above the threshold are streamed without asking
+	countRecords(mockDB, 5000)
+	mockDB.EXPECT().Offset(0).Return(mockDB)
+	mockDB.EXPECT().Limit(recordStreamThreshold + 1).Return(mockDB)
+	mockDB.EXPECT().FindInBatches(gomock.Any(), recordStreamBatchSize, gomock.Any()).DoAndReturn(findBatches(nil, []int{1}))
+	w = serveRecord(r, "GET", "/api/records?size=1001", "")
+	assert.Contains(t, w.Body.String(), `"streamed":true`)
+
+	// unless the client turns streaming off
+	countRecords(mockDB, 5000)
+	mockDB.EXPECT().Offset(0).Return(mockDB)
+	mockDB.EXPECT().Limit(recordStreamThreshold + 1).Return(mockDB)
+	mockDB.EXPECT().Order("id ASC").Return(mockDB)
+	mockDB.EXPECT().Find(gomock.Any()).Return(&gorm.DB{})
+	w = serveRecord(r, "GET", "/api/records?size=1001&stream=false", "")
+	assert.NotContains(t, w.Body.String(), `"streamed"`)
+
+	assert.Equal(t, 400, serveRecord(r, "GET", "/api/records?stream=maybe", "").Code)
+}
+
+// TestStreamRecordsNDJSON tests streaming pages with another sort with an OFFSET per batch, one record per
+// line, and a last line holding the error of a list cut short
+func TestStreamRecordsNDJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := func(ids ...int) func(dest interface{}, conds ...interface{}) *gorm.DB {
+		return func(dest interface{}, conds ...interface{}) *gorm.DB {
+			for _, id := range ids {
+				*dest.(*[]UserDatas) = append(*dest.(*[]UserDatas), UserDatas{ID: id})
+			}
+			return &gorm.DB{}
+		}
+	}
+	mockDB := NewMockDatabase(ctrl)
+	countRecords(mockDB, 2)
+	gomock.InOrder(
+		mockDB.EXPECT().Offset(0).Return(mockDB),
+		mockDB.EXPECT().Limit(5).Return(mockDB),
+		mockDB.EXPECT().Order("salary DESC, id ASC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(page(8, 3)),
+	)
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+	get := func(path string) (int, []string, string) {
+		w := serveRecordAccepting(r, path, ndjsonContentType)
+		return w.Code, strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n"), w.Header().Get("Content-Type")
+	}
+
+	code, lines, contentType := get("/api/records?size=5&sort=salary:desc&stream=true")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, ndjsonContentType, contentType)
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], `{"id":8,`), lines[0])
+
+	// A failing batch ends the stream with an error line
+	countRecords(mockDB, 1000)
+	gomock.InOrder(
+		mockDB.EXPECT().Offset(0).Return(mockDB),
+		mockDB.EXPECT().Limit(1000).Return(mockDB),
+		mockDB.EXPECT().FindInBatches(gomock.Any(), recordStreamBatchSize, gomock.Any()).DoAndReturn(findBatches(errors.New("connection reset"), []int{1, 2})),
+	)
+	_, lines, _ = get("/api/records?size=1000&stream=true")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[2], `"message":"Failed to fetch records"`)
+}
+
+// TestStreamRecordsAfter tests streaming keyset pages, reading one record more to find the next cursor
+func TestStreamRecordsAfter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	gomock.InOrder(
+		mockDB.EXPECT().Where("id > ?", 3).Return(mockDB),
+		mockDB.EXPECT().Offset(0).Return(mockDB),
+		mockDB.EXPECT().Limit(3).Return(mockDB),
+		mockDB.EXPECT().FindInBatches(gomock.Any(), recordStreamBatchSize, gomock.Any()).DoAndReturn(findBatches(nil, []int{4, 9, 12})),
+	)
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := serveRecord(r, "GET", "/api/records?cursor=3&size=2&stream=true", "")
+	assert.Equal(t, 200, w.Code)
+	var body envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	assert.Len(t, body.Data, 2)
+	assert.Equal(t, 9.0, body.Meta["next_cursor"])
+}
+
+// TestReadRecordBatches tests that batches of other sorts continue at the next offset until the page is read
+func TestReadRecordBatches(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fill := func(n int) func(dest interface{}, conds ...interface{}) *gorm.DB {
+		return func(dest interface{}, conds ...interface{}) *gorm.DB {
+			*dest.(*[]UserDatas) = make([]UserDatas, n)
+			return &gorm.DB{}
+		}
+	}
+	mockDB := NewMockDatabase(ctrl)
+	gomock.InOrder(
+		mockDB.EXPECT().Offset(100).Return(mockDB),
+		mockDB.EXPECT().Limit(500).Return(mockDB),
+		mockDB.EXPECT().Order("age ASC, id ASC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(fill(500)),
+		mockDB.EXPECT().Offset(600).Return(mockDB),
+		mockDB.EXPECT().Limit(500).Return(mockDB),
+		mockDB.EXPECT().Order("age ASC, id ASC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(fill(500)),
+		mockDB.EXPECT().Offset(1100).Return(mockDB),
+		mockDB.EXPECT().Limit(200).Return(mockDB),
+		mockDB.EXPECT().Order("age ASC, id ASC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(fill(150)),
+	)
+
+	var sizes []int
+	err := readRecordBatches(func() Database { return mockDB }, "age ASC, id ASC", 100, 1200, func(records []UserDatas) error {
+		sizes = append(sizes, len(records))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{500, 500, 150}, sizes)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// pgUniqueViolation is the PostgreSQL error code of a duplicate key
+const pgUniqueViolation = "23505"
+
+// linkHeader carries the pagination links of list endpoints (RFC 8288, formerly RFC 5988), so clients
+// can page without reading the envelope
+const linkHeader = "Link"
+
+// pageCount returns the number of pages of size items holding total items
+func pageCount(total int64, size int) int {
+	return int((total + int64(size) - 1) / int64(size))
+}
+
+// listLink returns the link to the list with the given query parameters set, keeping the others such as
+// filters and sort
+func listLink(requestURL *url.URL, params map[string]int) string {
+	query := requestURL.Query()
+	for name, value := range params {
+		query.Set(name, strconv.Itoa(value))
+	}
+	return requestURL.Path + "?" + query.Encode()
+}
+
+// setLinkHeader sets the Link header to the given links, in the order of rels; rels without a link are
+// left out
+func setLinkHeader(c *gin.Context, links map[string]string, rels ...string) {
+	values := []string{}
+	for _, rel := range rels {
+		if link, ok := links[rel]; ok {
+			values = append(values, "<"+link+`>; rel="`+rel+`"`)
+		}
+	}
+	if len(values) > 0 {
+		c.Header(linkHeader, strings.Join(values, ", "))
+	}
+}
+
+// setPageLinks sets the Link header of a page to the first, prev, next and last pages of the list. prev
+// and next are left out on the first and last page; an empty list has one page.
+func setPageLinks(c *gin.Context, page, size int, total int64) {
+	lastPage := max(pageCount(total, size), 1)
+	links := map[string]string{
+		"first": listLink(c.Request.URL, map[string]int{"page": 1, "size": size}),
+		"last":  listLink(c.Request.URL, map[string]int{"page": lastPage, "size": size}),
+	}
+	if page > 1 {
+		links["prev"] = listLink(c.Request.URL, map[string]int{"page": min(page-1, lastPage), "size": size})
+	}
+	if page < lastPage {
+		links["next"] = listLink(c.Request.URL, map[string]int{"page": page + 1, "size": size})
+	}
+	setLinkHeader(c, links, "first", "prev", "next", "last")
+}
+
+// setCursorLinks sets the Link header of a keyset page to the first page and, unless it is the last
+// page, to the next one. Keyset pages have no prev or last link, since they are only read forwards.
+func setCursorLinks(c *gin.Context, size int, nextCursor interface{}) {
+	links := map[string]string{"first": listLink(c.Request.URL, map[string]int{"cursor": 0, "size": size})}
+	if nextCursor != nil {
+		links["next"] = listLink(c.Request.URL, map[string]int{"cursor": nextCursor.(int), "size": size})
+	}
+	setLinkHeader(c, links, "first", "next")
+}
+
+// paginationMeta returns the page, size, total, total_pages and the next and prev page links of a list,
+// keeping the other query parameters such as filters. Links are null on the first and last page.
+func paginationMeta(requestURL *url.URL, page, size int, total int64) map[string]interface{} {
+	totalPages := pageCount(total, size)
+
+	link := func(target int) interface{} {
+		if target < 1 || target > totalPages {
+			return nil
+		}
+		return listLink(requestURL, map[string]int{"page": target, "size": size})
+	}
+
+	return map[string]interface{}{
+		"page":        page,
+		"size":        size,
+		"total":       total,
+		"total_pages": totalPages,
+		"next":        link(page + 1),
+		"prev":        link(min(page-1, totalPages)),
+	}
+}
+
+// cursorMeta returns the size, cursor and next_cursor of a keyset page and the link to the next page,
+// keeping the other query parameters. next_cursor and the link are null on the last page.
+func cursorMeta(requestURL *url.URL, size, cursor int, nextCursor interface{}) map[string]interface{} {
+	var next interface{}
+	if nextCursor != nil {
+		next = listLink(requestURL, map[string]int{"cursor": nextCursor.(int), "size": size})
+	}
+	return map[string]interface{}{
+		"size":        size,
+		"cursor":      cursor,
+		"next_cursor": nextCursor,
+		"next":        next,
+	}
+}
+
+// listRecordsAfter responds with the size records following the cursor, the id of the last record of the
+// previous page, or the first ones when it is empty or 0. Keyset pages are read with WHERE id > cursor
+// instead of an OFFSET, so they stay fast however deep they go; they are ordered by id and have no total.
+func listRecordsAfter(c *gin.Context, db Database, cursorStr string, size int, filters []recordFilter, order string, provenance, stream bool) {
+	cursor := 0
+	if cursorStr != "" {
+		parsed, err := strconv.Atoi(cursorStr)
+		if err != nil || parsed < 0 {
+			respondError(c, 400, "Invalid cursor", "cursor must be the id of the last record of the previous page")
+			return
+		}
+		cursor = parsed
+	}
+
+	var condition string
+	switch order {
+	case "id ASC":
+		condition = "id > ?"
+	case "id DESC":
+		condition = "id < ?"
+	default:
+		respondError(c, 400, "Invalid sort", "cursor pagination only supports sorting by id")
+		return
+	}
+
+	newQuery := func() Database {
+		query := applyRecordFilters(db, filters)
+		if cursor > 0 {
+			query = query.Where(condition, cursor)
+		}
+		return query
+	}
+
+	// Read one more record than requested to tell whether there is a next page
+	if stream {
+		setCursorLinks(c, size, nil) // The next cursor is only known once the page is written
+		out := newRecordStream(c, size, provenance)
+		err := readRecordBatches(newQuery, order, 0, size+1, out.write)
+		var nextCursor interface{}
+		if out.more {
+			nextCursor = out.lastID
+		}
+		out.end(cursorMeta(c.Request.URL, size, cursor, nextCursor), err)
+		return
+	}
+
+	records := []UserDatas{}
+	if err := newQuery().Limit(size + 1).Order(order).Find(&records).Error; err != nil {
+		log.WithError(err).Error("Failed to fetch records")
+		respondError(c, 500, "Failed to fetch records")
+		return
+	}
+	var nextCursor interface{}
+	if len(records) > size {
+		records = records[:size]
+		nextCursor = records[size-1].ID
+	}
+
+	log.WithField("records_count", len(records)).Info("Records fetched successfully")
+	setCursorLinks(c, size, nextCursor)
+	meta := cursorMeta(c.Request.URL, size, cursor, nextCursor)
+	if provenance {
+		respond(c, 200, withProvenance(records), meta)
+		return
+	}
+	respond(c, 200, records, meta)
+}
+
+// recordID parses the :id path parameter, responding with 400 when it isn't a positive integer
+func recordID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 1 {
+		respondError(c, 400, "Invalid record id", "id must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// findRecord loads the record with the given id, responding with 404 or 500 when it can't
+func findRecord(c *gin.Context, db Database, id int) (*UserDatas, bool) {
+	var record UserDatas
+	err := db.First(&record, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Record not found")
+		return nil, false
+	}
+	if err != nil {
+		log.WithError(err).WithField("id", id).Error("Failed to fetch record")
+		respondError(c, 500, "Failed to fetch record")
+		return nil, false
+	}
+	return &record, true
+}
+
+// respondWriteError responds to a failed insert or update, with 409 when it conflicts with an existing record
+func respondWriteError(c *gin.Context, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		respondError(c, 409, "Record conflicts with an existing record", pgErr.Detail)
+		return
+	}
+	log.WithError(err).Error("Failed to save record")
+	respondError(c, 500, "Failed to save record")
+}
+
+// getRecord handles GET /api/records/:id
+func getRecord(c *gin.Context, db Database) {
+	id, ok := recordID(c)
+	if !ok {
+		return
+	}
+	provenance, ok := parseInclude(c)
+	if !ok {
+		return
+	}
+	record, ok := findRecord(c, db, id)
+	if !ok {
+		return
+	}
+	if provenance {
+		respond(c, 200, recordWithProvenance{UserDatas: *record, Provenance: record.Provenance}, nil)
+		return
+	}
+	respond(c, 200, record, nil)
+}
+
+// createRecord handles POST /api/records; the id is always assigned by the database
+func createRecord(c *gin.Context, db Database) {
+	var record UserDatas
+	if !bindRecord(c, &record) {
+		return
+	}
+	record.ID = 0
+
+	if err := db.Create(&record).Error; err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	log.WithField("id", record.ID).Info("Record created")
+	c.Header("Location", "/api/records/"+strconv.Itoa(record.ID))
+	respond(c, 201, record, nil)
+}
+
+// replaceRecord handles PUT /api/records/:id, replacing every field of an existing record.
+// The replaced record no longer comes from an imported file, so its provenance is cleared.
+func replaceRecord(c *gin.Context, db Database) {
+	id, ok := recordID(c)
+	if !ok {
+		return
+	}
+	if _, ok := findRecord(c, db, id); !ok {
+		return
+	}
+
+	var record UserDatas
+	if !bindRecord(c, &record) {
+		return
+	}
+	record.ID = id
+
+	if err := db.Save(&record).Error; err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	respond(c, 200, record, nil)
+}
+
+// patchRecord handles PATCH /api/records/:id, changing only the fields present in the body
+func patchRecord(c *gin.Context, db Database) {
+	id, ok := recordID(c)
+	if !ok {
+		return
+	}
+	record, ok := findRecord(c, db, id)
+	if !ok {
+		return
+	}
+
+	// Decoding into the stored record keeps the fields missing from the body; the result is validated as a whole
+	if !bindRecord(c, record) {
+		return
+	}
+	record.ID = id
+
+	if err := db.Save(record).Error; err != nil {
+		respondWriteError(c, err)
+		return
+	}
+	respond(c, 200, record, nil)
+}
+
+// deleteRecord handles DELETE /api/records/:id
+func deleteRecord(c *gin.Context, db Database) {
+	id, ok := recordID(c)
+	if !ok {
+		return
+	}
+
+	result := db.Delete(&UserDatas{}, id)
+	if result.Error != nil {
+		log.WithError(result.Error).WithField("id", id).Error("Failed to delete record")
+		respondError(c, 500, "Failed to delete record")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, 404, "Record not found")
+		return
+	}
+	log.WithField("id", id).Info("Record deleted")
+	c.Status(204)
+}
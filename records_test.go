@@ -0,0 +1,225 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// storedRecord returns a First stub that loads a copy of record
+func storedRecord(record UserDatas) func(dest interface{}, conds ...interface{}) *gorm.DB {
+	return func(dest interface{}, conds ...interface{}) *gorm.DB {
+		*dest.(*UserDatas) = record
+		return &gorm.DB{}
+	}
+}
+
+// serveRecord sends a request with an optional JSON body to the router
+func serveRecord(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestRecordCRUD tests reading, creating, replacing, patching and deleting single records
+func TestRecordCRUD(t *testing.T) {
+	disableAuth(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	gin.SetMode(gin.TestMode)
+	mockDB := NewMockDatabase(ctrl)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+	stored := UserDatas{ID: 7, FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Age: 30, DateJoined: newDate(2022, 1, 1)}
+
+	// Read, with the date formatted as YYYY-MM-DD
+	mockDB.EXPECT().First(gomock.Any(), 7).DoAndReturn(storedRecord(stored))
+	w := serveRecord(r, "GET", "/api/records/7", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"date_joined":"2022-01-01"`)
+
+	mockDB.EXPECT().First(gomock.Any(), 8).Return(&gorm.DB{Error: gorm.ErrRecordNotFound})
+	assert.Equal(t, 404, serveRecord(r, "GET", "/api/records/8", "").Code)
+	assert.Equal(t, 400, serveRecord(r, "GET", "/api/records/abc", "").Code)
+
+	// Create, ignoring a client supplied id
+	mockDB.EXPECT().Create(gomock.Any()).DoAndReturn(func(value interface{}) *gorm.DB {
+		assert.Equal(t, 0, value.(*UserDatas).ID)
+		value.(*UserDatas).ID = 9
+		return &gorm.DB{}
+	})
+	w = serveRecord(r, "POST", "/api/records", `{"id":3,"first_name":"Jim","email":"jim@example.com","age":41}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "/api/records/9", w.Header().Get("Location"))
+
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/records", `{"first_name":"Jim","email":"not-an-email"}`).Code)
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/records", `{"first_name":"Jim","email":"jim@example.com","date_joined":"01/02/2022"}`).Code)
+
+	// A duplicate email conflicts with the unique index created by upsert imports
+	mockDB.EXPECT().Create(gomock.Any()).Return(&gorm.DB{Error: &pgconn.PgError{Code: "23505", Detail: "Key (email)=(jim@example.com) already exists."}})
+	w = serveRecord(r, "POST", "/api/records", `{"first_name":"Jim","email":"jim@example.com"}`)
+	assert.Equal(t, 409, w.Code)
+	assert.Contains(t, w.Body.String(), "already exists")
+
+	// Replace every field
+	mockDB.EXPECT().First(gomock.Any(), 7).DoAndReturn(storedRecord(stored))
+	mockDB.EXPECT().Save(gomock.Any()).DoAndReturn(func(value interface{}) *gorm.DB {
+		assert.Equal(t, UserDatas{ID: 7, FirstName: "Janet", Email: "janet@example.com"}, *value.(*UserDatas))
+		return &gorm.DB{}
+	})
+	assert.Equal(t, 200, serveRecord(r, "PUT", "/api/records/7", `{"first_name":"Janet","email":"janet@example.com"}`).Code)
+
+	// Patch only the given fields
+	mockDB.EXPECT().First(gomock.Any(), 7).DoAndReturn(storedRecord(stored))
+	mockDB.EXPECT().Save(gomock.Any()).DoAndReturn(func(value interface{}) *gorm.DB {
+		patched := *value.(*UserDatas)
+		assert.Equal(t, 31, patched.Age)
+		assert.Equal(t, "Doe", patched.LastName)
+		assert.Equal(t, newDate(2022, 1, 1), patched.DateJoined)
+		return &gorm.DB{}
+	})
+	assert.Equal(t, 200, serveRecord(r, "PATCH", "/api/records/7", `{"age":31}`).Code)
+
+	mockDB.EXPECT().First(gomock.Any(), 7).DoAndReturn(storedRecord(stored))
+	assert.Equal(t, 400, serveRecord(r, "PATCH", "/api/records/7", `{"age":-1}`).Code)
+
+	// Delete
+	mockDB.EXPECT().Delete(gomock.Any(), 7).Return(&gorm.DB{RowsAffected: 1})
+	assert.Equal(t, 204, serveRecord(r, "DELETE", "/api/records/7", "").Code)
+	mockDB.EXPECT().Delete(gomock.Any(), 8).Return(&gorm.DB{})
+	assert.Equal(t, 404, serveRecord(r, "DELETE", "/api/records/8", "").Code)
+}
+
+// TestPaginationMeta tests the totals and page links of list responses
+func TestPaginationMeta(t *testing.T) {
+	requestURL, _ := url.Parse("/api/records?department=IT&page=2&size=5")
+	meta := paginationMeta(requestURL, 2, 5, 12)
+	assert.Equal(t, int64(12), meta["total"])
+	assert.Equal(t, 3, meta["total_pages"])
+	assert.Equal(t, "/api/records?department=IT&page=3&size=5", meta["next"])
+	assert.Equal(t, "/api/records?department=IT&page=1&size=5", meta["prev"])
+
+	meta = paginationMeta(requestURL, 1, 5, 5)
+	assert.Nil(t, meta["next"])
+	assert.Nil(t, meta["prev"])
+
+	// Past the last page, prev leads back to it
+	meta = paginationMeta(requestURL, 9, 5, 12)
+	assert.Nil(t, meta["next"])
+	assert.Equal(t, "/api/records?department=IT&page=3&size=5", meta["prev"])
+
+	meta = paginationMeta(requestURL, 1, 10, 0)
+	assert.Equal(t, 0, meta["total_pages"])
+	assert.Nil(t, meta["next"])
+}
+
+// TestPageLinks tests the Link header of offset and keyset pages
+func TestPageLinks(t *testing.T) {
+	links := func(path string, set func(c *gin.Context)) string {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", path, nil)
+		set(c)
+		return w.Header().Get(linkHeader)
+	}
+
+	header := links("/api/records?department=IT&sort=salary:desc&page=2&size=5", func(c *gin.Context) { setPageLinks(c, 2, 5, 12) })
+	assert.Equal(t, `</api/records?department=IT&page=1&size=5&sort=salary%3Adesc>; rel="first", `+
+		`</api/records?department=IT&page=1&size=5&sort=salary%3Adesc>; rel="prev", `+
+		`</api/records?department=IT&page=3&size=5&sort=salary%3Adesc>; rel="next", `+
+		`</api/records?department=IT&page=3&size=5&sort=salary%3Adesc>; rel="last"`, header)
+
+	header = links("/api/records", func(c *gin.Context) { setPageLinks(c, 1, 10, 0) })
+	assert.Equal(t, `</api/records?page=1&size=10>; rel="first", </api/records?page=1&size=10>; rel="last"`, header)
+
+	// Past the last page, prev leads back to it
+	header = links("/api/records?page=9&size=5", func(c *gin.Context) { setPageLinks(c, 9, 5, 12) })
+	assert.Contains(t, header, `</api/records?page=3&size=5>; rel="prev"`)
+	assert.NotContains(t, header, `rel="next"`)
+
+	header = links("/api/records?cursor=20&size=5", func(c *gin.Context) { setCursorLinks(c, 5, 25) })
+	assert.Equal(t, `</api/records?cursor=0&size=5>; rel="first", </api/records?cursor=25&size=5>; rel="next"`, header)
+	header = links("/api/records?cursor=20&size=5", func(c *gin.Context) { setCursorLinks(c, 5, nil) })
+	assert.Equal(t, `</api/records?cursor=0&size=5>; rel="first"`, header)
+}
+
+// TestCursorMeta tests the next cursor and link of keyset pages
+func TestCursorMeta(t *testing.T) {
+	requestURL, _ := url.Parse("/api/records?department=IT&cursor=20&size=5")
+	meta := cursorMeta(requestURL, 5, 20, 25)
+	assert.Equal(t, 25, meta["next_cursor"])
+	assert.Equal(t, "/api/records?cursor=25&department=IT&size=5", meta["next"])
+
+	meta = cursorMeta(requestURL, 5, 20, nil)
+	assert.Nil(t, meta["next_cursor"])
+	assert.Nil(t, meta["next"])
+}
+
+// TestKeysetRecords tests paging /api/records after a cursor without an OFFSET or a count
+func TestKeysetRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	page := func(ids ...int) func(dest interface{}, conds ...interface{}) *gorm.DB {
+		return func(dest interface{}, conds ...interface{}) *gorm.DB {
+			for _, id := range ids {
+				*dest.(*[]UserDatas) = append(*dest.(*[]UserDatas), UserDatas{ID: id})
+			}
+			return &gorm.DB{}
+		}
+	}
+	mockDB := NewMockDatabase(ctrl)
+	gomock.InOrder(
+		// First page of two: a third record means there is a next page
+		mockDB.EXPECT().Where("department = ?", "IT").Return(mockDB),
+		mockDB.EXPECT().Limit(3).Return(mockDB),
+		mockDB.EXPECT().Order("id ASC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(page(4, 9, 12)),
+
+		// Last page
+		mockDB.EXPECT().Where("department = ?", "IT").Return(mockDB),
+		mockDB.EXPECT().Where("id > ?", 9).Return(mockDB),
+		mockDB.EXPECT().Limit(3).Return(mockDB),
+		mockDB.EXPECT().Order("id ASC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(page(12)),
+
+		// Backwards
+		mockDB.EXPECT().Where("id < ?", 12).Return(mockDB),
+		mockDB.EXPECT().Limit(3).Return(mockDB),
+		mockDB.EXPECT().Order("id DESC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).DoAndReturn(page(9, 4)),
+	)
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := serveRecord(r, "GET", "/api/records?department=IT&cursor=&size=2", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_cursor":9`)
+	assert.Contains(t, w.Body.String(), `"next":"/api/records?cursor=9\u0026department=IT\u0026size=2"`)
+	assert.NotContains(t, w.Body.String(), `"id":12`)
+	assert.Empty(t, w.Header().Get(totalCountHeader))
+	assert.Contains(t, w.Header().Get(linkHeader), `</api/records?cursor=9&department=IT&size=2>; rel="next"`)
+
+	w = serveRecord(r, "GET", "/api/records?department=IT&cursor=9&size=2", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_cursor":null`)
+
+	w = serveRecord(r, "GET", "/api/records?cursor=12&size=2&sort=id:desc", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_cursor":null`)
+
+	assert.Equal(t, 400, serveRecord(r, "GET", "/api/records?cursor=-1", "").Code)
+	assert.Equal(t, 400, serveRecord(r, "GET", "/api/records?cursor=9&page=2", "").Code)
+	assert.Equal(t, 400, serveRecord(r, "GET", "/api/records?cursor=9&sort=salary:desc", "").Code)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Concurrency limits of heavy routes, independent of any rate limit:
+// requests beyond the limit wait in a bounded queue and get 429 when it is full or they waited too long
+const (
+	uploadConcurrency = 4  // Concurrent CSV uploads and import submissions
+	uploadQueue       = 8  // Uploads waiting for a slot
+	statsConcurrency  = 4  // Concurrent aggregate queries
+	statsQueue        = 16 // Aggregate queries waiting for a slot
+	listConcurrency   = 8  // Concurrent record listings, which export data page by page
+	listQueue         = 32 // Listings waiting for a slot
+
+	routeQueueWait   = 5 * time.Second // Longest a request waits for a slot
+	routeRetryAfterS = 1               // Retry-After seconds sent with 429
+)
+
+// routeLimiter bounds the in-flight requests of a route, so a burst can't exhaust DB connections
+type routeLimiter struct {
+	name    string
+	slots   chan struct{} // Requests being handled
+	waiting chan struct{} // Requests being handled or queued
+	wait    time.Duration
+}
+
+// newRouteLimiter creates a limiter handling concurrency requests at a time with up to queue more waiting
+func newRouteLimiter(name string, concurrency, queue int, wait time.Duration) *routeLimiter {
+	return &routeLimiter{
+		name:    name,
+		slots:   make(chan struct{}, concurrency),
+		waiting: make(chan struct{}, concurrency+queue),
+		wait:    wait,
+	}
+}
+
+// middleware admits requests while a slot is free, queues them while the queue has room and rejects the rest
+func (l *routeLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		select {
+		case l.waiting <- struct{}{}:
+		default:
+			l.reject(c, "queue is full")
+			return
+		}
+		defer func() { <-l.waiting }()
+
+		queued := time.Now()
+		timer := time.NewTimer(l.wait)
+		defer timer.Stop()
+		select {
+		case l.slots <- struct{}{}:
+			requestTraceFrom(c.Request.Context()).addQueueWait(time.Since(queued))
+		case <-timer.C:
+			l.reject(c, "timed out waiting for a slot")
+			return
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		}
+		defer func() { <-l.slots }()
+
+		c.Next()
+	}
+}
+
+// reject answers with 429 and a Retry-After header
+func (l *routeLimiter) reject(c *gin.Context, reason string) {
+	requestLogger(c).WithFields(logrus.Fields{"route": c.FullPath(), "limiter": l.name, "reason": reason}).Warn("Rejected request over the concurrency limit")
+	c.Header("Retry-After", strconv.Itoa(routeRetryAfterS))
+	respondError(c, 429, "Too many concurrent requests", reason)
+	c.Abort()
+}
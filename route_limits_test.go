@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRouteLimiter tests queueing requests over the concurrency limit and rejecting them once the queue is full
+func TestRouteLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	release := make(chan struct{})
+	entered := make(chan struct{}, 4)
+	export := newRouteLimiter("export", 1, 1, time.Minute)
+	r.GET("/export", export.middleware(), func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(200)
+	})
+	slowEntered := make(chan struct{}, 1)
+	r.GET("/slow", newRouteLimiter("slow", 1, 1, 10*time.Millisecond).middleware(), func(c *gin.Context) {
+		slowEntered <- struct{}{}
+		<-release
+		c.Status(200)
+	})
+
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// One request is handled and one queued; the third finds the queue full
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve("/export")
+		}()
+	}
+	<-entered
+	assert.Eventually(t, func() bool { return len(export.waiting) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, 429, serve("/export"))
+
+	// Queued requests run once the slot is freed
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 200, <-codes)
+	assert.Equal(t, 200, <-codes)
+
+	// A queued request that waits too long is rejected
+	release = make(chan struct{})
+	go serve("/slow")
+	<-slowEntered
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/slow", nil)
+	r.ServeHTTP(w, req)
+	close(release)
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ImportRowError is a CSV row rejected during an import, stored in the import_row_errors table
+type ImportRowError struct {
+	ID     uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	JobID  uint   `gorm:"index" json:"-"`
+	Line   int    `json:"line"`
+	Column string `gorm:"size:100" json:"column"` // Empty when the database rejected the row as a whole
+	Reason string `gorm:"type:text" json:"reason"`
+	Record string `gorm:"type:text" json:"record"` // The rejected row encoded as CSV
+}
+
+// TableName specifies the name of the table in the database
+func (ImportRowError) TableName() string {
+	return "import_row_errors"
+}
+
+// encodeCSVRecord encodes a record as a single CSV line without the line break
+func encodeCSVRecord(record []string) string {
+	var b strings.Builder
+	writer := csv.NewWriter(&b)
+	writer.Write(record)
+	writer.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// rowErrorsHeader is the header of the rejects CSV: the error details followed by the upload columns
+func rowErrorsHeader() []string {
+	columns := make([]string, 0, len(csvColumns))
+	for name := range csvColumns {
+		columns = append(columns, name)
+	}
+	sort.Slice(columns, func(i, j int) bool { return csvColumns[columns[i]] < csvColumns[columns[j]] })
+	return append([]string{"line", "error_column", "error_reason"}, columns...)
+}
+
+// importRowErrors handles GET /api/imports/:id/errors, downloading the rejected rows as CSV
+func importRowErrors(c *gin.Context, store JobStore) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, 400, "Invalid import job ID")
+		return
+	}
+
+	if _, err := store.Get(uint(id)); errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Import job not found")
+		return
+	} else if err != nil {
+		log.WithError(err).Error("Failed to fetch import job")
+		respondError(c, 500, "Failed to fetch import job")
+		return
+	}
+
+	rowErrors, err := store.RowErrors(uint(id))
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch import row errors")
+		respondError(c, 500, "Failed to fetch import row errors")
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=import-%d-errors.csv", id))
+	c.Status(200)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(rowErrorsHeader())
+	for _, rowError := range rowErrors {
+		// Stored records were written by encodeCSVRecord; fall back to the raw text if one can't be parsed
+		record, err := csv.NewReader(strings.NewReader(rowError.Record)).Read()
+		if err != nil {
+			record = []string{rowError.Record}
+		}
+		writer.Write(append([]string{strconv.Itoa(rowError.Line), rowError.Column, rowError.Reason}, record...))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.WithError(err).Error("Failed to write import row errors")
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// savedFiltersPath is the path of the saved filters, which belong to the authenticated user
+const savedFiltersPath = "/api/saved-filters"
+
+// savedFilterName matches the names filters can be saved under, e.g. my_team
+var savedFilterName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+
+// savedFilterExcludedParams are the query parameters of /api/records a saved filter can't hold, since they
+// pick a page of the list rather than describe it
+var savedFilterExcludedParams = map[string]bool{"page": true, "cursor": true, "stream": true, "filter": true}
+
+// savedFilters stores the saved filters; nil in tests, where filters can't be saved
+var savedFilters SavedFilterStore
+
+// SavedFilter is a named combination of the filter, sort, size and include parameters of /api/records,
+// stored in the saved_filters table. Names are unique per owner, the subject of the principal saving it.
+type SavedFilter struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Owner     string    `gorm:"size:255;uniqueIndex:idx_saved_filters_owner_name" json:"-"`
+	Name      string    `gorm:"size:100;uniqueIndex:idx_saved_filters_owner_name" json:"name"`
+	Query     string    `gorm:"size:2000" json:"query"` // URL-encoded query parameters, e.g. department=IT&sort=salary%3Adesc
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the name of the table in the database
+func (SavedFilter) TableName() string {
+	return "saved_filters"
+}
+
+// SavedFilterStore interface defines how the saved filters of an owner are listed, saved and deleted
+type SavedFilterStore interface {
+	List(owner string) ([]SavedFilter, error)
+	Find(owner, name string) (*SavedFilter, error)
+	Save(filter *SavedFilter) error
+	Delete(owner, name string) error
+}
+
+// GormSavedFilterStore is a concrete implementation of SavedFilterStore using GORM
+type GormSavedFilterStore struct {
+	db *gorm.DB
+}
+
+// List loads the filters of an owner by name
+func (store *GormSavedFilterStore) List(owner string) ([]SavedFilter, error) {
+	var filters []SavedFilter
+	err := store.db.Where("owner = ?", owner).Order("name ASC").Find(&filters).Error
+	return filters, err
+}
+
+// Find loads a filter of an owner by name, returning gorm.ErrRecordNotFound for unknown names
+func (store *GormSavedFilterStore) Find(owner, name string) (*SavedFilter, error) {
+	var filter SavedFilter
+	if err := store.db.Where("owner = ? AND name = ?", owner, name).First(&filter).Error; err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// Save creates the filter, or replaces the query of the owner's filter with the same name, and loads
+// the stored filter into it
+func (store *GormSavedFilterStore) Save(filter *SavedFilter) error {
+	upsert := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "owner"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"query", "updated_at"}),
+	}
+	if err := store.db.Clauses(upsert).Create(filter).Error; err != nil {
+		return err
+	}
+	return store.db.Where("owner = ? AND name = ?", filter.Owner, filter.Name).First(filter).Error
+}
+
+// Delete removes a filter of an owner, returning gorm.ErrRecordNotFound for unknown names
+func (store *GormSavedFilterStore) Delete(owner, name string) error {
+	result := store.db.Where("owner = ? AND name = ?", owner, name).Delete(&SavedFilter{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// parseSavedFilterQuery checks the query parameters of a filter to save like /api/records would, and
+// returns them encoded in a canonical order
+func parseSavedFilterQuery(raw string) (string, error) {
+	query, err := url.ParseQuery(strings.TrimPrefix(raw, "?"))
+	if err != nil {
+		return "", err
+	}
+	for name := range query {
+		if savedFilterExcludedParams[name] {
+			return "", fmt.Errorf("%s can't be saved in a filter", name)
+		}
+	}
+	if _, err := parseRecordFilters(query); err != nil {
+		return "", err
+	}
+	if _, err := parseRecordSort(query.Get("sort")); err != nil {
+		return "", err
+	}
+	if size, ok := query["size"]; ok {
+		if n, err := strconv.Atoi(size[0]); err != nil || n < 1 {
+			return "", fmt.Errorf("invalid size %q", size[0])
+		}
+	}
+	for _, name := range strings.Split(query.Get("include"), ",") {
+		if name = strings.TrimSpace(name); name != "" && name != includeProvenance {
+			return "", fmt.Errorf("unknown include %q, expected %q", name, includeProvenance)
+		}
+	}
+	return query.Encode(), nil
+}
+
+// savedFilterOwner returns the subject of the authenticated principal, responding with 401 to requests
+// without one, since saved filters belong to their user
+func savedFilterOwner(c *gin.Context) (string, bool) {
+	principal, ok := c.Get(principalContextKey)
+	if !ok {
+		respondError(c, 401, "Authentication required", "saved filters belong to the authenticated user")
+		return "", false
+	}
+	return principal.(*Principal).Subject, true
+}
+
+// applySavedFilter expands the filter query parameter of a request to /api/records into the parameters
+// saved under its name. Parameters given in the request take precedence over the saved ones, e.g.
+// ?filter=my_team&sort=age narrows the list like my_team but sorts it by age. The request's query is
+// rewritten before the handler reads it, so the page links hold the expanded parameters. It responds with
+// 401 without a principal and 404 for unknown names, and returns false then.
+func applySavedFilter(c *gin.Context, store SavedFilterStore) bool {
+	query := c.Request.URL.Query()
+	name, ok := query["filter"]
+	if !ok {
+		return true
+	}
+	if store == nil {
+		respondError(c, 404, "Saved filters are not available")
+		return false
+	}
+	owner, ok := savedFilterOwner(c)
+	if !ok {
+		return false
+	}
+
+	filter, err := store.Find(owner, name[0])
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Saved filter not found", fmt.Sprintf("no filter is saved as %q", name[0]))
+		return false
+	}
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to load saved filter")
+		respondError(c, 500, "Failed to load saved filter")
+		return false
+	}
+	saved, err := url.ParseQuery(filter.Query)
+	if err != nil {
+		requestLogger(c).WithError(err).WithField("saved_filter", filter.ID).Error("Invalid saved filter")
+		respondError(c, 500, "Failed to load saved filter")
+		return false
+	}
+
+	query.Del("filter")
+	for param, values := range saved {
+		if _, given := query[param]; !given {
+			query[param] = values
+		}
+	}
+	c.Request.URL.RawQuery = query.Encode()
+	return true
+}
+
+// listSavedFilters handles GET /api/saved-filters, listing the filters of the authenticated user by name
+func listSavedFilters(c *gin.Context, store SavedFilterStore) {
+	if store == nil {
+		respondError(c, 404, "Saved filters are not available")
+		return
+	}
+	owner, ok := savedFilterOwner(c)
+	if !ok {
+		return
+	}
+	filters, err := store.List(owner)
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to list saved filters")
+		respondError(c, 500, "Failed to list saved filters")
+		return
+	}
+	if filters == nil {
+		filters = []SavedFilter{}
+	}
+	respond(c, 200, filters, gin.H{"count": len(filters)})
+}
+
+// getSavedFilter handles GET /api/saved-filters/:name
+func getSavedFilter(c *gin.Context, store SavedFilterStore) {
+	if store == nil {
+		respondError(c, 404, "Saved filters are not available")
+		return
+	}
+	owner, ok := savedFilterOwner(c)
+	if !ok {
+		return
+	}
+	filter, err := store.Find(owner, c.Param("name"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Saved filter not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to load saved filter")
+		respondError(c, 500, "Failed to load saved filter")
+		return
+	}
+	respond(c, 200, filter, nil)
+}
+
+// saveFilter handles PUT /api/saved-filters/:name with {"query": "department=IT&sort=salary:desc"},
+// creating the filter or replacing the one saved under the name
+func saveFilter(c *gin.Context, store SavedFilterStore) {
+	if store == nil {
+		respondError(c, 404, "Saved filters are not available")
+		return
+	}
+	owner, ok := savedFilterOwner(c)
+	if !ok {
+		return
+	}
+	name := c.Param("name")
+	if !savedFilterName.MatchString(name) {
+		respondError(c, 400, "Invalid saved filter name", "names have 1 to 100 letters, digits, _ and -")
+		return
+	}
+	var req struct {
+		Query string `json:"query" binding:"required,max=2000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid saved filter", err.Error())
+		return
+	}
+	query, err := parseSavedFilterQuery(req.Query)
+	if err != nil {
+		respondError(c, 400, "Invalid saved filter", err.Error())
+		return
+	}
+
+	filter := &SavedFilter{Owner: owner, Name: name, Query: query}
+	if err := store.Save(filter); err != nil {
+		requestLogger(c).WithError(err).Error("Failed to save filter")
+		respondError(c, 500, "Failed to save filter")
+		return
+	}
+	requestLogger(c).WithFields(logrus.Fields{"saved_filter": filter.ID, "name": filter.Name}).Info("Filter saved")
+	respond(c, 200, filter, nil)
+}
+
+// deleteSavedFilter handles DELETE /api/saved-filters/:name
+func deleteSavedFilter(c *gin.Context, store SavedFilterStore) {
+	if store == nil {
+		respondError(c, 404, "Saved filters are not available")
+		return
+	}
+	owner, ok := savedFilterOwner(c)
+	if !ok {
+		return
+	}
+	err := store.Delete(owner, c.Param("name"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Saved filter not found")
+		return
+	}
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to delete saved filter")
+		respondError(c, 500, "Failed to delete saved filter")
+		return
+	}
+	c.Status(204)
+}
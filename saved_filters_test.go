@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// serveAs sends a request as a gateway user with the reader role, or without credentials when user is empty
+func serveAs(r *gin.Engine, method, path, user, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-Gateway-User", user)
+		req.Header.Set("X-Gateway-Role", roleReader)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestSavedFilters tests saving, listing and deleting the filters of the authenticated user
+func TestSavedFilters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previousAuthenticators, previousStore := customAuthenticators, savedFilters
+	defer func() { customAuthenticators, savedFilters = previousAuthenticators, previousStore }()
+	registerAuthenticator(&gatewayAuthenticator{})
+	store := NewMockSavedFilterStore(ctrl)
+	savedFilters = store
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	// Readers may save filters, which are stored with their parameters in a canonical order
+	store.EXPECT().Save(gomock.Any()).DoAndReturn(func(filter *SavedFilter) error {
+		assert.Equal(t, "gateway:jane", filter.Owner)
+		assert.Equal(t, "my_team", filter.Name)
+		assert.Equal(t, "department=IT&min_salary=50000&sort=salary%3Adesc", filter.Query)
+		filter.ID = 3
+		return nil
+	})
+	w := serveAs(r, "PUT", "/api/saved-filters/my_team", "jane", `{"query": "?sort=salary:desc&min_salary=50000&department=IT"}`)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 3.0, body.Data["id"])
+	assert.NotContains(t, body.Data, "owner")
+
+	for _, tc := range []struct{ path, body string }{
+		{"/api/saved-filters/my%20team", `{"query": "department=IT"}`},
+		{"/api/saved-filters/my_team", `{"query": "department=IT&page=2"}`},
+		{"/api/saved-filters/my_team", `{"query": "team=IT"}`},
+		{"/api/saved-filters/my_team", `{"query": "sort=salary:up"}`},
+		{"/api/saved-filters/my_team", `{"query": "size=0"}`},
+		{"/api/saved-filters/my_team", `{}`},
+	} {
+		assert.Equal(t, 400, serveAs(r, "PUT", tc.path, "jane", tc.body).Code, tc.body)
+	}
+
+	store.EXPECT().List("gateway:jane").Return([]SavedFilter{{ID: 3, Name: "my_team", Query: "department=IT"}}, nil)
+	w = serveAs(r, "GET", "/api/saved-filters", "jane", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+
+	store.EXPECT().Find("gateway:jane", "other").Return(nil, gorm.ErrRecordNotFound)
+	assert.Equal(t, 404, serveAs(r, "GET", "/api/saved-filters/other", "jane", "").Code)
+
+	store.EXPECT().Delete("gateway:jane", "my_team").Return(nil)
+	assert.Equal(t, 204, serveAs(r, "DELETE", "/api/saved-filters/my_team", "jane", "").Code)
+	store.EXPECT().Delete("gateway:jane", "my_team").Return(gorm.ErrRecordNotFound)
+	assert.Equal(t, 404, serveAs(r, "DELETE", "/api/saved-filters/my_team", "jane", "").Code)
+
+	// Filters belong to a user, so anonymous requests can't have any
+	assert.Equal(t, 401, serveAs(r, "GET", "/api/saved-filters", "", "").Code)
+}
+
+// TestApplySavedFilter tests that ?filter=name lists the records of the saved parameters, unless the
+// request gives them itself
+func TestApplySavedFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previousAuthenticators, previousStore := customAuthenticators, savedFilters
+	defer func() { customAuthenticators, savedFilters = previousAuthenticators, previousStore }()
+	registerAuthenticator(&gatewayAuthenticator{})
+	store := NewMockSavedFilterStore(ctrl)
+	savedFilters = store
+
+	mockDB := NewMockDatabase(ctrl)
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	store.EXPECT().Find("gateway:jane", "my_team").Return(&SavedFilter{Name: "my_team", Query: "department=IT&size=5&sort=salary%3Adesc"}, nil)
+	gomock.InOrder(
+		mockDB.EXPECT().Model(gomock.Any()).Return(mockDB),
+		mockDB.EXPECT().Where("department = ?", "IT").Return(mockDB),
+		mockDB.EXPECT().Count(gomock.Any()).DoAndReturn(func(count *int64) *gorm.DB {
+			*count = 12
+			return &gorm.DB{}
+		}),
+		mockDB.EXPECT().Where("department = ?", "IT").Return(mockDB),
+		mockDB.EXPECT().Offset(0).Return(mockDB),
+		mockDB.EXPECT().Limit(5).Return(mockDB),
+		mockDB.EXPECT().Order("age ASC, id ASC").Return(mockDB),
+		mockDB.EXPECT().Find(gomock.Any()).Return(&gorm.DB{}),
+	)
+	w := serveAs(r, "GET", "/api/records?filter=my_team&sort=age", "jane", "")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Header().Get(linkHeader), `</api/records?department=IT&page=2&size=5&sort=age>; rel="next"`)
+
+	store.EXPECT().Find("gateway:jane", "gone").Return(nil, gorm.ErrRecordNotFound)
+	assert.Equal(t, 404, serveAs(r, "GET", "/api/records?filter=gone", "jane", "").Code)
+	assert.Equal(t, 401, serveAs(r, "GET", "/api/records?filter=my_team", "", "").Code)
+
+	store.EXPECT().Find("gateway:jane", "my_team").Return(&SavedFilter{Name: "my_team", Query: "department=IT"}, nil)
+	gomock.InOrder(
+		mockDB.EXPECT().Model(gomock.Any()).Return(mockDB),
+		mockDB.EXPECT().Where("department = ?", "IT").Return(mockDB),
+		mockDB.EXPECT().Count(gomock.Any()).Return(&gorm.DB{}),
+	)
+	assert.Equal(t, 200, serveAs(r, "HEAD", "/api/records?filter=my_team", "jane", "").Code)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Startup migration modes
+const (
+	migrateAuto   = "auto"    // AutoMigrate the tables, logging the drift it fixes
+	migrateDryRun = "dry-run" // Only report the drift, leaving the tables as they are
+)
+
+// Kinds of schema drift
+const (
+	driftMissingTable  = "missing_table"
+	driftMissingColumn = "missing_column"
+	driftColumnType    = "column_type"
+	driftMissingIndex  = "missing_index"
+)
+
+// schemaModels are the models whose tables the service migrates and checks for drift
+var schemaModels = []interface{}{&UserDatas{}, &ImportJob{}, &ImportRowError{}, &AuthUser{}, &SchemaChange{}, &Snapshot{}, &APIKey{}, &UploadStream{}, &SavedFilter{}}
+
+// schemaDrift is a difference between the live schema and the models
+type schemaDrift struct {
+	Table    string `json:"table"`
+	Kind     string `json:"kind"`
+	Column   string `json:"column,omitempty"`
+	Index    string `json:"index,omitempty"`
+	Expected string `json:"expected,omitempty"` // Column type of the model
+	Actual   string `json:"actual,omitempty"`   // Column type in the database
+}
+
+// columnType is a PostgreSQL column type by its internal name (udt_name), e.g. int8 or varchar with a length
+type columnType struct {
+	name   string
+	length int64 // Maximum length of varchar columns
+}
+
+// String formats the type, e.g. varchar(100)
+func (t columnType) String() string {
+	if t.length > 0 {
+		return fmt.Sprintf("%s(%d)", t.name, t.length)
+	}
+	return t.name
+}
+
+// columnTypeAliases maps SQL type names of gorm type tags to the names PostgreSQL reports
+var columnTypeAliases = map[string]string{
+	"smallint":                 "int2",
+	"integer":                  "int4",
+	"int":                      "int4",
+	"bigint":                   "int8",
+	"boolean":                  "bool",
+	"decimal":                  "numeric",
+	"real":                     "float4",
+	"double precision":         "float8",
+	"character varying":        "varchar",
+	"timestamp with time zone": "timestamptz",
+}
+
+// typeLength matches the length of a type tag such as varchar(100)
+var typeLength = regexp.MustCompile(`^(.*?)\s*\((\d+)\)$`)
+
+// expectedColumnType returns the column type AutoMigrate creates for a field
+func expectedColumnType(field *schema.Field) columnType {
+	switch field.DataType {
+	case schema.Bool:
+		return columnType{name: "bool"}
+	case schema.Int, schema.Uint:
+		size := field.Size
+		if field.DataType == schema.Uint {
+			size++
+		}
+		switch {
+		case size <= 16:
+			return columnType{name: "int2"}
+		case size <= 32:
+			return columnType{name: "int4"}
+		default:
+			return columnType{name: "int8"}
+		}
+	case schema.Float:
+		return columnType{name: "numeric"}
+	case schema.String:
+		if field.Size > 0 {
+			return columnType{name: "varchar", length: int64(field.Size)}
+		}
+		return columnType{name: "text"}
+	case schema.Time:
+		return columnType{name: "timestamptz"}
+	case schema.Bytes:
+		return columnType{name: "bytea"}
+	}
+
+	// Types set with a type tag, e.g. date or varchar(20)
+	name := strings.ToLower(string(field.DataType))
+	var length int64
+	if match := typeLength.FindStringSubmatch(name); match != nil {
+		name = match[1]
+		fmt.Sscan(match[2], &length)
+	}
+	if alias, ok := columnTypeAliases[name]; ok {
+		name = alias
+	}
+	if name != "varchar" {
+		length = 0
+	}
+	return columnType{name: name, length: length}
+}
+
+// compareTable lists the columns and indexes of a model that are missing or differ in the live table
+func compareTable(model *schema.Schema, live map[string]columnType, hasIndex func(name string) bool) []schemaDrift {
+	var drift []schemaDrift
+	for _, field := range model.Fields {
+		if field.DBName == "" || field.IgnoreMigration {
+			continue
+		}
+		expected := expectedColumnType(field)
+		actual, ok := live[field.DBName]
+		switch {
+		case !ok:
+			drift = append(drift, schemaDrift{Table: model.Table, Kind: driftMissingColumn, Column: field.DBName, Expected: expected.String()})
+		case actual != expected:
+			drift = append(drift, schemaDrift{Table: model.Table, Kind: driftColumnType, Column: field.DBName, Expected: expected.String(), Actual: actual.String()})
+		}
+	}
+
+	indexes := model.ParseIndexes()
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !hasIndex(name) {
+			drift = append(drift, schemaDrift{Table: model.Table, Kind: driftMissingIndex, Index: name})
+		}
+	}
+	return drift
+}
+
+// detectSchemaDrift compares the live tables with the models without changing them
+func detectSchemaDrift(db *gorm.DB, models ...interface{}) ([]schemaDrift, error) {
+	migrator := db.Migrator()
+	drift := []schemaDrift{}
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model: %w", err)
+		}
+		if !migrator.HasTable(model) {
+			drift = append(drift, schemaDrift{Table: stmt.Schema.Table, Kind: driftMissingTable})
+			continue
+		}
+
+		columns, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the columns of %s: %w", stmt.Schema.Table, err)
+		}
+		live := make(map[string]columnType, len(columns))
+		for _, column := range columns {
+			actual := columnType{name: column.DatabaseTypeName()}
+			if actual.name == "varchar" {
+				actual.length, _ = column.Length()
+			}
+			live[column.Name()] = actual
+		}
+		drift = append(drift, compareTable(stmt.Schema, live, func(name string) bool {
+			return migrator.HasIndex(model, name)
+		})...)
+	}
+	return drift, nil
+}
+
+// SchemaInspector compares the live tables with the models of the service
+type SchemaInspector interface {
+	SchemaDrift() ([]schemaDrift, error)
+}
+
+// SchemaDrift compares the live tables with the models of the service
+func (p *GormDatabaseProbe) SchemaDrift() ([]schemaDrift, error) {
+	return detectSchemaDrift(p.db, schemaModels...)
+}
+
+// migrateSchema checks the tables for drift at startup and, unless mode is dry-run, migrates them.
+// Drift is logged either way, so AutoMigrate never alters tables silently.
+func migrateSchema(db *gorm.DB, mode string) error {
+	drift, err := detectSchemaDrift(db, schemaModels...)
+	if err != nil {
+		log.WithError(err).Error("Failed to check the schema for drift")
+	}
+	logSchemaDrift(drift, mode)
+
+	if mode == migrateDryRun {
+		return nil
+	}
+	// AutoMigrate would cast text dates to date as they are, failing on other formats than YYYY-MM-DD
+	if err := migrateDateColumn(db); err != nil {
+		return fmt.Errorf("failed to convert date_joined to dates: %w", err)
+	}
+	if err := db.AutoMigrate(schemaModels...); err != nil {
+		return err
+	}
+	// Creating the extension may need privileges the service lacks; only searches fail without it
+	if err := migrateSearchIndex(db); err != nil {
+		log.WithError(err).Warn("Failed to create the search index, record search is unavailable")
+	}
+	return nil
+}
+
+// logSchemaDrift logs each difference, as a warning when it is left in place
+func logSchemaDrift(drift []schemaDrift, mode string) {
+	level, message := logrus.InfoLevel, "Migrating schema drift"
+	if mode == migrateDryRun {
+		level, message = logrus.WarnLevel, "Schema drift"
+	}
+	for _, d := range drift {
+		log.WithFields(logrus.Fields{
+			"table": d.Table, "kind": d.Kind, "column": d.Column, "index": d.Index, "expected": d.Expected, "actual": d.Actual,
+		}).Log(level, message)
+	}
+	if len(drift) == 0 {
+		log.WithField("migrate", mode).Info("Schema matches the models")
+	}
+}
+
+// schemaDriftHandler handles GET /api/admin/db/schema-drift, comparing the live tables with the models
+func schemaDriftHandler(c *gin.Context, inspector SchemaInspector) {
+	drift, err := inspector.SchemaDrift()
+	if err != nil {
+		log.WithError(err).Error("Failed to check the schema for drift")
+		respondError(c, 500, "Failed to check the schema for drift", err.Error())
+		return
+	}
+	respond(c, 200, drift, map[string]interface{}{"count": len(drift), "migrate": appConfig.Database.Migrate})
+}
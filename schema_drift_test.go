@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/schema"
+)
+
+// parseModel returns the gorm schema of a model
+func parseModel(t *testing.T, model interface{}) *schema.Schema {
+	parsed, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+	assert.NoError(t, err)
+	return parsed
+}
+
+// TestCompareTable tests finding missing columns, changed types and missing indexes
+func TestCompareTable(t *testing.T) {
+	users := parseModel(t, &UserDatas{})
+	live := map[string]columnType{
+		"id":                {name: "int8"},
+		"first_name":        {name: "varchar", length: 100},
+		"last_name":         {name: "varchar", length: 100},
+		"email":             {name: "varchar", length: 150},
+		"age":               {name: "int8"},
+		"gender":            {name: "varchar", length: 10},
+		"department":        {name: "varchar", length: 100},
+		"company":           {name: "varchar", length: 100},
+		"salary":            {name: "numeric"},
+		"date_joined":       {name: "date"},
+		"is_active":         {name: "bool"},
+		"import_id":         {name: "int8"},
+		"source_file":       {name: "varchar", length: 255},
+		"source_row_number": {name: "int8"},
+	}
+	assert.Empty(t, compareTable(users, live, func(string) bool { return true }))
+
+	delete(live, "email")
+	live["first_name"] = columnType{name: "varchar", length: 50}
+	live["age"] = columnType{name: "int4"}
+	live["date_joined"] = columnType{name: "text"}
+	assert.Equal(t, []schemaDrift{
+		{Table: "user_data", Kind: driftColumnType, Column: "first_name", Expected: "varchar(100)", Actual: "varchar(50)"},
+		{Table: "user_data", Kind: driftMissingColumn, Column: "email", Expected: "varchar(150)"},
+		{Table: "user_data", Kind: driftColumnType, Column: "age", Expected: "int8", Actual: "int4"},
+		{Table: "user_data", Kind: driftColumnType, Column: "date_joined", Expected: "date", Actual: "text"},
+	}, compareTable(users, live, func(string) bool { return true }))
+
+	// Indexes declared on the model must exist
+	jobs := parseModel(t, &ImportJob{})
+	drift := compareTable(jobs, map[string]columnType{}, func(string) bool { return false })
+	assert.Contains(t, drift, schemaDrift{Table: "import_jobs", Kind: driftMissingIndex, Index: "idx_import_jobs_state"})
+	assert.Contains(t, drift, schemaDrift{Table: "import_jobs", Kind: driftMissingColumn, Column: "error", Expected: "text"})
+	assert.Contains(t, drift, schemaDrift{Table: "import_jobs", Kind: driftMissingColumn, Column: "started_at", Expected: "timestamptz"})
+}
+
+// TestSchemaDriftHandler tests the schema drift admin endpoint
+func TestSchemaDriftHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	probe := NewMockDatabaseProbe(ctrl)
+	probe.EXPECT().SchemaDrift().Return([]schemaDrift{{Table: "user_data", Kind: driftMissingColumn, Column: "email", Expected: "varchar(150)"}}, nil)
+	probe.EXPECT().SchemaDrift().Return(nil, errors.New("connection refused"))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/admin/db/schema-drift", func(c *gin.Context) {
+		schemaDriftHandler(c, probe)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/db/schema-drift", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"kind":"missing_column"`)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.Contains(t, w.Body.String(), `"migrate":"auto"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/admin/db/schema-drift", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 500, w.Code)
+}
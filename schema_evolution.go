@@ -0,0 +1,337 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Schema change states
+const (
+	schemaChangePending  = "pending"  // Proposed by an import whose file had the column, awaiting approval
+	schemaChangeApplied  = "applied"  // Approved and added to the table; imports write to it
+	schemaChangeRejected = "rejected" // Declined; the column stays ignored and isn't proposed again
+)
+
+// evolvedColumnType is the type of added columns; values are stored as read from the file
+const evolvedColumnType = "text"
+
+// maxColumnNameLength is the longest identifier PostgreSQL keeps without truncating it
+const maxColumnNameLength = 63
+
+// errSchemaChangeDecided is returned when approving or rejecting a change that isn't pending
+var errSchemaChangeDecided = errors.New("schema change was already decided")
+
+// schemaChanges records and applies the columns proposed for new CSV columns; nil in tests
+var schemaChanges SchemaChangeStore
+
+// SchemaChange is the audit record of a nullable column proposed for a header column the table doesn't have
+type SchemaChange struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Table      string     `gorm:"column:table_name;size:63;uniqueIndex:idx_schema_changes_column" json:"table"`
+	Column     string     `gorm:"column:column_name;size:63;uniqueIndex:idx_schema_changes_column" json:"column"`
+	ColumnType string     `gorm:"size:20" json:"column_type"`
+	Header     string     `gorm:"size:255" json:"header"` // Header name the column was proposed for
+	State      string     `gorm:"size:10;index" json:"state"`
+	ImportID   uint       `json:"import_id"` // Import whose file first had the column
+	CreatedAt  time.Time  `json:"created_at"`
+	DecidedAt  *time.Time `json:"decided_at"`
+	DecidedBy  string     `gorm:"size:255" json:"decided_by,omitempty"` // Client that approved or rejected the change
+}
+
+// TableName specifies the name of the table in the database
+func (SchemaChange) TableName() string {
+	return "schema_changes"
+}
+
+// SchemaChangeStore interface defines how schema changes are proposed, decided and applied
+type SchemaChangeStore interface {
+	Propose(changes []SchemaChange) error
+	List(state string) ([]SchemaChange, error)
+	AppliedColumns() ([]string, error)
+	Decide(id uint, approve bool, by string) (*SchemaChange, error)
+}
+
+// GormSchemaChangeStore is a concrete implementation of SchemaChangeStore using GORM
+type GormSchemaChangeStore struct {
+	db *gorm.DB
+}
+
+// Propose records the changes as pending, skipping columns that were already proposed
+func (store *GormSchemaChangeStore) Propose(changes []SchemaChange) error {
+	return store.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&changes).Error
+}
+
+// List loads the schema changes in the given state, or all of them when state is empty, oldest first
+func (store *GormSchemaChangeStore) List(state string) ([]SchemaChange, error) {
+	query := store.db.Order("id ASC")
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+	var changes []SchemaChange
+	err := query.Find(&changes).Error
+	return changes, err
+}
+
+// AppliedColumns loads the names of the columns added to user_data, sorted
+func (store *GormSchemaChangeStore) AppliedColumns() ([]string, error) {
+	var columns []string
+	err := store.db.Model(&SchemaChange{}).
+		Where("table_name = ? AND state = ?", UserData{}.TableName(), schemaChangeApplied).
+		Order("column_name ASC").Pluck("column_name", &columns).Error
+	return columns, err
+}
+
+// Decide approves or rejects a pending change; approving adds the column in the same transaction.
+// It returns gorm.ErrRecordNotFound for unknown changes and errSchemaChangeDecided for decided ones.
+func (store *GormSchemaChangeStore) Decide(id uint, approve bool, by string) (*SchemaChange, error) {
+	var change SchemaChange
+	err := store.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&change, id).Error; err != nil {
+			return err
+		}
+		if change.State != schemaChangePending {
+			return errSchemaChangeDecided
+		}
+
+		change.State = schemaChangeRejected
+		if approve {
+			alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s",
+				pgx.Identifier{change.Table}.Sanitize(), pgx.Identifier{change.Column}.Sanitize(), change.ColumnType)
+			if err := tx.Exec(alter).Error; err != nil {
+				return fmt.Errorf("failed to add column %s: %w", change.Column, err)
+			}
+			change.State = schemaChangeApplied
+		}
+		decided := time.Now()
+		change.DecidedAt = &decided
+		change.DecidedBy = by
+		return tx.Save(&change).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// evolvedColumnName turns a header name into the snake_case column proposed for it, e.g. Cost Center into cost_center.
+// Headers without letters or digits, too long for a column name, or naming a column of the model aren't proposed.
+func evolvedColumnName(header string) (string, bool) {
+	var b strings.Builder
+	separate := false
+	for _, r := range strings.ToLower(header) {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			separate = true
+			continue
+		}
+		if separate && b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		separate = false
+		b.WriteRune(r)
+	}
+
+	name := b.String()
+	if name == "" || len(name) > maxColumnNameLength || name == "id" {
+		return "", false
+	}
+	for _, column := range copyColumns {
+		if normalizeHeader(column) == normalizeHeader(name) {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// loadEvolvedColumns returns the added columns imports write to, or none when schema evolution is off
+func loadEvolvedColumns() ([]string, error) {
+	if !appConfig.Ingestion.SchemaEvolution || schemaChanges == nil {
+		return nil, nil
+	}
+	return schemaChanges.AppliedColumns()
+}
+
+// proposeSchemaChanges records a pending column for each header column the import couldn't write,
+// returning the proposed column names. Nothing is proposed when schema evolution is off.
+func proposeSchemaChanges(progress *importProgress, headers []string) []string {
+	if !appConfig.Ingestion.SchemaEvolution || schemaChanges == nil || len(headers) == 0 {
+		return nil
+	}
+	var jobID uint
+	if progress != nil {
+		jobID = progress.jobID
+	}
+
+	var changes []SchemaChange
+	var columns []string
+	for _, header := range headers {
+		column, ok := evolvedColumnName(header)
+		if !ok {
+			continue
+		}
+		changes = append(changes, SchemaChange{
+			Table: UserData{}.TableName(), Column: column, ColumnType: evolvedColumnType,
+			Header: header, State: schemaChangePending, ImportID: jobID,
+		})
+		columns = append(columns, column)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := schemaChanges.Propose(changes); err != nil {
+		progress.logger().WithError(err).Error("Failed to propose schema changes")
+		return nil
+	}
+	progress.logger().WithField("columns", columns).Warn("Proposed columns for unknown CSV columns; their values are dropped until approved")
+	return columns
+}
+
+// evolvedColumns returns the added columns the import writes to
+func (p *importProgress) evolvedColumns() []string {
+	if p == nil {
+		return nil
+	}
+	return p.evolved
+}
+
+// extraValues maps the added columns to the row's values, which follow the csvColumns in the record
+func (p *importProgress) extraValues(record []string) map[string]string {
+	if p == nil || len(p.evolved) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(p.evolved))
+	for k, column := range p.evolved {
+		if i := len(csvColumns) + k; i < len(record) {
+			values[column] = record[i]
+		} else {
+			values[column] = ""
+		}
+	}
+	return values
+}
+
+// extraColumns returns the added columns the users have values for, sorted
+func extraColumns(users []UserData) []string {
+	seen := map[string]bool{}
+	var columns []string
+	for _, user := range users {
+		for column := range user.Extra {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// extraValue is the stored value of an added column; empty values are stored as NULL
+func extraValue(user UserData, column string) interface{} {
+	if value := user.Extra[column]; value != "" {
+		return value
+	}
+	return nil
+}
+
+// writeExtraColumns sets the added columns of users written by GORM, which only knows the model's columns,
+// with UPDATE ... FROM (VALUES ...) statements keyed on the IDs the insert returned
+func writeExtraColumns(tx *gorm.DB, users []UserData, columns []string) error {
+	names := make([]string, len(columns))
+	sets := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = pgx.Identifier{column}.Sanitize()
+		sets[i] = fmt.Sprintf("%s = v.%s", names[i], names[i])
+	}
+	row := "(?::bigint" + strings.Repeat(", ?::text", len(columns)) + ")"
+
+	batch := safeBatchSize(len(users), len(columns)+1, tx.Dialector.Name())
+	for start := 0; start < len(users); start += batch {
+		part := users[start:min(start+batch, len(users))]
+		rows := make([]string, len(part))
+		args := make([]interface{}, 0, len(part)*(len(columns)+1))
+		for i, user := range part {
+			rows[i] = row
+			args = append(args, user.ID)
+			for _, column := range columns {
+				args = append(args, extraValue(user, column))
+			}
+		}
+		update := fmt.Sprintf("UPDATE %s AS t SET %s FROM (VALUES %s) AS v(id, %s) WHERE t.id = v.id",
+			pgx.Identifier{UserData{}.TableName()}.Sanitize(), strings.Join(sets, ", "), strings.Join(rows, ", "), strings.Join(names, ", "))
+		if err := tx.Exec(update, args...).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listSchemaChanges handles GET /api/admin/schema-changes, optionally only those in the state query parameter
+func listSchemaChanges(c *gin.Context, store SchemaChangeStore) {
+	if store == nil {
+		respondError(c, 404, "Schema evolution is not available")
+		return
+	}
+	state := c.Query("state")
+	switch state {
+	case "", schemaChangePending, schemaChangeApplied, schemaChangeRejected:
+	default:
+		respondError(c, 400, "Invalid state", fmt.Sprintf("expected %s, %s or %s", schemaChangePending, schemaChangeApplied, schemaChangeRejected))
+		return
+	}
+
+	changes, err := store.List(state)
+	if err != nil {
+		log.WithError(err).Error("Failed to list schema changes")
+		respondError(c, 500, "Failed to list schema changes")
+		return
+	}
+	if changes == nil {
+		changes = []SchemaChange{}
+	}
+	respond(c, 200, changes, gin.H{"count": len(changes), "enabled": appConfig.Ingestion.SchemaEvolution})
+}
+
+// decideSchemaChange handles POST /api/admin/schema-changes/:id/approve and /reject.
+// Approving adds the column, which later imports fill; values of earlier imports aren't recovered.
+func decideSchemaChange(c *gin.Context, store SchemaChangeStore, approve bool) {
+	if store == nil || !appConfig.Ingestion.SchemaEvolution {
+		respondError(c, 409, "Schema evolution is disabled", "set CSV_SCHEMA_EVOLUTION=true to decide schema changes")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, 400, "Invalid schema change ID")
+		return
+	}
+
+	change, err := store.Decide(uint(id), approve, clientKey(c))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Schema change not found")
+		return
+	}
+	if errors.Is(err, errSchemaChangeDecided) {
+		respondError(c, 409, err.Error())
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("schema_change", id).Error("Failed to decide schema change")
+		respondError(c, 500, "Failed to decide schema change", err.Error())
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"table": change.Table, "column": change.Column, "state": change.State, "decided_by": change.DecidedBy,
+	}).Warn("Decided schema change")
+	respond(c, 200, change, nil)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestEvolvedColumnName tests naming the columns proposed for header columns
+func TestEvolvedColumnName(t *testing.T) {
+	for header, expected := range map[string]string{
+		"Cost Center":      "cost_center",
+		" Manager (Email)": "manager_email",
+		"2024 Bonus":       "2024_bonus",
+		"Région":           "r_gion",
+	} {
+		name, ok := evolvedColumnName(header)
+		assert.True(t, ok, header)
+		assert.Equal(t, expected, name, header)
+	}
+
+	for _, header := range []string{"---", "ID", "Import ID", "Source-File", strings.Repeat("x", 64)} {
+		_, ok := evolvedColumnName(header)
+		assert.False(t, ok, header)
+	}
+}
+
+// TestRunImportSchemaEvolution tests that unknown columns are proposed while added ones are imported
+func TestRunImportSchemaEvolution(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous, previousStore := appConfig, schemaChanges
+	defer func() { appConfig, schemaChanges = previous, previousStore }()
+	appConfig.Ingestion.SchemaEvolution = true
+	store := NewMockSchemaChangeStore(ctrl)
+	schemaChanges = store
+
+	csvData := "First Name,Last Name,Email,Age,Salary,Cost Center,Badge #,Team\n" +
+		"Jane,Doe,jane@example.com,30,50000,CC-1,17,Core\n" +
+		"John,Doe,john@example.com,41,40000,,18,Core\n"
+
+	// Cost Center was added to the table; Badge # and Team are proposed with the import that had them
+	store.EXPECT().Propose([]SchemaChange{
+		{Table: "user_data", Column: "badge", ColumnType: "text", Header: "Badge #", State: schemaChangePending, ImportID: 7},
+		{Table: "user_data", Column: "team", ColumnType: "text", Header: "Team", State: schemaChangePending, ImportID: 7},
+	}).Return(nil)
+
+	sink := &memorySink{}
+	overflow, _ := newOverflowHandler("")
+	progress := &importProgress{jobID: 7, evolved: []string{"cost_center", "office"}}
+	metrics, err := runImport(context.Background(), strings.NewReader(csvData), sink, importOptions{overflow: overflow, preserveOrder: true}, progress)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Badge #", "Team"}, metrics["unmapped_columns"])
+	assert.Equal(t, []string{"badge", "team"}, metrics["proposed_columns"])
+	assert.Len(t, sink.users, 2)
+	assert.Equal(t, map[string]string{"cost_center": "CC-1", "office": ""}, sink.users[0].Extra)
+	assert.Equal(t, map[string]string{"cost_center": "", "office": ""}, sink.users[1].Extra)
+
+	// Without schema evolution the columns are only reported
+	appConfig.Ingestion.SchemaEvolution = false
+	sink = &memorySink{}
+	metrics, err = runImport(context.Background(), strings.NewReader(csvData), sink, importOptions{overflow: overflow}, &importProgress{jobID: 8})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Cost Center", "Badge #", "Team"}, metrics["unmapped_columns"])
+	assert.Nil(t, metrics["proposed_columns"])
+	assert.Nil(t, sink.users[0].Extra)
+}
+
+// TestCopyRowsExtraColumns tests that COPY writes the added columns after the model's, empty values as NULL
+func TestCopyRowsExtraColumns(t *testing.T) {
+	users := []UserData{
+		{FirstName: "Jane", Extra: map[string]string{"cost_center": "CC-1", "team": ""}},
+		{FirstName: "John", Extra: map[string]string{"cost_center": "CC-2"}},
+	}
+	extra := extraColumns(users)
+	assert.Equal(t, []string{"cost_center", "team"}, extra)
+
+	rows := copyRows(users, extra)
+	assert.Len(t, rows[0], len(copyColumns)+2)
+	assert.Equal(t, []interface{}{"CC-1", nil}, rows[0][len(copyColumns):])
+	assert.Equal(t, []interface{}{"CC-2", nil}, rows[1][len(copyColumns):])
+}
+
+// TestDecideSchemaChange tests approving and rejecting proposed columns
+func TestDecideSchemaChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := appConfig
+	defer func() { appConfig = previous }()
+	appConfig.Ingestion.SchemaEvolution = true
+
+	store := NewMockSchemaChangeStore(ctrl)
+	store.EXPECT().List(schemaChangePending).Return([]SchemaChange{{ID: 1, Table: "user_data", Column: "team", State: schemaChangePending}}, nil)
+	store.EXPECT().Decide(uint(1), true, "ip:192.0.2.1").Return(&SchemaChange{ID: 1, Column: "team", State: schemaChangeApplied}, nil)
+	store.EXPECT().Decide(uint(1), false, "ip:192.0.2.1").Return(nil, errSchemaChangeDecided)
+	store.EXPECT().Decide(uint(2), false, "ip:192.0.2.1").Return(nil, gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/admin/schema-changes", func(c *gin.Context) {
+		listSchemaChanges(c, store)
+	})
+	r.POST("/api/admin/schema-changes/:id/approve", func(c *gin.Context) {
+		decideSchemaChange(c, store, true)
+	})
+	r.POST("/api/admin/schema-changes/:id/reject", func(c *gin.Context) {
+		decideSchemaChange(c, store, false)
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", "/api/admin/schema-changes?state=pending")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"column":"team"`)
+	assert.Contains(t, w.Body.String(), `"enabled":true`)
+	assert.Equal(t, 400, serve("GET", "/api/admin/schema-changes?state=done").Code)
+
+	w = serve("POST", "/api/admin/schema-changes/1/approve")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"state":"applied"`)
+	assert.Equal(t, 409, serve("POST", "/api/admin/schema-changes/1/reject").Code)
+	assert.Equal(t, 404, serve("POST", "/api/admin/schema-changes/2/reject").Code)
+	assert.Equal(t, 400, serve("POST", "/api/admin/schema-changes/x/approve").Code)
+
+	// Changes can't be decided while the feature is off
+	appConfig.Ingestion.SchemaEvolution = false
+	assert.Equal(t, 409, serve("POST", "/api/admin/schema-changes/1/approve").Code)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	searchPath           = "/api/records/search"
+	searchIndex          = "idx_user_data_search"
+	searchMinSimilarity  = 0.3 // Lowest word similarity of a match, from 0 to 1; pg_trgm's default of 0.6 misses most typos
+	searchMaxQueryLength = 200 // Characters of a search query
+	searchMaxSize        = 100 // Results per page
+)
+
+// searchDocument is the text a record is searched in. It only uses immutable functions so it can be indexed.
+const searchDocument = "lower(coalesce(first_name, '') || ' ' || coalesce(last_name, '') || ' ' || coalesce(email, ''))"
+
+// searchResult is a record matching a search, with how well it matches
+type searchResult struct {
+	UserDatas
+	Score float64 `gorm:"column:score;->" json:"score"` // Word similarity of the query to the record, 1 for an exact match
+}
+
+// migrateSearchIndex enables pg_trgm and creates the trigram index searches use, if they don't exist yet
+func migrateSearchIndex(db *gorm.DB) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return err
+	}
+	return db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING gin ((%s) gin_trgm_ops)",
+		searchIndex, UserData{}.TableName(), searchDocument)).Error
+}
+
+// recordSearch runs the fuzzy searches of the records
+var recordSearch RecordSearcher
+
+// RecordSearcher searches the records by their names and email
+type RecordSearcher interface {
+	Search(query string, offset, limit int) ([]searchResult, int64, error)
+}
+
+// GormRecordSearcher is a concrete implementation of RecordSearcher using GORM
+type GormRecordSearcher struct {
+	db *gorm.DB
+}
+
+// WithContext returns a searcher whose queries use ctx and carry its query tags
+func (s *GormRecordSearcher) WithContext(ctx context.Context) RecordSearcher {
+	return &GormRecordSearcher{db: s.db.WithContext(ctx)}
+}
+
+// Search returns a page of the records whose names or email are similar to query, best matches first,
+// and the number of matching records. The <% operator only matches with the index above the threshold set
+// for the transaction.
+func (s *GormRecordSearcher) Search(query string, offset, limit int) ([]searchResult, int64, error) {
+	results := []searchResult{}
+	var total int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		threshold := strconv.FormatFloat(searchMinSimilarity, 'f', -1, 64)
+		if err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", threshold).Error; err != nil {
+			return err
+		}
+		matches := "? <% " + searchDocument
+		if err := tx.Model(&UserDatas{}).Where(matches, query).Count(&total).Error; err != nil {
+			return err
+		}
+		return tx.Model(&UserDatas{}).Select("*, word_similarity(?, "+searchDocument+") AS score", query).
+			Where(matches, query).Order("score DESC, id ASC").Offset(offset).Limit(limit).Find(&results).Error
+	})
+	return results, total, err
+}
+
+// searchRecords handles GET /api/records/search?q=, matching the words of q against the first and last name
+// and email of the records, tolerating typos and partial words
+func searchRecords(c *gin.Context, searcher RecordSearcher) {
+	query := strings.Join(strings.Fields(strings.ToLower(c.Query("q"))), " ")
+	if query == "" {
+		respondError(c, 400, "Missing search query", "q is required")
+		return
+	}
+	if len([]rune(query)) > searchMaxQueryLength {
+		respondError(c, 400, "Search query too long", fmt.Sprintf("q is limited to %d characters", searchMaxQueryLength))
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		respondError(c, 400, "Invalid page number")
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil || size < 1 || size > searchMaxSize {
+		respondError(c, 400, "Invalid size number", fmt.Sprintf("size must be between 1 and %d", searchMaxSize))
+		return
+	}
+
+	results, total, err := contextStore(c.Request.Context(), searcher).Search(query, (page-1)*size, size)
+	if err != nil {
+		log.WithError(err).Error("Failed to search records")
+		respondError(c, 500, "Failed to search records")
+		return
+	}
+	c.Header(totalCountHeader, strconv.FormatInt(total, 10))
+	setPageLinks(c, page, size, total)
+	respond(c, 200, results, paginationMeta(c.Request.URL, page, size, total))
+}
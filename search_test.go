@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSearchRecords tests that searches are normalized, paged and ranked by the database
+func TestSearchRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := recordSearch
+	defer func() { recordSearch = previous }()
+	searcher := NewMockRecordSearcher(ctrl)
+	recordSearch = searcher
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	searcher.EXPECT().Search("jon do", 5, 5).Return([]searchResult{
+		{UserDatas: UserDatas{ID: 3, FirstName: "John", LastName: "Doe", Email: "john@example.com"}, Score: 0.57},
+	}, int64(6), nil)
+	w := serve("/api/records/search?q=+Jon++DO&page=2&size=5")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "6", w.Header().Get(totalCountHeader))
+	assert.Contains(t, w.Body.String(), `"first_name":"John"`)
+	assert.Contains(t, w.Body.String(), `"score":0.57`)
+	assert.Contains(t, w.Body.String(), `"total_pages":2`)
+	assert.Contains(t, w.Header().Get(linkHeader), `rel="prev"`)
+	assert.NotContains(t, w.Header().Get(linkHeader), `rel="next"`)
+
+	searcher.EXPECT().Search("nobody", 0, 10).Return([]searchResult{}, int64(0), nil)
+	w = serve("/api/records/search?q=nobody")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":[]`)
+
+	assert.Equal(t, 400, serve("/api/records/search?q=+").Code)
+	assert.Equal(t, 400, serve("/api/records/search?q=jane&size=101").Code)
+	assert.Equal(t, 400, serve("/api/records/search?q=jane&page=0").Code)
+
+	searcher.EXPECT().Search("jane", 0, 10).Return(nil, int64(0), errors.New("operator does not exist: unknown <% text"))
+	assert.Equal(t, 500, serve("/api/records/search?q=jane").Code)
+}
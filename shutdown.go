@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// errShuttingDown is the cause of imports cancelled because the server is stopping
+var errShuttingDown = errors.New("server is shutting down")
+
+// contextReader fails reads once its context is done, so readers stop between records when an import is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, context.Cause(r.ctx)
+	}
+	return r.r.Read(p)
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM, then shuts down gracefully: it stops accepting
+// requests, waits for in-flight requests and imports until the shutdown timeout, cancels the imports
+// still running, and closes the database pool and log file
+func serve(handler http.Handler, imports *importManager, db *gorm.DB, logFile io.Closer) {
+	srv := &http.Server{Addr: appConfig.Server.Addr(), Handler: handler, TLSConfig: clientCerts.tlsConfig()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.WithFields(logrus.Fields{"addr": srv.Addr, "tls": appConfig.Server.TLSCertFile != ""}).Info("Starting server")
+		if appConfig.Server.TLSCertFile != "" {
+			serveErr <- srv.ListenAndServeTLS(appConfig.Server.TLSCertFile, appConfig.Server.TLSKeyFile)
+			return
+		}
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log.WithError(err).Fatal("Failed to start the server")
+	case <-ctx.Done():
+	}
+	stop() // A second signal kills the process right away
+
+	timeout := time.Duration(appConfig.Server.ShutdownTimeout)
+	log.WithField("timeout", timeout.String()).Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Error("Failed to finish in-flight requests")
+	}
+	if err := imports.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Warn("Cancelled unfinished imports")
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.WithError(err).Error("Failed to close the database pool")
+		}
+	}
+
+	log.Info("Server stopped")
+	logFile.Close()
+}
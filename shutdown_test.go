@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// stringSource provides a CSV held in memory
+type stringSource string
+
+func (s stringSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(s))), nil
+}
+
+// endlessSource streams a CSV that never ends, one row per read
+type endlessSource struct{}
+
+func (endlessSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(&endlessReader{}), nil
+}
+
+type endlessReader struct {
+	started bool
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		return copy(p, "ID,First Name,Last Name,Email,Age,Gender,Department,Company,Salary,Date Joined,Is Active\n"), nil
+	}
+	time.Sleep(time.Millisecond)
+	return copy(p, "1,John,Doe,john@example.com,30,Male,IT,Corp,50000,2020-01-01,true\n"), nil
+}
+
+// TestContextReader tests that reads fail with the cancellation cause once the context is done
+func TestContextReader(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	r := &contextReader{ctx: ctx, r: strings.NewReader("ID\n1\n")}
+
+	buf := make([]byte, 3)
+	n, err := r.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "ID\n", string(buf[:n]))
+
+	cancel(errShuttingDown)
+	_, err = r.Read(buf)
+	assert.ErrorIs(t, err, errShuttingDown)
+}
+
+// TestImportManagerShutdown tests that shutdown waits for quick imports and cancels those outlasting the timeout
+func TestImportManagerShutdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store, final := newRecordingJobStore(ctrl)
+	overflow, _ := newOverflowHandler("")
+	options := importOptions{overflow: overflow, preserveOrder: true}
+
+	// A quick import finishes before the deadline
+	csvData := "ID,First Name,Last Name,Email,Age,Gender,Department,Company,Salary,Date Joined,Is Active\n" +
+		"1,John,Doe,john@example.com,30,Male,IT,Corp,50000,2020-01-01,true\n"
+	sink := &memorySink{}
+	imports := newImportManager(store, nil)
+	_, err := imports.submit(importTask{job: &ImportJob{}, source: stringSource(csvData), sink: sink, options: options})
+	assert.NoError(t, err)
+	assert.NoError(t, imports.Shutdown(context.Background()))
+	assert.Equal(t, importDone, final().State)
+	assert.Len(t, sink.users, 1)
+
+	// An endless import is cancelled once the deadline passes and marked failed
+	imports = newImportManager(store, nil)
+	_, err = imports.submit(importTask{job: &ImportJob{}, source: endlessSource{}, sink: &memorySink{}, options: options})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, imports.Shutdown(ctx), context.DeadlineExceeded)
+	assert.Equal(t, importFailed, final().State)
+	assert.Contains(t, final().Error, "import cancelled: server is shutting down")
+
+	// Imports submitted after shutdown are not started
+	_, err = imports.submit(importTask{job: &ImportJob{}, source: endlessSource{}, sink: &memorySink{}, options: options})
+	assert.NoError(t, err)
+	imports.Wait()
+	assert.Contains(t, final().Error, "import cancelled")
+}
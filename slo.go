@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sloWindow            = 5 * time.Minute // Rolling window used for the metrics
+	sloMaxSamples        = 10000           // Max samples kept per route within the window
+	sloAvailabilityGoal  = 0.999           // Fraction of requests that must not fail with a 5xx
+	sloLatencyGoal       = 0.99            // Fraction of requests that must finish within sloLatencyThreshold
+	sloLatencyThreshold  = 500 * time.Millisecond
+	unmatchedRouteMetric = "unmatched"
+)
+
+// requestSample is a single observed request
+type requestSample struct {
+	at       time.Time
+	duration time.Duration
+	failed   bool
+}
+
+// sloTracker keeps a rolling window of request samples per route
+type sloTracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	samples map[string][]requestSample
+}
+
+// RouteSLO is the computed SLO state of a single route
+type RouteSLO struct {
+	Route             string  `json:"route"`
+	Requests          int     `json:"requests"`
+	ErrorRate         float64 `json:"error_rate"`
+	P50Ms             float64 `json:"p50_ms"`
+	P95Ms             float64 `json:"p95_ms"`
+	P99Ms             float64 `json:"p99_ms"`
+	ErrorBudgetBurn   float64 `json:"error_budget_burn"`
+	LatencyBudgetBurn float64 `json:"latency_budget_burn"`
+}
+
+// Global tracker fed by sloMiddleware
+var slo = newSLOTracker(sloWindow)
+
+func newSLOTracker(window time.Duration) *sloTracker {
+	return &sloTracker{window: window, samples: map[string][]requestSample{}}
+}
+
+// record adds a sample for the route and drops samples that fell out of the window
+func (t *sloTracker) record(route string, sample requestSample) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	samples := append(t.prune(t.samples[route], sample.at), sample)
+	if len(samples) > sloMaxSamples {
+		samples = samples[len(samples)-sloMaxSamples:]
+	}
+	t.samples[route] = samples
+}
+
+// prune returns the samples that are still within the window at now
+func (t *sloTracker) prune(samples []requestSample, now time.Time) []requestSample {
+	cutoff := now.Add(-t.window)
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].at.After(cutoff) })
+	return samples[i:]
+}
+
+// snapshot computes the current SLO state of every route, sorted by route
+func (t *sloTracker) snapshot(now time.Time) []RouteSLO {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	routes := make([]string, 0, len(t.samples))
+	for route := range t.samples {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	result := make([]RouteSLO, 0, len(routes))
+	for _, route := range routes {
+		samples := t.prune(t.samples[route], now)
+		t.samples[route] = samples
+		if len(samples) == 0 {
+			continue
+		}
+		result = append(result, computeRouteSLO(route, samples))
+	}
+	return result
+}
+
+// computeRouteSLO computes percentiles, error rate and budget burn for the samples
+func computeRouteSLO(route string, samples []requestSample) RouteSLO {
+	durations := make([]time.Duration, len(samples))
+	failed, slow := 0, 0
+	for i, sample := range samples {
+		durations[i] = sample.duration
+		if sample.failed {
+			failed++
+		}
+		if sample.duration > sloLatencyThreshold {
+			slow++
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	total := float64(len(samples))
+	errorRate := float64(failed) / total
+	return RouteSLO{
+		Route:             route,
+		Requests:          len(samples),
+		ErrorRate:         errorRate,
+		P50Ms:             percentileMs(durations, 0.50),
+		P95Ms:             percentileMs(durations, 0.95),
+		P99Ms:             percentileMs(durations, 0.99),
+		ErrorBudgetBurn:   errorRate / (1 - sloAvailabilityGoal),
+		LatencyBudgetBurn: (float64(slow) / total) / (1 - sloLatencyGoal),
+	}
+}
+
+// percentileMs returns the nearest-rank percentile of sorted durations in milliseconds
+func percentileMs(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return float64(sorted[rank].Microseconds()) / 1000
+}
+
+// sloMiddleware records latency and failures per route template
+func sloMiddleware(tracker *sloTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRouteMetric
+		}
+		tracker.record(c.Request.Method+" "+route, requestSample{
+			at:       time.Now(),
+			duration: time.Since(start),
+			failed:   c.Writer.Status() >= 500,
+		})
+	}
+}
+
+// sloReport handles GET /api/admin/slo
+func sloReport(c *gin.Context, tracker *sloTracker) {
+	respond(c, 200, tracker.snapshot(time.Now()), gin.H{
+		"window": tracker.window.String(),
+		"objectives": gin.H{
+			"availability":         sloAvailabilityGoal,
+			"latency":              sloLatencyGoal,
+			"latency_threshold_ms": sloLatencyThreshold.Milliseconds(),
+		},
+	})
+}
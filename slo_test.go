@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestComputeRouteSLO tests percentile, error rate and burn calculations
+func TestComputeRouteSLO(t *testing.T) {
+	now := time.Now()
+	var samples []requestSample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, requestSample{at: now, duration: time.Duration(i) * time.Millisecond, failed: i == 100})
+	}
+
+	result := computeRouteSLO("GET /api/records", samples)
+	assert.Equal(t, 100, result.Requests)
+	assert.Equal(t, 50.0, result.P50Ms)
+	assert.Equal(t, 95.0, result.P95Ms)
+	assert.Equal(t, 99.0, result.P99Ms)
+	assert.InDelta(t, 0.01, result.ErrorRate, 1e-9)
+	assert.InDelta(t, 10.0, result.ErrorBudgetBurn, 1e-6)
+	assert.Equal(t, 0.0, result.LatencyBudgetBurn)
+}
+
+// TestSLOTrackerWindow tests that samples older than the window are dropped
+func TestSLOTrackerWindow(t *testing.T) {
+	tracker := newSLOTracker(time.Minute)
+	now := time.Now()
+	tracker.record("GET /a", requestSample{at: now.Add(-2 * time.Minute), duration: time.Second})
+	tracker.record("GET /a", requestSample{at: now, duration: time.Millisecond})
+
+	snapshot := tracker.snapshot(now)
+	assert.Len(t, snapshot, 1)
+	assert.Equal(t, 1, snapshot[0].Requests)
+}
+
+// TestSLOMiddleware tests that requests are recorded per route template and reported
+func TestSLOMiddleware(t *testing.T) {
+	tracker := newSLOTracker(time.Minute)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sloMiddleware(tracker))
+	r.GET("/items/:id", func(c *gin.Context) { c.JSON(500, gin.H{}) })
+	r.GET("/slo", func(c *gin.Context) { sloReport(c, tracker) })
+
+	for _, path := range []string{"/items/1", "/items/2"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/slo", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	var body struct {
+		Data []RouteSLO `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Data, 1)
+	assert.Equal(t, "GET /items/:id", body.Data[0].Route)
+	assert.Equal(t, 1.0, body.Data[0].ErrorRate)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	slowRequestQueries   = 5   // Slowest statements logged per slow request
+	slowRequestSQLLength = 500 // Characters of each statement's SQL that are logged
+	queryTimerStartKey   = "query_timer:start"
+)
+
+// tracedQuery is a statement a request ran and how long it took
+type tracedQuery struct {
+	SQL        string  `json:"sql"` // With placeholders, never the values
+	DurationMs float64 `json:"duration_ms"`
+	Rows       int64   `json:"rows"`
+}
+
+// requestTrace collects where the time of a request went, for logging it when it exceeds the latency budget
+type requestTrace struct {
+	mu        sync.Mutex
+	queueWait time.Duration
+	queries   int
+	queryTime time.Duration
+	slowest   []tracedQuery // Slowest statements first, at most slowRequestQueries
+}
+
+// requestTraceKey is the context key of the request trace
+type requestTraceKey struct{}
+
+// requestTraceFrom returns the trace carried by ctx, or nil for untraced requests and imports
+func requestTraceFrom(ctx context.Context) *requestTrace {
+	if ctx == nil {
+		return nil
+	}
+	trace, _ := ctx.Value(requestTraceKey{}).(*requestTrace)
+	return trace
+}
+
+// addQueueWait adds time spent waiting for a slot of a concurrency limit
+func (t *requestTrace) addQueueWait(wait time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queueWait += wait
+}
+
+// addQuery counts a statement, keeping it when it is among the slowest
+func (t *requestTrace) addQuery(sql string, duration time.Duration, rows int64) {
+	if t == nil {
+		return
+	}
+	sql = truncateSQL(sql)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queries++
+	t.queryTime += duration
+	t.slowest = append(t.slowest, tracedQuery{SQL: sql, DurationMs: float64(duration.Microseconds()) / 1000, Rows: rows})
+	sort.SliceStable(t.slowest, func(i, j int) bool { return t.slowest[i].DurationMs > t.slowest[j].DurationMs })
+	if len(t.slowest) > slowRequestQueries {
+		t.slowest = t.slowest[:slowRequestQueries]
+	}
+}
+
+// fields returns the diagnostics of a request that took elapsed
+func (t *requestTrace) fields(elapsed time.Duration) logrus.Fields {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return logrus.Fields{
+		"queue_wait_ms":   t.queueWait.Milliseconds(),
+		"db_queries":      t.queries,
+		"db_time_ms":      t.queryTime.Milliseconds(),
+		"other_time_ms":   max(0, elapsed-t.queueWait-t.queryTime).Milliseconds(), // Handler, serialization and client I/O
+		"slowest_queries": append([]tracedQuery(nil), t.slowest...),
+	}
+}
+
+// slowRequestMiddleware traces every request and logs those taking longer than budget as a single
+// "Slow request" entry with the queue wait and SQL timings; a zero budget disables tracing
+func slowRequestMiddleware(budget time.Duration) gin.HandlerFunc {
+	if budget <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		trace := &requestTrace{}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestTraceKey{}, trace))
+		c.Next()
+
+		elapsed := time.Since(start)
+		if elapsed <= budget {
+			return
+		}
+		log.WithFields(trace.fields(elapsed)).WithFields(logrus.Fields{
+			"request_id":  c.GetString(requestIDContextKey),
+			"method":      c.Request.Method,
+			"route":       c.FullPath(),
+			"status":      c.Writer.Status(),
+			"duration_ms": elapsed.Milliseconds(),
+			"budget_ms":   budget.Milliseconds(),
+		}).Warn("Slow request")
+	}
+}
+
+// queryTimer is a GORM plugin timing every statement of a traced request
+type queryTimer struct{}
+
+// Name identifies the plugin
+func (queryTimer) Name() string {
+	return "query_timer"
+}
+
+// Initialize registers the timing callbacks around each kind of statement
+func (queryTimer) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	register := []error{
+		callbacks.Create().Before("gorm:create").Register("query_timer:start_create", startQueryTimer),
+		callbacks.Create().After("gorm:create").Register("query_timer:end_create", endQueryTimer),
+		callbacks.Query().Before("gorm:query").Register("query_timer:start_query", startQueryTimer),
+		callbacks.Query().After("gorm:query").Register("query_timer:end_query", endQueryTimer),
+		callbacks.Update().Before("gorm:update").Register("query_timer:start_update", startQueryTimer),
+		callbacks.Update().After("gorm:update").Register("query_timer:end_update", endQueryTimer),
+		callbacks.Delete().Before("gorm:delete").Register("query_timer:start_delete", startQueryTimer),
+		callbacks.Delete().After("gorm:delete").Register("query_timer:end_delete", endQueryTimer),
+		callbacks.Row().Before("gorm:row").Register("query_timer:start_row", startQueryTimer),
+		callbacks.Row().After("gorm:row").Register("query_timer:end_row", endQueryTimer),
+		callbacks.Raw().Before("gorm:raw").Register("query_timer:start_raw", startQueryTimer),
+		callbacks.Raw().After("gorm:raw").Register("query_timer:end_raw", endQueryTimer),
+	}
+	for _, err := range register {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startQueryTimer notes when the statement of a traced request started
+func startQueryTimer(db *gorm.DB) {
+	if requestTraceFrom(db.Statement.Context) != nil {
+		db.InstanceSet(queryTimerStartKey, time.Now())
+	}
+}
+
+// endQueryTimer adds the statement and its duration to the request trace
+func endQueryTimer(db *gorm.DB) {
+	trace := requestTraceFrom(db.Statement.Context)
+	start, ok := db.InstanceGet(queryTimerStartKey)
+	if trace == nil || !ok {
+		return
+	}
+	trace.addQuery(db.Statement.SQL.String(), time.Since(start.(time.Time)), db.RowsAffected)
+}
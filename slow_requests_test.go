@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestRequestTrace tests that the statement count and time add up while only the slowest statements are kept
+func TestRequestTrace(t *testing.T) {
+	trace := &requestTrace{}
+	for i := 1; i <= 7; i++ {
+		trace.addQuery("SELECT "+strings.Repeat("x", i), time.Duration(i)*time.Millisecond, int64(i))
+	}
+	trace.addQuery(strings.Repeat("y", slowRequestSQLLength+1), 0, 0)
+	trace.addQueueWait(20 * time.Millisecond)
+
+	fields := trace.fields(100 * time.Millisecond)
+	assert.Equal(t, 8, fields["db_queries"])
+	assert.Equal(t, int64(28), fields["db_time_ms"])
+	assert.Equal(t, int64(20), fields["queue_wait_ms"])
+	assert.Equal(t, int64(52), fields["other_time_ms"])
+	slowest := fields["slowest_queries"].([]tracedQuery)
+	assert.Len(t, slowest, slowRequestQueries)
+	assert.Equal(t, tracedQuery{SQL: "SELECT xxxxxxx", DurationMs: 7, Rows: 7}, slowest[0])
+
+	// Untraced requests and imports have no trace to add to
+	var untraced *requestTrace
+	untraced.addQuery("SELECT 1", time.Second, 1)
+	untraced.addQueueWait(time.Second)
+}
+
+// TestQueryTimer tests that the statements of traced requests are timed
+func TestQueryTimer(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(queryTimer{}))
+
+	trace := &requestTrace{}
+	ctx := context.WithValue(context.Background(), requestTraceKey{}, trace)
+	db.WithContext(ctx).Where("department = ?", "IT").Find(&[]UserDatas{})
+	db.WithContext(ctx).Exec("SELECT 1")
+	db.Find(&[]UserDatas{})
+
+	assert.Equal(t, 2, trace.queries)
+	assert.Contains(t, []string{trace.slowest[0].SQL, trace.slowest[1].SQL}, `SELECT * FROM "user_data" WHERE department = $1`)
+}
+
+// TestSlowRequestMiddleware tests that only requests over the budget are logged, with their diagnostics
+func TestSlowRequestMiddleware(t *testing.T) {
+	hook := logtest.NewLocal(log)
+	defer hook.Reset()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestIDMiddleware(), slowRequestMiddleware(20*time.Millisecond))
+	r.GET("/slow", func(c *gin.Context) {
+		requestTraceFrom(c.Request.Context()).addQuery("SELECT pg_sleep(0.03)", 30*time.Millisecond, 1)
+		time.Sleep(30 * time.Millisecond)
+		c.Status(200)
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		c.Status(200)
+	})
+
+	serve := func(path string) {
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("/fast")
+	assert.Empty(t, hook.AllEntries())
+
+	serve("/slow")
+	entry := hook.LastEntry()
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "Slow request", entry.Message)
+		assert.Equal(t, logrus.WarnLevel, entry.Level)
+		assert.Equal(t, "/slow", entry.Data["route"])
+		assert.Equal(t, int64(20), entry.Data["budget_ms"])
+		assert.Equal(t, 1, entry.Data["db_queries"])
+		assert.Equal(t, "SELECT pg_sleep(0.03)", entry.Data["slowest_queries"].([]tracedQuery)[0].SQL)
+	}
+}
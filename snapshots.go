@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// errSnapshotTableMissing is returned when the copy of a snapshot was dropped outside the API
+var errSnapshotTableMissing = errors.New("snapshot table no longer exists")
+
+// Snapshot is a named copy of the user_data table that it can be restored from
+type Snapshot struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name       string     `gorm:"size:100;uniqueIndex" json:"name"`
+	CopyTable  string     `gorm:"size:63" json:"table"` // Table holding the copied rows, e.g. user_data_snapshot_3
+	Rows       int64      `json:"rows"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `gorm:"size:255" json:"created_by,omitempty"`
+	RestoredAt *time.Time `json:"restored_at"`
+}
+
+// TableName specifies the name of the table in the database
+func (Snapshot) TableName() string {
+	return "snapshots"
+}
+
+// snapshots keeps the named copies of the records
+var snapshots SnapshotStore
+
+// SnapshotStore creates, lists, restores and deletes the snapshots of the user_data table
+type SnapshotStore interface {
+	Create(name, by string) (*Snapshot, error)
+	List() ([]Snapshot, error)
+	Restore(id uint) (*Snapshot, error)
+	Delete(id uint) error
+	Records(id uint, afterID, limit int) ([]UserDatas, error)
+}
+
+// GormSnapshotStore is a concrete implementation of SnapshotStore using GORM
+type GormSnapshotStore struct {
+	db *gorm.DB
+}
+
+// WithContext returns a store whose queries use ctx and carry its query tags
+func (s *GormSnapshotStore) WithContext(ctx context.Context) SnapshotStore {
+	return &GormSnapshotStore{db: s.db.WithContext(ctx)}
+}
+
+// Create copies user_data into a new table with CREATE TABLE AS and records it under name
+func (s *GormSnapshotStore) Create(name, by string) (*Snapshot, error) {
+	snapshot := Snapshot{Name: name, CreatedBy: by}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&snapshot).Error; err != nil {
+			return err
+		}
+		snapshot.CopyTable = fmt.Sprintf("%s_snapshot_%d", UserData{}.TableName(), snapshot.ID)
+		copied := tx.Exec(fmt.Sprintf("CREATE TABLE %s AS TABLE %s",
+			pgx.Identifier{snapshot.CopyTable}.Sanitize(), pgx.Identifier{UserData{}.TableName()}.Sanitize()))
+		if copied.Error != nil {
+			return copied.Error
+		}
+		snapshot.Rows = copied.RowsAffected
+		return tx.Save(&snapshot).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// List lists the snapshots, oldest first
+func (s *GormSnapshotStore) List() ([]Snapshot, error) {
+	var list []Snapshot
+	err := s.db.Order("id ASC").Find(&list).Error
+	return list, err
+}
+
+// tableColumn is a column of a table with its type as Postgres formats it, e.g. character varying(150)
+type tableColumn struct {
+	Name string
+	Type string
+}
+
+// tableColumns reads the columns of table in order, returning none if the table doesn't exist
+func tableColumns(tx *gorm.DB, table string) ([]tableColumn, error) {
+	var columns []tableColumn
+	err := tx.Raw(`SELECT attname AS name, format_type(atttypid, atttypmod) AS type FROM pg_attribute
+		WHERE attrelid = to_regclass(?) AND attnum > 0 AND NOT attisdropped ORDER BY attnum`,
+		pgx.Identifier{table}.Sanitize()).Scan(&columns).Error
+	return columns, err
+}
+
+// restoreColumns lists the columns of user_data a snapshot is restored into, those the snapshot also has,
+// and the values selected for them from the snapshot. A value is cast to the current type of its column
+// when it changed since the snapshot; text dates of date_joined are converted as the migration converts them.
+func restoreColumns(snapshot, current []tableColumn) (columns, values string) {
+	types := make(map[string]string, len(snapshot))
+	for _, column := range snapshot {
+		types[column.Name] = column.Type
+	}
+
+	var names, selected []string
+	for _, column := range current {
+		from, ok := types[column.Name]
+		if !ok {
+			continue
+		}
+		name := pgx.Identifier{column.Name}.Sanitize()
+		value := name
+		switch {
+		case from == column.Type:
+		case column.Name == "date_joined" && column.Type == "date" && (from == "text" || strings.HasPrefix(from, "character varying")):
+			value = dateColumnConversion
+		default:
+			value = name + "::" + column.Type
+		}
+		names = append(names, name)
+		selected = append(selected, value)
+	}
+	return strings.Join(names, ", "), strings.Join(selected, ", ")
+}
+
+// Restore replaces the rows of user_data with those of the snapshot in one transaction and moves the
+// id sequence past them. Columns added since the snapshot are left empty, columns dropped since are
+// skipped and columns whose type changed are cast to their current type. It returns gorm.ErrRecordNotFound
+// for unknown snapshots.
+func (s *GormSnapshotStore) Restore(id uint) (*Snapshot, error) {
+	var snapshot Snapshot
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&snapshot, id).Error; err != nil {
+			return err
+		}
+
+		copied, err := tableColumns(tx, snapshot.CopyTable)
+		if err != nil {
+			return err
+		}
+		if len(copied) == 0 {
+			return errSnapshotTableMissing
+		}
+		current, err := tableColumns(tx, UserData{}.TableName())
+		if err != nil {
+			return err
+		}
+		columns, values := restoreColumns(copied, current)
+		if columns == "" {
+			return fmt.Errorf("snapshot %d has none of the columns of %s", snapshot.ID, UserData{}.TableName())
+		}
+
+		table := pgx.Identifier{UserData{}.TableName()}.Sanitize()
+		if err := tx.Exec("TRUNCATE " + table).Error; err != nil {
+			return err
+		}
+		restored := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", table, columns, values, pgx.Identifier{snapshot.CopyTable}.Sanitize()))
+		if restored.Error != nil {
+			return restored.Error
+		}
+		err = tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence(?, 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s", table),
+			UserData{}.TableName()).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		snapshot.RestoredAt = &now
+		snapshot.Rows = restored.RowsAffected
+		return tx.Save(&snapshot).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Delete drops the snapshot's table and record, returning gorm.ErrRecordNotFound for unknown snapshots
+func (s *GormSnapshotStore) Delete(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var snapshot Snapshot
+		if err := tx.First(&snapshot, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DROP TABLE IF EXISTS " + pgx.Identifier{snapshot.CopyTable}.Sanitize()).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&snapshot).Error
+	})
+}
+
+// Records reads a page of the snapshot's rows with IDs above afterID, returning gorm.ErrRecordNotFound
+// for unknown snapshots
+func (s *GormSnapshotStore) Records(id uint, afterID, limit int) ([]UserDatas, error) {
+	var snapshot Snapshot
+	if err := s.db.First(&snapshot, id).Error; err != nil {
+		return nil, err
+	}
+	var records []UserDatas
+	err := s.db.Table(snapshot.CopyTable).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&records).Error
+	return records, err
+}
+
+// snapshotID parses the :id path parameter, responding with 400 when it isn't a positive integer
+func snapshotID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		respondError(c, 400, "Invalid snapshot ID", "id must be a positive integer")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// createSnapshot handles POST /api/admin/snapshots, copying the records under the name of the body
+func createSnapshot(c *gin.Context, store SnapshotStore) {
+	var req struct {
+		Name string `json:"name" binding:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, 400, "Invalid snapshot", err.Error())
+		return
+	}
+
+	snapshot, err := store.Create(req.Name, clientKey(c))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		respondError(c, 409, "Snapshot name already exists", "snapshot "+strconv.Quote(req.Name)+" exists, delete it or choose another name")
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("snapshot", req.Name).Error("Failed to create snapshot")
+		respondError(c, 500, "Failed to create snapshot", err.Error())
+		return
+	}
+
+	log.WithFields(logrus.Fields{"snapshot": snapshot.Name, "rows": snapshot.Rows, "created_by": snapshot.CreatedBy}).Info("Created snapshot")
+	c.Header("Location", fmt.Sprintf("/api/admin/snapshots/%d", snapshot.ID))
+	respond(c, 201, snapshot, nil)
+}
+
+// listSnapshots handles GET /api/admin/snapshots
+func listSnapshots(c *gin.Context, store SnapshotStore) {
+	list, err := store.List()
+	if err != nil {
+		log.WithError(err).Error("Failed to list snapshots")
+		respondError(c, 500, "Failed to list snapshots")
+		return
+	}
+	if list == nil {
+		list = []Snapshot{}
+	}
+	respond(c, 200, list, gin.H{"count": len(list)})
+}
+
+// restoreSnapshot handles POST /api/admin/snapshots/:id/restore, replacing the records with the snapshot's
+func restoreSnapshot(c *gin.Context, store SnapshotStore) {
+	id, ok := snapshotID(c)
+	if !ok {
+		return
+	}
+
+	snapshot, err := store.Restore(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Snapshot not found")
+		return
+	}
+	if errors.Is(err, errSnapshotTableMissing) {
+		respondError(c, 409, "Snapshot can't be restored", err.Error())
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("snapshot_id", id).Error("Failed to restore snapshot")
+		respondError(c, 500, "Failed to restore snapshot", err.Error())
+		return
+	}
+
+	log.WithFields(logrus.Fields{"snapshot": snapshot.Name, "rows": snapshot.Rows, "client": clientKey(c)}).Warn("Restored snapshot")
+	respond(c, 200, snapshot, nil)
+}
+
+// deleteSnapshot handles DELETE /api/admin/snapshots/:id
+func deleteSnapshot(c *gin.Context, store SnapshotStore) {
+	id, ok := snapshotID(c)
+	if !ok {
+		return
+	}
+
+	err := store.Delete(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Snapshot not found")
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("snapshot_id", id).Error("Failed to delete snapshot")
+		respondError(c, 500, "Failed to delete snapshot", err.Error())
+		return
+	}
+	c.Status(204)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestSnapshots tests creating, listing, restoring and deleting snapshots of the records
+func TestSnapshots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	snapshot := &Snapshot{ID: 3, Name: "before-reimport", CopyTable: "user_data_snapshot_3", Rows: 120}
+	store := NewMockSnapshotStore(ctrl)
+	store.EXPECT().Create("before-reimport", "ip:192.0.2.1").Return(snapshot, nil)
+	store.EXPECT().Create("before-reimport", "ip:192.0.2.1").Return(nil, &pgconn.PgError{Code: pgUniqueViolation})
+	store.EXPECT().List().Return([]Snapshot{*snapshot}, nil)
+	store.EXPECT().Restore(uint(3)).Return(snapshot, nil)
+	store.EXPECT().Restore(uint(4)).Return(nil, gorm.ErrRecordNotFound)
+	store.EXPECT().Restore(uint(5)).Return(nil, errSnapshotTableMissing)
+	store.EXPECT().Delete(uint(3)).Return(nil)
+	store.EXPECT().Delete(uint(4)).Return(gorm.ErrRecordNotFound)
+	store.EXPECT().Delete(uint(6)).Return(errors.New("connection refused"))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/admin/snapshots", func(c *gin.Context) {
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		createSnapshot(c, store)
+	})
+	r.GET("/api/admin/snapshots", func(c *gin.Context) {
+		listSnapshots(c, store)
+	})
+	r.POST("/api/admin/snapshots/:id/restore", func(c *gin.Context) {
+		restoreSnapshot(c, store)
+	})
+	r.DELETE("/api/admin/snapshots/:id", func(c *gin.Context) {
+		deleteSnapshot(c, store)
+	})
+
+	w := serveRecord(r, "POST", "/api/admin/snapshots", `{"name":"before-reimport"}`)
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "/api/admin/snapshots/3", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"table":"user_data_snapshot_3"`)
+	assert.Equal(t, 409, serveRecord(r, "POST", "/api/admin/snapshots", `{"name":"before-reimport"}`).Code)
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/admin/snapshots", `{}`).Code)
+
+	w = serveRecord(r, "GET", "/api/admin/snapshots", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+
+	w = serveRecord(r, "POST", "/api/admin/snapshots/3/restore", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"rows":120`)
+	assert.Equal(t, 404, serveRecord(r, "POST", "/api/admin/snapshots/4/restore", "").Code)
+	assert.Equal(t, 409, serveRecord(r, "POST", "/api/admin/snapshots/5/restore", "").Code)
+	assert.Equal(t, 400, serveRecord(r, "POST", "/api/admin/snapshots/0/restore", "").Code)
+
+	assert.Equal(t, 204, serveRecord(r, "DELETE", "/api/admin/snapshots/3", "").Code)
+	assert.Equal(t, 404, serveRecord(r, "DELETE", "/api/admin/snapshots/4", "").Code)
+	assert.Equal(t, 500, serveRecord(r, "DELETE", "/api/admin/snapshots/6", "").Code)
+}
+
+// TestRestoreColumns tests restoring a snapshot taken before date_joined became a date and the columns changed
+func TestRestoreColumns(t *testing.T) {
+	snapshot := []tableColumn{
+		{Name: "id", Type: "bigint"},
+		{Name: "email", Type: "character varying(150)"},
+		{Name: "date_joined", Type: "text"},
+		{Name: "age", Type: "integer"},
+		{Name: "badge", Type: "text"},
+	}
+	current := []tableColumn{
+		{Name: "id", Type: "bigint"},
+		{Name: "email", Type: "character varying(150)"},
+		{Name: "date_joined", Type: "date"},
+		{Name: "age", Type: "bigint"},
+		{Name: "import_id", Type: "bigint"},
+	}
+
+	columns, values := restoreColumns(snapshot, current)
+	assert.Equal(t, `"id", "email", "date_joined", "age"`, columns)
+	assert.Equal(t, `"id", "email", `+dateColumnConversion+`, "age"::bigint`, values)
+
+	columns, values = restoreColumns(current, current)
+	assert.Equal(t, `"id", "email", "date_joined", "age", "import_id"`, columns)
+	assert.Equal(t, columns, values)
+
+	columns, _ = restoreColumns([]tableColumn{{Name: "badge", Type: "text"}}, current)
+	assert.Empty(t, columns)
+}
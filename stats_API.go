@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// pivotDimensions maps the allowed rows/cols query values to SQL expressions.
+// Only whitelisted expressions are ever interpolated into the query.
+var pivotDimensions = map[string]string{
+	"department": "COALESCE(department, '')",
+	"company":    "COALESCE(company, '')",
+	"gender":     "COALESCE(gender, '')",
+	"is_active":  "CAST(is_active AS TEXT)",
+}
+
+// pivotMetrics maps the allowed metric query values to SQL aggregate expressions
+var pivotMetrics = map[string]string{
+	"count":      "COUNT(*)",
+	"sum_salary": "SUM(salary)",
+	"avg_salary": "AVG(salary)",
+	"min_salary": "MIN(salary)",
+	"max_salary": "MAX(salary)",
+}
+
+// pivotCell is a single row of the grouped pivot query
+type pivotCell struct {
+	RowKey string
+	ColKey string
+	Value  float64
+}
+
+// buildPivot turns the grouped cells into a matrix indexed by sorted row and column keys
+func buildPivot(cells []pivotCell) ([]string, []string, [][]float64) {
+	rowIndex := map[string]int{}
+	colIndex := map[string]int{}
+	for _, cell := range cells {
+		rowIndex[cell.RowKey] = 0
+		colIndex[cell.ColKey] = 0
+	}
+
+	rowKeys := sortedKeys(rowIndex)
+	colKeys := sortedKeys(colIndex)
+	for i, key := range rowKeys {
+		rowIndex[key] = i
+	}
+	for i, key := range colKeys {
+		colIndex[key] = i
+	}
+
+	matrix := make([][]float64, len(rowKeys))
+	for i := range matrix {
+		matrix[i] = make([]float64, len(colKeys))
+	}
+	for _, cell := range cells {
+		matrix[rowIndex[cell.RowKey]][colIndex[cell.ColKey]] = cell.Value
+	}
+
+	return rowKeys, colKeys, matrix
+}
+
+// sortedKeys returns the keys of the map in ascending order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pivotStats handles GET /api/stats/pivot?rows=department&cols=gender&metric=count
+func pivotStats(c *gin.Context, db Database) {
+	rows := c.DefaultQuery("rows", "department")
+	cols := c.DefaultQuery("cols", "gender")
+	metric := c.DefaultQuery("metric", "count")
+
+	rowExpr, ok := pivotDimensions[rows]
+	if !ok {
+		log.WithField("rows", rows).Error("Invalid pivot rows dimension")
+		respondError(c, 400, "Invalid rows dimension")
+		return
+	}
+
+	colExpr, ok := pivotDimensions[cols]
+	if !ok {
+		log.WithField("cols", cols).Error("Invalid pivot cols dimension")
+		respondError(c, 400, "Invalid cols dimension")
+		return
+	}
+
+	if rows == cols {
+		log.WithField("rows", rows).Error("Pivot rows and cols must differ")
+		respondError(c, 400, "rows and cols must be different dimensions")
+		return
+	}
+
+	metricExpr, ok := pivotMetrics[metric]
+	if !ok {
+		log.WithField("metric", metric).Error("Invalid pivot metric")
+		respondError(c, 400, "Invalid metric")
+		return
+	}
+
+	// Compute every cell with a single grouped query
+	var cells []pivotCell
+	query := fmt.Sprintf("%s AS row_key, %s AS col_key, %s AS value", rowExpr, colExpr, metricExpr)
+	if err := db.Model(&UserDatas{}).Select(query).Group(rowExpr + ", " + colExpr).Scan(&cells).Error; err != nil {
+		log.WithError(err).Error("Failed to compute pivot")
+		respondError(c, 500, "Failed to compute pivot")
+		return
+	}
+
+	rowKeys, colKeys, matrix := buildPivot(cells)
+
+	log.WithFields(logrus.Fields{"rows": rows, "cols": cols, "metric": metric}).Info("Pivot computed successfully")
+	respond(c, 200, gin.H{
+		"row_keys": rowKeys,
+		"col_keys": colKeys,
+		"values":   matrix,
+	}, gin.H{"rows": rows, "cols": cols, "metric": metric})
+}
+
+// salaryAggregates are the SQL aggregates of GET /api/stats/salary, computed over the salaries of each group
+const salaryAggregates = "COUNT(salary) AS count, COALESCE(MIN(salary), 0) AS min, COALESCE(MAX(salary), 0) AS max, " +
+	"COALESCE(AVG(salary), 0) AS avg, " +
+	"COALESCE(PERCENTILE_CONT(0.25) WITHIN GROUP (ORDER BY salary), 0) AS p25, " +
+	"COALESCE(PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY salary), 0) AS p50, " +
+	"COALESCE(PERCENTILE_CONT(0.75) WITHIN GROUP (ORDER BY salary), 0) AS p75, " +
+	"COALESCE(PERCENTILE_CONT(0.9) WITHIN GROUP (ORDER BY salary), 0) AS p90, " +
+	"COALESCE(PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY salary), 0) AS p99"
+
+// salaryStat is the salary distribution of a group, or of every record when not grouped
+type salaryStat struct {
+	Key   string  `json:"key,omitempty"`
+	Count int64   `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	P25   float64 `json:"p25"`
+	P50   float64 `json:"p50"`
+	P75   float64 `json:"p75"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+// headcountStat is the number of records of a group and how many of them are active
+type headcountStat struct {
+	Key    string `json:"key"`
+	Count  int64  `json:"count"`
+	Active int64  `json:"active"`
+}
+
+// salaryStats handles GET /api/stats/salary?group_by=department, returning the salary distribution
+// of every record, or of each group when group_by is given
+func salaryStats(c *gin.Context, db Database) {
+	groupBy := c.Query("group_by")
+	if groupBy == "" {
+		var stats []salaryStat
+		if err := db.Model(&UserDatas{}).Select(salaryAggregates).Scan(&stats).Error; err != nil {
+			log.WithError(err).Error("Failed to compute salary stats")
+			respondError(c, 500, "Failed to compute salary stats")
+			return
+		}
+		var stat salaryStat
+		if len(stats) > 0 {
+			stat = stats[0]
+		}
+		respond(c, 200, stat, nil)
+		return
+	}
+
+	groupExpr, ok := pivotDimensions[groupBy]
+	if !ok {
+		log.WithField("group_by", groupBy).Error("Invalid salary stats group")
+		respondError(c, 400, "Invalid group_by dimension")
+		return
+	}
+
+	var stats []salaryStat
+	if err := db.Model(&UserDatas{}).Select(groupExpr + " AS key, " + salaryAggregates).Group(groupExpr).Order("key").Scan(&stats).Error; err != nil {
+		log.WithError(err).Error("Failed to compute salary stats")
+		respondError(c, 500, "Failed to compute salary stats")
+		return
+	}
+	respond(c, 200, stats, gin.H{"group_by": groupBy})
+}
+
+// headcountAggregates counts the records of a group and its active ones
+const headcountAggregates = "COUNT(*) AS count, COUNT(*) FILTER (WHERE is_active) AS active"
+
+// headcountStats handles GET /api/stats/headcount?group_by=department, counting the records of each group
+func headcountStats(c *gin.Context, db Database) {
+	groupBy := c.DefaultQuery("group_by", "department")
+	groupExpr, ok := pivotDimensions[groupBy]
+	if !ok {
+		log.WithField("group_by", groupBy).Error("Invalid headcount group")
+		respondError(c, 400, "Invalid group_by dimension")
+		return
+	}
+
+	var stats []headcountStat
+	if err := db.Model(&UserDatas{}).Select(groupExpr + " AS key, " + headcountAggregates).Group(groupExpr).Order("key").Scan(&stats).Error; err != nil {
+		log.WithError(err).Error("Failed to compute headcount")
+		respondError(c, 500, "Failed to compute headcount")
+		return
+	}
+	respond(c, 200, stats, gin.H{"group_by": groupBy})
+}
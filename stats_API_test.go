@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestBuildPivot tests that grouped cells are laid out as a sorted matrix
+func TestBuildPivot(t *testing.T) {
+	cells := []pivotCell{
+		{RowKey: "IT", ColKey: "Male", Value: 3},
+		{RowKey: "HR", ColKey: "Female", Value: 2},
+		{RowKey: "IT", ColKey: "Female", Value: 1},
+	}
+
+	rowKeys, colKeys, matrix := buildPivot(cells)
+	assert.Equal(t, []string{"HR", "IT"}, rowKeys)
+	assert.Equal(t, []string{"Female", "Male"}, colKeys)
+	assert.Equal(t, [][]float64{{2, 0}, {1, 3}}, matrix)
+}
+
+// TestPivotStats tests the pivot endpoint with a mocked grouped query
+func TestPivotStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Create a mock instance of Database
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Model(gomock.Any()).Return(mockDB)
+	mockDB.EXPECT().Select(gomock.Any()).Return(mockDB)
+	mockDB.EXPECT().Group(gomock.Any()).Return(mockDB)
+	mockDB.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest interface{}) *gorm.DB {
+		*dest.(*[]pivotCell) = []pivotCell{{RowKey: "IT", ColKey: "Male", Value: 4}}
+		return &gorm.DB{}
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/stats/pivot?rows=department&cols=gender&metric=count", nil)
+	r.ServeHTTP(w, req)
+
+	// Ensure status code 200 is returned with the matrix
+	assert.Equal(t, 200, w.Code)
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, []interface{}{"IT"}, body.Data["row_keys"])
+	assert.Equal(t, []interface{}{[]interface{}{4.0}}, body.Data["values"])
+	assert.Equal(t, "count", body.Meta["metric"])
+}
+
+// TestPivotStatsInvalidParams tests that unknown or duplicate dimensions are rejected
+func TestPivotStatsInvalidParams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	for _, query := range []string{"rows=salary", "cols=email", "rows=gender&cols=gender", "metric=median"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/stats/pivot?"+query, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 400, w.Code, query)
+	}
+}
+
+// TestSalaryStats tests the salary distribution of every record and per group
+func TestSalaryStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Model(gomock.Any()).Return(mockDB).Times(2)
+	mockDB.EXPECT().Select(gomock.Any()).DoAndReturn(func(query interface{}, args ...interface{}) Database {
+		assert.Contains(t, query, "PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY salary)")
+		return mockDB
+	}).Times(2)
+	mockDB.EXPECT().Group("COALESCE(department, '')").Return(mockDB)
+	mockDB.EXPECT().Order("key").Return(mockDB)
+	gomock.InOrder(
+		mockDB.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest interface{}) *gorm.DB {
+			*dest.(*[]salaryStat) = []salaryStat{{Count: 3, Min: 40000, Max: 60000, Avg: 50000, P50: 50000}}
+			return &gorm.DB{}
+		}),
+		mockDB.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest interface{}) *gorm.DB {
+			*dest.(*[]salaryStat) = []salaryStat{{Key: "HR", Count: 1, P50: 60000}, {Key: "IT", Count: 2, P50: 45000}}
+			return &gorm.DB{}
+		}),
+	)
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/stats/salary", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":{"count":3,"min":40000,"max":60000,"avg":50000,"p25":0,"p50":50000`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/stats/salary?group_by=department", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `{"key":"IT","count":2`)
+	assert.Contains(t, w.Body.String(), `"group_by":"department"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/stats/salary?group_by=email", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+// TestHeadcountStats tests counting records per group
+func TestHeadcountStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDB := NewMockDatabase(ctrl)
+	mockDB.EXPECT().Model(gomock.Any()).Return(mockDB)
+	mockDB.EXPECT().Select(gomock.Any()).Return(mockDB)
+	mockDB.EXPECT().Group("COALESCE(company, '')").Return(mockDB)
+	mockDB.EXPECT().Order("key").Return(mockDB)
+	mockDB.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest interface{}) *gorm.DB {
+		*dest.(*[]headcountStat) = []headcountStat{{Key: "Corp", Count: 5, Active: 4}}
+		return &gorm.DB{}
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(mockDB, NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/stats/headcount?group_by=company", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":[{"key":"Corp","count":5,"active":4}]`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/stats/headcount?group_by=salary", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
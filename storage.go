@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Supported artifact storage backends
+const (
+	storageLocal = "local"
+	storageS3    = "s3"
+	storageGCS   = "gcs" // Google Cloud Storage through its S3-compatible XML API with HMAC keys
+)
+
+// Default object storage endpoints per backend
+var defaultStorageEndpoints = map[string]string{
+	storageS3:  "s3.amazonaws.com",
+	storageGCS: "storage.googleapis.com",
+}
+
+// errBlobNotFound is returned when a key doesn't exist in the blob store
+var errBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores artifacts such as uploaded originals, error reports and exports by key,
+// so handlers don't depend on where or how files are kept
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// setupBlobStore creates the blob store selected by the storage configuration
+func setupBlobStore(config StorageConfig) (BlobStore, error) {
+	switch config.Backend {
+	case storageLocal:
+		return newLocalBlobStore(config.LocalPath)
+	case storageS3, storageGCS:
+		return newS3BlobStore(config)
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", config.Backend)
+	}
+}
+
+// localBlobStore keeps blobs as files below a root directory
+type localBlobStore struct {
+	root string
+}
+
+// newLocalBlobStore creates a local blob store, creating the root directory if needed
+func newLocalBlobStore(root string) (*localBlobStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &localBlobStore{root: root}, nil
+}
+
+// path maps a key to a file below the root, refusing keys that escape it
+func (s *localBlobStore) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file and renames it into place, so readers never see partial blobs
+func (s *localBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get opens the blob for reading
+func (s *localBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", errBlobNotFound, key)
+	}
+	return file, err
+}
+
+// Delete removes the blob; deleting a missing blob is not an error
+func (s *localBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// s3BlobStore keeps blobs as objects in an S3-compatible bucket
+type s3BlobStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// newS3BlobStore creates a blob store for an S3 or GCS bucket
+func newS3BlobStore(config StorageConfig) (*s3BlobStore, error) {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultStorageEndpoints[config.Backend]
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: !config.Insecure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", config.Backend, err)
+	}
+	return &s3BlobStore{client: client, bucket: config.Bucket}, nil
+}
+
+// Put uploads the blob as an object
+func (s *s3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+// Get opens the object for reading, checking first that it exists
+func (s *s3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%w: %s", errBlobNotFound, key)
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+// Delete removes the object; deleting a missing object is not an error
+func (s *s3BlobStore) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
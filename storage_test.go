@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLocalBlobStore tests storing, reading and deleting blobs on local disk
+func TestLocalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := newLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+
+	assert.NoError(t, blobs.Put(ctx, "uploads/a.csv", strings.NewReader("ID\n1\n"), 5))
+	reader, err := blobs.Get(ctx, "uploads/a.csv")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "ID\n1\n", string(data))
+
+	assert.NoError(t, blobs.Delete(ctx, "uploads/a.csv"))
+	assert.NoError(t, blobs.Delete(ctx, "uploads/a.csv"))
+	_, err = blobs.Get(ctx, "uploads/a.csv")
+	assert.ErrorIs(t, err, errBlobNotFound)
+
+	// Keys must stay within the root directory
+	assert.Error(t, blobs.Put(ctx, "../escape.csv", strings.NewReader(""), 0))
+}
+
+// TestSetupBlobStore tests selecting the backend from the configuration
+func TestSetupBlobStore(t *testing.T) {
+	blobs, err := setupBlobStore(StorageConfig{Backend: storageLocal, LocalPath: t.TempDir()})
+	assert.NoError(t, err)
+	assert.IsType(t, &localBlobStore{}, blobs)
+
+	blobs, err = setupBlobStore(StorageConfig{Backend: storageGCS, Bucket: "artifacts", AccessKey: "key", SecretKey: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "storage.googleapis.com", blobs.(*s3BlobStore).client.EndpointURL().Host)
+
+	_, err = setupBlobStore(StorageConfig{Backend: "ftp"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Upload stream states
+const (
+	uploadStreamOpen      = "open"
+	uploadStreamCompleted = "completed" // No more batches are accepted
+)
+
+const (
+	uploadStreamsPath        = "/api/upload-streams"
+	uploadStreamMaxBatchRows = 10000 // Rows per batch, bounding the memory and transaction of a batch
+)
+
+// uploadStreams stores the upload streams; nil in tests, where streaming uploads aren't available
+var uploadStreams UploadStreamStore
+
+// errWatermarkMoved is returned when another request committed rows of a stream since it was loaded
+var errWatermarkMoved = errors.New("the watermark of the stream moved")
+
+// UploadStream is a streaming upload in the upload_streams table. Clients send the rows in batches,
+// each starting at an offset in the stream, and resume from the watermark after a failure.
+type UploadStream struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string     `gorm:"size:255" json:"name"`         // Recorded as the source file of the rows
+	DateFormats  string     `gorm:"size:100" json:"date_formats"` // Formats of the dates in the rows, tried in order
+	State        string     `gorm:"size:10" json:"state"`
+	Watermark    int64      `json:"watermark"` // Rows of the stream committed, inserted or rejected; the next batch starts here
+	RowsInserted int64      `json:"rows_inserted"`
+	RowsRejected int64      `json:"rows_rejected"`
+	CreatedBy    string     `gorm:"size:255" json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+}
+
+// TableName specifies the name of the table in the database
+func (UploadStream) TableName() string {
+	return "upload_streams"
+}
+
+// UploadStreamStore interface defines how upload streams are persisted and their batches committed
+type UploadStreamStore interface {
+	Create(stream *UploadStream) error
+	Get(id uint) (*UploadStream, error)
+	Commit(id uint, from, to int64, users []UserData, rejected int) error
+	Complete(id uint) (*UploadStream, error)
+}
+
+// GormUploadStreamStore is a concrete implementation of UploadStreamStore using GORM
+type GormUploadStreamStore struct {
+	db *gorm.DB
+}
+
+// Create records a new stream
+func (store *GormUploadStreamStore) Create(stream *UploadStream) error {
+	return store.db.Create(stream).Error
+}
+
+// Get loads a stream by ID, returning gorm.ErrRecordNotFound when it doesn't exist
+func (store *GormUploadStreamStore) Get(id uint) (*UploadStream, error) {
+	var stream UploadStream
+	if err := store.db.First(&stream, id).Error; err != nil {
+		return nil, err
+	}
+	return &stream, nil
+}
+
+// Commit inserts users and moves the watermark of an open stream from from to to in one transaction, so
+// acknowledged rows are never written twice nor lost. It returns errWatermarkMoved when the watermark
+// isn't at from anymore or the stream was completed; updating the stream first also makes concurrent
+// batches of the stream wait for each other.
+func (store *GormUploadStreamStore) Commit(id uint, from, to int64, users []UserData, rejected int) error {
+	return store.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UploadStream{}).Where("id = ? AND watermark = ? AND state = ?", id, from, uploadStreamOpen).Updates(map[string]interface{}{
+			"watermark":     to,
+			"rows_inserted": gorm.Expr("rows_inserted + ?", len(users)),
+			"rows_rejected": gorm.Expr("rows_rejected + ?", rejected),
+			"updated_at":    time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errWatermarkMoved
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		columns, err := insertColumnCount(&UserData{}, tx.NamingStrategy)
+		if err != nil {
+			return fmt.Errorf("failed to parse UserData schema: %w", err)
+		}
+		return tx.CreateInBatches(users, safeBatchSize(len(users), columns, tx.Dialector.Name())).Error
+	})
+}
+
+// Complete marks a stream completed, keeping the time it was first completed.
+// It returns gorm.ErrRecordNotFound for unknown streams.
+func (store *GormUploadStreamStore) Complete(id uint) (*UploadStream, error) {
+	err := store.db.Model(&UploadStream{}).Where("id = ? AND state = ?", id, uploadStreamOpen).
+		Updates(map[string]interface{}{"state": uploadStreamCompleted, "completed_at": time.Now()}).Error
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(id)
+}
+
+// streamBatch is a batch of rows sent to a stream, one JSON object per line of the request body
+type streamBatch struct {
+	Offset *int64                   `json:"offset"` // Position in the stream of the first row, counted from 0
+	Rows   []map[string]interface{} `json:"rows"`   // Values by column name, e.g. {"email": "jane@example.com", "age": 34}
+}
+
+// streamRowError is a row of a batch that failed validation; it is acknowledged but not inserted
+type streamRowError struct {
+	Row   int64  `json:"row"`   // Position of the row in the stream
+	Field string `json:"field"` // Comma-separated names of the invalid columns
+	Error string `json:"error"`
+}
+
+// streamAck acknowledges a committed batch, one JSON object per line of the response body
+type streamAck struct {
+	Offset    int64            `json:"offset"`
+	Rows      int              `json:"rows"`
+	Skipped   int              `json:"skipped"` // Rows at the start of the batch committed before, e.g. by a request that failed after committing
+	Inserted  int              `json:"inserted"`
+	Rejected  []streamRowError `json:"rejected"`
+	Watermark int64            `json:"watermark"` // Rows of the stream committed so far; resume from here
+}
+
+// streamRecord converts the values of a row to a record in the order of csvColumns, as read from a CSV file
+func streamRecord(row map[string]interface{}) ([]string, error) {
+	record := make([]string, len(csvColumns))
+	for name, value := range row {
+		index, ok := csvColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		switch v := value.(type) {
+		case nil:
+		case string:
+			record[index] = v
+		case json.Number:
+			record[index] = v.String()
+		case bool:
+			record[index] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%s must be a string, number, boolean or null", name)
+		}
+	}
+	return record, nil
+}
+
+// streamUser builds the record to insert from a valid row at position row of the stream
+func streamUser(stream *UploadStream, record []string, formats []string, row int64) UserData {
+	age, _, _ := parseAge(record[4])
+	salary, _, _ := parseSalary(record[8])
+	isActive, _ := parseIsActive(record[10])
+	dateJoined, _ := parseDate(record[9], formats)
+	line := int(row) + 1
+	provenance := recordProvenance{SourceRowNumber: &line}
+	if stream.Name != "" {
+		provenance.SourceFile = &stream.Name
+	}
+	return UserData{
+		FirstName:  record[1],
+		LastName:   record[2],
+		Email:      record[3],
+		Age:        age,
+		Gender:     record[5],
+		Department: record[6],
+		Company:    record[7],
+		Salary:     salary,
+		DateJoined: dateJoined,
+		IsActive:   isActive,
+		Provenance: provenance,
+	}
+}
+
+// commitStreamBatch validates the rows of batch past the watermark of stream and commits them, moving
+// the watermark to the end of the batch. Rows before the watermark were committed before and are skipped,
+// so clients may resend a batch whose acknowledgement they didn't receive.
+func commitStreamBatch(store UploadStreamStore, stream *UploadStream, batch streamBatch, formats []string) (streamAck, error) {
+	offset := *batch.Offset
+	skip := int(min(stream.Watermark-offset, int64(len(batch.Rows))))
+	ack := streamAck{Offset: offset, Rows: len(batch.Rows), Skipped: skip, Rejected: []streamRowError{}}
+
+	overflow, _ := newOverflowHandler(overflowReject)
+	var users []UserData
+	for i, row := range batch.Rows[skip:] {
+		position := offset + int64(skip+i)
+		record, err := streamRecord(row)
+		if err != nil {
+			ack.Rejected = append(ack.Rejected, streamRowError{Row: position, Error: err.Error()})
+			continue
+		}
+		if column, ok := overflow.apply(record); !ok {
+			ack.Rejected = append(ack.Rejected, streamRowError{Row: position, Field: column, Error: fmt.Sprintf("longer than %d characters", csvColumnSizes[column])})
+			continue
+		}
+		if errs := validateRow(record, formats); len(errs) > 0 {
+			ack.Rejected = append(ack.Rejected, streamRowError{Row: position, Field: errs.Fields(), Error: errs.Error()})
+			continue
+		}
+		users = append(users, streamUser(stream, record, formats, position))
+	}
+
+	end := offset + int64(len(batch.Rows))
+	if end > stream.Watermark {
+		if err := store.Commit(stream.ID, stream.Watermark, end, users, len(ack.Rejected)); err != nil {
+			return ack, err
+		}
+		stream.Watermark = end
+		stream.RowsInserted += int64(len(users))
+		stream.RowsRejected += int64(len(ack.Rejected))
+		ack.Inserted = len(users)
+	}
+	ack.Watermark = stream.Watermark
+	return ack, nil
+}
+
+// writeStreamLine writes obj as a line of JSON with the configured casing and sends it to the client right away
+func writeStreamLine(c *gin.Context, obj interface{}) {
+	if err := json.NewEncoder(c.Writer).Encode(casedValue(obj)); err != nil {
+		requestLogger(c).WithError(err).Warn("Failed to write upload stream acknowledgement")
+		return
+	}
+	c.Writer.Flush()
+}
+
+// writeStreamError ends the response to a batch request with an error line holding the watermark to resume from
+func writeStreamError(c *gin.Context, watermark int64, message, details string) {
+	writeStreamLine(c, envelope{
+		Data:   gin.H{"watermark": watermark},
+		Meta:   responseMeta(c, nil),
+		Errors: []apiError{{Message: message, Details: details}},
+	})
+}
+
+// loadUploadStream loads the stream named by the id parameter, responding with 404 or 500 when it can't
+func loadUploadStream(c *gin.Context, store UploadStreamStore) (*UploadStream, bool) {
+	if store == nil {
+		respondError(c, 404, "Streaming uploads are not available")
+		return nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, 400, "Invalid upload stream ID")
+		return nil, false
+	}
+	stream, err := store.Get(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, 404, "Upload stream not found")
+		return nil, false
+	}
+	if err != nil {
+		requestLogger(c).WithError(err).WithField("upload_stream", id).Error("Failed to load upload stream")
+		respondError(c, 500, "Failed to load upload stream")
+		return nil, false
+	}
+	return stream, true
+}
+
+// createUploadStream handles POST /api/upload-streams, opening a stream whose watermark starts at 0
+func createUploadStream(c *gin.Context, store UploadStreamStore) {
+	if store == nil {
+		respondError(c, 404, "Streaming uploads are not available")
+		return
+	}
+	var req struct {
+		Name        string `json:"name" binding:"max=255"`
+		DateFormats string `json:"date_formats" binding:"max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, 400, "Invalid upload stream", err.Error())
+		return
+	}
+	formats, err := parseDateFormats(req.DateFormats)
+	if err != nil {
+		respondError(c, 400, "Invalid date_formats value", err.Error())
+		return
+	}
+
+	stream := &UploadStream{Name: req.Name, DateFormats: strings.Join(formats, ","), State: uploadStreamOpen, CreatedBy: clientKey(c)}
+	if err := store.Create(stream); err != nil {
+		requestLogger(c).WithError(err).Error("Failed to create upload stream")
+		respondError(c, 500, "Failed to create upload stream")
+		return
+	}
+	requestLogger(c).WithFields(logrus.Fields{"upload_stream": stream.ID, "name": stream.Name, "created_by": stream.CreatedBy}).Info("Upload stream opened")
+	c.Header("Location", fmt.Sprintf("%s/%d", uploadStreamsPath, stream.ID))
+	respond(c, 201, stream, nil)
+}
+
+// uploadStreamStatus handles GET /api/upload-streams/:id, telling a client where to resume
+func uploadStreamStatus(c *gin.Context, store UploadStreamStore) {
+	if stream, ok := loadUploadStream(c, store); ok {
+		respond(c, 200, stream, nil)
+	}
+}
+
+// uploadStreamBatches handles POST /api/upload-streams/:id/batches. The body is a sequence of JSON
+// batches, and an acknowledgement is written and flushed as each one is committed, while the next ones
+// are still being sent. The first error ends the response with an error line holding the watermark.
+func uploadStreamBatches(c *gin.Context, store UploadStreamStore) {
+	stream, ok := loadUploadStream(c, store)
+	if !ok {
+		return
+	}
+	if stream.State != uploadStreamOpen {
+		respondError(c, 409, "Upload stream is completed", "open a new stream to upload more rows")
+		return
+	}
+	formats, err := parseDateFormats(stream.DateFormats)
+	if err != nil {
+		requestLogger(c).WithError(err).WithField("upload_stream", stream.ID).Error("Invalid date formats of upload stream")
+		respondError(c, 500, "Failed to load upload stream")
+		return
+	}
+
+	// HTTP/1.1 requests can't be read anymore once the response started, unless full duplex is enabled;
+	// HTTP/2 requests always can be
+	if err := http.NewResponseController(c.Writer).EnableFullDuplex(); err != nil {
+		requestLogger(c).WithError(err).Debug("Full duplex not supported, acknowledgements are written as batches arrive")
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(200)
+
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	entry := requestLogger(c).WithField("upload_stream", stream.ID)
+	for {
+		var batch streamBatch
+		err := decoder.Decode(&batch)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			writeStreamError(c, stream.Watermark, "Invalid batch", err.Error())
+			return
+		}
+		switch {
+		case batch.Offset == nil || *batch.Offset < 0:
+			writeStreamError(c, stream.Watermark, "Invalid batch", "offset must be given and not negative")
+			return
+		case len(batch.Rows) > uploadStreamMaxBatchRows:
+			writeStreamError(c, stream.Watermark, "Batch too large", fmt.Sprintf("batches are limited to %d rows", uploadStreamMaxBatchRows))
+			return
+		case *batch.Offset > stream.Watermark:
+			writeStreamError(c, stream.Watermark, "Batch out of order", fmt.Sprintf("offset %d is past the watermark %d; resend the rows from the watermark", *batch.Offset, stream.Watermark))
+			return
+		}
+
+		ack, err := commitStreamBatch(store, stream, batch, formats)
+		if errors.Is(err, errWatermarkMoved) {
+			if current, getErr := store.Get(stream.ID); getErr == nil {
+				stream = current
+			}
+			writeStreamError(c, stream.Watermark, "Upload stream changed", "another request committed rows of the stream or completed it; resend the rows from the watermark")
+			return
+		}
+		if err != nil {
+			entry.WithError(err).Error("Failed to commit upload stream batch")
+			writeStreamError(c, stream.Watermark, "Failed to commit batch", err.Error())
+			return
+		}
+		entry.WithFields(logrus.Fields{"offset": ack.Offset, "inserted": ack.Inserted, "rejected": len(ack.Rejected), "watermark": ack.Watermark}).Debug("Upload stream batch committed")
+		writeStreamLine(c, envelope{Data: ack, Meta: responseMeta(c, nil), Errors: []apiError{}})
+	}
+}
+
+// completeUploadStream handles POST /api/upload-streams/:id/complete; completing a completed stream is a no-op
+func completeUploadStream(c *gin.Context, store UploadStreamStore) {
+	stream, ok := loadUploadStream(c, store)
+	if !ok {
+		return
+	}
+	stream, err := store.Complete(stream.ID)
+	if err != nil {
+		requestLogger(c).WithError(err).Error("Failed to complete upload stream")
+		respondError(c, 500, "Failed to complete upload stream")
+		return
+	}
+	requestLogger(c).WithFields(logrus.Fields{"upload_stream": stream.ID, "rows_inserted": stream.RowsInserted, "rows_rejected": stream.RowsRejected}).Info("Upload stream completed")
+	respond(c, 200, stream, nil)
+}
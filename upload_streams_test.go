@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamRow returns the values of a valid row with the given email
+func streamRow(email string) map[string]interface{} {
+	return map[string]interface{}{
+		"first_name": "Jane", "last_name": "Doe", "email": email, "age": json.Number("34"), "gender": "female",
+		"department": "Sales", "company": "Acme", "salary": json.Number("52000.5"), "date_joined": "2021-03-04", "is_active": true,
+	}
+}
+
+// TestCommitStreamBatch tests that rows before the watermark are skipped and the others committed with the rejections
+func TestCommitStreamBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := NewMockUploadStreamStore(ctrl)
+	stream := &UploadStream{ID: 3, Name: "people.csv", State: uploadStreamOpen}
+	offset := func(n int64) *int64 { return &n }
+
+	invalid := streamRow("not-an-email")
+	store.EXPECT().Commit(uint(3), int64(0), int64(2), gomock.Any(), 1).DoAndReturn(func(_ uint, _, _ int64, users []UserData, _ int) error {
+		assert.Len(t, users, 1)
+		assert.Equal(t, "jane@example.com", users[0].Email)
+		assert.Equal(t, 34, users[0].Age)
+		assert.Equal(t, newDate(2021, 3, 4), users[0].DateJoined)
+		assert.Equal(t, "people.csv", *users[0].Provenance.SourceFile)
+		assert.Equal(t, 1, *users[0].Provenance.SourceRowNumber)
+		return nil
+	})
+	ack, err := commitStreamBatch(store, stream, streamBatch{Offset: offset(0), Rows: []map[string]interface{}{streamRow("jane@example.com"), invalid}}, defaultDateFormats)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ack.Inserted)
+	assert.Equal(t, []streamRowError{{Row: 1, Field: "email", Error: "email must be a valid email address"}}, ack.Rejected)
+	assert.Equal(t, int64(2), ack.Watermark)
+	assert.Equal(t, int64(2), stream.Watermark)
+
+	// A resent batch overlapping the watermark only commits its new rows
+	store.EXPECT().Commit(uint(3), int64(2), int64(4), gomock.Len(2), 0).Return(nil)
+	ack, err = commitStreamBatch(store, stream, streamBatch{Offset: offset(1), Rows: []map[string]interface{}{streamRow("a@example.com"), streamRow("b@example.com"), streamRow("c@example.com")}}, defaultDateFormats)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ack.Skipped)
+	assert.Equal(t, 2, ack.Inserted)
+	assert.Equal(t, int64(4), ack.Watermark)
+
+	// A batch committed before is acknowledged without writing
+	ack, err = commitStreamBatch(store, stream, streamBatch{Offset: offset(2), Rows: []map[string]interface{}{streamRow("b@example.com")}}, defaultDateFormats)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ack.Skipped)
+	assert.Equal(t, 0, ack.Inserted)
+	assert.Equal(t, int64(4), ack.Watermark)
+
+	_, err = streamRecord(map[string]interface{}{"nickname": "JD"})
+	assert.EqualError(t, err, `unknown column "nickname"`)
+}
+
+// TestUploadStreamBatches tests that each batch of a request is acknowledged and an out of order batch ends the stream
+func TestUploadStreamBatches(t *testing.T) {
+	disableAuth(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := uploadStreams
+	defer func() { uploadStreams = previous }()
+	store := NewMockUploadStreamStore(ctrl)
+	uploadStreams = store
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	store.EXPECT().Get(uint(5)).Return(&UploadStream{ID: 5, State: uploadStreamOpen, DateFormats: "dd/mm/yyyy"}, nil)
+	store.EXPECT().Commit(uint(5), int64(0), int64(1), gomock.Len(1), 0).Return(nil)
+	row := `{"first_name":"Jane","email":"jane@example.com","age":34,"salary":52000,"date_joined":"04/03/2021"}`
+	body := `{"offset":0,"rows":[` + row + `]}` + "\n" + `{"offset":3,"rows":[` + row + `]}` + "\n"
+	w := serveRecord(r, "POST", "/api/upload-streams/5/batches", body)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	var lines []envelope
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	for scanner.Scan() {
+		var line envelope
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	assert.Len(t, lines, 2)
+	assert.Equal(t, float64(1), lines[0].Data.(map[string]interface{})["watermark"])
+	assert.Empty(t, lines[0].Errors)
+	assert.Equal(t, "Batch out of order", lines[1].Errors[0].Message)
+	assert.Equal(t, float64(1), lines[1].Data.(map[string]interface{})["watermark"])
+
+	// Completed streams take no more batches
+	store.EXPECT().Get(uint(5)).Return(&UploadStream{ID: 5, State: uploadStreamCompleted}, nil)
+	assert.Equal(t, 409, serveRecord(r, "POST", "/api/upload-streams/5/batches", body).Code)
+}
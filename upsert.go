@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Supported write modes of the postgres sink
+const (
+	writeModeInsert = "insert" // Append every row, the default
+	writeModeUpsert = "upsert" // Update the existing record with the same email instead of adding another
+)
+
+// userEmailIndex is the unique index upserts are keyed on
+const userEmailIndex = "idx_user_data_email"
+
+// upsertColumns are the columns an upsert overwrites; the id of the existing record is kept
+var upsertColumns = []string{
+	"first_name", "last_name", "age", "gender",
+	"department", "company", "salary", "date_joined", "is_active",
+	"import_id", "source_file", "source_row_number",
+}
+
+// EnsureUniqueEmail creates the unique index on email required by upserts, if it doesn't exist yet.
+// It fails while the table still holds duplicate emails, e.g. from earlier plain imports.
+func (handler *GormDBHandler) EnsureUniqueEmail() error {
+	err := handler.db.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (email)", userEmailIndex, UserData{}.TableName())).Error
+	if err != nil {
+		return fmt.Errorf("failed to create unique index on email, remove duplicate emails first: %w", err)
+	}
+	return nil
+}
+
+// Upsert inserts users with INSERT ... ON CONFLICT (email) DO UPDATE in batches of one transaction
+func (handler *GormDBHandler) Upsert(users []UserData, batchSize int) error {
+	columns, err := insertColumnCount(&UserData{}, handler.db.NamingStrategy)
+	if err != nil {
+		return fmt.Errorf("failed to parse UserData schema: %w", err)
+	}
+	batchSize = safeBatchSize(batchSize, columns, handler.db.Dialector.Name())
+
+	upsert := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "email"}},
+		DoUpdates: clause.AssignmentColumns(upsertColumns),
+	}
+	extra := extraColumns(users)
+	if len(extra) == 0 {
+		return handler.db.Clauses(upsert).CreateInBatches(users, batchSize).Error
+	}
+	// The IDs returned for updated rows are those of the existing records, whose added columns are overwritten too
+	return handler.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(upsert).CreateInBatches(users, batchSize).Error; err != nil {
+			return err
+		}
+		return writeExtraColumns(tx, users, extra)
+	})
+}
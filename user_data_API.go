@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -12,23 +14,28 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/natefinch/lumberjack"
 	"github.com/sirupsen/logrus"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// UserDatas defines the struct to map to the user_data table
+// UserDatas defines the struct to map to the user_data table and the binding rules of records written
+// through the API; the values are checked further by validateFields
 type UserDatas struct {
-	ID         int    `gorm:"primaryKey;autoIncrement"`
-	FirstName  string `gorm:"size:100"`
-	LastName   string `gorm:"size:100"`
-	Email      string `gorm:"size:150"`
-	Age        int
-	Gender     string `gorm:"size:10"`
-	Department string `gorm:"size:100"`
-	Company    string `gorm:"size:100"`
-	Salary     float64
-	DateJoined string `gorm:"type:date"`
-	IsActive   bool
+	ID         int     `gorm:"primaryKey;autoIncrement" json:"id"`
+	FirstName  string  `gorm:"size:100" json:"first_name" binding:"required,max=100"`
+	LastName   string  `gorm:"size:100" json:"last_name" binding:"max=100"`
+	Email      string  `gorm:"size:150" json:"email" binding:"required,max=150"`
+	Age        int     `json:"age"`
+	Gender     string  `gorm:"size:10" json:"gender" binding:"max=10"`
+	Department string  `gorm:"size:100" json:"department" binding:"max=100"`
+	Company    string  `gorm:"size:100" json:"company" binding:"max=100"`
+	Salary     float64 `json:"salary"`
+	DateJoined Date    `gorm:"type:date" json:"date_joined"`
+	IsActive   bool    `json:"is_active"`
+
+	Provenance recordProvenance `gorm:"embedded" json:"-"` // Returned with include=provenance, never set through the API
 }
 
 // TableName specifies the name of the table in the database
@@ -39,9 +46,20 @@ func (UserDatas) TableName() string {
 // Database interface for database operations
 type Database interface {
 	Find(dest interface{}, conds ...interface{}) *gorm.DB
+	Scan(dest interface{}) *gorm.DB
+	Count(count *int64) *gorm.DB
 	Offset(offset int) Database
 	Limit(limit int) Database
 	Order(value string) Database
+	Model(value interface{}) Database
+	Select(query interface{}, args ...interface{}) Database
+	Group(name string) Database
+	Where(query interface{}, args ...interface{}) Database
+	First(dest interface{}, conds ...interface{}) *gorm.DB
+	Create(value interface{}) *gorm.DB
+	Save(value interface{}) *gorm.DB
+	Delete(value interface{}, conds ...interface{}) *gorm.DB
+	FindInBatches(dest interface{}, batchSize int, fc func(tx *gorm.DB, batch int) error) *gorm.DB
 }
 
 // GormDatabase is the concrete implementation of the Database interface
@@ -49,53 +67,120 @@ type GormDatabase struct {
 	DB *gorm.DB
 }
 
-// Implement the Database interface for GormDatabase
+// Implement the Database interface for GormDatabase.
+// Chain methods return a new GormDatabase so that query conditions never
+// leak into the shared handle used by other requests.
 func (g *GormDatabase) Find(dest interface{}, conds ...interface{}) *gorm.DB {
 	return g.DB.Find(dest, conds...)
 }
 
+func (g *GormDatabase) Scan(dest interface{}) *gorm.DB {
+	return g.DB.Scan(dest)
+}
+
+func (g *GormDatabase) Count(count *int64) *gorm.DB {
+	return g.DB.Count(count)
+}
+
 func (g *GormDatabase) Offset(offset int) Database {
-	g.DB = g.DB.Offset(offset)
-	return g
+	return &GormDatabase{DB: g.DB.Offset(offset)}
 }
 
 func (g *GormDatabase) Limit(limit int) Database {
-	g.DB = g.DB.Limit(limit)
-	return g
+	return &GormDatabase{DB: g.DB.Limit(limit)}
 }
 
 func (g *GormDatabase) Order(value string) Database {
-	g.DB = g.DB.Order(value)
-	return g
+	return &GormDatabase{DB: g.DB.Order(value)}
+}
+
+func (g *GormDatabase) Model(value interface{}) Database {
+	return &GormDatabase{DB: g.DB.Model(value)}
+}
+
+func (g *GormDatabase) Select(query interface{}, args ...interface{}) Database {
+	return &GormDatabase{DB: g.DB.Select(query, args...)}
+}
+
+func (g *GormDatabase) Group(name string) Database {
+	return &GormDatabase{DB: g.DB.Group(name)}
+}
+
+func (g *GormDatabase) Where(query interface{}, args ...interface{}) Database {
+	return &GormDatabase{DB: g.DB.Where(query, args...)}
+}
+
+func (g *GormDatabase) First(dest interface{}, conds ...interface{}) *gorm.DB {
+	return g.DB.First(dest, conds...)
+}
+
+func (g *GormDatabase) Create(value interface{}) *gorm.DB {
+	return g.DB.Create(value)
+}
+
+func (g *GormDatabase) Save(value interface{}) *gorm.DB {
+	return g.DB.Save(value)
+}
+
+func (g *GormDatabase) Delete(value interface{}, conds ...interface{}) *gorm.DB {
+	return g.DB.Delete(value, conds...)
+}
+
+func (g *GormDatabase) FindInBatches(dest interface{}, batchSize int, fc func(tx *gorm.DB, batch int) error) *gorm.DB {
+	return g.DB.FindInBatches(dest, batchSize, fc)
 }
 
 // Initialize Logrus logger
 var log = logrus.New()
 
-// setupLogger configures Logrus with log rotation
-func setupLogger() {
-	log.SetOutput(&lumberjack.Logger{
-		Filename:   "File.log",
-		MaxSize:    10,   // Max size in MB before rotating
-		MaxBackups: 3,    // Max number of old log files to keep
-		MaxAge:     7,    // Max age in days to keep old log files
-		Compress:   true, // Compress old log files
-	})
+// Application settings
+const (
+	logFilePath   = "File.log"
+	logMaxSizeMB  = 10
+	logMaxBackups = 3
+	logMaxAgeDays = 7
+)
+
+// setupLogger configures Logrus with log rotation, returning the log file to close on shutdown
+func setupLogger() io.Closer {
+	logFile := &lumberjack.Logger{
+		Filename:   logFilePath,
+		MaxSize:    logMaxSizeMB,  // Max size in MB before rotating
+		MaxBackups: logMaxBackups, // Max number of old log files to keep
+		MaxAge:     logMaxAgeDays, // Max age in days to keep old log files
+		Compress:   true,          // Compress old log files
+	}
+	log.SetOutput(logFile)
 	log.SetFormatter(&logrus.JSONFormatter{})
 	log.SetLevel(logrus.InfoLevel)
+	return logFile
 }
 
 // setupDatabases initializes PostgreSQL connection using GORM
 func setupDatabases() *gorm.DB {
-	dsn := "host=localhost user=postgres password=Virat@2#Virat@2# dbname=mini-Project port=8899 sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(appConfig.Database.DSN()), &gorm.Config{
+		Logger: newGormLogger(time.Duration(appConfig.Database.SlowQueryThreshold)),
+	})
 	if err != nil {
 		log.WithError(err).Fatal("Failed to connect to the database")
 	}
+	if err := configurePool(db, appConfig.Database); err != nil {
+		log.WithError(err).Fatal("Failed to configure the connection pool")
+	}
+	if appConfig.Database.QueryTags {
+		if err := db.Use(queryTagger{}); err != nil {
+			log.WithError(err).Fatal("Failed to register the query tagger")
+		}
+	}
+	if err := db.Use(queryTimer{}); err != nil {
+		log.WithError(err).Fatal("Failed to register the query timer")
+	}
 	log.Info("Successfully connected to the database")
 
-	// Migrate the schema to create the table if it doesn't exist
-	db.AutoMigrate(&UserDatas{})
+	// Migrate the schema to create the tables if it doesn't exist, or only report drift in dry-run mode
+	if err := migrateSchema(db, appConfig.Database.Migrate); err != nil {
+		log.WithError(err).Fatal("Failed to migrate database")
+	}
 
 	return db
 }
@@ -150,7 +235,7 @@ func requestResponseLogger() gin.HandlerFunc {
 		startTime := time.Now()
 
 		// Log request details (method and URL only, no body)
-		log.WithFields(logrus.Fields{
+		requestLogger(c).WithFields(logrus.Fields{
 			"method": c.Request.Method,
 			"url":    c.Request.URL.String(),
 		}).Info("Incoming request")
@@ -163,7 +248,7 @@ func requestResponseLogger() gin.HandlerFunc {
 
 		// Log response metadata (status and duration only)
 		duration := time.Since(startTime)
-		log.WithFields(logrus.Fields{
+		requestLogger(c).WithFields(logrus.Fields{
 			"status":   responseWriter.Status(),
 			"duration": duration.String(),
 		}).Info("Outgoing response")
@@ -181,74 +266,474 @@ func (r *responseCapture) Write(data []byte) (int, error) {
 	return r.ResponseWriter.Write(data)
 }
 
-// setupAPI sets up the API with REST endpoints using Gin
-func setupAPI(db Database) *gin.Engine {
+// Unwrap returns the wrapped writer, so http.ResponseController reaches the connection's writer
+func (r *responseCapture) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// setupAPI sets up the API with REST endpoints using Gin.
+// db serves the read endpoints and dbHandler the CSV upload, both backed by the same connection.
+// Uploads are queued on imports, whose store also serves the import status endpoint.
+func setupAPI(db Database, dbHandler DBHandler, imports *importManager) *gin.Engine {
 	r := gin.New()
-	r.Use(requestResponseLogger())
+	// Only the configured proxies may set the client IP that rate limits count, so clients can't
+	// choose their own with X-Forwarded-For
+	if err := r.SetTrustedProxies(appConfig.Server.TrustedProxies); err != nil {
+		log.WithError(err).Error("Invalid trusted proxies, trusting none")
+		r.SetTrustedProxies(nil)
+	}
+	r.Use(requestIDMiddleware(), queryTagsMiddleware(), slowRequestMiddleware(time.Duration(appConfig.Server.SlowRequestThreshold)), requestResponseLogger(), sloMiddleware(slo), sentryMiddleware(), authMiddleware(), rateLimitMiddleware(appConfig.RateLimit), readOnlyMiddleware(readOnly))
+	r.MaxMultipartMemory = maxMultipartMemory
+
+	// Bound the in-flight requests of the heavy routes
+	uploadLimit := newRouteLimiter("uploads", uploadConcurrency, uploadQueue, routeQueueWait).middleware()
+	statsLimit := newRouteLimiter("stats", statsConcurrency, statsQueue, routeQueueWait).middleware()
+	listLimit := newRouteLimiter("records", listConcurrency, listQueue, routeQueueWait).middleware()
+	clientUploads := clientUploadLimit(appConfig.RateLimit.ClientUploads)
+
+	// Liveness and readiness probes; /readyz fails until the database answers and is migrated
+	ready := &readiness{}
+	r.GET("/healthz", healthz)
+	r.GET("/readyz", func(c *gin.Context) {
+		ready.readyz(c, databaseProbe)
+	})
+
+	// Endpoint to report the version, commit and enabled features of the running build
+	r.GET("/api/version", versionHandler)
+
+	// Endpoint to upload a CSV file into the user_data table
+	r.POST("/upload-csv", clientUploads, uploadLimit, func(c *gin.Context) {
+		uploadCSV(c, dbHandler, imports)
+	})
+
+	// Endpoint to upload newline-delimited JSON records into the user_data table
+	r.POST("/upload-json", clientUploads, uploadLimit, func(c *gin.Context) {
+		uploadJSON(c, dbHandler, imports)
+	})
 
-	// Endpoint to retrieve all user records from the database
-	r.GET("/api/records", func(c *gin.Context) {
+	// Endpoint to queue an import from a registered source such as a URL or blob
+	r.POST("/api/imports", clientUploads, uploadLimit, func(c *gin.Context) {
+		createImport(c, dbHandler, imports)
+	})
+
+	// Endpoint to queue an import streamed from a remote CSV file
+	r.POST("/api/imports/url", clientUploads, uploadLimit, func(c *gin.Context) {
+		createURLImport(c, dbHandler, imports)
+	})
+
+	// Endpoints of streaming uploads: rows are sent in batches on an open stream, each batch acknowledged
+	// with the rows-committed watermark a client resumes from after a failure
+	r.POST(uploadStreamsPath, func(c *gin.Context) {
+		createUploadStream(c, uploadStreams)
+	})
+	r.GET(uploadStreamsPath+"/:id", func(c *gin.Context) {
+		uploadStreamStatus(c, uploadStreams)
+	})
+	r.POST(uploadStreamsPath+"/:id/batches", clientUploads, uploadLimit, func(c *gin.Context) {
+		uploadStreamBatches(c, uploadStreams)
+	})
+	r.POST(uploadStreamsPath+"/:id/complete", func(c *gin.Context) {
+		completeUploadStream(c, uploadStreams)
+	})
+
+	// Endpoint to estimate the duration and resource impact of an import before uploading
+	r.GET("/api/imports/estimate", func(c *gin.Context) {
+		importEstimateHandler(c, imports)
+	})
+
+	// Endpoint to search the history of imports by their metadata, newest first
+	r.GET("/api/imports", func(c *gin.Context) {
+		listImports(c, imports.store)
+	})
+
+	// Endpoint to retrieve the state of an import job
+	r.GET("/api/imports/:id", func(c *gin.Context) {
+		importStatus(c, imports.store)
+	})
+
+	// Endpoint to download the rows rejected by an import as CSV
+	r.GET("/api/imports/:id/errors", func(c *gin.Context) {
+		importRowErrors(c, imports.store)
+	})
+
+	// Endpoint to retrieve the log entries of an import job
+	r.GET("/api/imports/:id/logs", func(c *gin.Context) {
+		importLogs(c, imports.store, logFilePath)
+	})
+
+	// Endpoint to retrieve the user records matching the filter query parameters, a page at a time
+	// by page number or, with cursor, after the last record of the previous page
+	r.GET("/api/records", listLimit, func(c *gin.Context) {
+		if !applySavedFilter(c, savedFilters) {
+			return
+		}
 		pageStr := c.DefaultQuery("page", "1")
 		sizeStr := c.DefaultQuery("size", "10")
+		cursorStr, keyset := c.GetQuery("cursor")
+		if keyset && c.Query("page") != "" {
+			respondError(c, 400, "Invalid page number", "page and cursor can't be combined")
+			return
+		}
 
 		page, err := strconv.Atoi(pageStr)
 		if err != nil || page < 1 {
 			log.WithField("page", pageStr).Error("Invalid page number")
-			c.JSON(400, gin.H{"error": "Invalid page number"})
+			respondError(c, 400, "Invalid page number")
 			return
 		}
 
 		size, err := strconv.Atoi(sizeStr)
 		if err != nil || size < 1 {
 			log.WithField("size", sizeStr).Error("Invalid size number")
-			c.JSON(400, gin.H{"error": "Invalid size number"})
+			respondError(c, 400, "Invalid size number")
+			return
+		}
+
+		filters, err := parseRecordFilters(c.Request.URL.Query())
+		if err != nil {
+			respondError(c, 400, "Invalid filter", err.Error())
+			return
+		}
+
+		order, err := parseRecordSort(c.Query("sort"))
+		if err != nil {
+			respondError(c, 400, "Invalid sort", err.Error())
+			return
+		}
+
+		provenance, ok := parseInclude(c)
+		if !ok {
+			return
+		}
+
+		stream, ok := parseRecordStream(c, size)
+		if !ok {
+			return
+		}
+
+		recordQueryUsage.record(filters, order)
+		if keyset {
+			listRecordsAfter(c, requestDatabase(c, db), cursorStr, size, filters, order, provenance, stream)
+			return
+		}
+
+		// Count the matching records so clients can build pagers
+		var total int64
+		if err := applyRecordFilters(requestDatabase(c, db).Model(&UserDatas{}), filters).Count(&total).Error; err != nil {
+			log.WithError(err).Error("Failed to count records")
+			respondError(c, 500, "Failed to count records")
 			return
 		}
 
 		offset := (page - 1) * size
-		var records []UserDatas
+		c.Header(totalCountHeader, strconv.FormatInt(total, 10))
+		setPageLinks(c, page, size, total)
+
+		// Write large pages as they are read, so they don't have to fit in memory
+		if stream {
+			newQuery := func() Database { return applyRecordFilters(requestDatabase(c, db), filters) }
+			out := newRecordStream(c, size, provenance)
+			out.end(paginationMeta(c.Request.URL, page, size, total), readRecordBatches(newQuery, order, offset, size, out.write))
+			return
+		}
 
-		if err := db.Offset(offset).Limit(size).Order("id ASC").Find(&records).Error; err != nil {
+		records := []UserDatas{}
+
+		if err := applyRecordFilters(requestDatabase(c, db), filters).Offset(offset).Limit(size).Order(order).Find(&records).Error; err != nil {
 			log.WithError(err).Error("Failed to fetch records")
-			c.JSON(500, gin.H{"error": "Failed to fetch records"})
+			respondError(c, 500, "Failed to fetch records")
 			return
 		}
 
 		log.WithField("records_count", len(records)).Info("Records fetched successfully")
-		c.JSON(200, records)
+		if provenance {
+			respond(c, 200, withProvenance(records), paginationMeta(c.Request.URL, page, size, total))
+			return
+		}
+		respond(c, 200, records, paginationMeta(c.Request.URL, page, size, total))
 	})
 
+	// HEAD variant of the records endpoint returning only the total count header of the matching records
+	r.HEAD("/api/records", listLimit, func(c *gin.Context) {
+		if !applySavedFilter(c, savedFilters) {
+			return
+		}
+		filters, err := parseRecordFilters(c.Request.URL.Query())
+		if err != nil {
+			c.Status(400)
+			return
+		}
+		recordQueryUsage.record(filters, "id ASC")
+
+		var total int64
+		if err := applyRecordFilters(requestDatabase(c, db).Model(&UserDatas{}), filters).Count(&total).Error; err != nil {
+			log.WithError(err).Error("Failed to count records")
+			c.Status(500)
+			return
+		}
+		c.Header(totalCountHeader, strconv.FormatInt(total, 10))
+		c.Status(200)
+	})
+
+	// Endpoints to list, read, save and delete the named filters of the authenticated user, applied to
+	// the records list with ?filter=name
+	r.GET(savedFiltersPath, func(c *gin.Context) {
+		listSavedFilters(c, savedFilters)
+	})
+	r.GET(savedFiltersPath+"/:name", func(c *gin.Context) {
+		getSavedFilter(c, savedFilters)
+	})
+	r.PUT(savedFiltersPath+"/:name", func(c *gin.Context) {
+		saveFilter(c, savedFilters)
+	})
+	r.DELETE(savedFiltersPath+"/:name", func(c *gin.Context) {
+		deleteSavedFilter(c, savedFilters)
+	})
+
+	// Endpoint to search the records by name and email, best matches first
+	r.GET(searchPath, listLimit, func(c *gin.Context) {
+		searchRecords(c, recordSearch)
+	})
+
+	// Endpoints to read, create, replace, modify and delete individual records
+	r.POST("/api/records", func(c *gin.Context) {
+		createRecord(c, requestDatabase(c, db))
+	})
+	r.GET("/api/records/:id", func(c *gin.Context) {
+		getRecord(c, requestDatabase(c, db))
+	})
+	r.PUT("/api/records/:id", func(c *gin.Context) {
+		replaceRecord(c, requestDatabase(c, db))
+	})
+	r.PATCH("/api/records/:id", func(c *gin.Context) {
+		patchRecord(c, requestDatabase(c, db))
+	})
+	r.DELETE("/api/records/:id", func(c *gin.Context) {
+		deleteRecord(c, requestDatabase(c, db))
+	})
+
+	// Endpoint to diff the records against an uploaded file or a snapshot by key
+	r.POST(comparePath, clientUploads, uploadLimit, func(c *gin.Context) {
+		compareDataset(c, requestDatabase(c, db), contextStore(c.Request.Context(), snapshots))
+	})
+
+	// Endpoint to retrieve a pivot table of user counts/salaries by two dimensions
+	r.GET("/api/stats/pivot", statsLimit, func(c *gin.Context) {
+		pivotStats(c, requestDatabase(c, db))
+	})
+
+	// Endpoints to retrieve the salary distribution and headcount, optionally grouped
+	r.GET("/api/stats/salary", statsLimit, func(c *gin.Context) {
+		salaryStats(c, requestDatabase(c, db))
+	})
+	r.GET("/api/stats/headcount", statsLimit, func(c *gin.Context) {
+		headcountStats(c, requestDatabase(c, db))
+	})
+
+	// Endpoint to retrieve rolling latency/error-rate SLO metrics per route
+	r.GET("/api/admin/slo", func(c *gin.Context) {
+		sloReport(c, slo)
+	})
+
+	// Endpoints to obtain and refresh access tokens, and to add users
+	r.POST("/api/auth/token", func(c *gin.Context) {
+		issueToken(c, requestDatabase(c, db))
+	})
+	r.POST("/api/auth/refresh", func(c *gin.Context) {
+		refreshToken(c, requestDatabase(c, db))
+	})
+	r.POST("/api/admin/users", func(c *gin.Context) {
+		createUser(c, requestDatabase(c, db))
+	})
+
+	// Endpoint to retrieve the effective configuration with secrets redacted
+	r.GET("/api/admin/config", func(c *gin.Context) {
+		respond(c, 200, effectiveConfig(), nil)
+	})
+
+	// Endpoints to check and switch read-only mode
+	r.GET(readOnlyPath, func(c *gin.Context) {
+		readOnlyStatus(c, readOnly)
+	})
+	r.PUT(readOnlyPath, func(c *gin.Context) {
+		setReadOnly(c, readOnly)
+	})
+
+	// Endpoints to list the database's running queries and cancel a runaway one
+	r.GET("/api/admin/db/activity", func(c *gin.Context) {
+		dbActivityHandler(c, contextStore(c.Request.Context(), queryActivity))
+	})
+	r.POST("/api/admin/db/cancel/:pid", func(c *gin.Context) {
+		cancelQueryHandler(c, contextStore(c.Request.Context(), queryActivity))
+	})
+
+	// Endpoints to report the columns record queries use that lack an index, and to create the suggested ones
+	r.GET("/api/admin/db/index-advice", func(c *gin.Context) {
+		indexAdviceHandler(c, contextStore(c.Request.Context(), indexes))
+	})
+	r.POST("/api/admin/db/index-advice/apply", func(c *gin.Context) {
+		applyIndexAdviceHandler(c, contextStore(c.Request.Context(), indexes))
+	})
+
+	// Endpoint to compare the live tables with the models
+	r.GET("/api/admin/db/schema-drift", func(c *gin.Context) {
+		schemaDriftHandler(c, contextStore(c.Request.Context(), databaseProbe))
+	})
+
+	// Endpoints to copy the records into named snapshots and to restore them
+	r.POST("/api/admin/snapshots", func(c *gin.Context) {
+		createSnapshot(c, contextStore(c.Request.Context(), snapshots))
+	})
+	r.GET("/api/admin/snapshots", func(c *gin.Context) {
+		listSnapshots(c, contextStore(c.Request.Context(), snapshots))
+	})
+	r.POST("/api/admin/snapshots/:id/restore", func(c *gin.Context) {
+		restoreSnapshot(c, contextStore(c.Request.Context(), snapshots))
+	})
+	r.DELETE("/api/admin/snapshots/:id", func(c *gin.Context) {
+		deleteSnapshot(c, contextStore(c.Request.Context(), snapshots))
+	})
+
+	// Endpoints to create, list and revoke the API keys of scripts and integrations
+	r.POST("/api/admin/api-keys", func(c *gin.Context) {
+		createAPIKey(c, apiKeys)
+	})
+	r.GET("/api/admin/api-keys", func(c *gin.Context) {
+		listAPIKeys(c, apiKeys)
+	})
+	r.POST("/api/admin/api-keys/:id/revoke", func(c *gin.Context) {
+		revokeAPIKey(c, apiKeys)
+	})
+
+	// Endpoints to review the columns proposed for new CSV columns, and to add or decline them
+	r.GET("/api/admin/schema-changes", func(c *gin.Context) {
+		listSchemaChanges(c, schemaChanges)
+	})
+	r.POST("/api/admin/schema-changes/:id/approve", func(c *gin.Context) {
+		decideSchemaChange(c, schemaChanges, true)
+	})
+	r.POST("/api/admin/schema-changes/:id/reject", func(c *gin.Context) {
+		decideSchemaChange(c, schemaChanges, false)
+	})
+
+	// Swagger UI and the OpenAPI spec at /swagger/doc.json
+	registerSwagger()
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
 	// Endpoint to retrieve analyzed logs
 	r.GET("/api/logs", func(c *gin.Context) {
-		logCounts, err := analyzeLogs("File.log")
+		logCounts, err := analyzeLogs(logFilePath)
 		if err != nil {
 			log.WithError(err).Error("Failed to analyze logs")
-			c.JSON(500, gin.H{"error": err.Error()})
+			respondError(c, 500, err.Error())
 			return
 		}
 
-		c.JSON(200, logCounts)
+		respond(c, 200, logCounts, nil)
 	})
 
+	// Answer OPTIONS and unsupported methods with the allowed methods of each route
+	registerOptionsRoutes(r)
+
 	return r
 }
 
 func main() {
 	// Set up the logger
-	setupLogger()
+	logFile := setupLogger()
+
+	// Load the configuration from the config file and environment
+	config, err := loadConfig()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid configuration")
+	}
+	appConfig = config
+	readOnly.set(appConfig.Server.ReadOnly, appConfig.Server.ReadOnlyReason)
+
+	// Set up error reporting
+	if err := setupSentry(); err != nil {
+		log.WithError(err).Error("Error reporting disabled")
+	}
+	defer flushSentry()
 
 	// Set up the database
 	db := setupDatabases()
 
-	// Wrap GORM DB in the interface implementation
+	// Wrap the shared GORM DB in the interface implementations
 	gormDB := &GormDatabase{DB: db}
+	dbHandler := &GormDBHandler{db: db}
 
-	// Set up API with the Database interface
-	r := setupAPI(gormDB)
+	// Require tokens when a JWT secret is configured, creating the configured admin account if needed
+	auth = newJWTAuthenticator(appConfig.Auth)
+	// Authenticate machine callers by their TLS client certificates, if enabled
+	if clientCerts, err = newCertAuthenticator(appConfig.Auth); err != nil {
+		log.WithError(err).Fatal("Failed to set up client certificate authentication")
+	}
+	if err := ensureAdminUser(gormDB, appConfig.Auth.AdminUser, appConfig.Auth.AdminPassword); err != nil {
+		log.WithError(err).Fatal("Failed to set up the admin user")
+	}
+
+	// Set up the artifact storage backend
+	blobs, err := setupBlobStore(appConfig.Storage)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to set up artifact storage")
+	}
+
+	// Copy imported rows to the analytical warehouse, if one is configured
+	warehouse, err = setupWarehouse(appConfig.Warehouse)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to set up the warehouse")
+	}
+
+	// Record the columns proposed for new CSV columns, added once approved
+	schemaChanges = &GormSchemaChangeStore{db: db}
+
+	// Authenticate scripts and integrations by their API keys
+	apiKeys = &GormAPIKeyStore{db: db}
+
+	// Track the watermarks of streaming uploads
+	uploadStreams = &GormUploadStreamStore{db: db}
+
+	// Keep the named filters users save for the records list
+	savedFilters = &GormSavedFilterStore{db: db}
 
-	// Run the API on port 8080
-	log.Info("Starting server on port 8080")
-	if err := r.Run(":8080"); err != nil {
-		log.WithError(err).Fatal("Failed to start the server")
+	// Copy the records into named snapshots and restore them
+	snapshots = &GormSnapshotStore{db: db}
+
+	// Read the index statistics for the index advisor and create the indexes it suggests
+	indexes = &GormIndexStore{db: db}
+
+	// Check the database for the readiness probe, and list and cancel its running queries
+	databaseProbe = &GormDatabaseProbe{db: db}
+	queryActivity = &GormActivityStore{db: db}
+
+	// Search the records, and measure their table for the upload quota
+	recordSearch = &GormRecordSearcher{db: db}
+	tableSizer = &GormTableSizer{db: db}
+
+	// Run CSV imports in the background, tracking them in the import_jobs table
+	imports := newImportManager(&GormJobStore{db: db}, blobs)
+	go imports.watchStaleJobs(importHeartbeatTimeout)
+
+	// Create the indexes suggested for the columns record queries use, if enabled
+	if appConfig.Database.AutoIndex {
+		go autoCreateIndexes(indexes, indexAdvisorInterval)
 	}
+
+	// Set up API with the Database and DBHandler interfaces
+	r := setupAPI(gormDB, dbHandler, imports)
+
+	// Warm up the connection pool and the hot queries while /readyz reports unready
+	if appConfig.Server.Warmup {
+		warmup.start(warmupSteps(db, warmupConnections(appConfig.Database)), time.Duration(appConfig.Server.WarmupTimeout))
+	}
+
+	// Log the effective configuration so operators can verify it
+	log.WithField("config", effectiveConfig()).Info("Effective configuration")
+	if appConfig.Auth.Disabled {
+		log.Warn("Authentication is disabled (AUTH_DISABLED=true): requests without credentials may upload, change records and use the admin endpoints")
+	}
+
+	// Run the API until SIGINT or SIGTERM, then drain requests and imports before exiting
+	serve(r, imports, db, logFile)
 }
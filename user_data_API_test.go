@@ -113,7 +113,7 @@ func TestSetupAPI(t *testing.T) {
 
 	// Set up Gin engine with the actual database connection
 	gin.SetMode(gin.TestMode)
-	r := setupAPI(gormDB)
+	r := setupAPI(gormDB, &GormDBHandler{db: db}, newImportManager(&GormJobStore{db: db}, nil))
 
 	// Record the response for the /api/records endpoint
 	w := httptest.NewRecorder()
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation modes of imports
+const (
+	validationLenient = "lenient" // Rows failing validation are skipped and reported, the others imported
+	validationStrict  = "strict"  // The file is validated before any row is written and rejected if a row fails
+)
+
+// Accepted ages, in years
+const (
+	minAge = 0
+	maxAge = 120
+)
+
+// validGenders are the accepted genders, compared ignoring case; the gender may also be left empty
+var validGenders = []string{"male", "female", "non-binary", "other"}
+
+// fieldError is a value that failed validation
+type fieldError struct {
+	Field   string
+	Message string // e.g. "must be between 0 and 120"
+}
+
+// fieldErrors are the failed validations of a record, in field order
+type fieldErrors []fieldError
+
+// Fields returns the comma-separated names of the invalid fields, e.g. "age,gender"
+func (errs fieldErrors) Fields() string {
+	names := make([]string, len(errs))
+	for i, e := range errs {
+		names[i] = e.Field
+	}
+	return strings.Join(names, ",")
+}
+
+// Error describes every invalid field, e.g. "age must be between 0 and 120; gender must be ..."
+func (errs fieldErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Field + " " + e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// parseValidationMode checks a validation mode; an empty mode means the configured one
+func parseValidationMode(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		return appConfig.Ingestion.Validation, nil
+	case validationLenient, validationStrict:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported validation mode %q, expected lenient or strict", mode)
+	}
+}
+
+// validateFields checks the values of a record shared by imports and the records API
+func validateFields(email string, age int, salary float64, gender string) fieldErrors {
+	var errs fieldErrors
+	email = strings.TrimSpace(email)
+	// Addresses without a dot in the domain, e.g. jane@localhost, are valid but not expected in the data
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		errs = append(errs, fieldError{Field: "email", Message: "must be a valid email address"})
+	}
+	if age < minAge || age > maxAge {
+		errs = append(errs, fieldError{Field: "age", Message: fmt.Sprintf("must be between %d and %d", minAge, maxAge)})
+	}
+	if gender = strings.TrimSpace(gender); gender != "" && !containsFold(validGenders, gender) {
+		errs = append(errs, fieldError{Field: "gender", Message: "must be one of " + strings.Join(validGenders, ", ") + " or empty"})
+	}
+	if salary < 0 || math.IsNaN(salary) || math.IsInf(salary, 0) {
+		errs = append(errs, fieldError{Field: "salary", Message: "must be a non-negative number"})
+	}
+	return errs
+}
+
+// containsFold reports whether values holds value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRow checks an upload row in the order of csvColumns, including whether its age, salary and
+// date joined parse; dates may be written in any of the named formats
+func validateRow(record []string, formats []string) fieldErrors {
+	var errs fieldErrors
+	age, _, err := parseAge(record[4])
+	if err != nil {
+		errs = append(errs, fieldError{Field: "age", Message: fmt.Sprintf("must be a whole number, got %q", record[4])})
+	}
+	salary, _, err := parseSalary(record[8])
+	if err != nil {
+		errs = append(errs, fieldError{Field: "salary", Message: fmt.Sprintf("must be a number, got %q", record[8])})
+	}
+	errs = append(errs, validateFields(record[3], age, salary, record[5])...)
+	if _, err := parseDate(record[9], formats); err != nil {
+		errs = append(errs, dateFieldError(formats))
+	}
+	return errs
+}
+
+// validateImport reads the whole file without writing it, recording each row failing validation in progress.
+// It returns the number of invalid rows.
+func validateImport(ctx context.Context, file io.Reader, options importOptions, progress *importProgress) (int, error) {
+	buffered, decompressor, _, err := decompress(bufio.NewReader(&contextReader{ctx: ctx, r: file}))
+	if err != nil {
+		return 0, err
+	}
+	defer decompressor.Close()
+
+	ch := make(chan csvChunk, csvChannelBuffer)
+	stats := &readerStats{entry: progress.logger()}
+	startReader(buffered, options.format, options.sheet, options.columns, progress.evolvedColumns(), ch, stats)
+
+	invalid := 0
+	for chunk := range ch {
+		for i, record := range chunk.records {
+			if errs := validateRow(record, options.dateFormats); len(errs) > 0 {
+				progress.reject(chunk.lines[i], errs.Fields(), errs.Error(), record)
+				invalid++
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return invalid, fmt.Errorf("import cancelled: %w", context.Cause(ctx))
+	}
+	return invalid, stats.Err()
+}
+
+// validateSource runs the validation pass of strict imports over the task's source, returning an error
+// when the file can't be read or has invalid rows
+func validateSource(ctx context.Context, task importTask, progress *importProgress) error {
+	file, err := task.source.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open source: %w", err)
+	}
+	defer file.Close()
+
+	invalid, err := validateImport(ctx, file, task.options, progress)
+	if err != nil {
+		return err
+	}
+	if invalid > 0 {
+		return fmt.Errorf("file rejected: %d rows failed validation", invalid)
+	}
+	return nil
+}
+
+// bindRecord decodes the JSON body into record and validates it, answering 400 with an error per
+// invalid field. It returns false when a response has already been written.
+func bindRecord(c *gin.Context, record *UserDatas) bool {
+	var errs fieldErrors
+	err := c.ShouldBindJSON(record)
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, e := range validationErrs {
+			errs = append(errs, fieldError{Field: jsonFieldName(e.StructField()), Message: bindingMessage(e)})
+		}
+	case errors.As(err, &typeErr) && typeErr.Type == dateType:
+		errs = append(errs, dateFieldError(apiDateFormats))
+	case errors.As(err, &typeErr):
+		errs = append(errs, fieldError{Field: typeErr.Field, Message: fmt.Sprintf("has the wrong type: expected %s, got %s", typeErr.Type, typeErr.Value)})
+	case err != nil:
+		respondError(c, 400, "Invalid record", err.Error())
+		return false
+	}
+
+	// Fields the binding rejected aren't checked again
+	for _, e := range validateFields(record.Email, record.Age, record.Salary, record.Gender) {
+		if !strings.Contains(","+errs.Fields()+",", ","+e.Field+",") {
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) > 0 {
+		respondFieldErrors(c, 400, "Invalid record", errs)
+		return false
+	}
+	return true
+}
+
+// jsonFieldName returns the JSON name of a UserDatas field
+func jsonFieldName(field string) string {
+	if f, ok := reflect.TypeOf(UserDatas{}).FieldByName(field); ok {
+		if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" {
+			return name
+		}
+	}
+	return field
+}
+
+// bindingMessage describes a failed binding rule
+func bindingMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		return "must be at most " + e.Param() + " characters"
+	default:
+		return "failed the " + e.Tag() + " check"
+	}
+}
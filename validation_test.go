@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateFields tests the checks shared by imported rows and API records
+func TestValidateFields(t *testing.T) {
+	assert.Empty(t, validateFields("jane@example.com", 30, 50000, "Female"))
+	assert.Empty(t, validateFields(" jane@example.com", 0, 0, "NON-BINARY"))
+
+	errs := validateFields("Jane <jane@example.com>", 121, -1, "robot")
+	assert.Equal(t, "email,age,gender,salary", errs.Fields())
+	assert.Contains(t, errs.Error(), "age must be between 0 and 120")
+
+	errs = validateRow([]string{"", "Jane", "Doe", "jane", "old", "", "", "", "lots", "2020-02-30", ""}, nil)
+	assert.Equal(t, "age,salary,email,date_joined", errs.Fields())
+	assert.Empty(t, validateRow([]string{"", "Jane", "Doe", "jane@example.com", "30", "", "", "", "1", "31/01/2020", ""}, defaultDateFormats))
+}
+
+// TestImportValidationModes tests that lenient imports skip invalid rows while strict ones reject the file
+func TestImportValidationModes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store, final := newRecordingJobStore(ctrl)
+	blobs, err := newLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	imports := newImportManager(store, blobs)
+	overflow, _ := newOverflowHandler("")
+
+	csvData := "First Name,Last Name,Email,Age,Salary,Gender\n" +
+		"Jane,Doe,jane@example.com,30,50000,Female\n" +
+		"John,Doe,john@example.com,130,40000,robot\n"
+	run := func(validation string) *memorySink {
+		key, err := imports.storeUpload(context.Background(), strings.NewReader(csvData), int64(len(csvData)))
+		assert.NoError(t, err)
+		sink := &memorySink{}
+		_, err = imports.submit(importTask{
+			job:     &ImportJob{},
+			source:  &blobSource{blobs: blobs, key: key, remove: true},
+			sink:    sink,
+			options: importOptions{overflow: overflow, validation: validation},
+		})
+		assert.NoError(t, err)
+		imports.Wait()
+		return sink
+	}
+
+	sink := run(validationLenient)
+	assert.Equal(t, importDone, final().State)
+	assert.Len(t, sink.users, 1)
+	assert.Equal(t, int64(1), final().RowsSkipped)
+
+	sink = run(validationStrict)
+	assert.Equal(t, importFailed, final().State)
+	assert.Equal(t, "file rejected: 1 rows failed validation", final().Error)
+	assert.Empty(t, sink.users)
+	assert.Equal(t, int64(1), final().RowsSkipped)
+}
+
+// onceSource serves its file on the first Open only, like a download that can't be repeated
+type onceSource struct {
+	data  string
+	opens int
+}
+
+func (s *onceSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	s.opens++
+	if s.opens > 1 {
+		return io.NopCloser(strings.NewReader("First Name,Last Name,Email,Age,Salary\nJohn,Doe,john@example.com,130,40000\n")), nil
+	}
+	return io.NopCloser(strings.NewReader(s.data)), nil
+}
+
+// TestStrictImportSpoolsSource tests that strict imports read sources other than stored files once,
+// validating and writing the same copy and removing it afterwards
+func TestStrictImportSpoolsSource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store, final := newRecordingJobStore(ctrl)
+	dir := t.TempDir()
+	blobs, err := newLocalBlobStore(dir)
+	assert.NoError(t, err)
+	imports := newImportManager(store, blobs)
+	overflow, _ := newOverflowHandler("")
+
+	source := &onceSource{data: "First Name,Last Name,Email,Age,Salary\nJane,Doe,jane@example.com,30,50000\n"}
+	sink := &memorySink{}
+	_, err = imports.submit(importTask{
+		job:     &ImportJob{},
+		source:  source,
+		sink:    sink,
+		options: importOptions{overflow: overflow, validation: validationStrict},
+	})
+	assert.NoError(t, err)
+	imports.Wait()
+
+	assert.Equal(t, importDone, final().State)
+	assert.Equal(t, 1, source.opens)
+	if assert.Len(t, sink.users, 1) {
+		assert.Equal(t, "Jane", sink.users[0].FirstName)
+	}
+	uploads, _ := os.ReadDir(filepath.Join(dir, "uploads"))
+	assert.Empty(t, uploads)
+}
+
+// TestRecordFieldErrors tests that invalid record bodies are answered with an error per field
+func TestRecordFieldErrors(t *testing.T) {
+	disableAuth(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+
+	w := serveRecord(r, "POST", "/api/records", `{"email":"jane@example","age":121,"gender":"robot"}`)
+	assert.Equal(t, 400, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `{"message":"Invalid record","field":"first_name","details":"is required"}`)
+	assert.Contains(t, body, `{"message":"Invalid record","field":"email","details":"must be a valid email address"}`)
+	assert.Contains(t, body, `{"message":"Invalid record","field":"age","details":"must be between 0 and 120"}`)
+	assert.Contains(t, body, `"field":"gender"`)
+
+	w = serveRecord(r, "POST", "/api/records", `{"first_name":"Jane","email":"jane@example.com","age":"thirty"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), `{"message":"Invalid record","field":"age","details":"has the wrong type: expected int, got string"}`)
+}
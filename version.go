@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build information, set when building with
+// -ldflags "-X main.version=1.4.0 -X main.gitCommit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)".
+// Builds without them fall back to the VCS details the Go toolchain embeds.
+var (
+	version   = "dev"
+	gitCommit = ""
+	buildTime = ""
+)
+
+// buildInfo identifies the running build
+type buildInfo struct {
+	Version   string   `json:"version"`
+	GitCommit string   `json:"git_commit"`
+	Modified  bool     `json:"modified"` // Built from a working tree with uncommitted changes, as far as known
+	BuildTime string   `json:"build_time"`
+	GoVersion string   `json:"go_version"`
+	Platform  string   `json:"platform"`
+	Hostname  string   `json:"hostname"`
+	Features  []string `json:"features"` // Enabled optional features, sorted
+}
+
+// currentBuildInfo returns the build information of the running binary
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Features:  enabledFeatures(),
+	}
+	info.Hostname, _ = os.Hostname()
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range embedded.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.GitCommit == "":
+				info.GitCommit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value // Time of the commit, the closest to a build time there is
+			case setting.Key == "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// enabledFeatures lists the optional features the instance runs with
+func enabledFeatures() []string {
+	enabled, _ := readOnly.state()
+	flags := map[string]bool{
+		"api_keys":          apiKeys != nil,
+		"auth":              auth != nil,
+		"auto_index":        appConfig.Database.AutoIndex,
+		"client_certs":      clientCerts != nil,
+		"copy_ingest":       appConfig.Ingestion.InsertMethod == insertMethodCopy,
+		"custom_auth":       len(customAuthenticators) > 0,
+		"error_reporting":   os.Getenv("SENTRY_DSN") != "",
+		"query_tags":        appConfig.Database.QueryTags,
+		"read_only":         enabled,
+		"schema_evolution":  appConfig.Ingestion.SchemaEvolution,
+		"slow_query_log":    appConfig.Database.SlowQueryThreshold > 0,
+		"slow_request_log":  appConfig.Server.SlowRequestThreshold > 0,
+		"strict_validation": appConfig.Ingestion.Validation == validationStrict,
+		"warehouse":         appConfig.Warehouse.Backend != "",
+		"warmup":            appConfig.Server.Warmup,
+	}
+	features := []string{}
+	for name, on := range flags {
+		if on {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
+
+// versionHandler handles GET /api/version, telling support which build a deployment runs
+func versionHandler(c *gin.Context) {
+	respond(c, 200, currentBuildInfo(), nil)
+}
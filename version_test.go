@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestVersionEndpoint tests that the build information set with ldflags and the enabled features are reported
+func TestVersionEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previousVersion, previousCommit, previousTime := version, gitCommit, buildTime
+	defer func() { version, gitCommit, buildTime = previousVersion, previousCommit, previousTime }()
+	version, gitCommit, buildTime = "1.4.0", "3f2a9c1", "2026-10-01T12:00:00Z"
+
+	previous := appConfig
+	defer func() { appConfig = previous }()
+	appConfig.Server.Warmup = true
+	appConfig.Server.SlowRequestThreshold = Duration(time.Second)
+	appConfig.Warehouse.Backend = ""
+	t.Setenv("SENTRY_DSN", "")
+
+	gin.SetMode(gin.TestMode)
+	r := setupAPI(NewMockDatabase(ctrl), NewMockDBHandler(ctrl), newImportManager(NewMockJobStore(ctrl), nil))
+	w := serveRecord(r, "GET", "/api/version", "")
+	assert.Equal(t, 200, w.Code)
+
+	var body struct {
+		Data buildInfo `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "1.4.0", body.Data.Version)
+	assert.Equal(t, "3f2a9c1", body.Data.GitCommit)
+	assert.Equal(t, "2026-10-01T12:00:00Z", body.Data.BuildTime)
+	assert.Equal(t, runtime.Version(), body.Data.GoVersion)
+	assert.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, body.Data.Platform)
+	assert.Contains(t, body.Data.Features, "warmup")
+	assert.Contains(t, body.Data.Features, "slow_request_log")
+	assert.NotContains(t, body.Data.Features, "warehouse")
+	assert.NotContains(t, body.Data.Features, "error_reporting")
+	assert.IsNonDecreasing(t, body.Data.Features)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Supported warehouse backends; an empty backend disables the write-through
+const warehouseClickHouse = "clickhouse"
+
+// warehouseTimeout bounds each warehouse write, so a slow warehouse can't stall imports
+const warehouseTimeout = 30 * time.Second
+
+// warehouseTableName matches table names that are safe to use in a query, optionally with a database
+var warehouseTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// warehouse receives a copy of every batch committed to PostgreSQL; nil when no warehouse is configured
+var warehouse Sink
+
+// setupWarehouse creates the writer of the configured warehouse, or nil if none is configured
+func setupWarehouse(config WarehouseConfig) (Sink, error) {
+	switch config.Backend {
+	case "":
+		return nil, nil
+	case warehouseClickHouse:
+		return newClickHouseSink(config)
+	default:
+		return nil, fmt.Errorf("unsupported warehouse backend %q", config.Backend)
+	}
+}
+
+// clickHouseSink inserts rows into a ClickHouse table through its HTTP interface
+type clickHouseSink struct {
+	client   *http.Client
+	endpoint string // URL of the insert query
+	user     string
+	password string
+	ctx      context.Context
+}
+
+// newClickHouseSink creates a writer for the configured ClickHouse table
+func newClickHouseSink(config WarehouseConfig) (*clickHouseSink, error) {
+	if !warehouseTableName.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid warehouse table %q", config.Table)
+	}
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid warehouse url: %w", err)
+	}
+	query := endpoint.Query()
+	query.Set("query", fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", config.Table))
+	query.Set("input_format_skip_unknown_fields", "1") // The table may keep only some of the columns
+	endpoint.RawQuery = query.Encode()
+
+	return &clickHouseSink{
+		client:   &http.Client{Timeout: warehouseTimeout},
+		endpoint: endpoint.String(),
+		user:     config.User,
+		password: config.Password,
+		ctx:      context.Background(),
+	}, nil
+}
+
+// WithContext returns a copy of the sink whose inserts are cancelled with ctx
+func (s *clickHouseSink) WithContext(ctx context.Context) Sink {
+	scoped := *s
+	scoped.ctx = ctx
+	return &scoped
+}
+
+// Write inserts the rows in a single request; ClickHouse inserts the block of rows atomically
+func (s *clickHouseSink) Write(users []UserData) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, user := range users {
+		if err := encoder.Encode(user); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	if s.user != "" {
+		req.Header.Set("X-ClickHouse-User", s.user)
+		req.Header.Set("X-ClickHouse-Key", s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse insert failed with status %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// teeStats counts the rows copied to the warehouse during an import
+type teeStats struct {
+	written atomic.Int64
+	failed  atomic.Int64
+}
+
+// teeSink writes each batch to the primary sink and, once it is committed there, copies it to the warehouse.
+// Warehouse failures are logged and counted but don't fail the batch, since the rows are already committed.
+type teeSink struct {
+	primary   Sink
+	warehouse Sink
+	stats     *teeStats
+}
+
+// newTeeSink copies the batches written to primary to the warehouse
+func newTeeSink(primary, warehouse Sink) *teeSink {
+	return &teeSink{primary: primary, warehouse: warehouse, stats: &teeStats{}}
+}
+
+// WithContext scopes both sinks to ctx, sharing the counters of the import
+func (s *teeSink) WithContext(ctx context.Context) Sink {
+	scoped := *s
+	if sink, ok := s.primary.(contextSink); ok {
+		scoped.primary = sink.WithContext(ctx)
+	}
+	if sink, ok := s.warehouse.(contextSink); ok {
+		scoped.warehouse = sink.WithContext(ctx)
+	}
+	return &scoped
+}
+
+// Write writes to the primary sink first and only copies batches that were committed
+func (s *teeSink) Write(users []UserData) error {
+	if err := s.primary.Write(users); err != nil {
+		return err
+	}
+	s.copy(users)
+	return nil
+}
+
+// Transaction runs fn in a transaction of the primary sink and copies the batches written in it to the
+// warehouse once it is committed; batches of a rolled back transaction aren't copied
+func (s *teeSink) Transaction(fn func(tx Sink) error) error {
+	primary, ok := s.primary.(transactionalSink)
+	if !ok {
+		return fn(s)
+	}
+	var written [][]UserData
+	err := primary.Transaction(func(tx Sink) error {
+		return fn(&batchRecorder{sink: tx, written: &written})
+	})
+	if err != nil {
+		return err
+	}
+	for _, users := range written {
+		s.copy(users)
+	}
+	return nil
+}
+
+// batchRecorder notes the batches its sink wrote successfully
+type batchRecorder struct {
+	sink    Sink
+	written *[][]UserData
+}
+
+// Write writes to the sink and notes the batch when it was written
+func (r *batchRecorder) Write(users []UserData) error {
+	if err := r.sink.Write(users); err != nil {
+		return err
+	}
+	*r.written = append(*r.written, users)
+	return nil
+}
+
+// copy writes committed rows to the warehouse
+func (s *teeSink) copy(users []UserData) {
+	if err := s.warehouse.Write(users); err != nil {
+		s.stats.failed.Add(int64(len(users)))
+		log.WithError(err).WithField("rows", len(users)).Error("Warehouse write failed")
+		sentry.CaptureException(fmt.Errorf("warehouse write of %d records failed: %w", len(users), err))
+		return
+	}
+	s.stats.written.Add(int64(len(users)))
+}
+
+// report returns the warehouse counters for the import metrics
+func (s *teeSink) report() map[string]interface{} {
+	return map[string]interface{}{
+		"warehouse_rows_written": s.stats.written.Load(),
+		"warehouse_rows_failed":  s.stats.failed.Load(),
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingSink rejects every write
+type failingSink struct {
+	err    error
+	writes int
+}
+
+func (s *failingSink) Write(users []UserData) error {
+	s.writes++
+	return s.err
+}
+
+// TestClickHouseSink tests inserting rows through the ClickHouse HTTP interface
+func TestClickHouseSink(t *testing.T) {
+	var query, user, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		user = r.Header.Get("X-ClickHouse-User")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if r.URL.Query().Get("database") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "Code: 81. DB::Exception: Database missing does not exist\n")
+		}
+	}))
+	defer server.Close()
+
+	sink, err := newClickHouseSink(WarehouseConfig{URL: server.URL, Table: "analytics.user_data", User: "importer", Password: "secret"})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write([]UserData{
+		{ID: 1, FirstName: "Jane", Email: "jane@example.com", Age: 30, Salary: 50000, DateJoined: newDate(2022, 1, 1), IsActive: true},
+		{ID: 2, FirstName: "Jim"},
+	}))
+	assert.Equal(t, "INSERT INTO analytics.user_data FORMAT JSONEachRow", query)
+	assert.Equal(t, "importer", user)
+	assert.Equal(t, `{"id":1,"first_name":"Jane","last_name":"","email":"jane@example.com","age":30,"gender":"","department":"","company":"","salary":50000,"date_joined":"2022-01-01","is_active":true}`+"\n"+
+		`{"id":2,"first_name":"Jim","last_name":"","email":"","age":0,"gender":"","department":"","company":"","salary":0,"date_joined":null,"is_active":false}`+"\n", body)
+
+	// Errors of the server are returned with its message
+	sink, err = newClickHouseSink(WarehouseConfig{URL: server.URL + "/?database=missing", Table: "user_data"})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, sink.Write([]UserData{{ID: 1}}), "Database missing does not exist")
+
+	_, err = newClickHouseSink(WarehouseConfig{URL: server.URL, Table: "user_data; DROP TABLE x"})
+	assert.ErrorContains(t, err, "invalid warehouse table")
+}
+
+// TestTeeSink tests that only committed batches are copied and that warehouse failures don't fail the import
+func TestTeeSink(t *testing.T) {
+	primary := &memorySink{}
+	copied := &memorySink{}
+	tee := newTeeSink(primary, copied)
+	assert.NoError(t, tee.Write([]UserData{{FirstName: "Jane"}, {FirstName: "Jim"}}))
+	assert.Len(t, primary.users, 2)
+	assert.Len(t, copied.users, 2)
+
+	// Batches the primary sink rejects are not copied
+	rejected := &failingSink{err: errors.New("duplicate key")}
+	tee = newTeeSink(rejected, copied)
+	assert.EqualError(t, tee.Write([]UserData{{FirstName: "Jane"}}), "duplicate key")
+	assert.Len(t, copied.users, 2)
+
+	// Warehouse failures are counted, the rows stay committed
+	unavailable := &failingSink{err: errors.New("connection refused")}
+	tee = newTeeSink(primary, unavailable)
+	assert.NoError(t, tee.Write([]UserData{{FirstName: "Joe"}}))
+	assert.NoError(t, tee.Write([]UserData{{FirstName: "Ann"}, {FirstName: "Bob"}}))
+	assert.Len(t, primary.users, 5)
+	assert.Equal(t, 2, unavailable.writes)
+	assert.Equal(t, map[string]interface{}{"warehouse_rows_written": int64(0), "warehouse_rows_failed": int64(3)}, tee.report())
+}
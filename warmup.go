@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// States of the startup warm-up reported by /readyz
+const (
+	warmupRunning = "running"
+	warmupDone    = "ok"
+	warmupFailed  = "failed" // Some step failed; the server is ready anyway, only the first requests are slower
+)
+
+// warmupStep is a named part of the startup warm-up
+type warmupStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// warmupState tracks the startup warm-up, which keeps /readyz unready while it runs
+type warmupState struct {
+	mu    sync.Mutex
+	state string // Empty when no warm-up was started
+}
+
+// warmup is the startup warm-up of the server
+var warmup = &warmupState{}
+
+// status returns the state of the warm-up, empty when none was started
+func (w *warmupState) status() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+// start runs the steps in the background, each after the other, until they are done or timeout passes.
+// A failing step is logged and the next one still runs. The returned channel is closed once finished.
+func (w *warmupState) start(steps []warmupStep, timeout time.Duration) <-chan struct{} {
+	w.mu.Lock()
+	w.state = warmupRunning
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		started := time.Now()
+		state := warmupDone
+		for _, step := range steps {
+			stepStarted := time.Now()
+			entry := log.WithField("step", step.name)
+			if err := step.run(ctx); err != nil {
+				entry.WithError(err).Warn("Warm-up step failed")
+				state = warmupFailed
+				continue
+			}
+			entry.WithField("duration_ms", time.Since(stepStarted).Milliseconds()).Info("Warm-up step done")
+		}
+
+		w.mu.Lock()
+		w.state = state
+		w.mu.Unlock()
+		log.WithFields(logrus.Fields{"state": state, "duration_ms": time.Since(started).Milliseconds()}).Info("Warm-up finished")
+	}()
+	return done
+}
+
+// warmupConnections is the number of connections the warm-up opens: those the pool keeps idle
+func warmupConnections(config DatabaseConfig) int {
+	n := config.MaxIdleConns
+	if config.MaxOpenConns > 0 && config.MaxOpenConns < n {
+		n = config.MaxOpenConns
+	}
+	return max(n, 1)
+}
+
+// warmupSteps returns the steps warming up db: opening the idle connections of the pool, then running the
+// queries of the busiest routes on them. pgx prepares and caches statements per connection, and the
+// queries load the table into PostgreSQL's buffer cache, so the first requests find both ready.
+func warmupSteps(db *gorm.DB, conns int) []warmupStep {
+	return []warmupStep{
+		{name: "connections", run: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return openConnections(ctx, sqlDB, conns)
+		}},
+		{name: "queries", run: func(ctx context.Context) error {
+			// Run from as many goroutines as there are idle connections, so each connection is likely used
+			errs := make([]error, conns)
+			var wg sync.WaitGroup
+			for i := 0; i < conns; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = warmupQueries(db.WithContext(ctx))
+				}(i)
+			}
+			wg.Wait()
+			return errors.Join(errs...)
+		}},
+	}
+}
+
+// openConnections opens n connections of the pool at once and returns them to it, where they stay idle
+func openConnections(ctx context.Context, sqlDB *sql.DB, n int) error {
+	var conns []*sql.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+		if err := conn.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warmupQueries runs the statements of the first records page and the default stats
+func warmupQueries(db *gorm.DB) error {
+	var total int64
+	if err := db.Model(&UserDatas{}).Count(&total).Error; err != nil {
+		return err
+	}
+	order, _ := parseRecordSort("")
+	if err := db.Order(order).Limit(10).Find(&[]UserDatas{}).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&UserDatas{}).Select(salaryAggregates).Scan(&[]salaryStat{}).Error; err != nil {
+		return err
+	}
+	groupExpr := pivotDimensions["department"]
+	return db.Model(&UserDatas{}).Select(groupExpr + " AS key, " + headcountAggregates).Group(groupExpr).Order("key").Scan(&[]headcountStat{}).Error
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWarmupReadiness tests that /readyz stays unready while the warm-up runs, even when a step fails
+func TestWarmupReadiness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	previous := warmup
+	defer func() { warmup = previous }()
+	warmup = &warmupState{}
+
+	probe := NewMockDatabaseProbe(ctrl)
+	probe.EXPECT().Ping().Return(nil).AnyTimes()
+	probe.EXPECT().SchemaDrift().Return(nil, nil)
+	probe.EXPECT().PoolStats().Return(sql.DBStats{}, nil).AnyTimes()
+
+	gin.SetMode(gin.TestMode)
+	ready := &readiness{}
+	r := gin.New()
+	r.GET("/readyz", func(c *gin.Context) {
+		ready.readyz(c, probe)
+	})
+	readyz := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without a warm-up the probe doesn't report one
+	assert.NotContains(t, readyz().Body.String(), "warmup")
+
+	release := make(chan struct{})
+	var ran []string
+	done := warmup.start([]warmupStep{
+		{name: "connections", run: func(ctx context.Context) error {
+			<-release
+			ran = append(ran, "connections")
+			return errors.New("too many connections")
+		}},
+		{name: "queries", run: func(ctx context.Context) error {
+			ran = append(ran, "queries")
+			return nil
+		}},
+	}, time.Minute)
+
+	w := readyz()
+	assert.Equal(t, 503, w.Code)
+	assert.Contains(t, w.Body.String(), `"warmup":"running"`)
+
+	close(release)
+	<-done
+	assert.Equal(t, []string{"connections", "queries"}, ran)
+	w = readyz()
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"warmup":"failed"`)
+}
+
+// TestWarmupTimeout tests that steps are cancelled once the warm-up timeout passes
+func TestWarmupTimeout(t *testing.T) {
+	state := &warmupState{}
+	<-state.start([]warmupStep{{name: "queries", run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}}, 10*time.Millisecond)
+	assert.Equal(t, warmupFailed, state.status())
+}
+
+// TestWarmupConnections tests that the warm-up opens the connections the pool keeps idle
+func TestWarmupConnections(t *testing.T) {
+	assert.Equal(t, 5, warmupConnections(DatabaseConfig{MaxOpenConns: 25, MaxIdleConns: 5}))
+	assert.Equal(t, 2, warmupConnections(DatabaseConfig{MaxOpenConns: 2, MaxIdleConns: 5}))
+	assert.Equal(t, 1, warmupConnections(DatabaseConfig{}))
+}
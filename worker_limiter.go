@@ -0,0 +1,115 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Ingestion worker autoscaling settings
+const (
+	defaultIngestMinWorkers  = 2               // Lower bound of concurrent chunk workers unless configured
+	ingestTargetChunkLatency = 2 * time.Second // Chunk insert latency above which concurrency is reduced
+	latencySmoothing         = 0.3             // Weight of the newest sample in the latency moving average
+)
+
+// ingestMaxWorkers is the size of the chunk worker pool and the upper bound of concurrent inserts
+func ingestMaxWorkers() int {
+	if appConfig.Ingestion.MaxWorkers > 0 {
+		return appConfig.Ingestion.MaxWorkers
+	}
+	return runtime.NumCPU() * workersPerCPU
+}
+
+// ingestMinWorkers is the lower bound of concurrent inserts, lowered to the maximum so a small
+// configured pool is never raised
+func ingestMinWorkers() int {
+	minWorkers := appConfig.Ingestion.MinWorkers
+	if minWorkers < 1 {
+		minWorkers = defaultIngestMinWorkers
+	}
+	return min(minWorkers, ingestMaxWorkers())
+}
+
+// adaptiveLimiter bounds concurrent chunk workers and resizes the bound between min and max:
+// it grows while chunks are queued and the DB keeps up, and shrinks when inserts get slow.
+type adaptiveLimiter struct {
+	mu            sync.Mutex
+	cond          *sync.Cond
+	min           int
+	max           int
+	limit         int
+	inUse         int
+	waiting       int
+	avgLatency    time.Duration
+	targetLatency time.Duration
+	queueDepth    func() int
+}
+
+// newAdaptiveLimiter creates a limiter starting at min; queueDepth reports chunks not yet handed
+// to a worker, in addition to the workers blocked in Acquire
+func newAdaptiveLimiter(min, max int, targetLatency time.Duration, queueDepth func() int) *adaptiveLimiter {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	l := &adaptiveLimiter{min: min, max: max, limit: min, targetLatency: targetLatency, queueDepth: queueDepth}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Acquire blocks until a worker slot is free
+func (l *adaptiveLimiter) Acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inUse >= l.limit {
+		l.waiting++
+		l.cond.Wait()
+		l.waiting--
+	}
+	l.inUse++
+}
+
+// Release frees a worker slot and feeds the chunk latency into the scaling decision
+func (l *adaptiveLimiter) Release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inUse--
+	if l.avgLatency == 0 {
+		l.avgLatency = latency
+	} else {
+		l.avgLatency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(l.avgLatency))
+	}
+	l.adjust()
+	l.cond.Broadcast()
+}
+
+// adjust resizes the limit based on DB latency and queue depth; callers must hold mu
+func (l *adaptiveLimiter) adjust() {
+	previous := l.limit
+	switch {
+	case l.avgLatency > l.targetLatency && l.limit > l.min:
+		l.limit--
+	case l.avgLatency <= l.targetLatency && l.limit < l.max && l.waiting+l.queueDepth() > 0:
+		l.limit++
+	}
+
+	if l.limit != previous {
+		log.WithFields(logrus.Fields{
+			"workers":     l.limit,
+			"avg_latency": l.avgLatency.String(),
+		}).Debug("Adjusted ingestion concurrency")
+	}
+}
+
+// Limit returns the current concurrency bound
+func (l *adaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAdaptiveLimiterScaling tests growing under backlog and shrinking on slow inserts
+func TestAdaptiveLimiterScaling(t *testing.T) {
+	queued := 5
+	limiter := newAdaptiveLimiter(2, 4, 100*time.Millisecond, func() int { return queued })
+	assert.Equal(t, 2, limiter.Limit())
+
+	// Fast inserts with a backlog grow the pool up to max
+	for i := 0; i < 5; i++ {
+		limiter.Acquire()
+		limiter.Release(10 * time.Millisecond)
+	}
+	assert.Equal(t, 4, limiter.Limit())
+
+	// Slow inserts shrink the pool down to min
+	for i := 0; i < 10; i++ {
+		limiter.Acquire()
+		limiter.Release(time.Second)
+	}
+	assert.Equal(t, 2, limiter.Limit())
+
+	// Without a backlog the pool doesn't grow
+	queued = 0
+	for i := 0; i < 10; i++ {
+		limiter.Acquire()
+		limiter.Release(time.Millisecond)
+	}
+	assert.Equal(t, 2, limiter.Limit())
+}
+
+// TestAdaptiveLimiterBlocks tests that Acquire blocks when all slots are in use
+func TestAdaptiveLimiterBlocks(t *testing.T) {
+	limiter := newAdaptiveLimiter(1, 1, time.Second, func() int { return 0 })
+	limiter.Acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Acquire should block while the only slot is in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.Release(time.Millisecond)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire should succeed after Release")
+	}
+}
+
+// concurrencySink records the most writes it saw running at once
+type concurrencySink struct {
+	active  atomic.Int32
+	maxSeen atomic.Int32
+	mu      sync.Mutex
+	rows    int
+}
+
+func (s *concurrencySink) Write(users []UserData) error {
+	active := s.active.Add(1)
+	defer s.active.Add(-1)
+	for {
+		seen := s.maxSeen.Load()
+		if active <= seen || s.maxSeen.CompareAndSwap(seen, active) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	s.mu.Lock()
+	s.rows += len(users)
+	s.mu.Unlock()
+	return nil
+}
+
+// TestRunImportWorkerPool tests that the configured pool size bounds concurrent writes
+func TestRunImportWorkerPool(t *testing.T) {
+	defer func(ingestion IngestionConfig) { appConfig.Ingestion = ingestion }(appConfig.Ingestion)
+	appConfig.Ingestion.MaxWorkers = 2
+	appConfig.Ingestion.ChunkSize = 1
+	assert.Equal(t, 2, ingestMaxWorkers())
+
+	var csvData strings.Builder
+	csvData.WriteString("ID,FirstName,LastName,Email,Age,Gender,Department,Company,Salary,DateJoined,IsActive\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&csvData, "%d,Jane,Doe,jane%d@example.com,30,Female,IT,Acme,50000,2022-01-01,true\n", i, i)
+	}
+
+	sink := &concurrencySink{}
+	overflow, _ := newOverflowHandler("")
+	_, err := runImport(context.Background(), strings.NewReader(csvData.String()), sink, importOptions{overflow: overflow}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, sink.rows)
+	assert.LessOrEqual(t, sink.maxSeen.Load(), int32(2))
+}
+
+// TestIngestWorkerBounds tests the configured bounds, with a maximum of 1 never raised to the default minimum
+func TestIngestWorkerBounds(t *testing.T) {
+	defer func(ingestion IngestionConfig) { appConfig.Ingestion = ingestion }(appConfig.Ingestion)
+	appConfig.Ingestion.MinWorkers = 0
+	appConfig.Ingestion.MaxWorkers = 8
+	assert.Equal(t, defaultIngestMinWorkers, ingestMinWorkers())
+	assert.Equal(t, 8, ingestMaxWorkers())
+
+	appConfig.Ingestion.MinWorkers = 3
+	assert.Equal(t, 3, ingestMinWorkers())
+
+	appConfig.Ingestion.MinWorkers = 0
+	appConfig.Ingestion.MaxWorkers = 1
+	appConfig.Ingestion.ChunkSize = 1
+	assert.Equal(t, 1, ingestMinWorkers())
+	assert.Equal(t, 1, ingestMaxWorkers())
+
+	var csvData strings.Builder
+	csvData.WriteString("ID,FirstName,LastName,Email,Age,Gender,Department,Company,Salary,DateJoined,IsActive\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&csvData, "%d,Jane,Doe,jane%d@example.com,30,Female,IT,Acme,50000,2022-01-01,true\n", i, i)
+	}
+
+	sink := &concurrencySink{}
+	overflow, _ := newOverflowHandler("")
+	_, err := runImport(context.Background(), strings.NewReader(csvData.String()), sink, importOptions{overflow: overflow}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, sink.rows)
+	assert.Equal(t, int32(1), sink.maxSeen.Load())
+}
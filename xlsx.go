@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Supported upload file formats
+const (
+	fileFormatCSV    = "csv"
+	fileFormatXLSX   = "xlsx"
+	fileFormatNDJSON = "ndjson"
+)
+
+// zipMagic starts every zip archive, and so every .xlsx workbook
+var zipMagic = []byte("PK\x03\x04")
+
+// utf8BOM is the byte order mark some tools write at the start of UTF-8 files
+var utf8BOM = []byte("\ufeff")
+
+// formatSniffLength is how many leading bytes are looked at to tell the format of a file
+const formatSniffLength = 512
+
+// detectFileFormat tells workbooks, NDJSON and CSV files apart by their first bytes, without consuming
+// them. NDJSON starts with the '{' of its first object, which no CSV header does.
+func detectFileFormat(r *bufio.Reader) string {
+	head, _ := r.Peek(formatSniffLength)
+	if bytes.HasPrefix(head, zipMagic) {
+		return fileFormatXLSX
+	}
+	if text := bytes.TrimLeft(bytes.TrimPrefix(head, utf8BOM), " \t\r\n"); len(text) > 0 && text[0] == '{' {
+		return fileFormatNDJSON
+	}
+	return fileFormatCSV
+}
+
+// startReader starts reading the CSV, XLSX or NDJSON file into ch in chunks of the configured size,
+// returning its format. The format is detected from the content unless one is given.
+func startReader(r *bufio.Reader, format, sheet string, mapping columnMapping, evolved []string, ch chan<- csvChunk, stats *readerStats) string {
+	if format == "" {
+		format = detectFileFormat(r)
+	}
+	switch format {
+	case fileFormatXLSX:
+		go readXLSXChunk(r, sheet, mapping, evolved, appConfig.Ingestion.ChunkSize, ch, stats)
+	case fileFormatNDJSON:
+		go readNDJSONChunk(r, mapping, evolved, appConfig.Ingestion.ChunkSize, ch, stats)
+	default:
+		go readCSVChunk(r, mapping, evolved, appConfig.Ingestion.ChunkSize, ch, stats)
+	}
+	return format
+}
+
+// readXLSXChunk reads the rows of a workbook sheet in chunks and sends them to a channel like readCSVChunk.
+// The first sheet is read when sheet is empty. Cells are read as displayed, except that
+// boolean TRUE/FALSE cells become true/false. Columns are matched by the names in the header row.
+func readXLSXChunk(file io.Reader, sheet string, mapping columnMapping, evolved []string, chunkSize int, ch chan<- csvChunk, stats *readerStats) {
+	defer close(ch)
+
+	fail := func(err error) {
+		stats.logger().WithError(err).Error("Error reading XLSX file")
+		stats.fail(err)
+	}
+
+	workbook, err := excelize.OpenReader(file)
+	if err != nil {
+		fail(fmt.Errorf("failed to open workbook: %w", err))
+		return
+	}
+	defer workbook.Close()
+
+	if sheet == "" {
+		sheet = workbook.GetSheetName(0)
+	}
+	if index, err := workbook.GetSheetIndex(sheet); err != nil || index < 0 {
+		fail(fmt.Errorf("workbook has no sheet %q, its sheets are %v", sheet, workbook.GetSheetList()))
+		return
+	}
+
+	rows, err := workbook.Rows(sheet)
+	if err != nil {
+		fail(fmt.Errorf("failed to read sheet %q: %w", sheet, err))
+		return
+	}
+	defer rows.Close()
+
+	var layout columnLayout
+	line := 0
+	chunk := csvChunk{records: make([][]string, 0, chunkSize), lines: make([]int, 0, chunkSize)}
+	for rows.Next() {
+		line++
+		record, err := rows.Columns()
+		if err != nil {
+			fail(fmt.Errorf("failed to read row %d of sheet %q: %w", line, sheet, err))
+			return
+		}
+		if line == 1 {
+			if layout, err = resolveColumns(record, mapping); err != nil {
+				fail(err)
+				return
+			}
+			layout = layout.extend(record, evolved)
+			stats.skipColumns(unmappedColumns(record, layout))
+			continue
+		}
+		if len(record) == 0 {
+			continue // Skip blank rows
+		}
+
+		record = layout.project(record) // Also pads rows whose trailing cells are empty
+		for i, value := range record {
+			switch value {
+			case "TRUE":
+				record[i] = "true"
+			case "FALSE":
+				record[i] = "false"
+			}
+		}
+
+		chunk.records = append(chunk.records, record)
+		chunk.lines = append(chunk.lines, line)
+		if len(chunk.records) == chunkSize {
+			sendChunk(ch, chunk, stats)
+			chunk = csvChunk{records: make([][]string, 0, chunkSize), lines: make([]int, 0, chunkSize)}
+		}
+	}
+	if err := rows.Error(); err != nil {
+		fail(fmt.Errorf("failed to read sheet %q: %w", sheet, err))
+		return
+	}
+	if len(chunk.records) > 0 {
+		sendChunk(ch, chunk, stats) // Send the last chunk
+	}
+}
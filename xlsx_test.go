@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+// newTestWorkbook returns an .xlsx file with the rows on a sheet named Employees after an empty first sheet
+func newTestWorkbook(t *testing.T, rows [][]interface{}) []byte {
+	workbook := excelize.NewFile()
+	defer workbook.Close()
+	_, err := workbook.NewSheet("Employees")
+	assert.NoError(t, err)
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		assert.NoError(t, workbook.SetSheetRow("Employees", cell, &row))
+	}
+	buf, err := workbook.WriteToBuffer()
+	assert.NoError(t, err)
+	return buf.Bytes()
+}
+
+// TestDetectFileFormat tests telling workbooks, NDJSON and CSV files apart by their content
+func TestDetectFileFormat(t *testing.T) {
+	workbook := newTestWorkbook(t, nil)
+	assert.Equal(t, fileFormatXLSX, detectFileFormat(bufio.NewReader(bytes.NewReader(workbook))))
+	assert.Equal(t, fileFormatCSV, detectFileFormat(bufio.NewReader(strings.NewReader("ID,First Name\n"))))
+	assert.Equal(t, fileFormatCSV, detectFileFormat(bufio.NewReader(strings.NewReader(""))))
+	assert.Equal(t, fileFormatNDJSON, detectFileFormat(bufio.NewReader(strings.NewReader("\ufeff\n  {\"email\": \"jane@example.com\"}\n"))))
+}
+
+// TestReadXLSXChunk tests reading a selected sheet into chunks with line numbers
+func TestReadXLSXChunk(t *testing.T) {
+	workbook := newTestWorkbook(t, [][]interface{}{
+		{"ID", "First Name", "Last Name", "Email", "Age", "Gender", "Department", "Company", "Salary", "Date Joined", "Is Active"},
+		{1, "Jane", "Doe", "jane@example.com", 30, "Female", "IT", "Acme", 50000, "2022-01-01", true},
+		{},
+		{2, "Jim", "Doe", "jim@example.com", 41, "Male", "HR", "Acme", 40000.5, "2021-06-01"},
+	})
+
+	ch := make(chan csvChunk, 4)
+	stats := &readerStats{}
+	readXLSXChunk(bytes.NewReader(workbook), "Employees", nil, nil, 1, ch, stats)
+	assert.NoError(t, stats.Err())
+
+	var chunks []csvChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, []string{"1", "Jane", "Doe", "jane@example.com", "30", "Female", "IT", "Acme", "50000", "2022-01-01", "true"}, chunks[0].records[0])
+	assert.Equal(t, []int{2}, chunks[0].lines)
+	assert.Equal(t, []string{"2", "Jim", "Doe", "jim@example.com", "41", "Male", "HR", "Acme", "40000.5", "2021-06-01", ""}, chunks[1].records[0])
+	assert.Equal(t, []int{4}, chunks[1].lines)
+
+	// Unknown sheets stop the import
+	ch = make(chan csvChunk, 1)
+	stats = &readerStats{}
+	readXLSXChunk(bytes.NewReader(workbook), "Payroll", nil, nil, 10, ch, stats)
+	assert.ErrorContains(t, stats.Err(), `no sheet "Payroll"`)
+	_, open := <-ch
+	assert.False(t, open)
+}
+
+// TestRunImportXLSX tests that workbooks go through the same pipeline as CSV files
+func TestRunImportXLSX(t *testing.T) {
+	workbook := newTestWorkbook(t, [][]interface{}{
+		{"ID", "First Name", "Last Name", "Email", "Age", "Gender", "Department", "Company", "Salary", "Date Joined", "Is Active"},
+		{1, "Jane", "Doe", "jane@example.com", 30, "Female", "IT", "Acme", 50000, "2022-01-01", true},
+		{2, "Jim", "Doe", "jim@example.com", "old", "Male", "HR", "Acme", 40000, "2021-06-01", false},
+	})
+
+	sink := &memorySink{}
+	overflow, _ := newOverflowHandler("")
+	progress := &importProgress{}
+	metrics, err := runImport(context.Background(), bytes.NewReader(workbook), sink, importOptions{overflow: overflow, sheet: "Employees"}, progress)
+	assert.NoError(t, err)
+	assert.Equal(t, fileFormatXLSX, metrics["file_format"])
+	assert.Equal(t, []UserData{{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Age: 30, Gender: "Female",
+		Department: "IT", Company: "Acme", Salary: 50000, DateJoined: newDate(2022, 1, 1), IsActive: true}}, sink.users)
+	assert.Equal(t, int64(1), progress.skipped.Load())
+}